@@ -0,0 +1,137 @@
+package anyrl
+
+import (
+	"sync"
+	"time"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+)
+
+// Default settings for ActBatcher.
+const (
+	DefaultActBatchTimeout = 5 * time.Millisecond
+	DefaultActMaxBatch     = 32
+)
+
+// ActBatcher serves actions for a feed-forward policy,
+// combining concurrent requests into a single forward
+// pass.
+//
+// This improves throughput when many Goroutines (e.g. one
+// per client connection) each need one action at a time.
+type ActBatcher struct {
+	Policy anynet.Layer
+
+	// ActionSpace, if non-nil, is used to sample actions
+	// from the policy's outputs.
+	// If nil, the raw outputs are returned.
+	ActionSpace Sampler
+
+	// Creator is used to create input vectors.
+	// If nil, the creator of the policy's first parameter
+	// is used.
+	Creator anyvec.Creator
+
+	// Timeout is the longest a request will wait for
+	// other requests to join its batch.
+	//
+	// If 0, DefaultActBatchTimeout is used.
+	Timeout time.Duration
+
+	// MaxBatch is the largest number of requests handled
+	// in one forward pass.
+	//
+	// If 0, DefaultActMaxBatch is used.
+	MaxBatch int
+
+	once     sync.Once
+	requests chan *actRequest
+}
+
+type actRequest struct {
+	Obs    []float64
+	Result chan []float64
+}
+
+// Act computes the action for an observation.
+//
+// It is safe to call Act from multiple Goroutines.
+// It must not be called after Close.
+func (a *ActBatcher) Act(obs []float64) []float64 {
+	a.once.Do(func() {
+		a.requests = make(chan *actRequest)
+		go a.loop()
+	})
+	req := &actRequest{Obs: obs, Result: make(chan []float64, 1)}
+	a.requests <- req
+	return <-req.Result
+}
+
+// Close stops the background Goroutine which batches
+// requests.
+func (a *ActBatcher) Close() {
+	a.once.Do(func() {
+		a.requests = make(chan *actRequest)
+	})
+	close(a.requests)
+}
+
+func (a *ActBatcher) loop() {
+	for first := range a.requests {
+		batch := []*actRequest{first}
+		timeout := time.After(a.timeout())
+	GatherLoop:
+		for len(batch) < a.maxBatch() {
+			select {
+			case req, ok := <-a.requests:
+				if !ok {
+					break GatherLoop
+				}
+				batch = append(batch, req)
+			case <-timeout:
+				break GatherLoop
+			}
+		}
+		a.process(batch)
+	}
+}
+
+func (a *ActBatcher) process(batch []*actRequest) {
+	c := a.creator()
+	var joined []float64
+	for _, req := range batch {
+		joined = append(joined, req.Obs...)
+	}
+	out := a.Policy.Apply(anydiff.NewConst(anyvec.Make(c, joined)), len(batch)).Output()
+	if a.ActionSpace != nil {
+		out = a.ActionSpace.Sample(out, len(batch))
+	}
+	data := c.Float64Slice(out.Data())
+	size := len(data) / len(batch)
+	for i, req := range batch {
+		req.Result <- data[i*size : (i+1)*size]
+	}
+}
+
+func (a *ActBatcher) creator() anyvec.Creator {
+	if a.Creator != nil {
+		return a.Creator
+	}
+	return layerCreator(anynet.AllParameters(a.Policy))
+}
+
+func (a *ActBatcher) timeout() time.Duration {
+	if a.Timeout == 0 {
+		return DefaultActBatchTimeout
+	}
+	return a.Timeout
+}
+
+func (a *ActBatcher) maxBatch() int {
+	if a.MaxBatch == 0 {
+		return DefaultActMaxBatch
+	}
+	return a.MaxBatch
+}
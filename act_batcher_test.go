@@ -0,0 +1,38 @@
+package anyrl
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestActBatcher(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	policy := anynet.NewFC(c, 2, 3)
+	batcher := &ActBatcher{Policy: policy, MaxBatch: 4}
+	defer batcher.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			obs := []float64{float64(i), -float64(i)}
+			actual := batcher.Act(obs)
+			expected := c.Float64Slice(policy.Apply(
+				anydiff.NewConst(anyvec.Make(c, obs)), 1).Output().Data())
+			for j, x := range expected {
+				if math.Abs(actual[j]-x) > 1e-8 {
+					t.Errorf("request %d: expected %v but got %v", i, expected, actual)
+					break
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,65 @@
+package anyrl
+
+import (
+	"math"
+
+	"github.com/unixpickle/anyvec"
+)
+
+// An ActionFilter modifies sampled actions before they
+// are sent to an environment, e.g. to clamp torques or to
+// replace unsafe actions with safe ones.
+//
+// The filtered actions are the ones recorded in a
+// RolloutSet, so that the log-probabilities used during
+// training match the actions that were executed.
+// Thus, a filter should only produce actions which have
+// non-zero probability under the policy.
+type ActionFilter interface {
+	// FilterActions filters a batch of n actions.
+	// It may modify the vector in place.
+	FilterActions(actions anyvec.Vector, n int) anyvec.Vector
+}
+
+// FuncFilter is an ActionFilter which filters each action
+// independently with a function.
+type FuncFilter func(action []float64) []float64
+
+// FilterActions applies the function to each action.
+func (f FuncFilter) FilterActions(actions anyvec.Vector, n int) anyvec.Vector {
+	c := actions.Creator()
+	data := c.Float64Slice(actions.Data())
+	size := len(data) / n
+	var res []float64
+	for i := 0; i < n; i++ {
+		res = append(res, f(data[i*size:(i+1)*size])...)
+	}
+	return anyvec.Make(c, res)
+}
+
+// ClampFilter is an ActionFilter which clamps each
+// component of an action to a range.
+type ClampFilter struct {
+	// Low and High contain the bounds for each action
+	// component.
+	// A nil slice indicates that there is no bound.
+	Low  []float64
+	High []float64
+}
+
+// FilterActions clamps the actions.
+func (c *ClampFilter) FilterActions(actions anyvec.Vector, n int) anyvec.Vector {
+	return FuncFilter(func(action []float64) []float64 {
+		res := make([]float64, len(action))
+		for i, x := range action {
+			if c.Low != nil {
+				x = math.Max(x, c.Low[i])
+			}
+			if c.High != nil {
+				x = math.Min(x, c.High[i])
+			}
+			res[i] = x
+		}
+		return res
+	}).FilterActions(actions, n)
+}
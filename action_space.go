@@ -49,6 +49,21 @@ type KLer interface {
 	KL(params1, params2 anydiff.Res, batchSize int) anydiff.Res
 }
 
+// An AnalyticFisher can multiply vectors by the Fisher
+// information matrix of its distribution in closed form.
+//
+// Trainers such as anypg.NaturalPG use this to avoid
+// differentiating KL divergences twice.
+type AnalyticFisher interface {
+	// FisherProduct multiplies each vector in a batch by
+	// the Fisher information matrix (with respect to the
+	// parameters) of the corresponding distribution.
+	//
+	// Both params and vecs contain batchSize parameter
+	// vectors.
+	FisherProduct(params, vecs anyvec.Vector, batchSize int) anyvec.Vector
+}
+
 // An Entropyer can compute the entropy of a parametric
 // probability distribution.
 type Entropyer interface {
@@ -327,6 +342,29 @@ func (g Gaussian) Entropy(params anydiff.Res, batchSize int) anydiff.Res {
 	})
 }
 
+// FisherProduct multiplies vectors by the Fisher matrix.
+//
+// The Fisher matrix is diagonal, with an entry of
+// 1/variance for each mean and 1/2 for each log variance.
+func (g Gaussian) FisherProduct(params, vecs anyvec.Vector, batchSize int) anyvec.Vector {
+	c := params.Creator()
+	halfLen := params.Len() / 2
+
+	transParams := c.MakeVector(params.Len())
+	anyvec.Transpose(params, transParams, halfLen)
+	invVariance := transParams.Slice(halfLen, params.Len()).Copy()
+	invVariance.Scale(c.MakeNumeric(-1))
+	anyvec.Exp(invVariance)
+	halves := c.MakeVector(halfLen)
+	halves.AddScalar(c.MakeNumeric(0.5))
+
+	diag := c.MakeVector(params.Len())
+	anyvec.Transpose(c.Concat(invVariance, halves), diag, 2)
+	res := vecs.Copy()
+	res.Mul(diag)
+	return res
+}
+
 func (g Gaussian) splitParams(params anydiff.Res) (mean, logVariance anydiff.Res) {
 	halfLen := params.Output().Len() / 2
 	mat := &anydiff.Matrix{Data: params, Rows: halfLen, Cols: 2}
@@ -395,6 +433,37 @@ func (t *Tuple) LogProb(params anydiff.Res, output anyvec.Vector,
 	})
 }
 
+// ComponentLogProbs computes the log probability of each
+// component of the sampled output separately.
+//
+// The result contains len(t.Spaces) values per batch
+// element, packed like a tuple.
+//
+// This panics if a sub-space is not a LogProber.
+func (t *Tuple) ComponentLogProbs(params anydiff.Res, output anyvec.Vector,
+	batch int) anydiff.Res {
+	return anydiff.Pool(params, func(params anydiff.Res) anydiff.Res {
+		unpackedParams := unpackTuples(params, t.ParamSizes, batch)
+		unpackedSamples := unpackTuples(anydiff.NewConst(output), t.SampleSizes, batch)
+		var logProbs []anydiff.Res
+		for i, samples := range unpackedSamples {
+			logProber := t.Spaces[i].(LogProber)
+			logProbs = append(logProbs, logProber.LogProb(unpackedParams[i],
+				samples.Output(), batch))
+		}
+		var packed []anydiff.Res
+		for i := 0; i < batch; i++ {
+			for _, logProb := range logProbs {
+				packed = append(packed, anydiff.Slice(logProb, i, i+1))
+			}
+		}
+		if len(packed) == 0 {
+			return anydiff.NewConst(params.Output().Creator().MakeVector(0))
+		}
+		return anydiff.Concat(packed...)
+	})
+}
+
 // KL computes the KL divergences between two batches of
 // distributions.
 //
@@ -293,6 +293,40 @@ func TestGaussianKL(t *testing.T) {
 	}
 }
 
+func TestGaussianFisherProduct(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	params := c.MakeVectorData([]float64{
+		1, math.Log(0.5), 1, math.Log(1),
+		3, math.Log(0.7), -1, math.Log(1.5),
+	})
+	vecs := c.MakeVectorData([]float64{
+		0.5, -1, 2, 0.3,
+		-0.7, 1.5, 0.1, -0.2,
+	})
+	actual := Gaussian{}.FisherProduct(params, vecs, 2)
+
+	// The gradient of the KL divergence after a small step
+	// along vecs approximates the Fisher-vector product.
+	const epsilon = 1e-5
+	stepped := vecs.Copy()
+	stepped.Scale(epsilon)
+	stepped.Add(params)
+	steppedVar := anydiff.NewVar(stepped)
+	kl := Gaussian{}.KL(anydiff.NewConst(params), steppedVar, 2)
+	grad := anydiff.NewGrad(steppedVar)
+	upstream := c.MakeVector(2)
+	upstream.AddScalar(1.0)
+	kl.Propagate(upstream, grad)
+	expected := grad[steppedVar]
+	expected.Scale(1 / epsilon)
+
+	diff := actual.Copy()
+	diff.Sub(expected)
+	if anyvec.AbsMax(diff).(float64) > 1e-3 {
+		t.Errorf("expected %v but got %v", expected.Data(), actual.Data())
+	}
+}
+
 func TestGaussianEntropy(t *testing.T) {
 	c := anyvec64.DefaultCreator{}
 	params := c.MakeVectorData([]float64{
@@ -441,3 +475,22 @@ func assertSimilar(t *testing.T, actual, expected anyvec.Vector) {
 		t.Errorf("expected %v but got %v", expected.Data(), actual.Data())
 	}
 }
+
+func TestTupleComponentLogProbs(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	space := &Tuple{
+		Spaces:      []interface{}{Softmax{}, &Bernoulli{}},
+		ParamSizes:  []int{3, 1},
+		SampleSizes: []int{3, 1},
+	}
+	params := anydiff.NewConst(anyvec.Make(c, []float64{1, 2, 3, 0.5, -1, 0, 1, -0.5}))
+	samples := anyvec.Make(c, []float64{0, 1, 0, 1, 1, 0, 0, 0})
+
+	joint := space.LogProb(params, samples, 2).Output().Data().([]float64)
+	comps := space.ComponentLogProbs(params, samples, 2).Output().Data().([]float64)
+	for i, x := range joint {
+		if sum := comps[2*i] + comps[2*i+1]; math.Abs(sum-x) > 1e-8 {
+			t.Errorf("batch %d: components sum to %f but joint is %f", i, sum, x)
+		}
+	}
+}
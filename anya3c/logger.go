@@ -78,7 +78,7 @@ type AvgLogger struct {
 func (a *AvgLogger) LogEpisode(workerID int, reward float64) {
 	cr := anyvec64.DefaultCreator{}
 	if avg := a.episodeAvg.Add(a.Episode, cr, reward); avg != nil {
-		a.Logger.LogEpisode(workerID, avg.(float64))
+		a.Logger.LogEpisode(workerID, cr.Float64(avg))
 	}
 }
 
@@ -0,0 +1,164 @@
+package anydreamer
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// Default hyper-parameters for ActorCritic.
+const (
+	DefaultHorizon  = 15
+	DefaultDiscount = 0.99
+	DefaultLambda   = 0.95
+)
+
+// ActorCriticTerms stores statistics from a step of
+// imagined actor-critic training.
+type ActorCriticTerms struct {
+	MeanReturn     anyvec.Numeric
+	MeanCriticLoss anyvec.Numeric
+}
+
+// ActorCritic trains a continuous-action policy and a
+// value function on trajectories imagined by a Model.
+//
+// The actor maximizes lambda-returns by backpropagating
+// through the model's dynamics, and the critic regresses
+// towards the same lambda-returns.
+type ActorCritic struct {
+	Model *Model
+
+	// Actor maps features to action parameters for the
+	// anyrl.Gaussian action space.
+	Actor anynet.Layer
+
+	// Critic maps features to value estimates.
+	Critic anynet.Layer
+
+	// ActorParams and CriticParams specify which
+	// parameters to include in the respective gradients.
+	ActorParams  []*anydiff.Var
+	CriticParams []*anydiff.Var
+
+	// Horizon is the number of imagined timesteps.
+	//
+	// If 0, DefaultHorizon is used.
+	Horizon int
+
+	// Discount is the reward discount factor.
+	//
+	// If 0, DefaultDiscount is used.
+	Discount float64
+
+	// Lambda is the lambda-return coefficient.
+	//
+	// If 0, DefaultLambda is used.
+	Lambda float64
+}
+
+// Train imagines trajectories starting from a batch of n
+// features and computes gradients for the actor and the
+// critic.
+//
+// The gradients should be added to the parameters.
+func (a *ActorCritic) Train(starts anyvec.Vector, n int) (actorGrad,
+	criticGrad anydiff.Grad, terms *ActorCriticTerms) {
+	c := starts.Creator()
+	actorGrad = anydiff.NewGrad(a.ActorParams...)
+	criticGrad = anydiff.NewGrad(a.CriticParams...)
+
+	var feats, returns []anyvec.Vector
+	res := a.imagine(anydiff.NewConst(starts), n, 0, &feats, &returns)
+	returnSum := anydiff.Slice(res, n, 2*n)
+	scale := 1 / float64(n*a.horizon())
+	upstream := c.MakeVector(n)
+	upstream.AddScalar(c.MakeNumeric(scale))
+	returnSum.Propagate(upstream, actorGrad)
+
+	features := anydiff.NewConst(c.Concat(feats...))
+	targets := anydiff.NewConst(c.Concat(returns...))
+	numSamples := n * a.horizon()
+	criticLoss := anydiff.Scale(
+		anydiff.Sum(anyrl.SquareLoss{}.Loss(a.Critic.Apply(features, numSamples), targets)),
+		c.MakeNumeric(1/float64(numSamples)),
+	)
+	criticLoss.Propagate(anyvec.Make(c, []float64{-1}), criticGrad)
+
+	var total float64
+	for _, x := range c.Float64Slice(returnSum.Output().Data()) {
+		total += x
+	}
+	return actorGrad, criticGrad, &ActorCriticTerms{
+		MeanReturn:     c.MakeNumeric(total * scale),
+		MeanCriticLoss: anyvec.Sum(criticLoss.Output()),
+	}
+}
+
+// imagine produces a vector containing the lambda-returns
+// at step k followed by the sum of the lambda-returns from
+// step k onward.
+//
+// The features and lambda-returns at every step are
+// recorded for training the critic.
+func (a *ActorCritic) imagine(feat anydiff.Res, n, k int, feats,
+	returns *[]anyvec.Vector) anydiff.Res {
+	c := feat.Output().Creator()
+	return anydiff.Pool(feat, func(feat anydiff.Res) anydiff.Res {
+		actions := sampleGaussian(a.Actor.Apply(feat, n))
+		next := a.Model.imagineStep(feat, actions, n)
+		return anydiff.Pool(next, func(next anydiff.Res) anydiff.Res {
+			rewards := a.Model.Reward.Apply(next, n)
+			values := a.Critic.Apply(next, n)
+			discount := c.MakeNumeric(a.discount())
+
+			finish := func(lambdaReturn, rest anydiff.Res) anydiff.Res {
+				return anydiff.Pool(lambdaReturn, func(lambdaReturn anydiff.Res) anydiff.Res {
+					*feats = append(*feats, feat.Output().Copy())
+					*returns = append(*returns, lambdaReturn.Output().Copy())
+					sum := lambdaReturn
+					if rest != nil {
+						sum = anydiff.Add(sum, rest)
+					}
+					return anydiff.Concat(lambdaReturn, sum)
+				})
+			}
+
+			if k+1 == a.horizon() {
+				return finish(anydiff.Add(rewards, anydiff.Scale(values, discount)), nil)
+			}
+			future := a.imagine(next, n, k+1, feats, returns)
+			return anydiff.Pool(future, func(future anydiff.Res) anydiff.Res {
+				lambda := a.lambda()
+				bootstrap := anydiff.Add(
+					anydiff.Scale(values, c.MakeNumeric(1-lambda)),
+					anydiff.Scale(anydiff.Slice(future, 0, n), c.MakeNumeric(lambda)),
+				)
+				lambdaReturn := anydiff.Add(rewards, anydiff.Scale(bootstrap, discount))
+				return finish(lambdaReturn, anydiff.Slice(future, n, 2*n))
+			})
+		})
+	})
+}
+
+func (a *ActorCritic) horizon() int {
+	if a.Horizon == 0 {
+		return DefaultHorizon
+	}
+	return a.Horizon
+}
+
+func (a *ActorCritic) discount() float64 {
+	if a.Discount == 0 {
+		return DefaultDiscount
+	}
+	return a.Discount
+}
+
+func (a *ActorCritic) lambda() float64 {
+	if a.Lambda == 0 {
+		return DefaultLambda
+	}
+	return a.Lambda
+}
@@ -0,0 +1,130 @@
+package anydreamer
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// Agent is an anyrnn.Block which acts in an environment
+// by tracking the model's posterior features and feeding
+// them to an actor.
+//
+// The block samples actions itself, since the sampled
+// actions are needed to update the model state.
+// Thus, its outputs are actions, and it should be used
+// with an action space that returns its inputs (such as
+// anympc.Deterministic).
+//
+// Agent has no parameters and is not differentiable.
+// When using it with anyrl.RNNRoller, set the roller's
+// Creator.
+type Agent struct {
+	Model *Model
+	Actor anynet.Layer
+}
+
+// Start generates an initial state with zero h vectors.
+func (a *Agent) Start(n int) anyrnn.State {
+	present := make(anyrnn.PresentMap, n)
+	for i := range present {
+		present[i] = true
+	}
+	states := make([][]float64, n)
+	for i := range states {
+		states[i] = make([]float64, a.Model.StateSize)
+	}
+	return &agentState{PresentMap: present, States: states}
+}
+
+// PropagateStart does nothing, since Agent has no
+// parameters.
+func (a *Agent) PropagateStart(s anyrnn.StateGrad, g anydiff.Grad) {
+}
+
+// Step updates the model state with the observations and
+// samples actions.
+func (a *Agent) Step(s anyrnn.State, in anyvec.Vector) anyrnn.Res {
+	state := s.(*agentState)
+	c := in.Creator()
+	n := len(state.States)
+
+	var joined []float64
+	for _, h := range state.States {
+		joined = append(joined, h...)
+	}
+	h := anydiff.NewConst(anyvec.Make(c, joined))
+	embed := a.Model.Encoder.Apply(anydiff.NewConst(in), n)
+	z := sampleGaussian(a.Model.Posterior.Apply(mix(h, embed, n), n))
+	feat := mix(h, z, n)
+	actionParams := a.Actor.Apply(feat, n).Output()
+	actions := anyrl.Gaussian{}.Sample(actionParams, n)
+	next := c.Float64Slice(a.Model.Transition.Apply(
+		mix(feat, anydiff.NewConst(actions), n), n).Output().Data())
+
+	newStates := make([][]float64, n)
+	for i := range newStates {
+		newStates[i] = next[i*a.Model.StateSize : (i+1)*a.Model.StateSize]
+	}
+	return &agentRes{
+		OutState: &agentState{PresentMap: state.PresentMap, States: newStates},
+		OutVec:   actions,
+		InLen:    in.Len(),
+	}
+}
+
+type agentState struct {
+	PresentMap anyrnn.PresentMap
+	States     [][]float64
+}
+
+func (a *agentState) Present() anyrnn.PresentMap {
+	return a.PresentMap
+}
+
+func (a *agentState) Reduce(present anyrnn.PresentMap) anyrnn.State {
+	var states [][]float64
+	var idx int
+	for i, pres := range a.PresentMap {
+		if !pres {
+			continue
+		}
+		if present[i] {
+			states = append(states, a.States[idx])
+		}
+		idx++
+	}
+	return &agentState{PresentMap: present, States: states}
+}
+
+func (a *agentState) Expand(present anyrnn.PresentMap) anyrnn.StateGrad {
+	return &agentState{
+		PresentMap: present,
+		States:     make([][]float64, present.NumPresent()),
+	}
+}
+
+type agentRes struct {
+	OutState *agentState
+	OutVec   anyvec.Vector
+	InLen    int
+}
+
+func (a *agentRes) State() anyrnn.State {
+	return a.OutState
+}
+
+func (a *agentRes) Output() anyvec.Vector {
+	return a.OutVec
+}
+
+func (a *agentRes) Vars() anydiff.VarSet {
+	return anydiff.VarSet{}
+}
+
+func (a *agentRes) Propagate(u anyvec.Vector, s anyrnn.StateGrad,
+	g anydiff.Grad) (anyvec.Vector, anyrnn.StateGrad) {
+	return u.Creator().MakeVector(a.InLen), a.OutState.Expand(a.OutState.PresentMap)
+}
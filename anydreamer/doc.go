@@ -0,0 +1,7 @@
+// Package anydreamer implements a lightweight version of
+// Dreamer, a model-based algorithm which learns a latent
+// dynamics model from rollouts and trains an actor-critic
+// agent on trajectories imagined by the model.
+//
+// See https://arxiv.org/abs/1912.01603.
+package anydreamer
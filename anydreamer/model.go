@@ -0,0 +1,198 @@
+package anydreamer
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// ModelTerms stores the mean per-timestep values of the
+// terms in the world model's loss.
+type ModelTerms struct {
+	MeanRecon  anyvec.Numeric
+	MeanReward anyvec.Numeric
+	MeanKL     anyvec.Numeric
+}
+
+// Model is a recurrent state-space model (RSSM).
+//
+// The model state at each timestep consists of a
+// deterministic part h and a stochastic part z.
+// Together, these are called the features, which are
+// stored as h followed by z.
+//
+// Stochastic latents are parameterized like the
+// anyrl.Gaussian action space, with an interleaved mean
+// and log-variance for each component.
+type Model struct {
+	// Encoder maps observations to embeddings.
+	Encoder anynet.Layer
+
+	// Posterior maps h followed by an observation
+	// embedding to a distribution over z.
+	Posterior anynet.Layer
+
+	// Prior maps h to a distribution over z, without
+	// seeing the observation.
+	Prior anynet.Layer
+
+	// Transition maps the features followed by an action
+	// to the next h.
+	Transition anynet.Layer
+
+	// Decoder maps features to reconstructed
+	// observations.
+	Decoder anynet.Layer
+
+	// Reward maps the features after a transition to the
+	// predicted reward for that transition.
+	Reward anynet.Layer
+
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// StateSize is the size of h.
+	StateSize int
+
+	// KLWeight is the weight of the KL divergence between
+	// the posterior and the prior.
+	//
+	// If 0, a default of 1 is used.
+	KLWeight float64
+}
+
+// Train computes a gradient for a batch of sequences,
+// which must all have the same length.
+//
+// The gradient is for the negative loss, so it should be
+// added to the parameters.
+//
+// Train also returns the posterior features for every
+// timestep of every sequence, which can be used as the
+// starting points for imagination.
+//
+// If m.Params is empty, then an empty gradient and nil
+// results are returned.
+func (m *Model) Train(seqs []*Sequence) (anydiff.Grad, *ModelTerms, anyvec.Vector) {
+	grad := anydiff.NewGrad(m.Params...)
+	if len(grad) == 0 || len(seqs) == 0 {
+		return grad, nil, nil
+	}
+	c := m.Params[0].Vector.Creator()
+	n := len(seqs)
+	steps := len(seqs[0].Rewards)
+
+	var feats []anyvec.Vector
+	h := anydiff.NewConst(c.MakeVector(n * m.StateSize))
+	losses := m.unroll(seqs, 0, h, &feats)
+
+	scale := 1 / float64(n*steps)
+	klWeight := m.KLWeight
+	if klWeight == 0 {
+		klWeight = 1
+	}
+	losses.Propagate(anyvec.Make(c, []float64{-scale, -scale, -scale * klWeight}), grad)
+
+	totals := c.Float64Slice(losses.Output().Data())
+	return grad, &ModelTerms{
+		MeanRecon:  c.MakeNumeric(totals[0] * scale),
+		MeanReward: c.MakeNumeric(totals[1] * scale),
+		MeanKL:     c.MakeNumeric(totals[2] * scale),
+	}, c.Concat(feats...)
+}
+
+// unroll computes the summed reconstruction, reward, and
+// KL losses from timestep t onward.
+//
+// The reward for timestep t is predicted from the
+// features at timestep t+1, matching imagination.
+// Since the observation after the final timestep is not
+// recorded, the final features come from the prior.
+func (m *Model) unroll(seqs []*Sequence, t int, h anydiff.Res,
+	feats *[]anyvec.Vector) anydiff.Res {
+	c := h.Output().Creator()
+	n := len(seqs)
+	if t == len(seqs[0].Rewards) {
+		if t == 0 {
+			return anydiff.NewConst(c.MakeVector(3))
+		}
+		feat := mix(h, sampleGaussian(m.Prior.Apply(h, n)), n)
+		return anydiff.Concat(
+			anydiff.NewConst(c.MakeVector(1)),
+			m.rewardLoss(seqs, t-1, feat),
+			anydiff.NewConst(c.MakeVector(1)),
+		)
+	}
+	obs := anydiff.NewConst(joinStep(c, seqs, t, func(s *Sequence, t int) []float64 {
+		return s.Obs[t]
+	}))
+	actions := anydiff.NewConst(joinStep(c, seqs, t, func(s *Sequence, t int) []float64 {
+		return s.Actions[t]
+	}))
+
+	return anydiff.Pool(h, func(h anydiff.Res) anydiff.Res {
+		embed := m.Encoder.Apply(obs, n)
+		post := m.Posterior.Apply(mix(h, embed, n), n)
+		return anydiff.Pool(post, func(post anydiff.Res) anydiff.Res {
+			kl := anyrl.Gaussian{}.KL(post, m.Prior.Apply(h, n), n)
+			feat := mix(h, sampleGaussian(post), n)
+			return anydiff.Pool(feat, func(feat anydiff.Res) anydiff.Res {
+				*feats = append(*feats, feat.Output().Copy())
+				rewardLoss := anydiff.Res(anydiff.NewConst(c.MakeVector(1)))
+				if t > 0 {
+					rewardLoss = m.rewardLoss(seqs, t-1, feat)
+				}
+				losses := anydiff.Concat(
+					anydiff.Sum(anyrl.SquareLoss{}.Loss(m.Decoder.Apply(feat, n), obs)),
+					rewardLoss,
+					anydiff.Sum(kl),
+				)
+				next := m.Transition.Apply(mix(feat, actions, n), n)
+				return anydiff.Add(losses, m.unroll(seqs, t+1, next, feats))
+			})
+		})
+	})
+}
+
+// rewardLoss computes the summed loss for predicting the
+// rewards at timestep t from the features after it.
+func (m *Model) rewardLoss(seqs []*Sequence, t int, feat anydiff.Res) anydiff.Res {
+	c := feat.Output().Creator()
+	rewards := anydiff.NewConst(joinStep(c, seqs, t, func(s *Sequence, t int) []float64 {
+		return []float64{s.Rewards[t]}
+	}))
+	return anydiff.Sum(anyrl.SquareLoss{}.Loss(m.Reward.Apply(feat, len(seqs)), rewards))
+}
+
+// imagineStep predicts the next features after taking
+// actions from the given features.
+func (m *Model) imagineStep(feat, actions anydiff.Res, n int) anydiff.Res {
+	h := m.Transition.Apply(mix(feat, actions, n), n)
+	return anydiff.Pool(h, func(h anydiff.Res) anydiff.Res {
+		return mix(h, sampleGaussian(m.Prior.Apply(h, n)), n)
+	})
+}
+
+// mix concatenates each pair of vectors in two batches.
+func mix(in1, in2 anydiff.Res, n int) anydiff.Res {
+	return anynet.ConcatMixer{}.Mix(in1, in2, n)
+}
+
+// sampleGaussian samples from Gaussian distributions
+// using the reparameterization trick, so that gradients
+// flow through the samples to the parameters.
+func sampleGaussian(params anydiff.Res) anydiff.Res {
+	c := params.Output().Creator()
+	half := params.Output().Len() / 2
+	split := anydiff.Transpose(&anydiff.Matrix{Data: params, Rows: half, Cols: 2}).Data
+	return anydiff.Pool(split, func(split anydiff.Res) anydiff.Res {
+		mean := anydiff.Slice(split, 0, half)
+		logVar := anydiff.Slice(split, half, half*2)
+		noise := c.MakeVector(half)
+		anyvec.Rand(noise, anyvec.Normal, nil)
+		stddev := anydiff.Exp(anydiff.Scale(logVar, c.MakeNumeric(0.5)))
+		return anydiff.Add(mean, anydiff.Mul(stddev, anydiff.NewConst(noise)))
+	})
+}
@@ -0,0 +1,140 @@
+package anydreamer
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestDreamer(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	const obsSize, embedSize, stateSize, latentSize, actionSize = 2, 3, 4, 2, 1
+	featSize := stateSize + latentSize
+	model := &Model{
+		Encoder:    anynet.Net{anynet.NewFC(c, obsSize, embedSize), anynet.Tanh},
+		Posterior:  anynet.NewFC(c, stateSize+embedSize, latentSize*2),
+		Prior:      anynet.NewFC(c, stateSize, latentSize*2),
+		Transition: anynet.Net{anynet.NewFC(c, featSize+actionSize, stateSize), anynet.Tanh},
+		Decoder:    anynet.NewFC(c, featSize, obsSize),
+		Reward:     anynet.NewFC(c, featSize, 1),
+		StateSize:  stateSize,
+	}
+	model.Params = anynet.AllParameters(model.Encoder, model.Posterior, model.Prior,
+		model.Transition, model.Decoder, model.Reward)
+
+	var seqs []*Sequence
+	for i := 0; i < 4; i++ {
+		seq := &Sequence{}
+		for t := 0; t < 5; t++ {
+			seq.Obs = append(seq.Obs, []float64{float64(t) / 5, float64(i) / 4})
+			seq.Actions = append(seq.Actions, []float64{float64(t % 2)})
+			seq.Rewards = append(seq.Rewards, float64(t%2))
+		}
+		seqs = append(seqs, seq)
+	}
+
+	_, initTerms, feats := model.Train(seqs)
+	if feats.Len() != 4*5*featSize {
+		t.Fatalf("unexpected features length: %d", feats.Len())
+	}
+	for i := 0; i < 300; i++ {
+		grad, _, _ := model.Train(seqs)
+		grad.Scale(c.MakeNumeric(0.01))
+		grad.AddToVars()
+	}
+	_, finalTerms, feats := model.Train(seqs)
+	if finalTerms.MeanRecon.(float64) >= initTerms.MeanRecon.(float64) {
+		t.Errorf("reconstruction loss went from %f to %f", initTerms.MeanRecon,
+			finalTerms.MeanRecon)
+	}
+
+	ac := &ActorCritic{
+		Model:   model,
+		Actor:   anynet.NewFC(c, featSize, actionSize*2),
+		Critic:  anynet.NewFC(c, featSize, 1),
+		Horizon: 3,
+	}
+	ac.ActorParams = anynet.AllParameters(ac.Actor)
+	ac.CriticParams = anynet.AllParameters(ac.Critic)
+	actorGrad, criticGrad, terms := ac.Train(feats, 20)
+	for _, v := range ac.ActorParams {
+		if !finite(c, actorGrad[v]) {
+			t.Error("non-finite actor gradient")
+		}
+	}
+	for _, v := range ac.CriticParams {
+		if !finite(c, criticGrad[v]) {
+			t.Error("non-finite critic gradient")
+		}
+	}
+	if math.IsNaN(terms.MeanReturn.(float64)) {
+		t.Error("invalid mean return")
+	}
+
+	agent := &Agent{Model: model, Actor: ac.Actor}
+	state := agent.Start(2)
+	res := agent.Step(state, anyvec.Make(c, []float64{0, 0, 1, 1}))
+	if res.Output().Len() != 2*actionSize {
+		t.Errorf("unexpected action length: %d", res.Output().Len())
+	}
+	reduced := res.State().Reduce([]bool{false, true})
+	res = agent.Step(reduced, anyvec.Make(c, []float64{1, 1}))
+	if res.Output().Len() != actionSize {
+		t.Errorf("unexpected action length: %d", res.Output().Len())
+	}
+}
+
+func TestModelRewardTiming(t *testing.T) {
+	// The reward is the action at each timestep, so it can
+	// only be predicted from the features after the
+	// action is taken.
+	c := anyvec64.DefaultCreator{}
+	const obsSize, embedSize, stateSize, latentSize, actionSize = 1, 2, 4, 1, 1
+	featSize := stateSize + latentSize
+	model := &Model{
+		Encoder:    anynet.NewFC(c, obsSize, embedSize),
+		Posterior:  anynet.NewFC(c, stateSize+embedSize, latentSize*2),
+		Prior:      anynet.NewFC(c, stateSize, latentSize*2),
+		Transition: anynet.NewFC(c, featSize+actionSize, stateSize),
+		Decoder:    anynet.NewFC(c, featSize, obsSize),
+		Reward:     anynet.NewFC(c, featSize, 1),
+		StateSize:  stateSize,
+	}
+	model.Params = anynet.AllParameters(model.Encoder, model.Posterior, model.Prior,
+		model.Transition, model.Decoder, model.Reward)
+
+	var seqs []*Sequence
+	for i := 0; i < 8; i++ {
+		seq := &Sequence{}
+		for t := 0; t < 4; t++ {
+			action := float64(rand.Intn(2))
+			seq.Obs = append(seq.Obs, []float64{0})
+			seq.Actions = append(seq.Actions, []float64{action})
+			seq.Rewards = append(seq.Rewards, action)
+		}
+		seqs = append(seqs, seq)
+	}
+
+	for i := 0; i < 1000; i++ {
+		grad, _, _ := model.Train(seqs)
+		grad.Scale(c.MakeNumeric(0.01))
+		grad.AddToVars()
+	}
+	_, terms, _ := model.Train(seqs)
+	if loss := terms.MeanReward.(float64); loss > 0.05 {
+		t.Errorf("reward loss should be near zero but got %f", loss)
+	}
+}
+
+func finite(c anyvec.Creator, v anyvec.Vector) bool {
+	for _, x := range c.Float64Slice(v.Data()) {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package anydreamer
+
+import (
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// A Sequence is a fixed-length chunk of an episode.
+type Sequence struct {
+	Obs     [][]float64
+	Actions [][]float64
+	Rewards []float64
+}
+
+// Sequences splits the episodes in a RolloutSet into
+// non-overlapping sequences of the given length.
+//
+// Timesteps at the end of an episode which do not fill an
+// entire sequence are dropped.
+func Sequences(r *anyrl.RolloutSet, length int) []*Sequence {
+	inputs := episodeSteps(r.Inputs, len(r.Rewards))
+	actions := episodeSteps(r.Actions, len(r.Rewards))
+	var res []*Sequence
+	for i, rewards := range r.Rewards {
+		for start := 0; start+length <= len(rewards); start += length {
+			res = append(res, &Sequence{
+				Obs:     inputs[i][start : start+length],
+				Actions: actions[i][start : start+length],
+				Rewards: rewards[start : start+length],
+			})
+		}
+	}
+	return res
+}
+
+// episodeSteps splits a tape up into the vectors for each
+// timestep of each episode.
+func episodeSteps(t lazyseq.Tape, numEpisodes int) [][][]float64 {
+	c := t.Creator()
+	res := make([][][]float64, numEpisodes)
+	for batch := range t.ReadTape(0, -1) {
+		n := batch.NumPresent()
+		if n == 0 {
+			continue
+		}
+		data := c.Float64Slice(batch.Packed.Data())
+		size := len(data) / n
+		var offset int
+		for i, pres := range batch.Present {
+			if pres {
+				res[i] = append(res[i], data[offset:offset+size])
+				offset += size
+			}
+		}
+	}
+	return res
+}
+
+// joinStep packs one timestep of every sequence into a
+// vector.
+func joinStep(c anyvec.Creator, seqs []*Sequence, t int,
+	get func(s *Sequence, t int) []float64) anyvec.Vector {
+	var res []float64
+	for _, s := range seqs {
+		res = append(res, get(s, t)...)
+	}
+	return anyvec.Make(c, res)
+}
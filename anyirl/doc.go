@@ -0,0 +1,8 @@
+// Package anyirl implements Maximum Entropy Inverse
+// Reinforcement Learning, which recovers a reward
+// function from expert demonstrations.
+//
+// For more on MaxEnt IRL, see
+// https://www.aaai.org/Papers/AAAI/2008/AAAI08-227.pdf and
+// https://arxiv.org/abs/1603.00448.
+package anyirl
@@ -0,0 +1,121 @@
+package anyirl
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyrl"
+)
+
+// LinearIRL learns a reward function which is linear in
+// a set of hand-crafted features.
+//
+// Training matches the discounted feature expectations
+// of a policy to those of an expert.
+type LinearIRL struct {
+	// Features computes the feature vector for a single
+	// timestep.
+	Features func(obs, action []float64) []float64
+
+	// Weights are the current reward weights.
+	// There is one weight per feature.
+	//
+	// If nil, Update initializes the weights to zero.
+	Weights []float64
+
+	// Discount is the discount factor used for feature
+	// expectations.
+	//
+	// If 0, no discount is used.
+	Discount float64
+}
+
+// FeatureExpectations computes the mean discounted sum of
+// features across the episodes in the rollouts.
+func (l *LinearIRL) FeatureExpectations(r *anyrl.RolloutSet) []float64 {
+	var res []float64
+	c := r.Creator()
+	disc := discount(l.Discount)
+	forEachStep(r, func(t int, inputs, actions *anyseq.Batch) {
+		scale := math.Pow(disc, float64(t)) / float64(len(r.Rewards))
+		n := inputs.NumPresent()
+		obs := c.Float64Slice(inputs.Packed.Data())
+		acts := c.Float64Slice(actions.Packed.Data())
+		obsSize, actSize := len(obs)/n, len(acts)/n
+		for i := 0; i < n; i++ {
+			feats := l.Features(obs[i*obsSize:(i+1)*obsSize],
+				acts[i*actSize:(i+1)*actSize])
+			if res == nil {
+				res = make([]float64, len(feats))
+			}
+			for j, x := range feats {
+				res[j] += x * scale
+			}
+		}
+	})
+	return res
+}
+
+// Gradient computes the gradient of the expert's
+// log-likelihood with respect to the reward weights.
+//
+// Under the MaxEnt model, this is the difference between
+// the expert's and the policy's feature expectations,
+// where the policy should be (approximately) optimal for
+// the current reward weights.
+func (l *LinearIRL) Gradient(expert, policy *anyrl.RolloutSet) []float64 {
+	expertFeats := l.FeatureExpectations(expert)
+	policyFeats := l.FeatureExpectations(policy)
+	res := make([]float64, len(expertFeats))
+	for i, x := range expertFeats {
+		res[i] = x - policyFeats[i]
+	}
+	return res
+}
+
+// Update takes a gradient step on the reward weights.
+//
+// It returns the gradient that was used.
+func (l *LinearIRL) Update(expert, policy *anyrl.RolloutSet, stepSize float64) []float64 {
+	grad := l.Gradient(expert, policy)
+	if l.Weights == nil {
+		l.Weights = make([]float64, len(grad))
+	}
+	for i, x := range grad {
+		l.Weights[i] += x * stepSize
+	}
+	return grad
+}
+
+// Rewards computes the learned rewards for every
+// timestep in the rollouts.
+func (l *LinearIRL) Rewards(r *anyrl.RolloutSet) anyrl.Rewards {
+	res := make(anyrl.Rewards, len(r.Rewards))
+	c := r.Creator()
+	forEachStep(r, func(t int, inputs, actions *anyseq.Batch) {
+		n := inputs.NumPresent()
+		obs := c.Float64Slice(inputs.Packed.Data())
+		acts := c.Float64Slice(actions.Packed.Data())
+		obsSize, actSize := len(obs)/n, len(acts)/n
+		for i, seqIdx := range presentIndices(inputs) {
+			feats := l.Features(obs[i*obsSize:(i+1)*obsSize],
+				acts[i*actSize:(i+1)*actSize])
+			var rew float64
+			for j, x := range feats {
+				if j < len(l.Weights) {
+					rew += x * l.Weights[j]
+				}
+			}
+			res[seqIdx] = append(res[seqIdx], rew)
+		}
+	})
+	return res
+}
+
+// Substitute produces a copy of the rollouts in which the
+// rewards have been replaced by the learned rewards.
+func (l *LinearIRL) Substitute(r *anyrl.RolloutSet) *anyrl.RolloutSet {
+	res := *r
+	res.Rewards = l.Rewards(r)
+	return &res
+}
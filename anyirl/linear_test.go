@@ -0,0 +1,50 @@
+package anyirl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestLinearIRLGradient(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	makeRollouts := func(obs anyrl.Rewards) *anyrl.RolloutSet {
+		return &anyrl.RolloutSet{
+			Inputs:  obs.Tape(c),
+			Actions: obs.Tape(c),
+			Rewards: obs,
+		}
+	}
+	irl := &LinearIRL{
+		Features: func(obs, action []float64) []float64 {
+			return []float64{obs[0], 1}
+		},
+		Discount: 0.5,
+	}
+
+	expert := makeRollouts(anyrl.Rewards{{1, 2, 4}, {2}})
+	policy := makeRollouts(anyrl.Rewards{{0, 0}})
+	grad := irl.Update(expert, policy, 0.1)
+
+	// Expert features: (3+2)/2 and (1.75+1)/2.
+	// Policy features: 0 and 1.5.
+	expected := []float64{2.5, 1.375 - 1.5}
+	for i, x := range expected {
+		if math.Abs(grad[i]-x) > 1e-8 {
+			t.Errorf("feature %d: expected %f but got %f", i, x, grad[i])
+		}
+		if math.Abs(irl.Weights[i]-x*0.1) > 1e-8 {
+			t.Errorf("weight %d: expected %f but got %f", i, x*0.1, irl.Weights[i])
+		}
+	}
+
+	rewards := irl.Rewards(expert)
+	if len(rewards) != 2 || len(rewards[0]) != 3 || len(rewards[1]) != 1 {
+		t.Fatalf("unexpected reward shape: %v", rewards)
+	}
+	if math.Abs(rewards[1][0]-(2*irl.Weights[0]+irl.Weights[1])) > 1e-8 {
+		t.Errorf("unexpected reward: %f", rewards[1][0])
+	}
+}
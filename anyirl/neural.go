@@ -0,0 +1,167 @@
+package anyirl
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// NeuralIRL learns a reward function represented by a
+// neural network.
+//
+// The partition function of the MaxEnt model is estimated
+// using rollouts from a policy.
+// If the policy's action log-probabilities are available,
+// the rollouts are importance weighted as in Guided Cost
+// Learning; otherwise, they are weighted uniformly and the
+// policy should be (approximately) optimal for the current
+// reward function.
+type NeuralIRL struct {
+	// Model maps a batch of timesteps to a batch of
+	// scalar rewards.
+	//
+	// If UseActions is false, each timestep is an
+	// observation vector.
+	// Otherwise, each timestep is an observation vector
+	// followed by an action vector.
+	Model anynet.Layer
+
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// UseActions indicates whether actions are fed to
+	// the model alongside observations.
+	UseActions bool
+
+	// Discount is the reward discount factor.
+	//
+	// If 0, no discount is used.
+	Discount float64
+
+	// ActionSpace, if non-nil, is used to compute the
+	// log-likelihood of the policy rollouts from their
+	// AgentOuts for importance sampling.
+	ActionSpace anyrl.LogProber
+}
+
+// Train computes a gradient for the log-likelihood of the
+// expert rollouts.
+// The gradient should be added to the parameters.
+//
+// If n.Params is empty, an empty gradient is returned.
+func (n *NeuralIRL) Train(expert, policy *anyrl.RolloutSet) anydiff.Grad {
+	grad := anydiff.NewGrad(n.Params...)
+	if len(grad) == 0 {
+		return grad
+	}
+
+	expertCoeffs := make([]float64, len(expert.Rewards))
+	for i := range expertCoeffs {
+		expertCoeffs[i] = 1 / float64(len(expertCoeffs))
+	}
+	n.accumulate(grad, expert, expertCoeffs)
+
+	policyCoeffs := n.sampleWeights(policy)
+	for i, x := range policyCoeffs {
+		policyCoeffs[i] = -x
+	}
+	n.accumulate(grad, policy, policyCoeffs)
+
+	return grad
+}
+
+// Rewards computes the learned rewards for every
+// timestep in the rollouts.
+func (n *NeuralIRL) Rewards(r *anyrl.RolloutSet) anyrl.Rewards {
+	res := make(anyrl.Rewards, len(r.Rewards))
+	forEachStep(r, func(t int, inputs, actions *anyseq.Batch) {
+		out := n.apply(inputs, actions).Output()
+		values := out.Creator().Float64Slice(out.Data())
+		for i, seqIdx := range presentIndices(inputs) {
+			res[seqIdx] = append(res[seqIdx], values[i])
+		}
+	})
+	return res
+}
+
+// Substitute produces a copy of the rollouts in which the
+// rewards have been replaced by the learned rewards.
+func (n *NeuralIRL) Substitute(r *anyrl.RolloutSet) *anyrl.RolloutSet {
+	res := *r
+	res.Rewards = n.Rewards(r)
+	return &res
+}
+
+// sampleWeights computes the normalized weight of each
+// policy rollout in the partition function estimate.
+func (n *NeuralIRL) sampleWeights(r *anyrl.RolloutSet) []float64 {
+	if n.ActionSpace == nil {
+		res := make([]float64, len(r.Rewards))
+		for i := range res {
+			res[i] = 1 / float64(len(res))
+		}
+		return res
+	}
+
+	disc := discount(n.Discount)
+	logits := make([]float64, len(r.Rewards))
+	for i, seq := range n.Rewards(r) {
+		for t, rew := range seq {
+			logits[i] += rew * math.Pow(disc, float64(t))
+		}
+	}
+
+	c := r.Creator()
+	outs := r.AgentOuts.ReadTape(0, -1)
+	forEachStep(r, func(t int, inputs, actions *anyseq.Batch) {
+		outBatch := <-outs
+		logProbs := n.ActionSpace.LogProb(anydiff.NewConst(outBatch.Packed),
+			actions.Packed, actions.NumPresent()).Output()
+		values := c.Float64Slice(logProbs.Data())
+		for i, seqIdx := range presentIndices(inputs) {
+			logits[seqIdx] -= values[i]
+		}
+	})
+	for _ = range outs {
+	}
+
+	return softmax(logits)
+}
+
+// accumulate adds the gradient of the weighted discounted
+// reward sums to grad.
+func (n *NeuralIRL) accumulate(grad anydiff.Grad, r *anyrl.RolloutSet,
+	coeffs []float64) {
+	disc := discount(n.Discount)
+	forEachStep(r, func(t int, inputs, actions *anyseq.Batch) {
+		scale := math.Pow(disc, float64(t))
+		var upstream []float64
+		for _, seqIdx := range presentIndices(inputs) {
+			upstream = append(upstream, coeffs[seqIdx]*scale)
+		}
+		out := n.apply(inputs, actions)
+		out.Propagate(anyvec.Make(out.Output().Creator(), upstream), grad)
+	})
+}
+
+func (n *NeuralIRL) apply(inputs, actions *anyseq.Batch) anydiff.Res {
+	c := inputs.Packed.Creator()
+	num := inputs.NumPresent()
+	in := inputs.Packed
+	if n.UseActions {
+		obsSize := in.Len() / num
+		actSize := actions.Packed.Len() / num
+		var parts []anyvec.Vector
+		for i := 0; i < num; i++ {
+			parts = append(parts, in.Slice(i*obsSize, (i+1)*obsSize),
+				actions.Packed.Slice(i*actSize, (i+1)*actSize))
+		}
+		in = c.Concat(parts...)
+	}
+	return n.Model.Apply(anydiff.NewConst(in), num)
+}
@@ -0,0 +1,57 @@
+package anyirl
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestNeuralIRLSeparation(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	makeRollouts := func(obs anyrl.Rewards) *anyrl.RolloutSet {
+		return &anyrl.RolloutSet{
+			Inputs:  obs.Tape(c),
+			Actions: obs.Tape(c),
+			Rewards: obs,
+		}
+	}
+
+	// The expert visits positive states while the policy
+	// visits negative ones.
+	expert := makeRollouts(anyrl.Rewards{{1, 2}, {1.5}})
+	policy := makeRollouts(anyrl.Rewards{{-1, -2, -1}, {-0.5}})
+
+	fc := anynet.NewFC(c, 1, 1)
+	fc.Weights.Vector.Scale(c.MakeNumeric(0))
+	irl := &NeuralIRL{
+		Model:    fc,
+		Params:   fc.Parameters(),
+		Discount: 0.9,
+	}
+	for i := 0; i < 10; i++ {
+		grad := irl.Train(expert, policy)
+		grad.Scale(c.MakeNumeric(0.1))
+		grad.AddToVars()
+	}
+
+	expertRewards := irl.Rewards(expert)
+	policyRewards := irl.Rewards(policy)
+	var minExpert float64 = 1e10
+	for _, seq := range expertRewards {
+		for _, x := range seq {
+			if x < minExpert {
+				minExpert = x
+			}
+		}
+	}
+	for i, seq := range policyRewards {
+		for j, x := range seq {
+			if x >= minExpert {
+				t.Errorf("policy episode %d step %d: reward %f is not below expert rewards",
+					i, j, x)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package anyirl
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyrl"
+)
+
+// forEachStep calls f for every timestep in the rollouts,
+// passing the timestep index and the packed observation
+// and action batches.
+// Use presentIndices to map packed rows to episodes.
+func forEachStep(r *anyrl.RolloutSet,
+	f func(t int, inputs, actions *anyseq.Batch)) {
+	inputs := r.Inputs.ReadTape(0, -1)
+	actions := r.Actions.ReadTape(0, -1)
+	var t int
+	for inBatch := range inputs {
+		f(t, inBatch, <-actions)
+		t++
+	}
+	for _ = range actions {
+	}
+}
+
+// presentIndices lists the indices of the present
+// sequences in a batch.
+func presentIndices(b *anyseq.Batch) []int {
+	var res []int
+	for i, pres := range b.Present {
+		if pres {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// softmax computes normalized exponentials in a
+// numerically stable way.
+func softmax(logits []float64) []float64 {
+	max := math.Inf(-1)
+	for _, x := range logits {
+		max = math.Max(max, x)
+	}
+	res := make([]float64, len(logits))
+	var sum float64
+	for i, x := range logits {
+		res[i] = math.Exp(x - max)
+		sum += res[i]
+	}
+	for i := range res {
+		res[i] /= sum
+	}
+	return res
+}
+
+func discount(d float64) float64 {
+	if d == 0 {
+		return 1
+	}
+	return d
+}
@@ -0,0 +1,4 @@
+// Package anyleague implements league training for
+// competitive self-play, in the style of AlphaStar, with
+// main agents, exploiters, and frozen past checkpoints.
+package anyleague
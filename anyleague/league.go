@@ -0,0 +1,230 @@
+package anyleague
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+)
+
+// DefaultSelfPlayProb is the default probability that a
+// main agent plays against another main agent.
+const DefaultSelfPlayProb = 0.35
+
+// Role determines how a Player's opponents are chosen.
+type Role int
+
+// These are the supported player roles.
+const (
+	// Main agents play against each other and against
+	// the whole league.
+	Main Role = iota
+
+	// MainExploiter agents only play against main agents,
+	// to find their weaknesses.
+	MainExploiter
+
+	// LeagueExploiter agents play against the whole
+	// league, to find global blind spots.
+	LeagueExploiter
+
+	// Historical players are frozen checkpoints.
+	// They are not trained, but they serve as opponents.
+	Historical
+)
+
+// A Player is a member of a League.
+type Player struct {
+	Name string
+	Role Role
+
+	// Policy stores the player's policy.
+	// For Historical players, this should be a frozen
+	// copy of the policy, such as serialized parameters.
+	Policy interface{}
+}
+
+// PFSPHard is a prioritized fictitious self-play
+// weighting which focuses on the opponents that are
+// hardest to beat.
+func PFSPHard(winRate float64) float64 {
+	return (1 - winRate) * (1 - winRate)
+}
+
+// PFSPVariance is a prioritized fictitious self-play
+// weighting which focuses on opponents of a similar
+// skill level.
+func PFSPVariance(winRate float64) float64 {
+	return winRate * (1 - winRate)
+}
+
+// A League manages a population of players, chooses
+// matchups, and tracks per-matchup win rates.
+//
+// All of the methods are safe to call concurrently.
+type League struct {
+	// SelfPlayProb is the probability that a Main player
+	// is matched against another Main player rather than
+	// a Historical one.
+	//
+	// If 0, DefaultSelfPlayProb is used.
+	SelfPlayProb float64
+
+	// Weighting maps a player's win rate against an
+	// opponent to that opponent's unnormalized
+	// matchmaking weight.
+	//
+	// If nil, PFSPHard is used.
+	Weighting func(winRate float64) float64
+
+	lock    sync.Mutex
+	players []*Player
+	records map[[2]*Player]*record
+}
+
+type record struct {
+	Games float64
+	Score float64
+}
+
+// Add adds a player to the league.
+func (l *League) Add(p *Player) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.players = append(l.players, p)
+}
+
+// Players returns all of the players in the league.
+func (l *League) Players() []*Player {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]*Player{}, l.players...)
+}
+
+// Checkpoint adds a Historical player with the given
+// frozen policy, e.g. a snapshot of a Main player.
+func (l *League) Checkpoint(name string, policy interface{}) *Player {
+	res := &Player{Name: name, Role: Historical, Policy: policy}
+	l.Add(res)
+	return res
+}
+
+// Opponent chooses an opponent for a player according to
+// its role.
+//
+// An error is returned if there are no suitable
+// opponents.
+func (l *League) Opponent(p *Player) (*Player, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	mains := l.withRole(Main, p)
+	historical := l.withRole(Historical, nil)
+
+	var candidates []*Player
+	switch p.Role {
+	case Main:
+		candidates = historical
+		if len(historical) == 0 || (len(mains) > 0 && rand.Float64() < l.selfPlayProb()) {
+			candidates = mains
+		}
+	case MainExploiter, Historical:
+		candidates = mains
+	case LeagueExploiter:
+		candidates = historical
+		if len(candidates) == 0 {
+			candidates = mains
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("choose opponent: no suitable opponents")
+	}
+	if p.Role == MainExploiter || p.Role == Historical {
+		return candidates[rand.Intn(len(candidates))], nil
+	}
+	return l.prioritized(p, candidates), nil
+}
+
+// Report records the outcome of a game.
+// The outcome is from p's perspective: 1 for a win, 0.5
+// for a draw, and 0 for a loss.
+func (l *League) Report(p, opponent *Player, outcome float64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.record(p, opponent).Games++
+	l.record(p, opponent).Score += outcome
+	l.record(opponent, p).Games++
+	l.record(opponent, p).Score += 1 - outcome
+}
+
+// WinRate computes the fraction of games that p has won
+// against an opponent, counting draws as half a win.
+//
+// If the players have never played each other, 0.5 is
+// returned.
+func (l *League) WinRate(p, opponent *Player) float64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.winRate(p, opponent)
+}
+
+func (l *League) winRate(p, opponent *Player) float64 {
+	rec, ok := l.records[[2]*Player{p, opponent}]
+	if !ok || rec.Games == 0 {
+		return 0.5
+	}
+	return rec.Score / rec.Games
+}
+
+func (l *League) record(p, opponent *Player) *record {
+	if l.records == nil {
+		l.records = map[[2]*Player]*record{}
+	}
+	key := [2]*Player{p, opponent}
+	if rec, ok := l.records[key]; ok {
+		return rec
+	}
+	rec := &record{}
+	l.records[key] = rec
+	return rec
+}
+
+func (l *League) prioritized(p *Player, candidates []*Player) *Player {
+	weighting := l.Weighting
+	if weighting == nil {
+		weighting = PFSPHard
+	}
+	weights := make([]float64, len(candidates))
+	var total float64
+	for i, c := range candidates {
+		weights[i] = weighting(l.winRate(p, c))
+		total += weights[i]
+	}
+	if total == 0 {
+		return candidates[rand.Intn(len(candidates))]
+	}
+	x := rand.Float64() * total
+	for i, w := range weights {
+		x -= w
+		if x < 0 {
+			return candidates[i]
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+func (l *League) withRole(role Role, exclude *Player) []*Player {
+	var res []*Player
+	for _, p := range l.players {
+		if p.Role == role && p != exclude {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func (l *League) selfPlayProb() float64 {
+	if l.SelfPlayProb == 0 {
+		return DefaultSelfPlayProb
+	}
+	return l.SelfPlayProb
+}
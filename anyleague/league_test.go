@@ -0,0 +1,111 @@
+package anyleague
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLeagueWinRate(t *testing.T) {
+	l := &League{}
+	p1 := &Player{Name: "p1"}
+	p2 := &Player{Name: "p2"}
+	l.Add(p1)
+	l.Add(p2)
+	if l.WinRate(p1, p2) != 0.5 {
+		t.Error("expected default win rate of 0.5")
+	}
+	l.Report(p1, p2, 1)
+	l.Report(p1, p2, 0.5)
+	l.Report(p2, p1, 1)
+	if rate := l.WinRate(p1, p2); math.Abs(rate-0.5) > 1e-8 {
+		t.Errorf("unexpected win rate: %f", rate)
+	}
+	l.Report(p1, p2, 1)
+	if rate := l.WinRate(p2, p1); math.Abs(rate-0.375) > 1e-8 {
+		t.Errorf("unexpected win rate: %f", rate)
+	}
+}
+
+func TestLeagueOpponent(t *testing.T) {
+	l := &League{SelfPlayProb: 0.5}
+	main := &Player{Name: "main", Role: Main}
+	exploiter := &Player{Name: "exploiter", Role: MainExploiter}
+	l.Add(main)
+	l.Add(exploiter)
+
+	if _, err := l.Opponent(main); err == nil {
+		t.Error("expected error with no opponents")
+	}
+	if opp, err := l.Opponent(exploiter); err != nil || opp != main {
+		t.Errorf("unexpected opponent %v (err %v)", opp, err)
+	}
+
+	easy := l.Checkpoint("easy", nil)
+	hard := l.Checkpoint("hard", nil)
+	for i := 0; i < 10; i++ {
+		l.Report(main, easy, 1)
+	}
+
+	counts := map[*Player]int{}
+	for i := 0; i < 1000; i++ {
+		opp, err := l.Opponent(main)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[opp]++
+	}
+	if counts[easy] != 0 || counts[hard] != 1000 {
+		t.Errorf("unexpected opponent counts: easy=%d hard=%d", counts[easy], counts[hard])
+	}
+}
+
+func TestTournamentEvaluate(t *testing.T) {
+	players := []*Player{{Name: "a", Policy: 1.0}, {Name: "b", Policy: 3.0},
+		{Name: "c", Policy: 2.0}}
+	tourney := &Tournament{Rounds: 4, Parallelism: 2}
+	standings, err := tourney.Evaluate(players, func(p *Player) (float64, error) {
+		return p.Policy.(float64), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"b", "c", "a"} {
+		s := standings[i]
+		if s.Player.Name != name || s.Games != 4 || s.Lower != s.Mean || s.Upper != s.Mean {
+			t.Errorf("unexpected standing %d: %+v", i, s)
+		}
+	}
+}
+
+func TestTournamentRoundRobin(t *testing.T) {
+	players := []*Player{{Name: "weak", Policy: 1}, {Name: "strong", Policy: 2},
+		{Name: "medium", Policy: 1.5}}
+	tourney := &Tournament{Rounds: 6}
+	standings, err := tourney.RoundRobin(players, func(p1, p2 *Player) (float64, error) {
+		s1, s2 := toFloat(p1.Policy), toFloat(p2.Policy)
+		if s1 > s2 {
+			return 1, nil
+		}
+		return 0, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{1, 0.5, 0}
+	for i, name := range []string{"strong", "medium", "weak"} {
+		s := standings[i]
+		if s.Player.Name != name || s.Games != 12 || s.Mean != expected[i] {
+			t.Errorf("unexpected standing %d: %+v", i, s)
+		}
+	}
+}
+
+func toFloat(x interface{}) float64 {
+	switch x := x.(type) {
+	case int:
+		return float64(x)
+	case float64:
+		return x
+	}
+	panic("unexpected type")
+}
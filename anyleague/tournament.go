@@ -0,0 +1,189 @@
+package anyleague
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+)
+
+// Default settings for Tournament.
+const (
+	DefaultTournamentRounds      = 10
+	DefaultTournamentParallelism = 8
+)
+
+// A Standing is a player's result in a Tournament.
+type Standing struct {
+	Player *Player
+
+	// Mean is the player's mean score.
+	Mean float64
+
+	// Lower and Upper bound a 95% confidence interval
+	// for the player's expected score, assuming the
+	// scores are roughly normally distributed.
+	Lower float64
+	Upper float64
+
+	// Games is the number of games the player played.
+	Games int
+}
+
+// A Tournament evaluates many players (e.g. checkpoints)
+// in parallel and ranks them by their mean scores.
+type Tournament struct {
+	// Rounds is the number of games each player plays in
+	// Evaluate, or the number of games each pair of
+	// players plays in RoundRobin.
+	//
+	// If 0, DefaultTournamentRounds is used.
+	Rounds int
+
+	// Parallelism is the maximum number of concurrent
+	// games.
+	//
+	// If 0, DefaultTournamentParallelism is used.
+	Parallelism int
+}
+
+// Evaluate ranks players by their scores on a task, such
+// as the total reward of an episode in an environment.
+//
+// The eval function plays one game and returns the score.
+// It is called concurrently.
+//
+// The standings are sorted from best to worst.
+func (t *Tournament) Evaluate(players []*Player,
+	eval func(p *Player) (float64, error)) (standings []*Standing, err error) {
+	defer essentials.AddCtxTo("evaluate tournament", &err)
+	var games []func() error
+	scores := make([][]float64, len(players))
+	var lock sync.Mutex
+	for i, p := range players {
+		for j := 0; j < t.rounds(); j++ {
+			i, p := i, p
+			games = append(games, func() error {
+				score, err := eval(p)
+				if err != nil {
+					return err
+				}
+				lock.Lock()
+				scores[i] = append(scores[i], score)
+				lock.Unlock()
+				return nil
+			})
+		}
+	}
+	if err := t.runGames(games); err != nil {
+		return nil, err
+	}
+	return rankPlayers(players, scores), nil
+}
+
+// RoundRobin ranks players in a competitive game by
+// having every pair of players play each other.
+//
+// The play function plays one game and returns the
+// outcome from the first player's perspective (1 for a
+// win, 0.5 for a draw, 0 for a loss).
+// It is called concurrently.
+// Each pair plays half of its games with each player
+// going first.
+//
+// A player's score is its mean outcome, and the
+// standings are sorted from best to worst.
+func (t *Tournament) RoundRobin(players []*Player,
+	play func(p1, p2 *Player) (float64, error)) (standings []*Standing, err error) {
+	defer essentials.AddCtxTo("round-robin tournament", &err)
+	var games []func() error
+	scores := make([][]float64, len(players))
+	var lock sync.Mutex
+	for i := range players {
+		for j := i + 1; j < len(players); j++ {
+			for k := 0; k < t.rounds(); k++ {
+				first, second := i, j
+				if k%2 == 1 {
+					first, second = j, i
+				}
+				games = append(games, func() error {
+					outcome, err := play(players[first], players[second])
+					if err != nil {
+						return err
+					}
+					lock.Lock()
+					scores[first] = append(scores[first], outcome)
+					scores[second] = append(scores[second], 1-outcome)
+					lock.Unlock()
+					return nil
+				})
+			}
+		}
+	}
+	if err := t.runGames(games); err != nil {
+		return nil, err
+	}
+	return rankPlayers(players, scores), nil
+}
+
+func (t *Tournament) runGames(games []func() error) error {
+	parallelism := t.Parallelism
+	if parallelism == 0 {
+		parallelism = DefaultTournamentParallelism
+	}
+	gameChan := make(chan func() error, len(games))
+	for _, g := range games {
+		gameChan <- g
+	}
+	close(gameChan)
+
+	errChan := make(chan error, parallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range gameChan {
+				if err := g(); err != nil {
+					errChan <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errChan)
+	return <-errChan
+}
+
+func (t *Tournament) rounds() int {
+	if t.Rounds == 0 {
+		return DefaultTournamentRounds
+	}
+	return t.Rounds
+}
+
+func rankPlayers(players []*Player, scores [][]float64) []*Standing {
+	var res []*Standing
+	for i, p := range players {
+		s := &Standing{Player: p, Games: len(scores[i])}
+		if s.Games > 0 {
+			var sum, sqSum float64
+			for _, x := range scores[i] {
+				sum += x
+				sqSum += x * x
+			}
+			s.Mean = sum / float64(s.Games)
+			variance := math.Max(0, sqSum/float64(s.Games)-s.Mean*s.Mean)
+			margin := 1.96 * math.Sqrt(variance/float64(s.Games))
+			s.Lower = s.Mean - margin
+			s.Upper = s.Mean + margin
+		}
+		res = append(res, s)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Mean > res[j].Mean
+	})
+	return res
+}
@@ -0,0 +1,5 @@
+// Package anymcts implements Monte-Carlo tree search
+// guided by a policy/value network, in the style of
+// AlphaZero, for deterministic environments with discrete
+// action spaces.
+package anymcts
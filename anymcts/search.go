@@ -0,0 +1,244 @@
+package anymcts
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// Default values for Search.
+const (
+	DefaultSims  = 50
+	DefaultCPuct = 1.5
+)
+
+// Env is a deterministic environment which can be copied
+// in order to explore different actions from the same
+// state.
+//
+// Actions are one-hot vectors, like the ones produced by
+// anyrl.Softmax.
+type Env interface {
+	anyrl.Env
+
+	// Clone creates an independent copy of the
+	// environment in its current state.
+	Clone() (Env, error)
+}
+
+// Search runs Monte-Carlo tree search guided by a
+// policy/value network.
+type Search struct {
+	// Net maps a batch of observations to a batch of
+	// outputs, each of which contains NumActions softmax
+	// logits followed by a value estimate.
+	Net anynet.Layer
+
+	// NumActions is the number of discrete actions.
+	NumActions int
+
+	// Discount is the reward discount factor.
+	Discount float64
+
+	// Sims is the number of simulations per search.
+	//
+	// If 0, DefaultSims is used.
+	Sims int
+
+	// CPuct controls the amount of exploration in the
+	// PUCT selection rule.
+	//
+	// If 0, DefaultCPuct is used.
+	CPuct float64
+
+	// Creator is used to convert observations to
+	// vectors.
+	// If nil, the creator of the network's first
+	// parameter is used.
+	Creator anyvec.Creator
+}
+
+// Run searches from the current state of env, which
+// produced the observation obs.
+// The env is not modified.
+//
+// It returns the normalized visit counts of the root's
+// actions and the mean value of the root.
+func (s *Search) Run(env Env, obs []float64) (probs []float64, value float64,
+	err error) {
+	defer essentials.AddCtxTo("run MCTS", &err)
+	root := &node{Env: env, Obs: obs}
+	s.expand(root)
+	var totalValue float64
+	for i := 0; i < s.sims(); i++ {
+		v, err := s.simulate(root)
+		if err != nil {
+			return nil, 0, err
+		}
+		totalValue += v
+	}
+
+	probs = make([]float64, s.NumActions)
+	var total float64
+	for i, n := range root.Visits {
+		probs[i] = float64(n)
+		total += float64(n)
+	}
+	for i := range probs {
+		probs[i] /= total
+	}
+	return probs, totalValue / float64(s.sims()), nil
+}
+
+// simulate runs one simulation from n and returns the
+// discounted return observed from n.
+func (s *Search) simulate(n *node) (float64, error) {
+	action := s.selectAction(n)
+	child := n.Children[action]
+	if child == nil {
+		var err error
+		child, err = s.makeChild(n, action)
+		if err != nil {
+			return 0, err
+		}
+		n.Children[action] = child
+	}
+
+	var future float64
+	if !child.Done {
+		if child.Visited {
+			var err error
+			future, err = s.simulate(child)
+			if err != nil {
+				return 0, err
+			}
+		} else {
+			child.Visited = true
+			future = child.Value
+		}
+	}
+
+	ret := child.Reward + s.Discount*future
+	n.Visits[action]++
+	n.TotalValues[action] += ret
+	return ret, nil
+}
+
+func (s *Search) selectAction(n *node) int {
+	var totalVisits int
+	for _, v := range n.Visits {
+		totalVisits += v
+	}
+
+	// Using at least one visit lets the priors break ties
+	// before any action has been tried.
+	sqrtTotal := math.Sqrt(math.Max(1, float64(totalVisits)))
+
+	bestAction := 0
+	bestScore := math.Inf(-1)
+	for i, prior := range n.Priors {
+		var q float64
+		if n.Visits[i] > 0 {
+			q = n.TotalValues[i] / float64(n.Visits[i])
+		}
+		score := q + s.cPuct()*prior*sqrtTotal/float64(1+n.Visits[i])
+		if score > bestScore {
+			bestScore = score
+			bestAction = i
+		}
+	}
+	return bestAction
+}
+
+func (s *Search) makeChild(parent *node, action int) (*node, error) {
+	env, err := parent.Env.Clone()
+	if err != nil {
+		return nil, err
+	}
+	oneHot := make([]float64, s.NumActions)
+	oneHot[action] = 1
+	obs, reward, done, err := env.Step(oneHot)
+	if err != nil {
+		return nil, err
+	}
+	child := &node{Env: env, Obs: obs, Reward: reward, Done: done}
+	if !done {
+		s.expand(child)
+	}
+	return child, nil
+}
+
+// expand evaluates the network at a node.
+func (s *Search) expand(n *node) {
+	n.Priors, n.Value = s.evaluate(n.Obs)
+	n.Children = make([]*node, s.NumActions)
+	n.Visits = make([]int, s.NumActions)
+	n.TotalValues = make([]float64, s.NumActions)
+}
+
+// evaluate applies the network to an observation.
+func (s *Search) evaluate(obs []float64) (priors []float64, value float64) {
+	c := s.creator()
+	in := anydiff.NewConst(anyvec.Make(c, obs))
+	out := c.Float64Slice(s.Net.Apply(in, 1).Output().Data())
+	return softmax(out[:s.NumActions]), out[s.NumActions]
+}
+
+func (s *Search) creator() anyvec.Creator {
+	if s.Creator != nil {
+		return s.Creator
+	}
+	return anynet.AllParameters(s.Net)[0].Output().Creator()
+}
+
+func (s *Search) sims() int {
+	if s.Sims == 0 {
+		return DefaultSims
+	}
+	return s.Sims
+}
+
+func (s *Search) cPuct() float64 {
+	if s.CPuct == 0 {
+		return DefaultCPuct
+	}
+	return s.CPuct
+}
+
+type node struct {
+	Env    Env
+	Obs    []float64
+	Reward float64
+	Done   bool
+
+	// Visited is true once the node's value estimate has
+	// been used in a simulation.
+	Visited bool
+
+	Priors      []float64
+	Value       float64
+	Children    []*node
+	Visits      []int
+	TotalValues []float64
+}
+
+func softmax(logits []float64) []float64 {
+	max := logits[0]
+	for _, x := range logits {
+		max = math.Max(max, x)
+	}
+	var sum float64
+	res := make([]float64, len(logits))
+	for i, x := range logits {
+		res[i] = math.Exp(x - max)
+		sum += res[i]
+	}
+	for i := range res {
+		res[i] /= sum
+	}
+	return res
+}
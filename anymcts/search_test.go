@@ -0,0 +1,116 @@
+package anymcts
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestSearch(t *testing.T) {
+	search := &Search{
+		Net:        zeroNet{},
+		NumActions: 2,
+		Discount:   0.9,
+		Sims:       30,
+		Creator:    anyvec64.DefaultCreator{},
+	}
+	env := &chainEnv{}
+	obs, _ := env.Reset()
+	probs, _, err := search.Run(env, obs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if probs[1] <= probs[0] {
+		t.Errorf("expected action 1 to be preferred, but got %v", probs)
+	}
+	if env.Pos != 0 {
+		t.Error("search modified the environment")
+	}
+
+	trainer := &Trainer{Search: search}
+	samples, reward, err := trainer.SelfPlay(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reward != 3 {
+		t.Errorf("expected reward 3 but got %f", reward)
+	}
+	expectedValues := []float64{1 + 0.9 + 0.81, 1 + 0.9, 1}
+	if len(samples) != len(expectedValues) {
+		t.Fatalf("expected %d samples but got %d", len(expectedValues), len(samples))
+	}
+	for i, s := range samples {
+		if diff := s.Value - expectedValues[i]; diff > 1e-8 || diff < -1e-8 {
+			t.Errorf("sample %d: expected value %f but got %f", i, expectedValues[i],
+				s.Value)
+		}
+	}
+}
+
+func TestSearchPriors(t *testing.T) {
+	// With a single simulation, the only information about
+	// the actions comes from the priors.
+	search := &Search{
+		Net:        constNet{Logits: []float64{0, 2}},
+		NumActions: 2,
+		Discount:   0.9,
+		Sims:       1,
+		Creator:    anyvec64.DefaultCreator{},
+	}
+	env := &chainEnv{}
+	obs, _ := env.Reset()
+	probs, _, err := search.Run(env, obs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if probs[1] != 1 {
+		t.Errorf("expected action 1 to be selected, but got %v", probs)
+	}
+}
+
+// chainEnv gives a reward of 1 for action 1 and ends
+// after three steps.
+type chainEnv struct {
+	Pos int
+}
+
+func (c *chainEnv) Reset() ([]float64, error) {
+	c.Pos = 0
+	return []float64{0}, nil
+}
+
+func (c *chainEnv) Step(action []float64) ([]float64, float64, bool, error) {
+	c.Pos++
+	return []float64{float64(c.Pos)}, action[1], c.Pos == 3, nil
+}
+
+func (c *chainEnv) Clone() (Env, error) {
+	res := *c
+	return &res, nil
+}
+
+// zeroNet produces uniform policies and zero values for
+// two actions.
+type zeroNet struct{}
+
+func (z zeroNet) Apply(in anydiff.Res, n int) anydiff.Res {
+	return anydiff.NewConst(in.Output().Creator().MakeVector(3 * n))
+}
+
+// constNet produces the same logits and value for every
+// observation.
+type constNet struct {
+	Logits []float64
+	Value  float64
+}
+
+func (c constNet) Apply(in anydiff.Res, n int) anydiff.Res {
+	var res []float64
+	for i := 0; i < n; i++ {
+		res = append(res, c.Logits...)
+		res = append(res, c.Value)
+	}
+	return anydiff.NewConst(anyvec.Make(in.Output().Creator(), res))
+}
@@ -0,0 +1,182 @@
+package anymcts
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// A Sample is a training target produced by self-play.
+type Sample struct {
+	Obs []float64
+
+	// Policy is the search-improved action distribution.
+	Policy []float64
+
+	// Value is the discounted return observed from the
+	// sample's state until the end of the episode.
+	Value float64
+}
+
+// Terms stores the current values of the terms in the
+// training loss.
+type Terms struct {
+	MeanPolicyLoss anyvec.Numeric
+	MeanValueLoss  anyvec.Numeric
+}
+
+// Trainer fits a Search's network to search-improved
+// targets gathered through self-play.
+type Trainer struct {
+	Search *Search
+
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// Temperature controls how actions are selected from
+	// the visit counts during self-play.
+	// If 0, the most visited action is always selected.
+	Temperature float64
+
+	// MaxSteps, if non-zero, limits the length of
+	// self-play episodes.
+	// The returns of episodes which are cut off are
+	// bootstrapped from the network's value estimate.
+	MaxSteps int
+}
+
+// SelfPlay runs an episode in env, selecting actions with
+// the search, and returns a Sample for every timestep.
+//
+// It also returns the total reward of the episode.
+func (t *Trainer) SelfPlay(env Env) (samples []*Sample, reward float64, err error) {
+	defer essentials.AddCtxTo("self-play", &err)
+
+	obs, err := env.Reset()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var rewards []float64
+	var done bool
+	for step := 0; t.MaxSteps == 0 || step < t.MaxSteps; step++ {
+		probs, _, err := t.Search.Run(env, obs)
+		if err != nil {
+			return nil, 0, err
+		}
+		samples = append(samples, &Sample{Obs: obs, Policy: probs})
+
+		oneHot := make([]float64, len(probs))
+		oneHot[t.selectAction(probs)] = 1
+		var rew float64
+		obs, rew, done, err = env.Step(oneHot)
+		if err != nil {
+			return nil, 0, err
+		}
+		rewards = append(rewards, rew)
+		reward += rew
+		if done {
+			break
+		}
+	}
+
+	var ret float64
+	if !done || anyrl.EnvTruncated(env) {
+		_, ret = t.Search.evaluate(obs)
+	}
+	for i := len(rewards) - 1; i >= 0; i-- {
+		ret = rewards[i] + t.Search.Discount*ret
+		samples[i].Value = ret
+	}
+
+	return samples, reward, nil
+}
+
+// Train computes a gradient which moves the network's
+// policy towards the samples' policies and the network's
+// values towards the samples' values.
+//
+// The gradient is for the negative loss, so it should be
+// added to the parameters.
+//
+// If t.Params is empty, then an empty gradient and nil
+// Terms are returned.
+func (t *Trainer) Train(samples []*Sample) (anydiff.Grad, *Terms) {
+	grad := anydiff.NewGrad(t.Params...)
+	if len(grad) == 0 || len(samples) == 0 {
+		return grad, nil
+	}
+	c := t.Params[0].Vector.Creator()
+	n := len(samples)
+	numActions := t.Search.NumActions
+
+	var obs, policies, values []float64
+	for _, s := range samples {
+		obs = append(obs, s.Obs...)
+		policies = append(policies, s.Policy...)
+		values = append(values, s.Value)
+	}
+
+	out := t.Search.Net.Apply(anydiff.NewConst(anyvec.Make(c, obs)), n)
+	terms := anydiff.Pool(out, func(out anydiff.Res) anydiff.Res {
+		var logitParts, valueParts []anydiff.Res
+		for i := 0; i < n; i++ {
+			start := i * (numActions + 1)
+			logitParts = append(logitParts, anydiff.Slice(out, start, start+numActions))
+			valueParts = append(valueParts, anydiff.Slice(out, start+numActions,
+				start+numActions+1))
+		}
+		logProbs := anydiff.LogSoftmax(anydiff.Concat(logitParts...), numActions)
+		policyLoss := anydiff.Scale(
+			anydiff.Sum(anydiff.Mul(logProbs, anydiff.NewConst(anyvec.Make(c, policies)))),
+			c.MakeNumeric(-1/float64(n)),
+		)
+		valueLoss := anydiff.Scale(
+			anydiff.Sum(anydiff.Square(anydiff.Sub(
+				anydiff.Concat(valueParts...),
+				anydiff.NewConst(anyvec.Make(c, values)),
+			))),
+			c.MakeNumeric(1/float64(n)),
+		)
+		return anydiff.Concat(policyLoss, valueLoss)
+	})
+
+	terms.Propagate(anyvec.Make(c, []float64{-1, -1}), grad)
+
+	return grad, &Terms{
+		MeanPolicyLoss: anyvec.Sum(terms.Output().Slice(0, 1)),
+		MeanValueLoss:  anyvec.Sum(terms.Output().Slice(1, 2)),
+	}
+}
+
+func (t *Trainer) selectAction(probs []float64) int {
+	if t.Temperature == 0 {
+		var best int
+		for i, p := range probs {
+			if p > probs[best] {
+				best = i
+			}
+		}
+		return best
+	}
+
+	weights := make([]float64, len(probs))
+	var total float64
+	for i, p := range probs {
+		weights[i] = math.Pow(p, 1/t.Temperature)
+		total += weights[i]
+	}
+	x := rand.Float64() * total
+	for i, w := range weights {
+		x -= w
+		if x < 0 {
+			return i
+		}
+	}
+	return len(weights) - 1
+}
@@ -0,0 +1,130 @@
+package anymcts
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestSelfPlayMaxSteps(t *testing.T) {
+	search := &Search{
+		Net:        constNet{Logits: []float64{0, 0}, Value: 5},
+		NumActions: 2,
+		Discount:   0.9,
+		Sims:       30,
+		Creator:    anyvec64.DefaultCreator{},
+	}
+	trainer := &Trainer{Search: search, MaxSteps: 2}
+	samples, _, err := trainer.SelfPlay(&chainEnv{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The value of the state after the cutoff is used
+	// in place of the remaining rewards.
+	expectedValues := []float64{1 + 0.9*(1+0.9*5), 1 + 0.9*5}
+	if len(samples) != len(expectedValues) {
+		t.Fatalf("expected %d samples but got %d", len(expectedValues), len(samples))
+	}
+	for i, s := range samples {
+		if diff := s.Value - expectedValues[i]; diff > 1e-8 || diff < -1e-8 {
+			t.Errorf("sample %d: expected value %f but got %f", i, expectedValues[i],
+				s.Value)
+		}
+	}
+}
+
+func TestSelfPlayTruncated(t *testing.T) {
+	search := &Search{
+		Net:        constNet{Logits: []float64{0, 0}, Value: 5},
+		NumActions: 2,
+		Discount:   0.9,
+		Sims:       30,
+		Creator:    anyvec64.DefaultCreator{},
+	}
+	trainer := &Trainer{Search: search}
+	samples, _, err := trainer.SelfPlay(&truncatedChainEnv{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The episode ends because of a time limit, so the
+	// value of the final state is still used.
+	expectedValues := []float64{
+		1 + 0.9*(1+0.9*(1+0.9*5)),
+		1 + 0.9*(1+0.9*5),
+		1 + 0.9*5,
+	}
+	if len(samples) != len(expectedValues) {
+		t.Fatalf("expected %d samples but got %d", len(expectedValues), len(samples))
+	}
+	for i, s := range samples {
+		if math.Abs(s.Value-expectedValues[i]) > 1e-8 {
+			t.Errorf("sample %d: expected value %f but got %f", i, expectedValues[i],
+				s.Value)
+		}
+	}
+}
+
+func TestTrainerGradients(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	net := anynet.NewFC(c, 1, 3)
+	net.Weights.Vector.Scale(c.MakeNumeric(0))
+	net.Biases.Vector.Scale(c.MakeNumeric(0))
+	trainer := &Trainer{
+		Search: &Search{Net: net, NumActions: 2, Creator: c},
+		Params: net.Parameters(),
+	}
+	samples := []*Sample{{Obs: []float64{1}, Policy: []float64{0.2, 0.8}, Value: 2}}
+
+	// The network starts with a uniform policy and a
+	// value of 0, so the policy should move towards action
+	// 1 and the value should increase.
+	grad, terms := trainer.Train(samples)
+	if math.Abs(terms.MeanPolicyLoss.(float64)-math.Log(2)) > 1e-8 {
+		t.Errorf("expected policy loss %f but got %f", math.Log(2), terms.MeanPolicyLoss)
+	}
+	if math.Abs(terms.MeanValueLoss.(float64)-4) > 1e-8 {
+		t.Errorf("expected value loss 4 but got %f", terms.MeanValueLoss)
+	}
+	expected := []float64{-0.3, 0.3, 4}
+	actual := c.Float64Slice(grad[net.Biases].Data())
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-8 {
+			t.Fatalf("expected bias gradient %v but got %v", expected, actual)
+		}
+	}
+
+	grad.Scale(c.MakeNumeric(0.1))
+	grad.AddToVars()
+	_, newTerms := trainer.Train(samples)
+	if newTerms.MeanPolicyLoss.(float64) >= terms.MeanPolicyLoss.(float64) ||
+		newTerms.MeanValueLoss.(float64) >= terms.MeanValueLoss.(float64) {
+		t.Errorf("losses went from %v to %v", terms, newTerms)
+	}
+}
+
+func TestSelectAction(t *testing.T) {
+	trainer := &Trainer{}
+	if action := trainer.selectAction([]float64{0.2, 0.5, 0.3}); action != 1 {
+		t.Errorf("expected action 1 but got %d", action)
+	}
+	trainer.Temperature = 1
+	for i := 0; i < 100; i++ {
+		if action := trainer.selectAction([]float64{0.5, 0.5, 0}); action == 2 {
+			t.Fatal("selected an action with zero probability")
+		}
+	}
+}
+
+// truncatedChainEnv is a chainEnv whose episodes end due
+// to a time limit.
+type truncatedChainEnv struct {
+	chainEnv
+}
+
+func (t *truncatedChainEnv) Truncated() bool {
+	return true
+}
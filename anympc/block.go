@@ -0,0 +1,134 @@
+package anympc
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+)
+
+// Block is an anyrnn.Block which runs a CEM planner at
+// every timestep and outputs the first action of the
+// resulting plan.
+//
+// The remainder of each plan is carried in the block's
+// state and used to warm-start the planner at the next
+// timestep.
+//
+// Block has no parameters and is not differentiable.
+// When using it with anyrl.RNNRoller, set the roller's
+// Creator and use Deterministic as the action space.
+type Block struct {
+	Planner *CEM
+}
+
+// Start generates an initial state with no plans.
+func (b *Block) Start(n int) anyrnn.State {
+	present := make(anyrnn.PresentMap, n)
+	for i := range present {
+		present[i] = true
+	}
+	return &planState{PresentMap: present, Plans: make([][]float64, n)}
+}
+
+// PropagateStart does nothing, since Block has no
+// parameters.
+func (b *Block) PropagateStart(s anyrnn.StateGrad, g anydiff.Grad) {
+}
+
+// Step plans for each observation in the batch.
+func (b *Block) Step(s anyrnn.State, in anyvec.Vector) anyrnn.Res {
+	state := s.(*planState)
+	c := in.Creator()
+	n := len(state.Plans)
+	obs := c.Float64Slice(in.Data())
+	obsSize := len(obs) / n
+	actionSize := b.Planner.ActionSize
+
+	var actions []float64
+	newPlans := make([][]float64, n)
+	for i, plan := range state.Plans {
+		newPlans[i] = b.Planner.Plan(c, obs[i*obsSize:(i+1)*obsSize], shiftPlan(plan,
+			actionSize))
+		actions = append(actions, newPlans[i][:actionSize]...)
+	}
+
+	return &blockRes{
+		OutState: &planState{PresentMap: state.PresentMap, Plans: newPlans},
+		OutVec:   anyvec.Make(c, actions),
+		InLen:    in.Len(),
+	}
+}
+
+// shiftPlan drops the first action from a plan and pads
+// it with zeros at the end.
+func shiftPlan(plan []float64, actionSize int) []float64 {
+	if plan == nil {
+		return nil
+	}
+	res := make([]float64, len(plan))
+	copy(res, plan[actionSize:])
+	return res
+}
+
+// Deterministic is an anyrl.Sampler which returns the
+// agent's outputs as the actions.
+type Deterministic struct{}
+
+// Sample returns a copy of the parameters.
+func (d Deterministic) Sample(params anyvec.Vector, batch int) anyvec.Vector {
+	return params.Copy()
+}
+
+type planState struct {
+	PresentMap anyrnn.PresentMap
+	Plans      [][]float64
+}
+
+func (p *planState) Present() anyrnn.PresentMap {
+	return p.PresentMap
+}
+
+func (p *planState) Reduce(present anyrnn.PresentMap) anyrnn.State {
+	var plans [][]float64
+	var idx int
+	for i, pres := range p.PresentMap {
+		if !pres {
+			continue
+		}
+		if present[i] {
+			plans = append(plans, p.Plans[idx])
+		}
+		idx++
+	}
+	return &planState{PresentMap: present, Plans: plans}
+}
+
+func (p *planState) Expand(present anyrnn.PresentMap) anyrnn.StateGrad {
+	return &planState{
+		PresentMap: present,
+		Plans:      make([][]float64, present.NumPresent()),
+	}
+}
+
+type blockRes struct {
+	OutState *planState
+	OutVec   anyvec.Vector
+	InLen    int
+}
+
+func (b *blockRes) State() anyrnn.State {
+	return b.OutState
+}
+
+func (b *blockRes) Output() anyvec.Vector {
+	return b.OutVec
+}
+
+func (b *blockRes) Vars() anydiff.VarSet {
+	return anydiff.VarSet{}
+}
+
+func (b *blockRes) Propagate(u anyvec.Vector, s anyrnn.StateGrad,
+	g anydiff.Grad) (anyvec.Vector, anyrnn.StateGrad) {
+	return u.Creator().MakeVector(b.InLen), b.OutState.Expand(b.OutState.PresentMap)
+}
@@ -0,0 +1,188 @@
+package anympc
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// Default values for CEM.
+const (
+	DefaultCEMPopulation = 100
+	DefaultCEMElites     = 10
+	DefaultCEMIters      = 5
+	DefaultCEMStddev     = 1.0
+)
+
+// CEM plans action sequences with the cross-entropy
+// method.
+//
+// Candidate action sequences are sampled from a diagonal
+// Gaussian, evaluated under a Model, and the Gaussian is
+// refit to the best sequences.
+type CEM struct {
+	Model Model
+
+	// ActionSize is the size of each action vector.
+	ActionSize int
+
+	// Horizon is the number of timesteps to plan ahead.
+	Horizon int
+
+	// Discount is the reward discount factor used to
+	// evaluate action sequences.
+	Discount float64
+
+	// Population is the number of action sequences to
+	// sample per iteration.
+	//
+	// If 0, DefaultCEMPopulation is used.
+	Population int
+
+	// Elites is the number of top action sequences used
+	// to refit the sampling distribution.
+	//
+	// If 0, DefaultCEMElites is used.
+	Elites int
+
+	// Iters is the number of refitting iterations.
+	//
+	// If 0, DefaultCEMIters is used.
+	Iters int
+
+	// Stddev is the initial standard deviation of the
+	// sampling distribution.
+	//
+	// If 0, DefaultCEMStddev is used.
+	Stddev float64
+
+	// MinAction and MaxAction, if non-nil, bound each
+	// component of the sampled actions.
+	MinAction []float64
+	MaxAction []float64
+}
+
+// Plan optimizes an action sequence for an observation.
+//
+// The init argument is the initial mean of the sampling
+// distribution, packed as Horizon action vectors.
+// If it is nil, a zero mean is used.
+//
+// The result is the final mean of the sampling
+// distribution, packed like init.
+func (c *CEM) Plan(cr anyvec.Creator, obs, init []float64) []float64 {
+	size := c.Horizon * c.ActionSize
+	mean := make([]float64, size)
+	copy(mean, init)
+	stddev := make([]float64, size)
+	for i := range stddev {
+		stddev[i] = c.stddev()
+	}
+
+	pop := c.population()
+	numElites := essentials.MinInt(c.elites(), pop)
+	for iter := 0; iter < c.iters(); iter++ {
+		seqs := make([][]float64, pop)
+		for i := range seqs {
+			seq := make([]float64, size)
+			for j := range seq {
+				seq[j] = c.clip(j%c.ActionSize, mean[j]+rand.NormFloat64()*stddev[j])
+			}
+			seqs[i] = seq
+		}
+
+		returns := c.Evaluate(cr, obs, seqs)
+		indices := make([]int, pop)
+		for i := range indices {
+			indices[i] = i
+		}
+		sort.Slice(indices, func(i, j int) bool {
+			return returns[indices[i]] > returns[indices[j]]
+		})
+
+		for j := range mean {
+			var sum, sqSum float64
+			for _, idx := range indices[:numElites] {
+				x := seqs[idx][j]
+				sum += x
+				sqSum += x * x
+			}
+			mean[j] = sum / float64(numElites)
+			variance := sqSum/float64(numElites) - mean[j]*mean[j]
+			stddev[j] = math.Sqrt(math.Max(0, variance))
+		}
+	}
+
+	return mean
+}
+
+// Evaluate computes the discounted return of each action
+// sequence, starting at the given observation, as
+// predicted by the model.
+func (c *CEM) Evaluate(cr anyvec.Creator, obs []float64, seqs [][]float64) []float64 {
+	n := len(seqs)
+	returns := make([]float64, n)
+	if n == 0 {
+		return returns
+	}
+
+	obsVec := cr.MakeVector(len(obs) * n)
+	anyvec.AddRepeated(obsVec, anyvec.Make(cr, obs))
+
+	discount := 1.0
+	for t := 0; t < c.Horizon; t++ {
+		var actions []float64
+		for _, seq := range seqs {
+			actions = append(actions, seq[t*c.ActionSize:(t+1)*c.ActionSize]...)
+		}
+		next, rewards := c.Model.Predict(obsVec, anyvec.Make(cr, actions), n)
+		for i, r := range cr.Float64Slice(rewards.Data()) {
+			returns[i] += discount * r
+		}
+		discount *= c.Discount
+		obsVec = next
+	}
+
+	return returns
+}
+
+func (c *CEM) clip(component int, x float64) float64 {
+	if c.MinAction != nil {
+		x = math.Max(x, c.MinAction[component])
+	}
+	if c.MaxAction != nil {
+		x = math.Min(x, c.MaxAction[component])
+	}
+	return x
+}
+
+func (c *CEM) population() int {
+	if c.Population == 0 {
+		return DefaultCEMPopulation
+	}
+	return c.Population
+}
+
+func (c *CEM) elites() int {
+	if c.Elites == 0 {
+		return DefaultCEMElites
+	}
+	return c.Elites
+}
+
+func (c *CEM) iters() int {
+	if c.Iters == 0 {
+		return DefaultCEMIters
+	}
+	return c.Iters
+}
+
+func (c *CEM) stddev() float64 {
+	if c.Stddev == 0 {
+		return DefaultCEMStddev
+	}
+	return c.Stddev
+}
@@ -0,0 +1,69 @@
+package anympc
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestCEMPlan(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	planner := &CEM{
+		Model:      targetModel{},
+		ActionSize: 1,
+		Horizon:    3,
+		Discount:   0.9,
+		Population: 500,
+		Elites:     50,
+		Iters:      10,
+	}
+	plan := planner.Plan(c, []float64{0}, nil)
+	for i, x := range plan {
+		if math.Abs(x-2) > 0.1 {
+			t.Errorf("step %d: expected 2 but got %f", i, x)
+		}
+	}
+}
+
+func TestBlockState(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	block := &Block{
+		Planner: &CEM{
+			Model:      targetModel{},
+			ActionSize: 1,
+			Horizon:    2,
+			Discount:   1,
+			Iters:      10,
+		},
+	}
+	state := block.Start(3)
+	res := block.Step(state, anyvec.Make(c, []float64{0, 0, 0}))
+	if res.Output().Len() != 3 {
+		t.Fatalf("expected 3 actions but got %d", res.Output().Len())
+	}
+	reduced := res.State().Reduce([]bool{true, false, true})
+	res = block.Step(reduced, anyvec.Make(c, []float64{0, 0}))
+	if res.Output().Len() != 2 {
+		t.Fatalf("expected 2 actions but got %d", res.Output().Len())
+	}
+	for i, x := range c.Float64Slice(res.Output().Data()) {
+		if math.Abs(x-2) > 0.1 {
+			t.Errorf("sequence %d: expected 2 but got %f", i, x)
+		}
+	}
+}
+
+// targetModel rewards actions for being close to 2.
+type targetModel struct{}
+
+func (t targetModel) Predict(obs, actions anyvec.Vector, batch int) (next,
+	rewards anyvec.Vector) {
+	c := actions.Creator()
+	var rews []float64
+	for _, a := range c.Float64Slice(actions.Data()) {
+		rews = append(rews, -(a-2)*(a-2))
+	}
+	return obs.Copy(), anyvec.Make(c, rews)
+}
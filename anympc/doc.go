@@ -0,0 +1,4 @@
+// Package anympc implements model-predictive control,
+// which plans action sequences at decision time using a
+// learned model of the environment's dynamics.
+package anympc
@@ -0,0 +1,112 @@
+package anympc
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl/anyq"
+	"github.com/unixpickle/anyvec"
+)
+
+// A Model predicts the outcomes of actions.
+type Model interface {
+	// Predict takes a batch of observations and a batch
+	// of actions and predicts the next observations and
+	// the rewards for each pair.
+	Predict(obs, actions anyvec.Vector, batch int) (next, rewards anyvec.Vector)
+}
+
+// NetModel is a Model which uses a neural network to
+// predict the change in observation and the reward.
+//
+// The network's input for each batch element is an
+// observation followed by an action.
+// Its output is the predicted difference between the next
+// observation and the current one, followed by the
+// predicted reward.
+type NetModel struct {
+	Net anynet.Layer
+
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+}
+
+// Predict applies the network to predict the next
+// observations and the rewards.
+func (n *NetModel) Predict(obs, actions anyvec.Vector, batch int) (next,
+	rewards anyvec.Vector) {
+	c := obs.Creator()
+	out := n.Net.Apply(anydiff.NewConst(joinBatches(obs, actions, batch)), batch).Output()
+	obsSize := obs.Len() / batch
+
+	var nextParts, rewardParts []anyvec.Vector
+	for i := 0; i < batch; i++ {
+		start := i * (obsSize + 1)
+		nextParts = append(nextParts, out.Slice(start, start+obsSize))
+		rewardParts = append(rewardParts, out.Slice(start+obsSize, start+obsSize+1))
+	}
+	next = c.Concat(nextParts...)
+	next.Add(obs)
+	return next, c.Concat(rewardParts...)
+}
+
+// Train computes a gradient to improve the model's
+// predictions on a batch of transitions.
+// Terminal transitions are ignored, since they have no
+// next observation.
+//
+// The gradient is for the negative mean squared error, so
+// it should be added to the parameters.
+// The mean squared error is also returned.
+//
+// If n.Params is empty or there are no non-terminal
+// transitions, an empty gradient and a nil cost are
+// returned.
+func (n *NetModel) Train(batch []*anyq.Transition) (anydiff.Grad, anyvec.Numeric) {
+	grad := anydiff.NewGrad(n.Params...)
+	if len(grad) == 0 {
+		return grad, nil
+	}
+	c := n.Params[0].Vector.Creator()
+
+	var inputs, targets []float64
+	var num int
+	for _, t := range batch {
+		if t.Done {
+			continue
+		}
+		inputs = append(append(inputs, t.Obs...), t.Action...)
+		for i, x := range t.Next {
+			targets = append(targets, x-t.Obs[i])
+		}
+		targets = append(targets, t.Reward)
+		num++
+	}
+	if num == 0 {
+		return grad, nil
+	}
+
+	out := n.Net.Apply(anydiff.NewConst(anyvec.Make(c, inputs)), num)
+	cost := anynet.MSE{}.Cost(anydiff.NewConst(anyvec.Make(c, targets)), out, num)
+	meanCost := anydiff.Scale(anydiff.Sum(cost), c.MakeNumeric(1/float64(num)))
+
+	upstream := c.MakeVector(1)
+	upstream.AddScalar(c.MakeNumeric(-1))
+	meanCost.Propagate(upstream, grad)
+
+	return grad, anyvec.Sum(meanCost.Output())
+}
+
+// joinBatches concatenates the vectors in two batches so
+// that each vector from the first batch is followed by the
+// corresponding vector from the second batch.
+func joinBatches(b1, b2 anyvec.Vector, batch int) anyvec.Vector {
+	size1 := b1.Len() / batch
+	size2 := b2.Len() / batch
+	var parts []anyvec.Vector
+	for i := 0; i < batch; i++ {
+		parts = append(parts, b1.Slice(i*size1, (i+1)*size1),
+			b2.Slice(i*size2, (i+1)*size2))
+	}
+	return b1.Creator().Concat(parts...)
+}
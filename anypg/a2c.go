@@ -0,0 +1,205 @@
+package anypg
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// A2CTerms represents the current value of the A2C
+// objective function in terms of the advantage, critic,
+// and regularization terms.
+// The sum of the three terms exactly represents the
+// objective function.
+type A2CTerms struct {
+	MeanAdvantage      anyvec.Numeric
+	MeanCritic         anyvec.Numeric
+	MeanRegularization anyvec.Numeric
+
+	// AuxLosses contains the mean loss of each auxiliary
+	// task.
+	// These are not part of the sum above.
+	AuxLosses []anyvec.Numeric
+}
+
+// A2C implements synchronous advantage actor-critic for
+// agents with a single network that has both a policy
+// head and a value head.
+//
+// The policy loss, the value loss, and the regularization
+// term are combined into one objective, so gradients are
+// computed with a single backward pass through the
+// network.
+type A2C struct {
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// Agent applies the network to a sequence of inputs.
+	// Each output vector is the action space parameters
+	// followed by a single value estimate.
+	Agent func(obses lazyseq.Rereader) lazyseq.Rereader
+
+	// ActionSpace determines log-likelihoods of actions.
+	ActionSpace anyrl.LogProber
+
+	// CriticWeight is the importance assigned to the
+	// critic's loss during training.
+	//
+	// If 0, a default of 1 is used.
+	CriticWeight float64
+
+	// Loss is used to fit the critic.
+	//
+	// If nil, anyrl.SquareLoss is used.
+	Loss anyrl.Loss
+
+	// Regularizer can be used to encourage exploration,
+	// e.g. with an entropy bonus.
+	Regularizer Regularizer
+
+	// Discount is the reward discount factor.
+	Discount float64
+
+	// Lambda is the GAE coefficient.
+	Lambda float64
+
+	// Auxiliary contains auxiliary tasks which are
+	// trained on the same rollouts alongside the main
+	// objective.
+	Auxiliary []AuxTerm
+
+	// GradSurgery, if true, combines the gradients of
+	// the main objective and the auxiliary tasks with
+	// PCGrad instead of summing them.
+	GradSurgery bool
+
+	// Clipper, if non-nil, is used to clip gradients
+	// before they are returned.
+	Clipper *GradClipper
+
+	// Telemetry, if non-nil, records the norms of the
+	// gradient before clipping.
+	Telemetry *Telemetry
+
+	// Modes, if non-nil, is switched to TrainMode before
+	// the policy is applied.
+	Modes *anyrl.ModeController
+}
+
+// Advantage computes the GAE estimator for a batch using
+// the value head.
+//
+// You should call this once per batch, before calling
+// Run.
+func (a *A2C) Advantage(r *anyrl.RolloutSet) lazyseq.Tape {
+	judger := &GAEJudger{
+		ValueFunc: a.valueFunc,
+		Discount:  a.Discount,
+		Lambda:    a.Lambda,
+	}
+	return judger.JudgeActions(r).Tape(r.Inputs.Creator())
+}
+
+// valueFunc applies the value head for an ActionJudger.
+func (a *A2C) valueFunc(inputs lazyseq.Rereader) <-chan *anyseq.Batch {
+	values := lazyseq.Map(a.Agent(inputs), func(v anydiff.Res, n int) anydiff.Res {
+		_, value := SplitHeads(v, n)
+		return value
+	})
+	return values.Forward()
+}
+
+// Run computes the gradient for an A2C step.
+// It takes a batch of rollouts and the precomputed
+// advantages for that batch.
+//
+// If a.Params is empty, then an empty gradient and nil
+// A2CTerms are returned.
+func (a *A2C) Run(r *anyrl.RolloutSet, adv lazyseq.Tape) (anydiff.Grad, *A2CTerms) {
+	if a.Modes != nil {
+		a.Modes.Set(anyrl.TrainMode)
+	}
+
+	grad := anydiff.NewGrad(a.Params...)
+	if len(grad) == 0 {
+		return grad, nil
+	}
+	c := r.Creator()
+	targetValues := (&QJudger{Discount: a.Discount, ValueFunc: a.valueFunc}).JudgeActions(r)
+
+	criticCoeff := -1.0
+	if a.CriticWeight != 0 {
+		criticCoeff *= a.CriticWeight
+	}
+
+	obj := lazyseq.MapN(
+		func(n int, v ...anydiff.Res) anydiff.Res {
+			actions, advantage, targets := v[1].Output(), v[2], v[3]
+			return anydiff.Pool(v[0], func(out anydiff.Res) anydiff.Res {
+				actor, critic := SplitHeads(out, n)
+
+				advTerm := anydiff.Mul(a.ActionSpace.LogProb(actor, actions, n), advantage)
+				criticTerm := anydiff.Scale(a.loss().Loss(critic, targets),
+					c.MakeNumeric(criticCoeff))
+
+				var regTerm anydiff.Res
+				if a.Regularizer != nil {
+					regTerm = a.Regularizer.Regularize(actor, n)
+				} else {
+					regTerm = anydiff.NewConst(c.MakeVector(n))
+				}
+
+				cm := anynet.ConcatMixer{}
+				return cm.Mix(cm.Mix(advTerm, criticTerm, n), regTerm, n)
+			})
+		},
+		a.Agent(lazyseq.TapeRereader(r.Inputs)),
+		lazyseq.TapeRereader(r.Actions),
+		lazyseq.TapeRereader(adv),
+		lazyseq.TapeRereader(targetValues.Tape(c)),
+	)
+	objective := lazyseq.Mean(obj)
+	objective.Propagate(anyvec.Ones(c, 3), grad)
+
+	auxLosses := applyAux(grad, r, a.Auxiliary, a.GradSurgery)
+
+	if a.Telemetry != nil {
+		a.Telemetry.RecordGrad(grad)
+	}
+	if a.Clipper != nil {
+		a.Clipper.Clip(grad)
+	}
+
+	return grad, &A2CTerms{
+		MeanAdvantage:      anyvec.Sum(objective.Output().Slice(0, 1)),
+		MeanCritic:         anyvec.Sum(objective.Output().Slice(1, 2)),
+		MeanRegularization: anyvec.Sum(objective.Output().Slice(2, 3)),
+		AuxLosses:          auxLosses,
+	}
+}
+
+func (a *A2C) loss() anyrl.Loss {
+	if a.Loss == nil {
+		return anyrl.SquareLoss{}
+	}
+	return a.Loss
+}
+
+// SplitHeads splits a batch of outputs from a network
+// with a policy head and a value head.
+// Each output vector is assumed to contain the policy
+// head's outputs followed by a single value.
+func SplitHeads(out anydiff.Res, n int) (policy, value anydiff.Res) {
+	size := out.Output().Len() / n
+	var policyParts, valueParts []anydiff.Res
+	for i := 0; i < n; i++ {
+		start := i * size
+		policyParts = append(policyParts, anydiff.Slice(out, start, start+size-1))
+		valueParts = append(valueParts, anydiff.Slice(out, start+size-1, start+size))
+	}
+	return anydiff.Concat(policyParts...), anydiff.Concat(valueParts...)
+}
@@ -0,0 +1,46 @@
+package anypg
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/lazyseq"
+)
+
+func TestA2CDirection(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+
+	// The first two outputs are the policy head and the
+	// last output is the value head.
+	// Since the layer is linear, the heads have separate
+	// parameters, so both terms should improve.
+	block := &anyrnn.LayerBlock{Layer: anynet.NewFC(c, 3, 3)}
+	a2c := &A2C{
+		Params: block.Parameters(),
+		Agent: func(in lazyseq.Rereader) lazyseq.Rereader {
+			return lazyseq.Lazify(anyrnn.Map(lazyseq.Unlazify(in), block))
+		},
+		ActionSpace: anyrl.Softmax{},
+		Discount:    0.9,
+		Lambda:      0.95,
+	}
+	adv := a2c.Advantage(r)
+
+	grad, oldTerms := a2c.Run(r, adv)
+	grad.Scale(c.MakeNumeric(1e-3))
+	grad.AddToVars()
+	_, newTerms := a2c.Run(r, adv)
+
+	if newTerms.MeanAdvantage.(float64) <= oldTerms.MeanAdvantage.(float64) {
+		t.Errorf("advantage term went from %f to %f", oldTerms.MeanAdvantage,
+			newTerms.MeanAdvantage)
+	}
+	if newTerms.MeanCritic.(float64) <= oldTerms.MeanCritic.(float64) {
+		t.Errorf("critic term went from %f to %f", oldTerms.MeanCritic,
+			newTerms.MeanCritic)
+	}
+}
@@ -0,0 +1,152 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+	"github.com/unixpickle/lazyseq/lazyrnn"
+)
+
+// DefaultACKTRMaxStep is the default maximum step size
+// for ACKTR.
+const DefaultACKTRMaxStep = 0.25
+
+// ACKTRActionSpace is an action space which can be used
+// by ACKTR.
+//
+// Actions are sampled from the policy to estimate the
+// true Fisher matrix rather than the empirical one.
+type ACKTRActionSpace interface {
+	anyrl.LogProber
+	anyrl.Sampler
+}
+
+// ACKTR computes approximate natural gradient steps using
+// Kronecker-factored curvature estimates, as in
+// https://arxiv.org/abs/1708.05144.
+//
+// The policy's FC layers must be wrapped for K-FAC (see
+// WrapKFAC).
+// Parameters outside of the KFAC layers receive regular
+// gradient steps.
+type ACKTR struct {
+	Policy      anyrnn.Block
+	Params      []*anydiff.Var
+	ActionSpace ACKTRActionSpace
+	KFAC        *KFAC
+
+	// ApplyPolicy applies a policy to an input sequence.
+	// If nil, back-propagation through time is used.
+	ApplyPolicy func(s lazyseq.Rereader, b anyrnn.Block) lazyseq.Rereader
+
+	// ActionJudger is used to judge actions.
+	//
+	// If nil, TotalJudger is used.
+	ActionJudger ActionJudger
+
+	// Regularizer is used to regularize the action space.
+	//
+	// If nil, no regularization is used.
+	Regularizer Regularizer
+
+	// TargetKL is the trust region size used to scale
+	// the natural gradient.
+	//
+	// If 0, DefaultTargetKL is used.
+	TargetKL float64
+
+	// MaxStep is the largest step size which may be
+	// applied to the natural gradient.
+	//
+	// If 0, DefaultACKTRMaxStep is used.
+	MaxStep float64
+}
+
+// Run updates the curvature estimates and computes a step
+// to improve the agent's performance on the rollouts.
+func (a *ACKTR) Run(r *anyrl.RolloutSet) anydiff.Grad {
+	a.KFAC.Record(func() {
+		a.fisherPass(r)
+	})
+
+	pg := &PG{
+		Policy: func(in lazyseq.Rereader) lazyseq.Rereader {
+			return a.apply(in)
+		},
+		Params:       a.Params,
+		ActionSpace:  a.ActionSpace,
+		ActionJudger: a.actionJudger(),
+		Regularizer:  a.Regularizer,
+	}
+	grad := pg.Run(r)
+	if len(grad) == 0 || allZeros(grad) {
+		return grad
+	}
+
+	natural := copyGrad(grad)
+	a.KFAC.Precondition(natural)
+
+	// Under the K-FAC approximation, the quadratic form of
+	// the Fisher matrix is the dot product between the
+	// natural gradient and the gradient.
+	c := r.Creator()
+	quadratic := c.Float64(dotGrad(natural, grad))
+	stepSize := a.maxStep()
+	if quadratic > 0 {
+		stepSize = math.Min(stepSize, math.Sqrt(2*a.targetKL()/quadratic))
+	}
+	natural.Scale(c.MakeNumeric(stepSize))
+	return natural
+}
+
+// fisherPass back-propagates the log-likelihoods of
+// actions sampled from the policy so that the KFAC layers
+// can record curvature statistics.
+func (a *ACKTR) fisherPass(r *anyrl.RolloutSet) {
+	c := r.Creator()
+	logProbs := lazyseq.Map(a.apply(lazyseq.TapeRereader(r.Inputs)),
+		func(out anydiff.Res, n int) anydiff.Res {
+			sampled := a.ActionSpace.Sample(out.Output(), n)
+			return a.ActionSpace.LogProb(out, sampled, n)
+		})
+	mean := lazyseq.Mean(logProbs)
+
+	// Scaling by the number of timesteps undoes the mean,
+	// giving per-timestep gradients to the KFAC layers.
+	upstream := anyvec.Make(c, []float64{float64(r.NumSteps())})
+	mean.Propagate(upstream, anydiff.NewGrad(a.Params...))
+}
+
+func (a *ACKTR) apply(in lazyseq.Rereader) lazyseq.Rereader {
+	if a.ApplyPolicy == nil {
+		tape, writer := lazyseq.ReferenceTape(in.Creator())
+		return lazyseq.SeqRereader(lazyrnn.BPTT(in, a.Policy), tape, writer)
+	} else {
+		return a.ApplyPolicy(in, a.Policy)
+	}
+}
+
+func (a *ACKTR) actionJudger() ActionJudger {
+	if a.ActionJudger == nil {
+		return &TotalJudger{Normalize: true}
+	}
+	return a.ActionJudger
+}
+
+func (a *ACKTR) targetKL() float64 {
+	if a.TargetKL == 0 {
+		return DefaultTargetKL
+	}
+	return a.TargetKL
+}
+
+func (a *ACKTR) maxStep() float64 {
+	if a.MaxStep == 0 {
+		return DefaultACKTRMaxStep
+	}
+	return a.MaxStep
+}
@@ -0,0 +1,62 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/lazyseq"
+)
+
+func TestACKTRStepSize(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+
+	for _, limitKL := range []bool{false, true} {
+		block := &anyrnn.LayerBlock{
+			Layer: anynet.Net{
+				anynet.NewFC(c, 3, 2),
+				anynet.Tanh,
+				anynet.NewFC(c, 2, 2),
+			},
+		}
+		acktr := &ACKTR{
+			Policy:      block,
+			Params:      block.Parameters(),
+			ActionSpace: anyrl.Softmax{},
+			KFAC:        WrapKFAC(block),
+		}
+		if limitKL {
+			acktr.TargetKL = 1e-8
+			acktr.MaxStep = 1e8
+		} else {
+			acktr.TargetKL = 1e8
+			acktr.MaxStep = 1e-3
+		}
+		step := acktr.Run(r)
+
+		pg := &PG{
+			Policy: func(in lazyseq.Rereader) lazyseq.Rereader {
+				return acktr.apply(in)
+			},
+			Params:      acktr.Params,
+			ActionSpace: acktr.ActionSpace,
+		}
+		grad := pg.Run(r)
+		natural := copyGrad(grad)
+		acktr.KFAC.Precondition(natural)
+
+		stepSize := GradNorm(step) / GradNorm(natural)
+		kl := 0.5 * stepSize * stepSize * c.Float64(dotGrad(natural, grad))
+		if limitKL {
+			if math.Abs(kl-acktr.TargetKL)/acktr.TargetKL > 1e-3 {
+				t.Errorf("expected approximate KL %e but got %e", acktr.TargetKL, kl)
+			}
+		} else if math.Abs(stepSize-acktr.MaxStep)/acktr.MaxStep > 1e-3 {
+			t.Errorf("expected step size %e but got %e", acktr.MaxStep, stepSize)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// An AuxTask is an auxiliary task, as in UNREAL, which is
+// trained alongside the main objective to improve the
+// agent's representations.
+//
+// See https://arxiv.org/abs/1611.05397.
+type AuxTask interface {
+	// AuxLoss computes the mean loss of the task on the
+	// rollouts, as a one-component result.
+	AuxLoss(r *anyrl.RolloutSet) anydiff.Res
+}
+
+// AuxTerm is an auxiliary task with a coefficient for its
+// loss.
+type AuxTerm struct {
+	Task  AuxTask
+	Coeff float64
+}
+
+// applyAux adds the gradients of the auxiliary losses to
+// an ascent gradient and returns the losses.
+//
+// If surgery is true, the gradients are combined with
+// PCGrad rather than summed.
+func applyAux(grad anydiff.Grad, r *anyrl.RolloutSet, terms []AuxTerm,
+	surgery bool) []anyvec.Numeric {
+	grads := []anydiff.Grad{grad}
+	if surgery && len(terms) > 0 {
+		grads[0] = copyGrad(grad)
+	}
+	var res []anyvec.Numeric
+	for _, term := range terms {
+		termGrad := grad
+		if surgery {
+			termGrad = zeroGrad(grad)
+			grads = append(grads, termGrad)
+		}
+		loss := term.Task.AuxLoss(r)
+		c := loss.Output().Creator()
+		loss.Propagate(anyvec.Make(c, []float64{-term.Coeff}), termGrad)
+		res = append(res, anyvec.Sum(loss.Output()))
+	}
+	if surgery && len(terms) > 0 {
+		setGrad(grad, PCGrad(grads))
+	}
+	return res
+}
+
+// ValueReplay is an AuxTask which fits a value function
+// to the discounted returns of the rollouts.
+type ValueReplay struct {
+	// Critic applies the value function to a sequence of
+	// inputs.
+	Critic func(obses lazyseq.Rereader) lazyseq.Rereader
+
+	// Discount is the reward discount factor.
+	Discount float64
+
+	// Loss is used to fit the value function.
+	//
+	// If nil, anyrl.SquareLoss is used.
+	Loss anyrl.Loss
+}
+
+// AuxLoss computes the value regression loss.
+func (v *ValueReplay) AuxLoss(r *anyrl.RolloutSet) anydiff.Res {
+	c := r.Creator()
+	loss := v.Loss
+	if loss == nil {
+		loss = anyrl.SquareLoss{}
+	}
+	judger := &QJudger{
+		Discount: v.Discount,
+		ValueFunc: func(inputs lazyseq.Rereader) <-chan *anyseq.Batch {
+			return v.Critic(inputs).Forward()
+		},
+	}
+	targets := judger.JudgeActions(r).Tape(c)
+	return lazyseq.Mean(lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		return loss.Loss(v[0], v[1])
+	}, v.Critic(lazyseq.TapeRereader(r.Inputs)), lazyseq.TapeRereader(targets)))
+}
+
+// RewardPrediction is an AuxTask which classifies the
+// reward at each timestep as negative, zero, or positive.
+type RewardPrediction struct {
+	// Predict produces three logits (for negative, zero,
+	// and positive rewards) for each input.
+	Predict func(obses lazyseq.Rereader) lazyseq.Rereader
+}
+
+// AuxLoss computes the classification loss.
+func (p *RewardPrediction) AuxLoss(r *anyrl.RolloutSet) anydiff.Res {
+	c := r.Creator()
+	classes := make([][][]float64, len(r.Rewards))
+	for i, seq := range r.Rewards {
+		for _, rew := range seq {
+			oneHot := make([]float64, 3)
+			if rew < 0 {
+				oneHot[0] = 1
+			} else if rew == 0 {
+				oneHot[1] = 1
+			} else {
+				oneHot[2] = 1
+			}
+			classes[i] = append(classes[i], oneHot)
+		}
+	}
+	return lazyseq.Mean(lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		logProbs := anydiff.LogSoftmax(v[0], 3)
+		return anydiff.Scale(batchedDot(logProbs, v[1], n), c.MakeNumeric(-1))
+	}, p.Predict(lazyseq.TapeRereader(r.Inputs)), lazyseq.TapeRereader(vectorTape(c, classes))))
+}
+
+// PixelControl is an AuxTask which learns to maximally
+// change the pixels in each cell of a grid over the
+// observations.
+//
+// Observations are images stored in row-major order with
+// interleaved channels.
+// The pseudo-reward for a cell is the mean absolute change
+// in that cell's pixels between consecutive observations.
+// The Q-values are fit to the discounted sums of the
+// pseudo-rewards, which assumes the rollouts are
+// on-policy.
+type PixelControl struct {
+	// Q produces Q-values for each input.
+	// For each cell (in row-major order), there is one
+	// value per action.
+	Q func(obses lazyseq.Rereader) lazyseq.Rereader
+
+	Width  int
+	Height int
+	Depth  int
+
+	// CellSize is the width and height of each cell.
+	// The image dimensions must be divisible by it.
+	CellSize int
+
+	// Discount is the pseudo-reward discount factor.
+	Discount float64
+}
+
+// AuxLoss computes the Q-value regression loss.
+func (p *PixelControl) AuxLoss(r *anyrl.RolloutSet) anydiff.Res {
+	c := r.Creator()
+	numCells := (p.Width / p.CellSize) * (p.Height / p.CellSize)
+	inputs := episodeSteps(r.Inputs, len(r.Rewards))
+
+	targets := make([][][]float64, len(inputs))
+	for i, seq := range inputs {
+		sum := make([]float64, numCells)
+		targets[i] = make([][]float64, len(seq))
+		for t := len(seq) - 1; t >= 0; t-- {
+			var rew []float64
+			if t+1 < len(seq) {
+				rew = p.cellChanges(seq[t], seq[t+1])
+			} else {
+				rew = make([]float64, numCells)
+			}
+			for j, x := range rew {
+				sum[j] = x + p.Discount*sum[j]
+			}
+			targets[i][t] = append([]float64{}, sum...)
+		}
+	}
+
+	return lazyseq.Mean(lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		qValues, actions, targets := v[0], v[1].Output(), v[2]
+		numActions := actions.Len() / n
+		actionData := c.Float64Slice(actions.Data())
+		var maskData []float64
+		for i := 0; i < n; i++ {
+			for j := 0; j < numCells; j++ {
+				maskData = append(maskData, actionData[i*numActions:(i+1)*numActions]...)
+			}
+		}
+		mask := anydiff.NewConst(anyvec.Make(c, maskData))
+		selected := batchedDot(qValues, mask, n*numCells)
+		losses := anyrl.SquareLoss{}.Loss(selected, targets)
+		return anydiff.SumCols(&anydiff.Matrix{Data: losses, Rows: n, Cols: numCells})
+	}, p.Q(lazyseq.TapeRereader(r.Inputs)), lazyseq.TapeRereader(r.Actions),
+		lazyseq.TapeRereader(vectorTape(c, targets))))
+}
+
+func (p *PixelControl) cellChanges(obs, next []float64) []float64 {
+	cellsX := p.Width / p.CellSize
+	res := make([]float64, cellsX*(p.Height/p.CellSize))
+	for y := 0; y < p.Height; y++ {
+		for x := 0; x < p.Width; x++ {
+			cell := (y/p.CellSize)*cellsX + x/p.CellSize
+			for z := 0; z < p.Depth; z++ {
+				idx := (y*p.Width+x)*p.Depth + z
+				res[cell] += math.Abs(next[idx] - obs[idx])
+			}
+		}
+	}
+	scale := 1 / float64(p.CellSize*p.CellSize*p.Depth)
+	for i := range res {
+		res[i] *= scale
+	}
+	return res
+}
+
+func batchedDot(vecs1, vecs2 anydiff.Res, batchSize int) anydiff.Res {
+	return anydiff.SumCols(&anydiff.Matrix{
+		Data: anydiff.Mul(vecs1, vecs2),
+		Rows: batchSize,
+		Cols: vecs1.Output().Len() / batchSize,
+	})
+}
+
+// episodeSteps splits a tape up into the vectors for each
+// timestep of each episode.
+func episodeSteps(t lazyseq.Tape, numEpisodes int) [][][]float64 {
+	c := t.Creator()
+	res := make([][][]float64, numEpisodes)
+	for batch := range t.ReadTape(0, -1) {
+		n := batch.NumPresent()
+		if n == 0 {
+			continue
+		}
+		data := c.Float64Slice(batch.Packed.Data())
+		size := len(data) / n
+		var offset int
+		for i, pres := range batch.Present {
+			if pres {
+				res[i] = append(res[i], data[offset:offset+size])
+				offset += size
+			}
+		}
+	}
+	return res
+}
+
+// vectorTape creates a tape with a vector for each
+// timestep of each episode.
+func vectorTape(c anyvec.Creator, seqs [][][]float64) lazyseq.Tape {
+	res, writer := lazyseq.ReferenceTape(c)
+	for t := 0; true; t++ {
+		present := make([]bool, len(seqs))
+		var packed []float64
+		for i, seq := range seqs {
+			if t < len(seq) {
+				present[i] = true
+				packed = append(packed, seq[t]...)
+			}
+		}
+		if len(packed) == 0 {
+			break
+		}
+		writer <- &anyseq.Batch{Packed: anyvec.Make(c, packed), Present: present}
+	}
+	close(writer)
+	return res
+}
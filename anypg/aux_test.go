@@ -0,0 +1,43 @@
+package anypg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestPixelControlChanges(t *testing.T) {
+	p := &PixelControl{Width: 4, Height: 2, Depth: 1, CellSize: 2}
+	obs := []float64{
+		0, 0, 0, 0,
+		0, 0, 0, 0,
+	}
+	next := []float64{
+		1, 0, 0, 0,
+		-1, 2, 0, 0,
+	}
+	actual := p.cellChanges(obs, next)
+	expected := []float64{1, 0}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
+
+func TestVectorTape(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	seqs := [][][]float64{
+		{{1, 2}, {3, 4}},
+		{},
+		{{5, 6}},
+	}
+	actual := episodeSteps(vectorTape(c, seqs), len(seqs))
+	expected := [][][]float64{
+		{{1, 2}, {3, 4}},
+		nil,
+		{{5, 6}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
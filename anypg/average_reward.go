@@ -0,0 +1,121 @@
+package anypg
+
+import (
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/lazyseq"
+)
+
+// DifferentialJudger is an ActionJudger for the
+// average-reward setting, which is suitable for
+// continuing tasks without discounting.
+//
+// It maintains an online estimate of the average reward
+// per timestep and judges actions using differential
+// rewards, i.e. rewards minus the average reward.
+type DifferentialJudger struct {
+	// ValueFunc, if non-nil, estimates the differential
+	// value function.
+	// It is used like GAEJudger.ValueFunc, and the
+	// judgements are undiscounted GAE estimates based on
+	// the differential rewards.
+	//
+	// If nil, differential returns are used.
+	ValueFunc func(inputs lazyseq.Rereader) <-chan *anyseq.Batch
+
+	// Lambda is the GAE coefficient.
+	// It is only used if ValueFunc is non-nil.
+	Lambda float64
+
+	// StepSize is the step size for updating the average
+	// reward estimate after each batch.
+	//
+	// If 0, the estimate is the mean reward over every
+	// timestep seen so far.
+	StepSize float64
+
+	avgReward float64
+	numSteps  int
+}
+
+// JudgeActions updates the average reward estimate with
+// the rewards from the rollouts and then judges the
+// actions using differential rewards.
+//
+// If ValueFunc is set, truncated episodes are
+// bootstrapped like in GAEJudger.
+// Otherwise, there is nothing to bootstrap from, so
+// truncated episodes are treated like terminated ones.
+func (d *DifferentialJudger) JudgeActions(r *anyrl.RolloutSet) anyrl.Rewards {
+	d.update(r.Rewards)
+	if d.ValueFunc == nil {
+		return d.DifferentialReturns(r.Rewards)
+	}
+
+	estimatedValues := bootstrapValues(d.ValueFunc, r)
+
+	var res anyrl.Rewards
+	for i, rewSeq := range r.Rewards {
+		valSeq := estimatedValues[i]
+		advantages := make([]float64, len(rewSeq))
+		var accumulation float64
+		for t := len(rewSeq) - 1; t >= 0; t-- {
+			delta := rewSeq[t] - d.avgReward - valSeq[t]
+			if t+1 < len(valSeq) {
+				delta += valSeq[t+1]
+			} else if r.IsTruncated(i) {
+				delta += valSeq[t]
+			}
+			accumulation *= d.Lambda
+			accumulation += delta
+			advantages[t] = accumulation
+		}
+		res = append(res, advantages)
+	}
+	return res
+}
+
+// DifferentialReturns computes the sum of the future
+// differential rewards at each timestep.
+//
+// This can be used as a target for training a
+// differential value function.
+func (d *DifferentialJudger) DifferentialReturns(rewards anyrl.Rewards) anyrl.Rewards {
+	res := make(anyrl.Rewards, len(rewards))
+	for i, seq := range rewards {
+		res[i] = make([]float64, len(seq))
+		var sum float64
+		for t := len(seq) - 1; t >= 0; t-- {
+			sum += seq[t] - d.avgReward
+			res[i][t] = sum
+		}
+	}
+	return res
+}
+
+// AverageReward returns the current estimate of the
+// average reward per timestep.
+func (d *DifferentialJudger) AverageReward() float64 {
+	return d.avgReward
+}
+
+func (d *DifferentialJudger) update(rewards anyrl.Rewards) {
+	var sum float64
+	var count int
+	for _, seq := range rewards {
+		for _, x := range seq {
+			sum += x
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+	batchMean := sum / float64(count)
+	if d.StepSize != 0 {
+		d.avgReward += d.StepSize * (batchMean - d.avgReward)
+	} else {
+		d.numSteps += count
+		d.avgReward += (batchMean - d.avgReward) * float64(count) / float64(d.numSteps)
+	}
+}
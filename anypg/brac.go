@@ -0,0 +1,239 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// BRACActionSpace implements the action space methods
+// needed for behavior-regularized actor-critic.
+type BRACActionSpace interface {
+	anyrl.LogProber
+	anyrl.KLer
+}
+
+// BRACTerms represents the current value of the terms in
+// the BRAC objective function.
+type BRACTerms struct {
+	MeanAdvantage anyvec.Numeric
+	MeanCritic    anyvec.Numeric
+
+	// MeanKL is the mean KL divergence between the
+	// behavior policy and the actor.
+	MeanKL anyvec.Numeric
+
+	// MeanBehavior is the mean log-likelihood of the
+	// logged actions under the behavior model.
+	MeanBehavior anyvec.Numeric
+}
+
+// BRAC implements an offline behavior-regularized
+// actor-critic which trains on logged rollouts without
+// interacting with an environment.
+//
+// The actor is trained with importance-weighted advantages
+// and is penalized for diverging from an estimate of the
+// behavior policy which produced the rollouts.
+//
+// See https://arxiv.org/abs/1911.11361.
+type BRAC struct {
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// Actor is the policy being trained.
+	Actor func(obses lazyseq.Rereader) lazyseq.Rereader
+
+	// Critic estimates the value function.
+	Critic func(obses lazyseq.Rereader) lazyseq.Rereader
+
+	// Behavior estimates the action space parameters of
+	// the behavior policy.
+	// It is trained by maximum likelihood on the logged
+	// actions.
+	//
+	// If nil, the AgentOuts of the rollouts are used as
+	// the behavior policy.
+	Behavior func(obses lazyseq.Rereader) lazyseq.Rereader
+
+	// ActionSpace determines log-likelihoods of actions
+	// and divergences between policies.
+	ActionSpace BRACActionSpace
+
+	// CriticWeight is the importance assigned to the
+	// critic's loss during training.
+	//
+	// If 0, a default of 1 is used.
+	CriticWeight float64
+
+	// Loss is used to fit the critic.
+	//
+	// If nil, anyrl.SquareLoss is used.
+	Loss anyrl.Loss
+
+	// Discount is the reward discount factor.
+	Discount float64
+
+	// Lambda is the GAE coefficient.
+	Lambda float64
+
+	// KLWeight is the coefficient of the KL penalty.
+	// If TargetKL is set, this is the initial value of
+	// the learned Lagrange multiplier.
+	KLWeight float64
+
+	// TargetKL, if non-zero, causes the KL weight to be
+	// learned via dual gradient descent so that the mean
+	// KL divergence stays near TargetKL.
+	TargetKL float64
+
+	// MultiplierStepSize is the step size for updating
+	// the Lagrange multiplier.
+	// It is only used if TargetKL is non-zero.
+	MultiplierStepSize float64
+
+	// Clipper, if non-nil, is used to clip gradients
+	// before they are returned.
+	Clipper *GradClipper
+
+	multiplier    float64
+	hasMultiplier bool
+}
+
+// Advantage computes the GAE estimator for a batch.
+//
+// You should call this once per batch, just like with
+// PPO.Advantage.
+func (b *BRAC) Advantage(r *anyrl.RolloutSet) lazyseq.Tape {
+	judger := &GAEJudger{
+		ValueFunc: b.valueFunc,
+		Discount:  b.Discount,
+		Lambda:    b.Lambda,
+	}
+	return judger.JudgeActions(r).Tape(r.Inputs.Creator())
+}
+
+// valueFunc applies the critic for an ActionJudger.
+func (b *BRAC) valueFunc(inputs lazyseq.Rereader) <-chan *anyseq.Batch {
+	return b.Critic(inputs).Forward()
+}
+
+// Run computes the gradient for a BRAC step.
+//
+// If TargetKL is set, the KL weight is updated according
+// to the mean KL divergence after the gradient has been
+// computed.
+//
+// If b.Params is empty, then an empty gradient and nil
+// BRACTerms are returned.
+func (b *BRAC) Run(r *anyrl.RolloutSet, adv lazyseq.Tape) (anydiff.Grad, *BRACTerms) {
+	grad := anydiff.NewGrad(b.Params...)
+	if len(grad) == 0 {
+		return grad, nil
+	}
+	c := r.Creator()
+	targetValues := (&QJudger{Discount: b.Discount, ValueFunc: b.valueFunc}).JudgeActions(r)
+	inputs := lazyseq.TapeRereader(r.Inputs)
+
+	var behavior lazyseq.Rereader
+	if b.Behavior != nil {
+		behavior = b.Behavior(inputs)
+	} else {
+		behavior = lazyseq.TapeRereader(r.AgentOuts)
+	}
+
+	obj := lazyseq.MapN(
+		func(n int, v ...anydiff.Res) anydiff.Res {
+			actor, critic, behavior := v[0], v[1], v[2]
+			actions, advantage, targets := v[3].Output(), v[4], v[5]
+
+			constBehavior := anydiff.NewConst(behavior.Output())
+			ratios := anydiff.Exp(anydiff.Sub(
+				b.ActionSpace.LogProb(actor, actions, n),
+				b.ActionSpace.LogProb(constBehavior, actions, n),
+			))
+			advTerm := anydiff.Mul(ratios, advantage)
+
+			criticCoeff := -1.0
+			if b.CriticWeight != 0 {
+				criticCoeff *= b.CriticWeight
+			}
+			criticTerm := anydiff.Scale(
+				b.loss().Loss(critic, targets),
+				c.MakeNumeric(criticCoeff),
+			)
+
+			klTerm := b.ActionSpace.KL(constBehavior, actor, n)
+
+			var behaviorTerm anydiff.Res
+			if b.Behavior != nil {
+				behaviorTerm = b.ActionSpace.LogProb(behavior, actions, n)
+			} else {
+				behaviorTerm = anydiff.NewConst(c.MakeVector(n))
+			}
+
+			cm := anynet.ConcatMixer{}
+			return cm.Mix(
+				cm.Mix(advTerm, criticTerm, n),
+				cm.Mix(klTerm, behaviorTerm, n),
+				n,
+			)
+		},
+		b.Actor(inputs),
+		b.Critic(inputs),
+		behavior,
+		lazyseq.TapeRereader(r.Actions),
+		lazyseq.TapeRereader(adv),
+		lazyseq.TapeRereader(targetValues.Tape(c)),
+	)
+	objective := lazyseq.Mean(obj)
+
+	weight := b.klWeight()
+	objective.Propagate(anyvec.Make(c, []float64{1, 1, -weight, 1}), grad)
+	if b.Clipper != nil {
+		b.Clipper.Clip(grad)
+	}
+
+	terms := &BRACTerms{
+		MeanAdvantage: anyvec.Sum(objective.Output().Slice(0, 1)),
+		MeanCritic:    anyvec.Sum(objective.Output().Slice(1, 2)),
+		MeanKL:        anyvec.Sum(objective.Output().Slice(2, 3)),
+		MeanBehavior:  anyvec.Sum(objective.Output().Slice(3, 4)),
+	}
+
+	if b.TargetKL != 0 {
+		meanKL := c.Float64(terms.MeanKL)
+		b.multiplier = math.Max(0, weight+b.MultiplierStepSize*(meanKL-b.TargetKL))
+	}
+
+	return grad, terms
+}
+
+// Multiplier returns the current KL weight.
+func (b *BRAC) Multiplier() float64 {
+	return b.klWeight()
+}
+
+func (b *BRAC) klWeight() float64 {
+	if b.TargetKL == 0 {
+		return b.KLWeight
+	}
+	if !b.hasMultiplier {
+		b.multiplier = b.KLWeight
+		b.hasMultiplier = true
+	}
+	return b.multiplier
+}
+
+func (b *BRAC) loss() anyrl.Loss {
+	if b.Loss == nil {
+		return anyrl.SquareLoss{}
+	}
+	return b.Loss
+}
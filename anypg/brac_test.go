@@ -0,0 +1,73 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestBRACPenalty(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+	actor := anynet.NewFC(c, 3, 2)
+	r.AgentOuts = layerTape(anynet.NewFC(c, 3, 2), r.Inputs)
+
+	// With zero advantages and only actor parameters, the
+	// gradient comes entirely from the KL penalty.
+	brac := &BRAC{
+		Params:      actor.Parameters(),
+		Actor:       layerPolicy(actor),
+		Critic:      layerPolicy(anynet.NewFC(c, 3, 1)),
+		ActionSpace: anyrl.Softmax{},
+		Discount:    0.9,
+		Lambda:      0.95,
+		KLWeight:    1,
+	}
+	adv := anyrl.Rewards{{0, 0, 0}, {}, make([]float64, 11)}.Tape(c)
+
+	grad, oldTerms := brac.Run(r, adv)
+	grad.Scale(c.MakeNumeric(0.1))
+	grad.AddToVars()
+	_, newTerms := brac.Run(r, adv)
+	if newTerms.MeanKL.(float64) >= oldTerms.MeanKL.(float64) {
+		t.Errorf("KL went from %f to %f", oldTerms.MeanKL, newTerms.MeanKL)
+	}
+}
+
+func TestBRACMultiplier(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+	actor := anynet.NewFC(c, 3, 2)
+	r.AgentOuts = layerTape(anynet.NewFC(c, 3, 2), r.Inputs)
+	adv := anyrl.Rewards{{0, 0, 0}, {}, make([]float64, 11)}.Tape(c)
+
+	for _, above := range []bool{true, false} {
+		brac := &BRAC{
+			Params:             actor.Parameters(),
+			Actor:              layerPolicy(actor),
+			Critic:             layerPolicy(anynet.NewFC(c, 3, 1)),
+			ActionSpace:        anyrl.Softmax{},
+			Discount:           0.9,
+			Lambda:             0.95,
+			KLWeight:           1,
+			TargetKL:           10,
+			MultiplierStepSize: 0.01,
+		}
+		if above {
+			brac.TargetKL = 1e-8
+		}
+		_, terms := brac.Run(r, adv)
+		kl := terms.MeanKL.(float64)
+		expected := 1 + 0.01*(kl-brac.TargetKL)
+		if actual := brac.Multiplier(); math.Abs(actual-expected) > 1e-8 {
+			t.Errorf("expected multiplier %f but got %f", expected, actual)
+		} else if above && actual <= 1 {
+			t.Errorf("multiplier should increase when KL is above target")
+		} else if !above && actual >= 1 {
+			t.Errorf("multiplier should decrease when KL is below target")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package anypg
+
+import (
+	"sync"
+
+	"github.com/unixpickle/anydiff"
+)
+
+// A ConjGradCache stores the solution from the previous
+// run of Conjugate Gradients so that the next run can
+// start from it instead of from zero.
+//
+// Since the natural gradient changes slowly between
+// training iterations, this often saves iterations.
+type ConjGradCache struct {
+	lock     sync.Mutex
+	solution anydiff.Grad
+}
+
+// Reset discards the cached solution.
+func (c *ConjGradCache) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.solution = nil
+}
+
+// initialGuess returns a copy of the cached solution, or
+// nil if it does not cover the same parameters as grad.
+func (c *ConjGradCache) initialGuess(grad anydiff.Grad) anydiff.Grad {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.solution) != len(grad) {
+		return nil
+	}
+	for param, vec := range grad {
+		if old, ok := c.solution[param]; !ok || old.Len() != vec.Len() {
+			return nil
+		}
+	}
+	return copyGrad(c.solution)
+}
+
+func (c *ConjGradCache) store(solution anydiff.Grad) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.solution = copyGrad(solution)
+}
@@ -0,0 +1,40 @@
+package anypg
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestConjGradCache(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	v1 := anydiff.NewVar(anyvec.Make(c, []float64{1, 2}))
+	v2 := anydiff.NewVar(anyvec.Make(c, []float64{3}))
+
+	cache := &ConjGradCache{}
+	if cache.initialGuess(anydiff.NewGrad(v1)) != nil {
+		t.Error("empty cache should not give a guess")
+	}
+
+	solution := anydiff.Grad{v1: anyvec.Make(c, []float64{4, 5})}
+	cache.store(solution)
+	solution[v1].Scale(c.MakeNumeric(0))
+
+	guess := cache.initialGuess(anydiff.NewGrad(v1))
+	if guess == nil {
+		t.Fatal("expected a guess")
+	}
+	if actual := guess[v1].Data().([]float64); actual[0] != 4 || actual[1] != 5 {
+		t.Errorf("unexpected guess: %v", actual)
+	}
+	if cache.initialGuess(anydiff.NewGrad(v1, v2)) != nil {
+		t.Error("guess should require the same parameters")
+	}
+
+	cache.Reset()
+	if cache.initialGuess(anydiff.NewGrad(v1)) != nil {
+		t.Error("reset cache should not give a guess")
+	}
+}
@@ -0,0 +1,67 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+)
+
+// GradClipper clips gradients to prevent excessively
+// large steps, which are common with recurrent policies.
+//
+// If both MaxNorm and MaxValue are set, value clipping is
+// performed before norm clipping.
+type GradClipper struct {
+	// MaxNorm, if non-zero, is the maximum norm of the
+	// entire gradient.
+	// Larger gradients are scaled down to this norm.
+	MaxNorm float64
+
+	// MaxValue, if non-zero, is the maximum absolute
+	// value of any gradient component.
+	MaxValue float64
+}
+
+// Clip clips the gradient in place.
+func (g *GradClipper) Clip(grad anydiff.Grad) {
+	if g.MaxValue != 0 {
+		ClipGradValues(grad, g.MaxValue)
+	}
+	if g.MaxNorm != 0 {
+		ClipGradNorm(grad, g.MaxNorm)
+	}
+}
+
+// GradNorm computes the Euclidean norm of the entire
+// gradient.
+func GradNorm(grad anydiff.Grad) float64 {
+	var sqSum float64
+	for _, v := range grad {
+		sqSum += math.Pow(v.Creator().Float64(anyvec.Norm(v)), 2)
+	}
+	return math.Sqrt(sqSum)
+}
+
+// ClipGradNorm scales the gradient so that its norm is at
+// most maxNorm.
+//
+// It returns the norm of the gradient before clipping.
+func ClipGradNorm(grad anydiff.Grad, maxNorm float64) float64 {
+	norm := GradNorm(grad)
+	if norm > maxNorm {
+		for _, v := range grad {
+			v.Scale(v.Creator().MakeNumeric(maxNorm / norm))
+		}
+	}
+	return norm
+}
+
+// ClipGradValues clips every component of the gradient to
+// the range [-maxValue, maxValue].
+func ClipGradValues(grad anydiff.Grad, maxValue float64) {
+	for _, v := range grad {
+		c := v.Creator()
+		anyvec.ClipRange(v, c.MakeNumeric(-maxValue), c.MakeNumeric(maxValue))
+	}
+}
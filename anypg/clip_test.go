@@ -0,0 +1,64 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestGradClipperNorm(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	v1 := anydiff.NewVar(c.MakeVector(2))
+	v2 := anydiff.NewVar(c.MakeVector(1))
+	grad := anydiff.Grad{
+		v1: anyvec.Make(c, []float64{3, 0}),
+		v2: anyvec.Make(c, []float64{-4}),
+	}
+	if norm := GradNorm(grad); math.Abs(norm-5) > 1e-8 {
+		t.Fatalf("expected norm 5 but got %f", norm)
+	}
+
+	(&GradClipper{MaxNorm: 10}).Clip(grad)
+	testClippedGrad(t, grad[v1], []float64{3, 0})
+	testClippedGrad(t, grad[v2], []float64{-4})
+
+	(&GradClipper{MaxNorm: 2.5}).Clip(grad)
+	testClippedGrad(t, grad[v1], []float64{1.5, 0})
+	testClippedGrad(t, grad[v2], []float64{-2})
+}
+
+func TestGradClipperValues(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	v1 := anydiff.NewVar(c.MakeVector(3))
+	v2 := anydiff.NewVar(c.MakeVector(1))
+	grad := anydiff.Grad{
+		v1: anyvec.Make(c, []float64{3, -0.5, -2}),
+		v2: anyvec.Make(c, []float64{0.25}),
+	}
+	(&GradClipper{MaxValue: 1}).Clip(grad)
+	testClippedGrad(t, grad[v1], []float64{1, -0.5, -1})
+	testClippedGrad(t, grad[v2], []float64{0.25})
+}
+
+func TestGradClipperBoth(t *testing.T) {
+	// Values are clipped to [-4, 4], giving a norm of 5,
+	// which is then scaled down to 1.
+	c := anyvec64.DefaultCreator{}
+	v := anydiff.NewVar(c.MakeVector(2))
+	grad := anydiff.Grad{v: anyvec.Make(c, []float64{3, -10})}
+	(&GradClipper{MaxNorm: 1, MaxValue: 4}).Clip(grad)
+	testClippedGrad(t, grad[v], []float64{0.6, -0.8})
+}
+
+func testClippedGrad(t *testing.T, actual anyvec.Vector, expected []float64) {
+	data := actual.Creator().Float64Slice(actual.Data())
+	for i, x := range expected {
+		if math.Abs(data[i]-x) > 1e-8 {
+			t.Errorf("expected %v but got %v", expected, data)
+			return
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// cpoEpsilon prevents division by zero when solving the
+// CPO dual problem.
+const cpoEpsilon = 1e-8
+
+// CPO uses Constrained Policy Optimization to train agents
+// subject to a constraint on the expected total cost of
+// an episode.
+//
+// Costs are given as a separate per-timestep signal with
+// the same shape as the rewards.
+//
+// See https://arxiv.org/abs/1705.10528.
+type CPO struct {
+	TRPO
+
+	// CostLimit is the maximum allowed expected total
+	// cost per episode.
+	CostLimit float64
+
+	// CostJudger is used to judge actions based on the
+	// costs.
+	// It should not normalize its outputs, since the cost
+	// constraint depends on their scale.
+	//
+	// If nil, a QJudger with no discount is used.
+	CostJudger ActionJudger
+}
+
+// Run computes a step to improve the agent's performance
+// on the rollouts while satisfying the cost constraint.
+//
+// The costs argument specifies the cost at every timestep
+// of every rollout.
+//
+// If the current policy violates the constraint by too
+// much, the step attempts to decrease the cost without
+// regard for the rewards.
+func (c *CPO) Run(r *anyrl.RolloutSet, costs anyrl.Rewards) anydiff.Grad {
+	rewardRes := c.NaturalPG.run(r)
+	if len(rewardRes.Grad) == 0 {
+		return rewardRes.Grad
+	}
+
+	costRollouts := *r
+	costRollouts.Rewards = costs
+	costNPG := c.NaturalPG
+	costNPG.ActionJudger = c.costJudger()
+	costNPG.Regularizer = nil
+
+	// The cached solution is for the reward gradient, not
+	// the cost gradient, and the telemetry should only
+	// record the reward gradient.
+	costNPG.CGCache = nil
+	costNPG.Telemetry = nil
+	costRes := costNPG.run(&costRollouts)
+
+	// The surrogate objectives are means over timesteps
+	// rather than episodes, so the constraint must be
+	// scaled to match.
+	constraint := (costs.Mean() - c.CostLimit) * float64(len(costs)) /
+		float64(r.NumSteps())
+
+	cr := r.Creator()
+	fisherDot := func(g1, g2 anydiff.Grad) float64 {
+		rewardRes.ReducedOut.Reuse()
+		applied := rewardRes.Curvature(g2, rewardRes.ReducedOut)
+		return cr.Float64(dotGrad(g1, applied))
+	}
+
+	rewardDir, costDir := rewardRes.Grad, costRes.Grad
+	var s float64
+	if !costRes.ZeroGrad {
+		s = fisherDot(costDir, costDir)
+	}
+	if s <= 0 {
+		// Without a cost gradient, CPO reduces to TRPO.
+		if rewardRes.ZeroGrad {
+			return rewardRes.Grad
+		}
+		stepSize := c.stepSize(rewardRes)
+		return c.lineSearch(r, &costRollouts, rewardRes, rewardDir, stepSize,
+			cr.Float64(c.minImprovement(cr, stepSize)), false, constraint)
+	}
+
+	var q, rDot float64
+	if !rewardRes.ZeroGrad {
+		q = fisherDot(rewardDir, rewardDir)
+		rDot = fisherDot(rewardDir, costDir)
+	}
+
+	rewardScale, costScale, recovery := c.solveDual(q, rDot, s, constraint)
+
+	step := copyGrad(rewardDir)
+	step.Scale(cr.MakeNumeric(rewardScale))
+	scaledCost := copyGrad(costDir)
+	scaledCost.Scale(cr.MakeNumeric(costScale))
+	subFromGrad(step, scaledCost)
+
+	// The linear approximation predicts an improvement of
+	// g*step, which can be written in terms of q and rDot.
+	predicted := rewardScale*q - costScale*rDot
+	minImprovement := math.Max(0, predicted*c.acceptRatio())
+
+	rewardRes.Grad = step
+	return c.lineSearch(r, &costRollouts, rewardRes, step, cr.MakeNumeric(1),
+		minImprovement, recovery, constraint)
+}
+
+// solveDual computes the coefficients of the reward and
+// cost directions in the step.
+//
+// The step is rewardScale*H^-1*g - costScale*H^-1*b,
+// where g is the reward gradient, b is the cost gradient,
+// and q=g*H^-1*g, r=g*H^-1*b, s=b*H^-1*b.
+func (c *CPO) solveDual(q, r, s, constraint float64) (rewardScale, costScale float64,
+	recovery bool) {
+	delta := 2 * c.targetKL()
+
+	if constraint*constraint/s-delta > 0 {
+		if constraint < 0 {
+			// The constraint is inactive in the entire
+			// trust region.
+			if q <= 0 {
+				return 0, 0, false
+			}
+			return math.Sqrt(delta / q), 0, false
+		}
+		// No step in the trust region is feasible.
+		return 0, math.Sqrt(delta / s), true
+	}
+
+	a := q - r*r/s
+	b := delta - constraint*constraint/s
+	var lowA, highA, lowB, highB float64
+	if constraint < 0 {
+		lowA, highA = 0, r/constraint
+		lowB, highB = r/constraint, math.Inf(1)
+	} else {
+		lowA, highA = r/constraint, math.Inf(1)
+		lowB, highB = 0, r/constraint
+	}
+	lamA := math.Max(lowA, math.Min(highA, math.Sqrt(math.Max(0, a/(b+cpoEpsilon)))))
+	lamB := math.Max(lowB, math.Min(highB, math.Sqrt(q/delta)))
+
+	dualA := -0.5*(a/(lamA+cpoEpsilon)+b*lamA) - r*constraint/(s+cpoEpsilon)
+	dualB := -0.5 * (q/(lamB+cpoEpsilon) + delta*lamB)
+	lam := lamB
+	if dualA >= dualB {
+		lam = lamA
+	}
+	nu := math.Max(0, lam*constraint-r) / (s + cpoEpsilon)
+
+	return 1 / (lam + cpoEpsilon), nu / (lam + cpoEpsilon), false
+}
+
+// lineSearch scales the step until it satisfies the KL
+// and cost constraints and, unless it is a recovery step,
+// improves the surrogate objective by more than
+// minImprovement.
+//
+// Like in TRPO, minImprovement decays with the step.
+func (c *CPO) lineSearch(r, costRollouts *anyrl.RolloutSet, npg *naturalPGRes,
+	step anydiff.Grad, scale anyvec.Numeric, minImprovement float64, recovery bool,
+	constraint float64) anydiff.Grad {
+	cr := r.Creator()
+	step.Scale(scale)
+	npg.Grad = step
+	for i := 0; i < c.maxLineSearch(); i++ {
+		if c.acceptable(r, costRollouts, npg, minImprovement, recovery, constraint) {
+			break
+		}
+		step.Scale(cr.MakeNumeric(c.lineSearchDecay()))
+		minImprovement *= c.lineSearchDecay()
+	}
+	return step
+}
+
+func (c *CPO) acceptable(r, costRollouts *anyrl.RolloutSet, npg *naturalPGRes,
+	minImprovement float64, recovery bool, constraint float64) bool {
+	cr := r.Creator()
+	inSeq := lazyseq.TapeRereader(r.Inputs)
+	judgements := r.ApplyWeights(c.actionJudger().JudgeActions(r))
+	costJudgements := r.ApplyWeights(c.costJudger().JudgeActions(costRollouts))
+	rewardSeq := lazyseq.TapeRereader(judgements.Tape(cr))
+	costSeq := lazyseq.TapeRereader(costJudgements.Tape(cr))
+	newOutSeq := c.apply(inSeq, c.steppedPolicy(npg.Grad))
+	sampledOut := lazyseq.TapeRereader(r.Actions)
+	weightSeq := lazyseq.TapeRereader(c.klWeights(r).Tape(cr))
+	npg.PolicyOut.Reuse()
+
+	// At each timestep, compute a tuple
+	// <improvement, cost change, kl divergence>.
+	mappedOut := lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		reward, cost := v[0], v[1]
+		oldOut, newOut := v[2], v[3]
+		sampled, weight := v[4].Output(), v[5]
+
+		probRatio := anydiff.Exp(anydiff.Sub(
+			c.ActionSpace.LogProb(newOut, sampled, n),
+			c.ActionSpace.LogProb(oldOut, sampled, n),
+		))
+
+		rewardChange := anydiff.Sub(anydiff.Mul(probRatio, reward), reward)
+		costChange := anydiff.Sub(anydiff.Mul(probRatio, cost), cost)
+		kl := anydiff.Mul(c.kl(oldOut, newOut, n), weight)
+
+		joined := cr.Concat(rewardChange.Output(), costChange.Output(), kl.Output())
+		transposed := cr.MakeVector(joined.Len())
+		anyvec.Transpose(joined, transposed, 3)
+
+		return anydiff.NewConst(transposed)
+	}, rewardSeq, costSeq, npg.PolicyOut, newOutSeq, sampledOut, weightSeq)
+
+	outStats := lazyseq.Mean(mappedOut).Output()
+	improvement := anyvec.Sum(outStats.Slice(0, 1))
+	costChange := anyvec.Sum(outStats.Slice(1, 2))
+	kl := anyvec.Sum(outStats.Slice(2, 3))
+
+	if c.LogLineSearch != nil {
+		c.LogLineSearch(kl, improvement)
+	}
+
+	if cr.Float64(kl) >= c.maxKL() {
+		return false
+	}
+	if cr.Float64(costChange) > math.Max(0, -constraint) {
+		return false
+	}
+	return recovery || cr.Float64(improvement) > minImprovement
+}
+
+func (c *CPO) costJudger() ActionJudger {
+	if c.CostJudger == nil {
+		return &QJudger{}
+	} else {
+		return c.CostJudger
+	}
+}
@@ -0,0 +1,117 @@
+package anypg
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/lazyseq"
+)
+
+// DiagnosticSpace implements the action space methods
+// needed to compute Diagnostics.
+type DiagnosticSpace interface {
+	anyrl.LogProber
+	anyrl.KLer
+	anyrl.Entropyer
+}
+
+// Diagnostics stores per-timestep statistics about how a
+// policy differs from the policy which produced a set of
+// rollouts.
+//
+// Each tape has one component per timestep, so it can be
+// used to visualize where in the episodes the policy is
+// changing or collapsing.
+type Diagnostics struct {
+	// Entropy stores the entropy of the new policy.
+	Entropy lazyseq.Tape
+
+	// KL stores the KL divergence from the old policy to
+	// the new policy.
+	KL lazyseq.Tape
+
+	// Ratio stores the ratio between the new and old
+	// probabilities of the sampled actions.
+	Ratio lazyseq.Tape
+}
+
+// Diagnose computes Diagnostics for a new policy's
+// outputs on the rollouts.
+//
+// The rollouts must have AgentOuts, which are treated as
+// the old policy's outputs.
+func Diagnose(r *anyrl.RolloutSet, newOuts lazyseq.Rereader,
+	space DiagnosticSpace) *Diagnostics {
+	c := r.Creator()
+	stats := lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		newOut, oldOut, sampled := v[0], v[1], v[2].Output()
+		ratio := anydiff.Exp(anydiff.Sub(
+			space.LogProb(newOut, sampled, n),
+			space.LogProb(oldOut, sampled, n),
+		))
+		return anydiff.Concat(
+			space.Entropy(newOut, n),
+			space.KL(oldOut, newOut, n),
+			ratio,
+		)
+	}, newOuts, lazyseq.TapeRereader(r.AgentOuts), lazyseq.TapeRereader(r.Actions))
+
+	res := &Diagnostics{}
+	var writers []chan<- *anyseq.Batch
+	for _, tape := range []*lazyseq.Tape{&res.Entropy, &res.KL, &res.Ratio} {
+		var writer chan<- *anyseq.Batch
+		*tape, writer = lazyseq.ReferenceTape(c)
+		writers = append(writers, writer)
+	}
+	for batch := range stats.Forward() {
+		n := batch.NumPresent()
+		for i, writer := range writers {
+			writer <- &anyseq.Batch{
+				Packed:  batch.Packed.Slice(i*n, (i+1)*n),
+				Present: batch.Present,
+			}
+		}
+	}
+	for _, writer := range writers {
+		close(writer)
+	}
+	return res
+}
+
+// Diagnostics computes Diagnostics for the current policy
+// on the rollouts.
+//
+// The action space must implement DiagnosticSpace.
+func (p *PPO) Diagnostics(r *anyrl.RolloutSet) *Diagnostics {
+	return Diagnose(r, p.Actor(p.applyBase(r)), diagnosticSpace(p.ActionSpace))
+}
+
+// Diagnostics computes Diagnostics for the current policy
+// on the rollouts.
+//
+// The action space must implement DiagnosticSpace.
+func (n *NaturalPG) Diagnostics(r *anyrl.RolloutSet) *Diagnostics {
+	out := n.apply(lazyseq.TapeRereader(r.Inputs), n.Policy)
+	return Diagnose(r, out, diagnosticSpace(n.ActionSpace))
+}
+
+// Diagnostics computes Diagnostics for the current policy
+// head on the rollouts.
+//
+// The action space must implement DiagnosticSpace.
+func (a *A2C) Diagnostics(r *anyrl.RolloutSet) *Diagnostics {
+	out := lazyseq.Map(a.Agent(lazyseq.TapeRereader(r.Inputs)),
+		func(v anydiff.Res, n int) anydiff.Res {
+			policy, _ := SplitHeads(v, n)
+			return policy
+		})
+	return Diagnose(r, out, diagnosticSpace(a.ActionSpace))
+}
+
+func diagnosticSpace(space anyrl.LogProber) DiagnosticSpace {
+	res, ok := space.(DiagnosticSpace)
+	if !ok {
+		panic("Diagnostics requires an action space which implements DiagnosticSpace")
+	}
+	return res
+}
@@ -0,0 +1,102 @@
+package anypg
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/lazyseq"
+)
+
+func TestDiagnosticsUnchanged(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+	policy := anynet.NewFC(c, 3, 2)
+	r.AgentOuts = layerTape(policy, r.Inputs)
+
+	// The A2C agent has an extra value output after the
+	// two policy outputs.
+	agent := anynet.NewFC(c, 3, 3)
+	block := &anyrnn.LayerBlock{Layer: agent}
+	a2cOuts, writer := lazyseq.ReferenceTape(c)
+	for batch := range r.Inputs.ReadTape(0, -1) {
+		n := batch.NumPresent()
+		out, _ := SplitHeads(agent.Apply(anydiff.NewConst(batch.Packed), n), n)
+		writer <- &anyseq.Batch{Present: batch.Present, Packed: out.Output()}
+	}
+	close(writer)
+
+	ppo := &PPO{Actor: layerPolicy(policy), ActionSpace: anyrl.Softmax{}}
+	npg := &NaturalPG{
+		Policy:      &anyrnn.LayerBlock{Layer: policy},
+		ActionSpace: anyrl.Softmax{},
+	}
+	a2c := &A2C{
+		Agent: func(in lazyseq.Rereader) lazyseq.Rereader {
+			return lazyseq.Lazify(anyrnn.Map(lazyseq.Unlazify(in), block))
+		},
+		ActionSpace: anyrl.Softmax{},
+	}
+	for name, diagnose := range map[string]func() *Diagnostics{
+		"PPO":       func() *Diagnostics { return ppo.Diagnostics(r) },
+		"NaturalPG": func() *Diagnostics { return npg.Diagnostics(r) },
+		"A2C": func() *Diagnostics {
+			old := r.AgentOuts
+			r.AgentOuts = a2cOuts
+			defer func() { r.AgentOuts = old }()
+			return a2c.Diagnostics(r)
+		},
+	} {
+		diag := diagnose()
+		entropy := tapeSteps(diag.Entropy, len(r.Rewards))
+		kl := tapeSteps(diag.KL, len(r.Rewards))
+		ratio := tapeSteps(diag.Ratio, len(r.Rewards))
+		for i, seq := range r.Rewards {
+			if len(entropy[i]) != len(seq) || len(kl[i]) != len(seq) ||
+				len(ratio[i]) != len(seq) {
+				t.Errorf("%s: episode %d has the wrong number of timesteps", name, i)
+				continue
+			}
+			for step := range seq {
+				if len(entropy[i][step]) != 1 || len(kl[i][step]) != 1 ||
+					len(ratio[i][step]) != 1 {
+					t.Errorf("%s: episode %d step %d: expected one entry per timestep",
+						name, i, step)
+					continue
+				}
+				if entropy[i][step][0] <= 0 {
+					t.Errorf("%s: episode %d step %d: bad entropy %f", name, i, step,
+						entropy[i][step][0])
+				}
+				if math.Abs(kl[i][step][0]) > 1e-8 {
+					t.Errorf("%s: episode %d step %d: expected KL 0 but got %f", name, i,
+						step, kl[i][step][0])
+				}
+				if math.Abs(ratio[i][step][0]-1) > 1e-8 {
+					t.Errorf("%s: episode %d step %d: expected ratio 1 but got %f", name,
+						i, step, ratio[i][step][0])
+				}
+			}
+		}
+	}
+}
+
+func TestDiagnosticsSpace(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+	policy := anynet.NewFC(c, 3, 2)
+	r.AgentOuts = layerTape(policy, r.Inputs)
+	ppo := &PPO{Actor: layerPolicy(policy), ActionSpace: logProbOnly{anyrl.Softmax{}}}
+	defer func() {
+		if msg, ok := recover().(string); !ok || !strings.Contains(msg, "DiagnosticSpace") {
+			t.Errorf("expected a panic about DiagnosticSpace but got %v", msg)
+		}
+	}()
+	ppo.Diagnostics(r)
+}
@@ -0,0 +1,77 @@
+package anypg
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// Distiller trains a student policy to match the action
+// distributions of a teacher policy.
+//
+// The student may be smaller than the teacher or have an
+// entirely different architecture, as long as both
+// produce parameters for the same action space.
+type Distiller struct {
+	// Params specifies which parameters to include in
+	// the gradients.
+	// This should only include the student's parameters.
+	Params []*anydiff.Var
+
+	// Student applies the student policy to a sequence
+	// of inputs.
+	Student func(obses lazyseq.Rereader) lazyseq.Rereader
+
+	// Teacher applies the teacher policy to a sequence
+	// of inputs.
+	// The teacher's outputs are treated as constants.
+	//
+	// If nil, the AgentOuts of the rollouts are used as
+	// the teacher's outputs.
+	Teacher func(obses lazyseq.Rereader) lazyseq.Rereader
+
+	// ActionSpace is used to compute the KL divergence
+	// from the teacher to the student.
+	ActionSpace anyrl.KLer
+}
+
+// Run computes a gradient which decreases the mean KL
+// divergence from the teacher's distributions to the
+// student's distributions over the inputs in r.
+//
+// It also returns the mean KL divergence before the
+// update.
+//
+// If d.Params is empty, then an empty gradient and a nil
+// KL divergence are returned.
+//
+// Run panics if Student or ActionSpace is nil.
+func (d *Distiller) Run(r *anyrl.RolloutSet) (anydiff.Grad, anyvec.Numeric) {
+	if d.Student == nil {
+		panic("Distiller requires a Student")
+	} else if d.ActionSpace == nil {
+		panic("Distiller requires an ActionSpace")
+	}
+	grad := anydiff.NewGrad(d.Params...)
+	if len(grad) == 0 {
+		return grad, nil
+	}
+	c := r.Creator()
+
+	inputs := lazyseq.TapeRereader(r.Inputs)
+	var teacher lazyseq.Rereader
+	if d.Teacher != nil {
+		teacher = d.Teacher(inputs)
+	} else {
+		teacher = lazyseq.TapeRereader(r.AgentOuts)
+	}
+
+	kl := lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		return d.ActionSpace.KL(anydiff.NewConst(v[1].Output()), v[0], n)
+	}, d.Student(inputs), teacher)
+	mean := lazyseq.Mean(kl)
+	mean.Propagate(anyvec.Make(c, []float64{-1}), grad)
+
+	return grad, anyvec.Sum(mean.Output())
+}
@@ -0,0 +1,64 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/serializer"
+)
+
+func TestDistiller(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+	teacher := anynet.NewFC(c, 3, 2)
+	copied, err := serializer.Copy(teacher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	student := copied.(*anynet.FC)
+
+	d := &Distiller{
+		Params:      student.Parameters(),
+		Student:     layerPolicy(student),
+		Teacher:     layerPolicy(teacher),
+		ActionSpace: anyrl.Softmax{},
+	}
+	if _, kl := d.Run(r); math.Abs(kl.(float64)) > 1e-8 {
+		t.Errorf("expected zero KL for identical policies but got %f", kl)
+	}
+
+	// Use the AgentOuts as the teacher this time.
+	r.AgentOuts = layerTape(teacher, r.Inputs)
+	d.Teacher = nil
+	student = anynet.NewFC(c, 3, 2)
+	d.Params = student.Parameters()
+	d.Student = layerPolicy(student)
+	grad, oldKL := d.Run(r)
+	grad.Scale(c.MakeNumeric(0.1))
+	grad.AddToVars()
+	if _, newKL := d.Run(r); newKL.(float64) >= oldKL.(float64) {
+		t.Errorf("KL went from %f to %f", oldKL, newKL)
+	}
+}
+
+func TestDistillerMissingFields(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+	student := anynet.NewFC(c, 3, 2)
+	for _, d := range []*Distiller{
+		{Params: student.Parameters(), ActionSpace: anyrl.Softmax{}},
+		{Params: student.Parameters(), Student: layerPolicy(student)},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			d.Run(r)
+		}()
+	}
+}
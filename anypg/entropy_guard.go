@@ -0,0 +1,161 @@
+package anypg
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// Default settings for EntropyGuard.
+const (
+	DefaultEntropyWarning       = 1.5
+	DefaultEntropyBoost         = 2
+	DefaultEntropyRollbackAfter = 3
+)
+
+// A GuardAction is something an EntropyGuard did in
+// response to the latest entropy measurement.
+type GuardAction int
+
+// These are the possible GuardActions.
+const (
+	GuardNone GuardAction = iota
+	GuardWarn
+	GuardBoost
+	GuardRollback
+)
+
+// EntropyGuard tracks the entropy of a policy from one
+// iteration to the next and intervenes before the policy
+// collapses irreversibly.
+//
+// When the entropy drops below Floor, the coefficient of
+// Reg is increased.
+// If the entropy stays below Floor for RollbackAfter
+// iterations in a row, Params are restored to the values
+// they had at the last iteration where the entropy was
+// above Floor.
+type EntropyGuard struct {
+	// Floor is the minimum acceptable mean entropy.
+	Floor float64
+
+	// Warning is the fraction of Floor below which a
+	// warning is logged.
+	// It should be greater than 1.
+	//
+	// If 0, DefaultEntropyWarning is used.
+	Warning float64
+
+	// Reg, if non-nil, is the regularizer whose
+	// coefficient is boosted.
+	Reg *EntropyReg
+
+	// Boost is the factor by which Reg.Coeff is
+	// multiplied on each violation.
+	//
+	// If 0, DefaultEntropyBoost is used.
+	Boost float64
+
+	// MaxCoeff, if non-zero, limits Reg.Coeff.
+	MaxCoeff float64
+
+	// Params, if non-nil, are the parameters to snapshot
+	// and roll back.
+	Params []*anydiff.Var
+
+	// RollbackAfter is the number of consecutive
+	// violations before rolling back.
+	//
+	// If 0, DefaultEntropyRollbackAfter is used.
+	RollbackAfter int
+
+	// Logger, if non-nil, is sent an alert for every
+	// warning and intervention.
+	Logger anyrl.AlertLogger
+
+	snapshot   []anyvec.Vector
+	violations int
+}
+
+// Update processes the mean entropy of the policy for
+// the latest iteration and intervenes if necessary.
+//
+// It should be called once per iteration, e.g. with the
+// mean of the entropy from Diagnostics.
+func (e *EntropyGuard) Update(entropy float64) GuardAction {
+	if entropy >= e.Floor {
+		e.violations = 0
+		e.takeSnapshot()
+		if entropy < e.Floor*e.warning() {
+			e.alert(entropy, "approaching entropy floor")
+			return GuardWarn
+		}
+		return GuardNone
+	}
+
+	e.violations++
+	if e.Params != nil && e.snapshot != nil && e.violations >= e.rollbackAfter() {
+		for i, p := range e.Params {
+			p.Vector.Set(e.snapshot[i])
+		}
+		e.violations = 0
+		e.alert(entropy, "rolled back parameters")
+		return GuardRollback
+	}
+
+	if e.Reg != nil {
+		e.Reg.Coeff *= e.boost()
+		if e.MaxCoeff != 0 {
+			e.Reg.Coeff = math.Min(e.Reg.Coeff, e.MaxCoeff)
+		}
+		e.alert(entropy, fmt.Sprintf("boosted entropy coefficient to %f", e.Reg.Coeff))
+		return GuardBoost
+	}
+
+	e.alert(entropy, "entropy below floor")
+	return GuardWarn
+}
+
+func (e *EntropyGuard) takeSnapshot() {
+	if e.Params == nil {
+		return
+	}
+	e.snapshot = make([]anyvec.Vector, len(e.Params))
+	for i, p := range e.Params {
+		e.snapshot[i] = p.Vector.Copy()
+	}
+}
+
+func (e *EntropyGuard) alert(entropy float64, msg string) {
+	if e.Logger != nil {
+		e.Logger.LogAlert(&anyrl.Alert{
+			Kind:    anyrl.EntropyCollapse,
+			Value:   entropy,
+			Message: fmt.Sprintf("%s (entropy %f, floor %f)", msg, entropy, e.Floor),
+		})
+	}
+}
+
+func (e *EntropyGuard) warning() float64 {
+	if e.Warning == 0 {
+		return DefaultEntropyWarning
+	}
+	return e.Warning
+}
+
+func (e *EntropyGuard) boost() float64 {
+	if e.Boost == 0 {
+		return DefaultEntropyBoost
+	}
+	return e.Boost
+}
+
+func (e *EntropyGuard) rollbackAfter() int {
+	if e.RollbackAfter == 0 {
+		return DefaultEntropyRollbackAfter
+	}
+	return e.RollbackAfter
+}
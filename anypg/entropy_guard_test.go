@@ -0,0 +1,46 @@
+package anypg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestEntropyGuard(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	param := anydiff.NewVar(anyvec.Make(c, []float64{1, 2}))
+	reg := &EntropyReg{Coeff: 0.01}
+	guard := &EntropyGuard{
+		Floor:         1,
+		Reg:           reg,
+		MaxCoeff:      0.03,
+		Params:        []*anydiff.Var{param},
+		RollbackAfter: 3,
+	}
+
+	if a := guard.Update(2); a != GuardNone {
+		t.Errorf("expected GuardNone but got %v", a)
+	}
+	if a := guard.Update(1.2); a != GuardWarn {
+		t.Errorf("expected GuardWarn but got %v", a)
+	}
+
+	param.Vector.Set(anyvec.Make(c, []float64{3, 4}))
+	for i, expected := range []float64{0.02, 0.03} {
+		if a := guard.Update(0.5); a != GuardBoost {
+			t.Errorf("violation %d: expected GuardBoost but got %v", i, a)
+		}
+		if reg.Coeff != expected {
+			t.Errorf("violation %d: expected coeff %f but got %f", i, expected, reg.Coeff)
+		}
+	}
+	if a := guard.Update(0.5); a != GuardRollback {
+		t.Errorf("expected GuardRollback but got %v", a)
+	}
+	if actual := param.Vector.Data().([]float64); !reflect.DeepEqual(actual, []float64{1, 2}) {
+		t.Errorf("expected rolled back parameters but got %v", actual)
+	}
+}
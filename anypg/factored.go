@@ -0,0 +1,186 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// A ComponentJudger judges each component of the actions
+// from a factored action space separately.
+type ComponentJudger interface {
+	// JudgeComponents produces a tape with one vector
+	// per timestep, containing an advantage for each
+	// component of the action.
+	JudgeComponents(r *anyrl.RolloutSet) lazyseq.Tape
+}
+
+// FactoredPG computes policy gradients for factored
+// action spaces, where each component of an action is
+// weighted by its own advantage.
+//
+// With a CounterfactualJudger, each component is credited
+// only for its own contribution to the return, which
+// reduces variance for large multi-discrete spaces.
+type FactoredPG struct {
+	Policy      func(in lazyseq.Rereader) lazyseq.Rereader
+	Params      []*anydiff.Var
+	ActionSpace *anyrl.Tuple
+
+	// Judger computes the per-component advantages.
+	Judger ComponentJudger
+
+	// Regularizer, if non-nil, is used to regularize the
+	// action distributions.
+	Regularizer Regularizer
+}
+
+// Run computes the policy gradient for the rollouts.
+func (f *FactoredPG) Run(r *anyrl.RolloutSet) anydiff.Grad {
+	grad := anydiff.NewGrad(f.Params...)
+	if len(grad) == 0 {
+		return grad
+	}
+	c := r.Creator()
+	obj := lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		return anydiff.Pool(v[0], func(params anydiff.Res) anydiff.Res {
+			logProbs := f.ActionSpace.ComponentLogProbs(params, v[1].Output(), n)
+			res := batchedDot(logProbs, v[2], n)
+			if f.Regularizer != nil {
+				res = anydiff.Add(res, f.Regularizer.Regularize(params, n))
+			}
+			return res
+		})
+	}, f.Policy(lazyseq.TapeRereader(r.Inputs)), lazyseq.TapeRereader(r.Actions),
+		lazyseq.TapeRereader(f.Judger.JudgeComponents(r)))
+	lazyseq.Mean(obj).Propagate(anyvec.Ones(c, 1), grad)
+	return grad
+}
+
+// SharedJudger is a ComponentJudger which gives every
+// component the same advantage from an ActionJudger.
+//
+// With a SharedJudger, FactoredPG is equivalent to PG.
+type SharedJudger struct {
+	Judger        ActionJudger
+	NumComponents int
+}
+
+// JudgeComponents repeats the judgement for every
+// component.
+func (s *SharedJudger) JudgeComponents(r *anyrl.RolloutSet) lazyseq.Tape {
+	judgements := s.Judger.JudgeActions(r)
+	seqs := make([][][]float64, len(judgements))
+	for i, seq := range judgements {
+		for _, adv := range seq {
+			vec := make([]float64, s.NumComponents)
+			for j := range vec {
+				vec[j] = adv
+			}
+			seqs[i] = append(seqs[i], vec)
+		}
+	}
+	return vectorTape(r.Creator(), seqs)
+}
+
+// CounterfactualJudger computes per-component advantages
+// with counterfactual baselines, as in COMA.
+//
+// For component k, the advantage is
+//
+//	Q(s, a) - sum_j pi_k(j|s) Q(s, a with a_k replaced by j)
+//
+// so the baseline marginalizes out only the k-th
+// component.
+//
+// Every component of the action space must be an
+// anyrl.Softmax.
+// The rollouts must have AgentOuts.
+type CounterfactualJudger struct {
+	// Q maps an observation followed by a (packed) action
+	// to a single value estimate.
+	// It should be trained separately, e.g. to predict
+	// discounted returns.
+	Q anynet.Layer
+
+	ActionSpace *anyrl.Tuple
+}
+
+// JudgeComponents computes the advantages.
+func (c *CounterfactualJudger) JudgeComponents(r *anyrl.RolloutSet) lazyseq.Tape {
+	obs := episodeSteps(r.Inputs, len(r.Rewards))
+	actions := episodeSteps(r.Actions, len(r.Rewards))
+	params := episodeSteps(r.AgentOuts, len(r.Rewards))
+	seqs := make([][][]float64, len(r.Rewards))
+	for i := range seqs {
+		for t := range obs[i] {
+			seqs[i] = append(seqs[i], c.judgeStep(r.Creator(), obs[i][t], actions[i][t],
+				params[i][t]))
+		}
+	}
+	return vectorTape(r.Creator(), seqs)
+}
+
+func (c *CounterfactualJudger) judgeStep(cr anyvec.Creator, obs, action,
+	params []float64) []float64 {
+	// The first row is the actual action, followed by
+	// every alternative for every component.
+	var rows []float64
+	rows = append(rows, obs...)
+	rows = append(rows, action...)
+	numRows := 1
+	var sampleOffset int
+	for _, size := range c.ActionSpace.SampleSizes {
+		for j := 0; j < size; j++ {
+			alt := append([]float64{}, action...)
+			for k := 0; k < size; k++ {
+				alt[sampleOffset+k] = 0
+			}
+			alt[sampleOffset+j] = 1
+			rows = append(rows, obs...)
+			rows = append(rows, alt...)
+			numRows++
+		}
+		sampleOffset += size
+	}
+
+	values := cr.Float64Slice(c.Q.Apply(anydiff.NewConst(anyvec.Make(cr, rows)),
+		numRows).Output().Data())
+	actual := values[0]
+	values = values[1:]
+
+	res := make([]float64, len(c.ActionSpace.SampleSizes))
+	var paramOffset int
+	for k, size := range c.ActionSpace.SampleSizes {
+		probs := softmax(params[paramOffset : paramOffset+size])
+		var baseline float64
+		for j, prob := range probs {
+			baseline += prob * values[j]
+		}
+		res[k] = actual - baseline
+		values = values[size:]
+		paramOffset += c.ActionSpace.ParamSizes[k]
+	}
+	return res
+}
+
+func softmax(logits []float64) []float64 {
+	max := math.Inf(-1)
+	for _, x := range logits {
+		max = math.Max(max, x)
+	}
+	res := make([]float64, len(logits))
+	var sum float64
+	for i, x := range logits {
+		res[i] = math.Exp(x - max)
+		sum += res[i]
+	}
+	for i := range res {
+		res[i] /= sum
+	}
+	return res
+}
@@ -0,0 +1,56 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestCounterfactualJudger(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := &anyrl.RolloutSet{
+		Inputs:    anyrl.Rewards{{7}}.Tape(c),
+		Actions:   vectorTape(c, [][][]float64{{{1, 0, 0, 1}}}),
+		AgentOuts: vectorTape(c, [][][]float64{{{0, 0, 0, 0}}}),
+		Rewards:   anyrl.Rewards{{1}},
+	}
+	judger := &CounterfactualJudger{
+		Q: linearActionQ{1, 2, 3, 5},
+		ActionSpace: &anyrl.Tuple{
+			Spaces:      []interface{}{anyrl.Softmax{}, anyrl.Softmax{}},
+			ParamSizes:  []int{2, 2},
+			SampleSizes: []int{2, 2},
+		},
+	}
+	batch := <-judger.JudgeComponents(r).ReadTape(0, 1)
+	actual := batch.Packed.Data().([]float64)
+	expected := []float64{-0.5, 1}
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-8 {
+			t.Errorf("expected %v but got %v", expected, actual)
+			break
+		}
+	}
+}
+
+// linearActionQ is a Q-function which ignores a
+// one-dimensional observation and computes a dot product
+// with the action.
+type linearActionQ []float64
+
+func (l linearActionQ) Apply(in anydiff.Res, n int) anydiff.Res {
+	c := in.Output().Creator()
+	data := c.Float64Slice(in.Output().Data())
+	size := len(data) / n
+	res := make([]float64, n)
+	for i := range res {
+		for j, w := range l {
+			res[i] += w * data[i*size+1+j]
+		}
+	}
+	return anydiff.NewConst(anyvec.Make(c, res))
+}
@@ -2,6 +2,7 @@ package anypg
 
 import (
 	"math"
+	"sort"
 
 	"github.com/unixpickle/anydiff/anyseq"
 	"github.com/unixpickle/anyrl"
@@ -39,16 +40,33 @@ type QJudger struct {
 	//
 	// If this is 0, a reasonably small value is used.
 	Epsilon float64
+
+	// ValueFunc, if non-nil, is used to bootstrap the
+	// returns of truncated episodes from the value of
+	// their final inputs (see anyrl.RolloutSet).
+	// It works like GAEJudger.ValueFunc.
+	//
+	// If nil, truncated episodes are treated like
+	// terminal ones.
+	ValueFunc func(inputs lazyseq.Rereader) <-chan *anyseq.Batch
 }
 
 // JudgeActions transforms the rewards so that each reward
 // is replaced with the sum of all the rewards from that
 // timestep to the end of the episode.
 func (q *QJudger) JudgeActions(r *anyrl.RolloutSet) anyrl.Rewards {
+	var bootstrap [][]float64
+	if q.ValueFunc != nil && hasFinalInputs(r) {
+		bootstrap = bootstrapValues(q.ValueFunc, r)
+	}
+
 	var res anyrl.Rewards
-	for _, seq := range r.Rewards {
+	for i, seq := range r.Rewards {
 		newSeq := make([]float64, len(seq))
 		var sum float64
+		if bootstrap != nil && len(bootstrap[i]) > len(seq) {
+			sum = bootstrap[i][len(seq)]
+		}
 		for t := len(seq) - 1; t >= 0; t-- {
 			if q.Discount != 0 {
 				sum *= q.Discount
@@ -145,6 +163,84 @@ func (t *TotalJudger) normalize(rewards []float64) {
 	}
 }
 
+// DefaultCVaRAlpha is the default fraction of episodes
+// considered by CVaRJudger.
+const DefaultCVaRAlpha = 0.1
+
+// CVaRJudger is an ActionJudger for risk-sensitive
+// training which optimizes the conditional value-at-risk
+// of the total reward.
+//
+// Only the worst Alpha fraction of episodes receive a
+// non-zero judgement, so gradients focus entirely on
+// improving the worst-case outcomes.
+//
+// For more on CVaR policy gradients, see
+// https://arxiv.org/abs/1404.3862.
+type CVaRJudger struct {
+	// Alpha is the fraction of episodes (by total reward)
+	// which the objective considers.
+	// It should be between 0 and 1.
+	//
+	// If 0, DefaultCVaRAlpha is used.
+	Alpha float64
+}
+
+// JudgeActions repeats each episode's CVaR advantage at
+// every timestep of the episode.
+//
+// An episode's advantage is its total reward minus the
+// value-at-risk, divided by Alpha, if the total is at most
+// the value-at-risk.
+// Otherwise, the advantage is 0.
+func (c *CVaRJudger) JudgeActions(r *anyrl.RolloutSet) anyrl.Rewards {
+	totals := r.Rewards.Totals()
+	valueAtRisk := c.valueAtRisk(r.Rewards, totals)
+
+	var res anyrl.Rewards
+	for seqIdx, seq := range r.Rewards {
+		var adv float64
+		if totals[seqIdx] <= valueAtRisk {
+			adv = (totals[seqIdx] - valueAtRisk) / c.alpha()
+		}
+		newSeq := make([]float64, len(seq))
+		for i := range newSeq {
+			newSeq[i] = adv
+		}
+		res = append(res, newSeq)
+	}
+
+	return res
+}
+
+func (c *CVaRJudger) valueAtRisk(rewards anyrl.Rewards, totals []float64) float64 {
+	var sorted []float64
+	for i, seq := range rewards {
+		if len(seq) > 0 {
+			sorted = append(sorted, totals[i])
+		}
+	}
+	if len(sorted) == 0 {
+		return 0
+	}
+	sort.Float64s(sorted)
+	idx := int(math.Ceil(c.alpha()*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+func (c *CVaRJudger) alpha() float64 {
+	if c.Alpha == 0 {
+		return DefaultCVaRAlpha
+	} else {
+		return c.Alpha
+	}
+}
+
 // A GAEJudger uses Generalized Advantage Estimation to
 // judge actions based on the predictions from a value
 // estimator.
@@ -167,20 +263,14 @@ type GAEJudger struct {
 }
 
 // JudgeActions computes generalized advantage estimates.
+//
+// For truncated episodes (see anyrl.TruncationEnv), the
+// return after the final timestep is bootstrapped using
+// the value of the episode's final input.
+// If the final input was not recorded, the value of the
+// last observed state is used instead.
 func (g *GAEJudger) JudgeActions(r *anyrl.RolloutSet) anyrl.Rewards {
-	input := lazyseq.TapeRereader(r.Inputs)
-	criticOut := g.ValueFunc(input)
-
-	estimatedValues := make([][]float64, len(r.Rewards))
-	for outBatch := range criticOut {
-		comps := vectorToComponents(outBatch.Packed)
-		for i, pres := range outBatch.Present {
-			if pres {
-				estimatedValues[i] = append(estimatedValues[i], comps[0])
-				comps = comps[1:]
-			}
-		}
-	}
+	estimatedValues := bootstrapValues(g.ValueFunc, r)
 
 	var res [][]float64
 	for i, rewSeq := range r.Rewards {
@@ -189,8 +279,10 @@ func (g *GAEJudger) JudgeActions(r *anyrl.RolloutSet) anyrl.Rewards {
 		var accumulation float64
 		for t := len(rewSeq) - 1; t >= 0; t-- {
 			delta := rewSeq[t] - valSeq[t]
-			if t+1 < len(rewSeq) {
+			if t+1 < len(valSeq) {
 				delta += g.Discount * valSeq[t+1]
+			} else if r.IsTruncated(i) {
+				delta += g.Discount * valSeq[t]
 			}
 			accumulation *= g.Discount * g.Lambda
 			accumulation += delta
@@ -202,6 +294,103 @@ func (g *GAEJudger) JudgeActions(r *anyrl.RolloutSet) anyrl.Rewards {
 	return anyrl.Rewards(res)
 }
 
+// criticValues applies a value function to the inputs of
+// a RolloutSet and splits the values up by episode.
+func criticValues(valueFunc func(inputs lazyseq.Rereader) <-chan *anyseq.Batch,
+	r *anyrl.RolloutSet) [][]float64 {
+	return tapeValues(valueFunc, r.Inputs, len(r.Rewards))
+}
+
+// bootstrapValues is like criticValues, but every
+// truncated episode with a final input gets an extra
+// value for that input.
+func bootstrapValues(valueFunc func(inputs lazyseq.Rereader) <-chan *anyseq.Batch,
+	r *anyrl.RolloutSet) [][]float64 {
+	if !hasFinalInputs(r) {
+		return criticValues(valueFunc, r)
+	}
+	return tapeValues(valueFunc, bootstrapInputs(r), len(r.Rewards))
+}
+
+func tapeValues(valueFunc func(inputs lazyseq.Rereader) <-chan *anyseq.Batch,
+	inputs lazyseq.Tape, numEpisodes int) [][]float64 {
+	criticOut := valueFunc(lazyseq.TapeRereader(inputs))
+
+	estimatedValues := make([][]float64, numEpisodes)
+	for outBatch := range criticOut {
+		comps := outBatch.Packed.Creator().Float64Slice(outBatch.Packed.Data())
+		for i, pres := range outBatch.Present {
+			if pres {
+				estimatedValues[i] = append(estimatedValues[i], comps[0])
+				comps = comps[1:]
+			}
+		}
+	}
+	return estimatedValues
+}
+
+// hasFinalInputs checks if any truncated episode has a
+// recorded final input.
+func hasFinalInputs(r *anyrl.RolloutSet) bool {
+	for i := range r.Rewards {
+		if r.IsTruncated(i) && r.FinalInput(i) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// bootstrapInputs creates a copy of the input tape in
+// which the final input of each truncated episode is
+// appended to the episode as an extra timestep.
+func bootstrapInputs(r *anyrl.RolloutSet) lazyseq.Tape {
+	c := r.Creator()
+	res, writer := lazyseq.ReferenceTape(c)
+	go func() {
+		defer close(writer)
+		var t int
+		for batch := range r.Inputs.ReadTape(0, -1) {
+			writer <- appendFinalInputs(r, batch, t)
+			t++
+		}
+		// Final inputs of the longest episodes need their
+		// own timestep.
+		empty := &anyseq.Batch{Present: make([]bool, len(r.Rewards))}
+		if final := appendFinalInputs(r, empty, t); final.NumPresent() > 0 {
+			writer <- final
+		}
+	}()
+	return res
+}
+
+// appendFinalInputs adds the final inputs of truncated
+// episodes which end right before timestep t to a batch.
+func appendFinalInputs(r *anyrl.RolloutSet, batch *anyseq.Batch, t int) *anyseq.Batch {
+	var chunkSize int
+	if n := batch.NumPresent(); n > 0 {
+		chunkSize = batch.Packed.Len() / n
+	}
+	res := &anyseq.Batch{Present: make([]bool, len(batch.Present))}
+	var chunks []anyvec.Vector
+	var offset int
+	for i, pres := range batch.Present {
+		if pres {
+			chunks = append(chunks, batch.Packed.Slice(offset, offset+chunkSize))
+			offset += chunkSize
+			res.Present[i] = true
+		} else if len(r.Rewards[i]) == t && r.IsTruncated(i) && r.FinalInput(i) != nil {
+			chunks = append(chunks, r.FinalInput(i))
+			res.Present[i] = true
+		}
+	}
+	if len(chunks) == 0 {
+		res.Packed = r.Creator().MakeVector(0)
+	} else {
+		res.Packed = r.Creator().Concat(chunks...)
+	}
+	return res
+}
+
 func flattenRewards(r anyrl.Rewards) []float64 {
 	var values []float64
 	for _, seq := range r {
@@ -220,18 +409,3 @@ func unflattenRewards(output anyrl.Rewards, flat []float64) {
 		}
 	}
 }
-
-func vectorToComponents(vec anyvec.Vector) []float64 {
-	switch data := vec.Data().(type) {
-	case []float32:
-		res := make([]float64, len(data))
-		for i, x := range data {
-			res[i] = float64(x)
-		}
-		return res
-	case []float64:
-		return data
-	default:
-		panic("unsupported numeric type")
-	}
-}
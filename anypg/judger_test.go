@@ -6,6 +6,7 @@ import (
 
 	"github.com/unixpickle/anydiff/anyseq"
 	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/anyvec/anyvec64"
 	"github.com/unixpickle/lazyseq"
 )
@@ -89,6 +90,42 @@ func TestGAEJudgerNoBias(t *testing.T) {
 	testRewardsEquiv(t, actual, expected)
 }
 
+func TestGAEJudgerTruncated(t *testing.T) {
+	judger := &GAEJudger{
+		ValueFunc: identityValueFunc,
+		Discount:  0.5,
+		Lambda:    1,
+	}
+	actual := judger.JudgeActions(truncatedRolloutsForTest())
+	expected := [][]float64{
+		{3, 4},
+		{-0.5},
+		{-2.5, -4},
+	}
+	testRewardsEquiv(t, actual, expected)
+}
+
+func TestQJudgerTruncated(t *testing.T) {
+	rollouts := truncatedRolloutsForTest()
+
+	actual := (&QJudger{Discount: 0.5}).JudgeActions(rollouts)
+	expected := [][]float64{
+		{1.5, 1},
+		{1},
+		{1.5, 1},
+	}
+	testRewardsEquiv(t, actual, expected)
+
+	judger := &QJudger{Discount: 0.5, ValueFunc: identityValueFunc}
+	actual = judger.JudgeActions(rollouts)
+	expected = [][]float64{
+		{4, 6},
+		{1},
+		{1.5, 1},
+	}
+	testRewardsEquiv(t, actual, expected)
+}
+
 func TestTotalJudger(t *testing.T) {
 	rewards := [][]float64{
 		{1, 2, 3, 1},
@@ -111,6 +148,70 @@ func TestTotalJudger(t *testing.T) {
 	testRewardsEquiv(t, actual, expected)
 }
 
+func TestCVaRJudger(t *testing.T) {
+	rewards := [][]float64{
+		{1, 2},
+		{-1},
+		{0.5, 0.5},
+		{5},
+	}
+
+	actual := (&CVaRJudger{Alpha: 0.5}).JudgeActions(&anyrl.RolloutSet{Rewards: rewards})
+	expected := [][]float64{
+		{0, 0},
+		{-4},
+		{0, 0},
+		{0},
+	}
+
+	testRewardsEquiv(t, actual, expected)
+}
+
+func TestDifferentialJudger(t *testing.T) {
+	judger := &DifferentialJudger{}
+
+	actual := judger.JudgeActions(&anyrl.RolloutSet{Rewards: [][]float64{{1, 3}, {2}}})
+	testRewardsEquiv(t, actual, [][]float64{{0, 1}, {0}})
+
+	actual = judger.JudgeActions(&anyrl.RolloutSet{Rewards: [][]float64{{4}}})
+	testRewardsEquiv(t, actual, [][]float64{{1.5}})
+	if avg := judger.AverageReward(); math.Abs(avg-2.5) > 1e-8 {
+		t.Errorf("expected average reward 2.5 but got %f", avg)
+	}
+}
+
+func TestDifferentialJudgerTruncated(t *testing.T) {
+	// Every reward is 1, so the differential rewards are
+	// all 0 and only the values matter.
+	judger := &DifferentialJudger{ValueFunc: identityValueFunc, Lambda: 1}
+	actual := judger.JudgeActions(truncatedRolloutsForTest())
+	expected := [][]float64{
+		{9, 8},
+		{0},
+		{-4, -5},
+	}
+	testRewardsEquiv(t, actual, expected)
+}
+
+func TestLagrangianJudger(t *testing.T) {
+	rewards := [][]float64{{1, 0.5}, {2}}
+	costs := [][]float64{{0, 1}, {3}}
+
+	lagrangian := &Lagrangian{Budget: 1, StepSize: 1}
+	if m := lagrangian.Update(costs); m != 1 {
+		t.Fatalf("expected multiplier 1 but got %f", m)
+	}
+
+	judger := &LagrangianJudger{Lagrangian: lagrangian, Costs: costs}
+	actual := judger.JudgeActions(&anyrl.RolloutSet{Rewards: rewards})
+	expected := [][]float64{
+		{(1.5 - 1) / 2.0, (0.5 - 1) / 2.0},
+		{(2.0 - 3) / 2},
+	}
+
+	testRewardsEquiv(t, actual, expected)
+}
+
 func testRewardsEquiv(t *testing.T, actual, expected anyrl.Rewards) {
 	if len(actual) != len(expected) {
 		t.Errorf("expected %d sequences but got %d", len(expected), len(actual))
@@ -136,3 +237,70 @@ func testRewardsEquiv(t *testing.T, actual, expected anyrl.Rewards) {
 		}
 	}
 }
+
+func TestPipeline(t *testing.T) {
+	rewards := [][]float64{
+		{1, 0.5, 4},
+		{},
+		{0.5, -3},
+	}
+	p := &Pipeline{
+		Rewards: []RewardTransform{
+			&RewardClip{Min: -1, Max: 2},
+			&RewardScale{Scale: 2},
+			&IntrinsicSum{
+				Intrinsic: func(r *anyrl.RolloutSet) anyrl.Rewards {
+					return [][]float64{{1, 1, 1}, {}, {1, 1}}
+				},
+			},
+		},
+		Judger:     &QJudger{Discount: 0.5},
+		Advantages: []RewardTransform{&RewardScale{Scale: 2}},
+	}
+
+	actual := p.JudgeActions(&anyrl.RolloutSet{Rewards: rewards})
+	expected := [][]float64{
+		{10.5, 9, 10},
+		{},
+		{3, -2},
+	}
+
+	testRewardsEquiv(t, actual, expected)
+	if rewards[0][2] != 4 {
+		t.Error("original rewards were modified")
+	}
+}
+
+func TestIntrinsicSumMissing(t *testing.T) {
+	r := &anyrl.RolloutSet{
+		Rewards:   anyrl.Rewards{{1, 2}, {3}},
+		Intrinsic: anyrl.Rewards{{0.5, 0.5}},
+	}
+	actual := (&IntrinsicSum{}).Transform(r, r.Rewards)
+	testRewardsEquiv(t, actual, anyrl.Rewards{{1.5, 2.5}, {3}})
+
+	r.Intrinsic = nil
+	actual = (&IntrinsicSum{}).Transform(r, r.Rewards)
+	testRewardsEquiv(t, actual, r.Rewards)
+}
+
+// truncatedRolloutsForTest creates rollouts where the
+// first two episodes are truncated, but only the first
+// has a final input.
+// Each input is a single number.
+func truncatedRolloutsForTest() *anyrl.RolloutSet {
+	c := anyvec64.DefaultCreator{}
+	inputs := anyrl.Rewards{{1, 2}, {3}, {4, 5}}
+	return &anyrl.RolloutSet{
+		Inputs:      inputs.Tape(c),
+		Rewards:     anyrl.Rewards{{1, 1}, {1}, {1, 1}},
+		Truncated:   []bool{true, true, false},
+		FinalInputs: []anyvec.Vector{c.MakeVectorData([]float64{10}), nil, nil},
+	}
+}
+
+// identityValueFunc uses each (single number) input as
+// its own value.
+func identityValueFunc(inputs lazyseq.Rereader) <-chan *anyseq.Batch {
+	return inputs.Forward()
+}
@@ -0,0 +1,356 @@
+package anypg
+
+import (
+	"math"
+	"sync"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+)
+
+// Default settings for KFAC.
+const (
+	DefaultKFACDamping = 1e-2
+	DefaultKFACDecay   = 0.95
+)
+
+// KFACLayer wraps an anynet.FC and records the statistics
+// needed for Kronecker-factored curvature estimates.
+//
+// A KFACLayer serializes as its underlying FC, so saved
+// policies do not depend on K-FAC.
+type KFACLayer struct {
+	FC *anynet.FC
+
+	lock      sync.Mutex
+	recording bool
+	inSum     []float64
+	inCount   int
+	outSum    []float64
+	outCount  int
+
+	// Running estimates of the two Kronecker factors.
+	inFactor  []float64
+	outFactor []float64
+}
+
+// Apply applies the FC layer, recording the inputs and
+// the gradients of the outputs if statistics are being
+// gathered.
+func (k *KFACLayer) Apply(in anydiff.Res, n int) anydiff.Res {
+	out := k.FC.Apply(in, n)
+	k.lock.Lock()
+	recording := k.recording
+	k.lock.Unlock()
+	if !recording {
+		return out
+	}
+	c := in.Output().Creator()
+	inData := c.Float64Slice(in.Output().Data())
+	augmented := make([]float64, 0, n*(k.FC.InCount+1))
+	for i := 0; i < n; i++ {
+		augmented = append(augmented, inData[i*k.FC.InCount:(i+1)*k.FC.InCount]...)
+		augmented = append(augmented, 1)
+	}
+	k.lock.Lock()
+	k.inSum = addOuterProducts(k.inSum, augmented, k.FC.InCount+1)
+	k.inCount += n
+	k.lock.Unlock()
+	return &kfacRes{Res: out, Layer: k}
+}
+
+// Parameters returns the parameters of the FC layer.
+func (k *KFACLayer) Parameters() []*anydiff.Var {
+	return k.FC.Parameters()
+}
+
+// SerializerType returns the serializer type of the FC
+// layer.
+func (k *KFACLayer) SerializerType() string {
+	return k.FC.SerializerType()
+}
+
+// Serialize serializes the FC layer.
+func (k *KFACLayer) Serialize() ([]byte, error) {
+	return k.FC.Serialize()
+}
+
+func (k *KFACLayer) setRecording(r bool) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.recording = r
+}
+
+// updateFactors folds the recorded statistics into the
+// running factor estimates.
+func (k *KFACLayer) updateFactors(decay float64) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.inCount == 0 || k.outCount == 0 {
+		return
+	}
+	newIn := scaleSlice(k.inSum, 1/float64(k.inCount))
+	newOut := scaleSlice(k.outSum, 1/float64(k.outCount))
+	if k.inFactor == nil {
+		k.inFactor, k.outFactor = newIn, newOut
+	} else {
+		k.inFactor = mixSlices(k.inFactor, newIn, decay)
+		k.outFactor = mixSlices(k.outFactor, newOut, decay)
+	}
+	k.inSum, k.outSum = nil, nil
+	k.inCount, k.outCount = 0, 0
+}
+
+// precondition replaces the layer's entries in grad with
+// G^-1 * grad * A^-1, where A and G are the damped input
+// and output factors.
+func (k *KFACLayer) precondition(grad anydiff.Grad, damping float64) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	weightGrad, ok1 := grad[k.FC.Weights]
+	biasGrad, ok2 := grad[k.FC.Biases]
+	if !ok1 || !ok2 || k.inFactor == nil {
+		return
+	}
+	c := weightGrad.Creator()
+	in, out := k.FC.InCount, k.FC.OutCount
+	weights := c.Float64Slice(weightGrad.Data())
+	biases := c.Float64Slice(biasGrad.Data())
+
+	mat := make([]float64, out*(in+1))
+	for o := 0; o < out; o++ {
+		copy(mat[o*(in+1):], weights[o*in:(o+1)*in])
+		mat[o*(in+1)+in] = biases[o]
+	}
+
+	dampingRoot := math.Sqrt(damping)
+	inInv := invertMatrix(addIdentity(k.inFactor, in+1, dampingRoot), in+1)
+	outInv := invertMatrix(addIdentity(k.outFactor, out, dampingRoot), out)
+	mat = matMul(outInv, mat, out, out, in+1)
+	mat = matMul(mat, inInv, out, in+1, in+1)
+
+	for o := 0; o < out; o++ {
+		copy(weights[o*in:(o+1)*in], mat[o*(in+1):o*(in+1)+in])
+		biases[o] = mat[o*(in+1)+in]
+	}
+	weightGrad.SetData(c.MakeNumericList(weights))
+	biasGrad.SetData(c.MakeNumericList(biases))
+}
+
+type kfacRes struct {
+	anydiff.Res
+	Layer *KFACLayer
+}
+
+func (k *kfacRes) Propagate(u anyvec.Vector, g anydiff.Grad) {
+	c := u.Creator()
+	k.Layer.lock.Lock()
+	if k.Layer.recording {
+		data := c.Float64Slice(u.Data())
+		k.Layer.outSum = addOuterProducts(k.Layer.outSum, data, k.Layer.FC.OutCount)
+		k.Layer.outCount += len(data) / k.Layer.FC.OutCount
+	}
+	k.Layer.lock.Unlock()
+	k.Res.Propagate(u, g)
+}
+
+// KFAC maintains Kronecker-factored approximations of the
+// Fisher information matrix for a set of KFACLayers.
+//
+// See https://arxiv.org/abs/1503.05671.
+type KFAC struct {
+	Layers []*KFACLayer
+
+	// Damping is added to the Fisher approximation
+	// before it is inverted.
+	// The square root of Damping is added to each of the
+	// two factors.
+	//
+	// If 0, DefaultKFACDamping is used.
+	Damping float64
+
+	// Decay is the factor for the running averages of the
+	// curvature statistics.
+	//
+	// If 0, DefaultKFACDecay is used.
+	Decay float64
+}
+
+// WrapKFAC replaces every anynet.FC in an anynet.Net,
+// anyrnn.Stack, or anyrnn.LayerBlock with a KFACLayer and
+// returns a KFAC for the new layers.
+//
+// Layers are replaced in place, so obj itself cannot be
+// an *anynet.FC.
+func WrapKFAC(obj interface{}) *KFAC {
+	return &KFAC{Layers: wrapKFAC(obj)}
+}
+
+func wrapKFAC(obj interface{}) []*KFACLayer {
+	var res []*KFACLayer
+	switch obj := obj.(type) {
+	case anynet.Net:
+		for i, layer := range obj {
+			if fc, ok := layer.(*anynet.FC); ok {
+				wrapped := &KFACLayer{FC: fc}
+				obj[i] = wrapped
+				res = append(res, wrapped)
+			} else {
+				res = append(res, wrapKFAC(layer)...)
+			}
+		}
+	case anyrnn.Stack:
+		for _, block := range obj {
+			res = append(res, wrapKFAC(block)...)
+		}
+	case *anyrnn.LayerBlock:
+		if fc, ok := obj.Layer.(*anynet.FC); ok {
+			wrapped := &KFACLayer{FC: fc}
+			obj.Layer = wrapped
+			res = append(res, wrapped)
+		} else {
+			res = append(res, wrapKFAC(obj.Layer)...)
+		}
+	}
+	return res
+}
+
+// Record calls f while gathering curvature statistics,
+// then updates the running factor estimates.
+//
+// The function f should propagate the gradients of the
+// per-timestep log-likelihoods of sampled actions through
+// the layers.
+func (k *KFAC) Record(f func()) {
+	for _, layer := range k.Layers {
+		layer.setRecording(true)
+	}
+	f()
+	for _, layer := range k.Layers {
+		layer.setRecording(false)
+		layer.updateFactors(k.decay())
+	}
+}
+
+// Precondition multiplies the entries of grad belonging
+// to the KFACLayers by the inverse of the approximate
+// Fisher matrix.
+//
+// Other entries are left unchanged.
+func (k *KFAC) Precondition(grad anydiff.Grad) {
+	for _, layer := range k.Layers {
+		layer.precondition(grad, k.damping())
+	}
+}
+
+func (k *KFAC) damping() float64 {
+	if k.Damping == 0 {
+		return DefaultKFACDamping
+	}
+	return k.Damping
+}
+
+func (k *KFAC) decay() float64 {
+	if k.Decay == 0 {
+		return DefaultKFACDecay
+	}
+	return k.Decay
+}
+
+func addOuterProducts(sum, rows []float64, size int) []float64 {
+	if sum == nil {
+		sum = make([]float64, size*size)
+	}
+	for start := 0; start < len(rows); start += size {
+		row := rows[start : start+size]
+		for i, x := range row {
+			for j, y := range row {
+				sum[i*size+j] += x * y
+			}
+		}
+	}
+	return sum
+}
+
+func scaleSlice(s []float64, scale float64) []float64 {
+	res := make([]float64, len(s))
+	for i, x := range s {
+		res[i] = x * scale
+	}
+	return res
+}
+
+func mixSlices(old, new []float64, decay float64) []float64 {
+	res := make([]float64, len(old))
+	for i, x := range old {
+		res[i] = decay*x + (1-decay)*new[i]
+	}
+	return res
+}
+
+func addIdentity(mat []float64, size int, scale float64) []float64 {
+	res := append([]float64{}, mat...)
+	for i := 0; i < size; i++ {
+		res[i*size+i] += scale
+	}
+	return res
+}
+
+// matMul multiplies an n-by-m matrix by an m-by-p matrix.
+func matMul(a, b []float64, n, m, p int) []float64 {
+	res := make([]float64, n*p)
+	for i := 0; i < n; i++ {
+		for k := 0; k < m; k++ {
+			x := a[i*m+k]
+			for j := 0; j < p; j++ {
+				res[i*p+j] += x * b[k*p+j]
+			}
+		}
+	}
+	return res
+}
+
+// invertMatrix inverts a square matrix with Gauss-Jordan
+// elimination and partial pivoting.
+func invertMatrix(mat []float64, size int) []float64 {
+	a := append([]float64{}, mat...)
+	inv := make([]float64, size*size)
+	for i := 0; i < size; i++ {
+		inv[i*size+i] = 1
+	}
+	for col := 0; col < size; col++ {
+		pivot := col
+		for row := col + 1; row < size; row++ {
+			if math.Abs(a[row*size+col]) > math.Abs(a[pivot*size+col]) {
+				pivot = row
+			}
+		}
+		if pivot != col {
+			for j := 0; j < size; j++ {
+				a[col*size+j], a[pivot*size+j] = a[pivot*size+j], a[col*size+j]
+				inv[col*size+j], inv[pivot*size+j] = inv[pivot*size+j], inv[col*size+j]
+			}
+		}
+		scale := 1 / a[col*size+col]
+		for j := 0; j < size; j++ {
+			a[col*size+j] *= scale
+			inv[col*size+j] *= scale
+		}
+		for row := 0; row < size; row++ {
+			if row == col {
+				continue
+			}
+			factor := a[row*size+col]
+			if factor == 0 {
+				continue
+			}
+			for j := 0; j < size; j++ {
+				a[row*size+j] -= factor * a[col*size+j]
+				inv[row*size+j] -= factor * inv[col*size+j]
+			}
+		}
+	}
+	return inv
+}
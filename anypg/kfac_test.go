@@ -0,0 +1,57 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/serializer"
+)
+
+func TestKFACPrecondition(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	fc := anynet.NewFC(c, 1, 1)
+	net := anynet.Net{fc}
+	kfac := WrapKFAC(net)
+	kfac.Damping = 1e-12
+	if len(kfac.Layers) != 1 || net[0] != kfac.Layers[0] {
+		t.Fatal("layer was not wrapped")
+	}
+
+	kfac.Record(func() {
+		in := anydiff.NewConst(anyvec.Make(c, []float64{1, 2}))
+		out := net.Apply(in, 2)
+		out.Propagate(anyvec.Make(c, []float64{1, 1}), anydiff.NewGrad(fc.Parameters()...))
+	})
+
+	grad := anydiff.Grad{
+		fc.Weights: anyvec.Make(c, []float64{1}),
+		fc.Biases:  anyvec.Make(c, []float64{0}),
+	}
+	kfac.Precondition(grad)
+
+	// The input factor is [[2.5, 1.5], [1.5, 1]] and the
+	// output factor is [[1]].
+	actual := []float64{
+		grad[fc.Weights].Data().([]float64)[0],
+		grad[fc.Biases].Data().([]float64)[0],
+	}
+	expected := []float64{4, -6}
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-3 {
+			t.Errorf("expected %v but got %v", expected, actual)
+			break
+		}
+	}
+
+	copied, err := serializer.Copy(net[0].(serializer.Serializer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := copied.(*anynet.FC); !ok {
+		t.Errorf("expected *anynet.FC but got %T", copied)
+	}
+}
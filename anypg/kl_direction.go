@@ -0,0 +1,123 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+)
+
+// A KLDirection selects the divergence used to measure
+// the size of a policy update.
+//
+// KL divergence is asymmetric.
+// Constraining KL(new||old) is more mode-seeking than
+// constraining KL(old||new), since the new policy is
+// penalized for putting probability where the old policy
+// had little.
+//
+// All of the directions have the same second-order
+// behavior, so they only differ in constraints on finite
+// steps (e.g. in the TRPO line search).
+type KLDirection int
+
+// These are the supported KL directions.
+const (
+	// ForwardKL uses KL(old||new).
+	ForwardKL KLDirection = iota
+
+	// ReverseKL uses KL(new||old).
+	ReverseKL
+
+	// SymmetricKL uses the mean of the forward and
+	// reverse divergences.
+	SymmetricKL
+
+	// JensenShannonKL uses four times the Jensen-Shannon
+	// divergence, which is bounded and symmetric.
+	// The factor of four gives it the same second-order
+	// behavior as the other directions.
+	//
+	// It requires the mixture of two action distributions
+	// to be in the same family, so it is only supported
+	// for anyrl.Softmax and *anyrl.Bernoulli.
+	JensenShannonKL
+)
+
+// String returns a human-readable name for the
+// direction.
+func (k KLDirection) String() string {
+	switch k {
+	case ForwardKL:
+		return "forward"
+	case ReverseKL:
+		return "reverse"
+	case SymmetricKL:
+		return "symmetric"
+	case JensenShannonKL:
+		return "jensen-shannon"
+	default:
+		return "unknown"
+	}
+}
+
+// kl computes the divergence between the old and new
+// policy outputs according to n.KLDirection.
+func (n *NaturalPG) kl(oldOut, newOut anydiff.Res, num int) anydiff.Res {
+	switch n.KLDirection {
+	case ForwardKL:
+		return n.ActionSpace.KL(oldOut, newOut, num)
+	case ReverseKL:
+		return n.ActionSpace.KL(newOut, oldOut, num)
+	case SymmetricKL:
+		c := oldOut.Output().Creator()
+		return anydiff.Scale(anydiff.Add(
+			n.ActionSpace.KL(oldOut, newOut, num),
+			n.ActionSpace.KL(newOut, oldOut, num),
+		), c.MakeNumeric(0.5))
+	case JensenShannonKL:
+		c := oldOut.Output().Creator()
+		mix := mixtureParams(n.ActionSpace, oldOut, newOut, num)
+		return anydiff.Scale(anydiff.Add(
+			n.ActionSpace.KL(oldOut, mix, num),
+			n.ActionSpace.KL(newOut, mix, num),
+		), c.MakeNumeric(2))
+	default:
+		panic("unknown KL direction: " + n.KLDirection.String())
+	}
+}
+
+// mixtureParams computes the parameters of an equal
+// mixture of two batches of distributions.
+func mixtureParams(space interface{}, params1, params2 anydiff.Res, num int) anydiff.Res {
+	switch space.(type) {
+	case anyrl.Softmax, *anyrl.Softmax:
+		chunkSize := params1.Output().Len() / num
+		return logMixture(
+			anydiff.LogSoftmax(params1, chunkSize),
+			anydiff.LogSoftmax(params2, chunkSize),
+		)
+	case *anyrl.Bernoulli:
+		c := params1.Output().Creator()
+		minusOne := c.MakeNumeric(-1)
+		on := logMixture(anydiff.LogSigmoid(params1), anydiff.LogSigmoid(params2))
+		off := logMixture(
+			anydiff.LogSigmoid(anydiff.Scale(params1, minusOne)),
+			anydiff.LogSigmoid(anydiff.Scale(params2, minusOne)),
+		)
+		return anydiff.Sub(on, off)
+	default:
+		panic("Jensen-Shannon divergence requires anyrl.Softmax or *anyrl.Bernoulli")
+	}
+}
+
+// logMixture computes log((exp(a)+exp(b))/2) in a
+// numerically stable way, using the fact that
+// log(1+exp(b-a)) = -logSigmoid(a-b).
+func logMixture(a, b anydiff.Res) anydiff.Res {
+	c := a.Output().Creator()
+	return anydiff.AddScalar(
+		anydiff.Sub(a, anydiff.LogSigmoid(anydiff.Sub(a, b))),
+		c.MakeNumeric(math.Log(0.5)),
+	)
+}
@@ -0,0 +1,95 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestKLDirection(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	oldOut := anydiff.NewConst(anyvec.Make(c, []float64{1, 0, -1, 0.5, 0.5, 2}))
+	newOut := anydiff.NewConst(anyvec.Make(c, []float64{0, 2, 0, -1, 1, 1}))
+	space := anyrl.Softmax{}
+	forward := space.KL(oldOut, newOut, 2).Output().Data().([]float64)
+	reverse := space.KL(newOut, oldOut, 2).Output().Data().([]float64)
+
+	for _, direction := range []KLDirection{ForwardKL, ReverseKL, SymmetricKL} {
+		npg := &NaturalPG{ActionSpace: space, KLDirection: direction}
+		actual := npg.kl(oldOut, newOut, 2).Output().Data().([]float64)
+		for i, a := range actual {
+			var expected float64
+			switch direction {
+			case ForwardKL:
+				expected = forward[i]
+			case ReverseKL:
+				expected = reverse[i]
+			case SymmetricKL:
+				expected = (forward[i] + reverse[i]) / 2
+			}
+			if math.Abs(a-expected) > 1e-8 {
+				t.Errorf("%s %d: expected %f but got %f", direction, i, expected, a)
+			}
+		}
+	}
+	if math.Abs(forward[0]-reverse[0]) < 1e-3 {
+		t.Error("test divergences should be asymmetric")
+	}
+}
+
+func TestJensenShannonKL(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	for _, space := range []NaturalActionSpace{anyrl.Softmax{}, &anyrl.Bernoulli{}} {
+		oldParams := []float64{1, 0, -1, 0.5, 0.5, 2}
+		newParams := []float64{0, 2, 0, -1, 1, 1}
+		oldOut := anydiff.NewConst(anyvec.Make(c, oldParams))
+		newOut := anydiff.NewConst(anyvec.Make(c, newParams))
+		npg := &NaturalPG{ActionSpace: space, KLDirection: JensenShannonKL}
+		actual := npg.kl(oldOut, newOut, 2).Output().Data().([]float64)
+
+		for i := 0; i < 2; i++ {
+			p := distProbs(space, oldParams[i*3:(i+1)*3])
+			q := distProbs(space, newParams[i*3:(i+1)*3])
+			var expected float64
+			for j := range p {
+				m := (p[j] + q[j]) / 2
+				expected += 2 * (p[j]*math.Log(p[j]/m) + q[j]*math.Log(q[j]/m))
+			}
+			if math.Abs(actual[i]-expected) > 1e-8 {
+				t.Errorf("%T %d: expected %f but got %f", space, i, expected, actual[i])
+			}
+		}
+	}
+
+	// For small steps, the divergence should match the
+	// forward KL.
+	oldOut := anydiff.NewConst(anyvec.Make(c, []float64{1, 0, -1}))
+	newOut := anydiff.NewConst(anyvec.Make(c, []float64{1.001, -0.002, -1}))
+	forward := (&NaturalPG{ActionSpace: anyrl.Softmax{}}).kl(oldOut, newOut, 1)
+	js := (&NaturalPG{ActionSpace: anyrl.Softmax{}, KLDirection: JensenShannonKL}).kl(
+		oldOut, newOut, 1)
+	f := c.Float64(anyvec.Sum(forward.Output()))
+	j := c.Float64(anyvec.Sum(js.Output()))
+	if math.Abs(f-j)/f > 1e-2 {
+		t.Errorf("expected %f to be close to %f", j, f)
+	}
+}
+
+// distProbs computes the probabilities of the outcomes
+// of a Softmax distribution, or of the off/on outcomes of
+// each Bernoulli variable.
+func distProbs(space NaturalActionSpace, params []float64) []float64 {
+	if _, ok := space.(anyrl.Softmax); ok {
+		return softmaxSlice(params)
+	}
+	var res []float64
+	for _, x := range params {
+		on := 1 / (1 + math.Exp(-x))
+		res = append(res, 1-on, on)
+	}
+	return res
+}
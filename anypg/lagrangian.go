@@ -0,0 +1,122 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/lazyseq"
+)
+
+// Lagrangian implements Lagrangian relaxation for
+// constrained RL.
+//
+// It maintains a multiplier on a cost signal and combines
+// reward and cost advantages into a single advantage which
+// can be used with PPO or with PG.
+// The multiplier is adapted so that the expected total
+// cost per episode approaches Budget.
+type Lagrangian struct {
+	// Budget is the maximum allowed expected total cost
+	// per episode.
+	Budget float64
+
+	// StepSize is the step size for updating the
+	// multiplier.
+	StepSize float64
+
+	// Multiplier is the current Lagrange multiplier.
+	// It may be set to choose an initial value.
+	Multiplier float64
+}
+
+// Update adapts the multiplier based on the costs from a
+// batch of rollouts.
+//
+// It returns the new multiplier.
+func (l *Lagrangian) Update(costs anyrl.Rewards) float64 {
+	l.Multiplier = math.Max(0, l.Multiplier+l.StepSize*(costs.Mean()-l.Budget))
+	return l.Multiplier
+}
+
+// Combine combines per-timestep reward and cost
+// advantages into per-timestep advantages for the
+// Lagrangian objective.
+//
+// The result is normalized by (1+Multiplier) so that its
+// scale does not grow with the multiplier.
+func (l *Lagrangian) Combine(rewardAdv, costAdv anyrl.Rewards) anyrl.Rewards {
+	res := make(anyrl.Rewards, len(rewardAdv))
+	scale := 1 / (1 + l.Multiplier)
+	for i, seq := range rewardAdv {
+		res[i] = make([]float64, len(seq))
+		for t, x := range seq {
+			res[i][t] = (x - l.Multiplier*costAdv[i][t]) * scale
+		}
+	}
+	return res
+}
+
+// Advantage is like Combine, but for advantage tapes such
+// as the ones produced by PPO.Advantage.
+//
+// A cost advantage tape can be computed by running a
+// GAEJudger with a cost critic on a copy of the rollouts
+// in which the rewards are replaced with the costs.
+func (l *Lagrangian) Advantage(rewardAdv, costAdv lazyseq.Tape) lazyseq.Tape {
+	c := rewardAdv.Creator()
+	res, writer := lazyseq.ReferenceTape(c)
+	costCh := costAdv.ReadTape(0, -1)
+	for rewardBatch := range rewardAdv.ReadTape(0, -1) {
+		costBatch := <-costCh
+		packed := costBatch.Packed.Copy()
+		packed.Scale(c.MakeNumeric(-l.Multiplier))
+		packed.Add(rewardBatch.Packed)
+		packed.Scale(c.MakeNumeric(1 / (1 + l.Multiplier)))
+		writer <- &anyseq.Batch{Packed: packed, Present: rewardBatch.Present}
+	}
+	for _ = range costCh {
+	}
+	close(writer)
+	return res
+}
+
+// LagrangianJudger is an ActionJudger which combines the
+// judgements of rewards and costs using a Lagrangian.
+//
+// It can be used as the ActionJudger for PG.
+type LagrangianJudger struct {
+	Lagrangian *Lagrangian
+
+	// Costs stores the cost at every timestep of the
+	// rollouts being judged.
+	// It should be updated for every batch.
+	Costs anyrl.Rewards
+
+	// RewardJudger judges actions based on the rewards.
+	//
+	// If nil, QJudger is used.
+	RewardJudger ActionJudger
+
+	// CostJudger judges actions based on the costs.
+	//
+	// If nil, QJudger is used.
+	CostJudger ActionJudger
+}
+
+// JudgeActions combines the reward and cost judgements.
+func (l *LagrangianJudger) JudgeActions(r *anyrl.RolloutSet) anyrl.Rewards {
+	costRollouts := *r
+	costRollouts.Rewards = l.Costs
+	return l.Lagrangian.Combine(
+		judgerOrQ(l.RewardJudger).JudgeActions(r),
+		judgerOrQ(l.CostJudger).JudgeActions(&costRollouts),
+	)
+}
+
+func judgerOrQ(j ActionJudger) ActionJudger {
+	if j == nil {
+		return &QJudger{}
+	}
+	return j
+}
@@ -4,13 +4,11 @@ import (
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anydiff/anyfwd"
 	"github.com/unixpickle/anydiff/anyseq"
-	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anyrnn"
 	"github.com/unixpickle/anyrl"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/lazyseq"
 	"github.com/unixpickle/lazyseq/lazyrnn"
-	"github.com/unixpickle/serializer"
 )
 
 // Default number of iterations for Conjugate Gradients.
@@ -37,14 +35,55 @@ type NaturalPG struct {
 	// If 0, DefaultConjGradIters is used.
 	Iters int
 
+	// Tol, if non-zero, stops Conjugate Gradients early
+	// once the norm of the residual falls below it.
+	// Iters still limits the number of iterations.
+	Tol float64
+
+	// LogConjGrad, if non-nil, is called after Conjugate
+	// Gradients finishes with the number of iterations
+	// performed and the norm of the final residual.
+	LogConjGrad func(iters int, residual anyvec.Numeric)
+
+	// CGCache, if non-nil, is used to start Conjugate
+	// Gradients from the previous solution.
+	CGCache *ConjGradCache
+
+	// DiagPrecond, if true, preconditions Conjugate
+	// Gradients with a diagonal estimate of the Fisher
+	// matrix, which speeds up convergence when the
+	// parameters are badly scaled.
+	//
+	// The estimate uses the squared score functions of
+	// the sampled actions, which costs one extra backward
+	// pass per episode.
+	DiagPrecond bool
+
 	// Damping specifies the damping coefficient for the
 	// Conjugate Gradients algorithm.
 	// It is the multiple of the identity matrix to add
 	// to the Fisher information matrix.
 	Damping float64
 
+	// UseHessian, if true, replaces the Fisher matrix
+	// with the Hessian of the negative surrogate
+	// objective (the importance-weighted advantages).
+	// This is a tighter model of the objective, but it
+	// is not guaranteed to be positive definite, so
+	// Damping should usually be set as well.
+	// Hessian-vector products use forward auto-diff for
+	// the entire backward pass, so they are slower than
+	// Fisher-vector products.
+	UseHessian bool
+
 	// ApplyPolicy applies a policy to an input sequence.
 	// If nil, back-propagation through time is used.
+	//
+	// Fisher-vector products are computed one timestep
+	// at a time, so the only part of a RolloutSet which
+	// must fit in memory is whatever ApplyPolicy keeps.
+	// For very large batches, use a function that does
+	// not store every hidden state.
 	ApplyPolicy func(s lazyseq.Rereader, b anyrnn.Block) lazyseq.Rereader
 
 	// ActionJudger is used to judge actions.
@@ -60,10 +99,94 @@ type NaturalPG struct {
 	// If nil, all rollouts are used.
 	Reduce func(in *anyrl.RolloutSet) *anyrl.RolloutSet
 
+	// FisherFrac, if non-zero, is the fraction of
+	// episodes used to estimate the Fisher matrix.
+	// Whole episodes are kept so that recurrent policies
+	// see complete sequences.
+	//
+	// It is a shorthand for setting Reduce to use an
+	// anyrl.FracReducer, and it is ignored if Reduce is
+	// non-nil.
+	FisherFrac float64
+
 	// Regularizer is used to regularize the action space.
+	// The regularization term is added to the surrogate
+	// objective, so it affects the gradient passed to
+	// Conjugate Gradients and, for TRPO, the improvement
+	// checked by the line search.
 	//
 	// If nil, no regularization is used.
 	Regularizer Regularizer
+
+	// EpisodeKL, if true, indicates that KL divergences
+	// should be averaged over the timesteps of each
+	// episode and then over episodes, rather than over
+	// all timesteps at once.
+	//
+	// This gives every episode equal weight in the trust
+	// region, which matters for recurrent policies whose
+	// later actions depend on earlier ones.
+	EpisodeKL bool
+
+	// KLDiscount, if non-zero, weights the KL divergence
+	// at timestep t of an episode by KLDiscount^t.
+	// This focuses the trust region on the beginnings of
+	// episodes, where changes to the policy affect the
+	// most future timesteps.
+	// The weights are normalized so that the trust region
+	// has the same overall scale.
+	// It may be combined with EpisodeKL.
+	KLDiscount float64
+
+	// KLWeights, if non-nil, computes custom weights for
+	// the KL divergence at every timestep, overriding
+	// EpisodeKL and KLDiscount.
+	// The weights should have a mean of about 1.
+	// Episode weights from the RolloutSet are applied on
+	// top of them.
+	KLWeights func(r *anyrl.RolloutSet) anyrl.Rewards
+
+	// KLDirection selects the divergence used to measure
+	// the size of a step.
+	// The default is ForwardKL, i.e. KL(old||new).
+	KLDirection KLDirection
+
+	// Telemetry, if non-nil, records the norms of the
+	// policy gradient and the natural gradient.
+	Telemetry *Telemetry
+
+	// Modes, if non-nil, is switched to TrainMode before
+	// the policy is applied.
+	Modes *anyrl.ModeController
+}
+
+// NaturalPGStats contains diagnostics about a step
+// computed by NaturalPG or TRPO.
+type NaturalPGStats struct {
+	// ZeroGrad is true if the policy gradient was zero,
+	// in which case no other statistics are set.
+	ZeroGrad bool
+
+	// CGIters is the number of Conjugate Gradients
+	// iterations performed.
+	CGIters int
+
+	// CGResidual is the norm of the final residual of
+	// Conjugate Gradients.
+	CGResidual anyvec.Numeric
+
+	// The following fields are only set by TRPO.
+	//
+	// MeanKL and Improvement are the mean KL divergence
+	// and the surrogate improvement of the last step
+	// evaluated by the line search.
+	// LineSearchIters is the number of times the step was
+	// shrunk, and StepScale is the total factor by which
+	// it was shrunk.
+	MeanKL          anyvec.Numeric
+	Improvement     anyvec.Numeric
+	LineSearchIters int
+	StepScale       float64
 }
 
 // Run computes the natural gradient for the rollouts.
@@ -71,8 +194,19 @@ func (n *NaturalPG) Run(r *anyrl.RolloutSet) anydiff.Grad {
 	return n.run(r).Grad
 }
 
+// RunStats is like Run, but it also returns diagnostics
+// about the step.
+func (n *NaturalPG) RunStats(r *anyrl.RolloutSet) (anydiff.Grad, *NaturalPGStats) {
+	res := n.run(r)
+	return res.Grad, res.stats()
+}
+
 func (n *NaturalPG) run(r *anyrl.RolloutSet) *naturalPGRes {
-	res := &naturalPGRes{ReducedRollouts: r}
+	if n.Modes != nil {
+		n.Modes.Set(anyrl.TrainMode)
+	}
+
+	res := &naturalPGRes{ReducedRollouts: r, Curvature: n.curvature(r)}
 	pg := &PG{
 		Policy: func(in lazyseq.Rereader) lazyseq.Rereader {
 			res.PolicyOut = lazyseq.MakeReuser(n.apply(in, n.Policy))
@@ -85,6 +219,9 @@ func (n *NaturalPG) run(r *anyrl.RolloutSet) *naturalPGRes {
 		Regularizer:  n.Regularizer,
 	}
 	res.Grad = pg.Run(r)
+	if n.Telemetry != nil {
+		n.Telemetry.RecordGrad(res.Grad)
+	}
 
 	// We check for an all-zero gradient because that is
 	// a fairly common case (if all rollouts were optimal,
@@ -94,19 +231,24 @@ func (n *NaturalPG) run(r *anyrl.RolloutSet) *naturalPGRes {
 		return res
 	}
 
-	if n.Reduce != nil {
-		res.ReducedRollouts = n.Reduce(r)
+	if reduce := n.reducer(); reduce != nil {
+		res.ReducedRollouts = reduce(r)
 		in := lazyseq.TapeRereader(res.ReducedRollouts.Inputs)
 		res.ReducedOut = lazyseq.MakeReuser(n.apply(in, n.Policy))
+		res.Curvature = n.curvature(res.ReducedRollouts)
 	}
 
-	n.conjugateGradients(res.ReducedRollouts, res.ReducedOut, res.Grad)
+	res.CGIters, res.CGResidual = n.conjugateGradients(res.ReducedRollouts,
+		res.Curvature, res.ReducedOut, res.Grad)
+	if n.Telemetry != nil {
+		n.Telemetry.RecordNatural(res.Grad)
+	}
 
 	return res
 }
 
-func (n *NaturalPG) conjugateGradients(r *anyrl.RolloutSet, policyOuts lazyseq.Reuser,
-	grad anydiff.Grad) {
+func (n *NaturalPG) conjugateGradients(r *anyrl.RolloutSet, applyCurv curvatureFunc,
+	policyOuts lazyseq.Reuser, grad anydiff.Grad) (iters int, residualNorm anyvec.Numeric) {
 	c := r.Creator()
 	ops := c.NumOps()
 
@@ -115,24 +257,47 @@ func (n *NaturalPG) conjugateGradients(r *anyrl.RolloutSet, policyOuts lazyseq.R
 	// Algorithm taken from
 	// https://en.wikipedia.org/wiki/Conjugate_gradient_method#The_resulting_algorithm.
 
-	// x = 0
-	x := zeroGrad(grad)
+	// x = 0, or the previous solution
+	var x anydiff.Grad
+	if n.CGCache != nil {
+		x = n.CGCache.initialGuess(grad)
+	}
 
-	// r = b - Ax = b
+	// r = b - Ax
 	residual := copyGrad(grad)
+	if x != nil {
+		policyOuts.Reuse()
+		subFromGrad(residual, applyCurv(x, policyOuts))
+	} else {
+		x = zeroGrad(grad)
+	}
 
-	// p = r
-	proj := copyGrad(grad)
+	// z = M^-1 * r, where M is the preconditioner
+	precond := copyGrad
+	if n.DiagPrecond {
+		precond = n.diagPreconditioner(r, grad)
+	}
+	preconded := precond(residual)
+
+	// p = z
+	proj := copyGrad(preconded)
 
 	residualMag := dotGrad(residual, residual)
+	residualDot := dotGrad(residual, preconded)
+	tolSq := c.MakeNumeric(n.Tol * n.Tol)
+
+	for iters < n.iters() {
+		if n.Tol != 0 && ops.Less(residualMag, tolSq) {
+			break
+		}
+		iters++
 
-	for i := 0; i < n.iters(); i++ {
 		// A*p
 		policyOuts.Reuse()
-		appliedProj := n.applyFisher(r, proj, policyOuts)
+		appliedProj := applyCurv(proj, policyOuts)
 
-		// (r dot r) / (p dot A*p)
-		alpha := ops.Div(residualMag, dotGrad(proj, appliedProj))
+		// (r dot z) / (p dot A*p)
+		alpha := ops.Div(residualDot, dotGrad(proj, appliedProj))
 
 		// x = x + alpha*p
 		alphaProj := copyGrad(proj)
@@ -143,23 +308,160 @@ func (n *NaturalPG) conjugateGradients(r *anyrl.RolloutSet, policyOuts lazyseq.R
 		appliedProj.Scale(alpha)
 		subFromGrad(residual, appliedProj)
 
-		// (newR dot newR) / (r dot r)
-		newResidualMag := dotGrad(residual, residual)
-		beta := ops.Div(newResidualMag, residualMag)
-		residualMag = newResidualMag
+		// (newR dot newZ) / (r dot z)
+		residualMag = dotGrad(residual, residual)
+		preconded = precond(residual)
+		newResidualDot := dotGrad(residual, preconded)
+		beta := ops.Div(newResidualDot, residualDot)
+		residualDot = newResidualDot
 
-		// p = beta*p + r
+		// p = beta*p + z
 		oldProj := proj
-		proj = copyGrad(residual)
+		proj = preconded
 		oldProj.Scale(beta)
 		addToGrad(proj, oldProj)
 	}
 
+	residualNorm = ops.Pow(residualMag, c.MakeNumeric(0.5))
+	if n.LogConjGrad != nil {
+		n.LogConjGrad(iters, residualNorm)
+	}
+	if n.CGCache != nil {
+		n.CGCache.store(x)
+	}
+
 	setGrad(grad, x)
+	return
+}
+
+// diagPreconditioner estimates the diagonal of the
+// Fisher matrix and returns a function which divides a
+// gradient by it.
+//
+// Scores from different timesteps of an episode are
+// uncorrelated in expectation, so the squared gradient of
+// an episode's total log-likelihood estimates the sum of
+// the squared per-timestep scores.
+func (n *NaturalPG) diagPreconditioner(r *anyrl.RolloutSet,
+	grad anydiff.Grad) func(g anydiff.Grad) anydiff.Grad {
+	c := r.Creator()
+	diag := zeroGrad(grad)
+	for i, seq := range r.Rewards {
+		if len(seq) == 0 {
+			continue
+		}
+		episode := anyrl.SelectRollouts(r, []int{i})
+		logProbs := lazyseq.MapN(func(num int, v ...anydiff.Res) anydiff.Res {
+			return n.ActionSpace.LogProb(v[0], v[1].Output(), num)
+		}, n.apply(lazyseq.TapeRereader(episode.Inputs), n.Policy),
+			lazyseq.TapeRereader(episode.Actions))
+		score := zeroGrad(grad)
+		lazyseq.Sum(logProbs).Propagate(anyvec.Make(c, []float64{1}), score)
+		for _, v := range score {
+			v.Mul(v.Copy())
+			v.Scale(c.MakeNumeric(r.Weight(i)))
+		}
+		addToGrad(diag, score)
+	}
+
+	// Damping is part of the matrix being inverted, and
+	// the epsilon prevents division by zero for unused
+	// parameters.
+	const epsilon = 1e-8
+	diag.Scale(c.MakeNumeric(1 / float64(r.NumSteps())))
+	for _, v := range diag {
+		v.AddScalar(c.MakeNumeric(n.Damping + epsilon))
+	}
+
+	return func(g anydiff.Grad) anydiff.Grad {
+		res := copyGrad(g)
+		for variable, v := range res {
+			v.Div(diag[variable])
+		}
+		return res
+	}
+}
+
+// A curvatureFunc computes the product of a gradient
+// with the curvature matrix used by Conjugate Gradients.
+type curvatureFunc func(grad anydiff.Grad, oldOuts lazyseq.Rereader) anydiff.Grad
+
+// curvature creates a curvatureFunc for the rollouts,
+// which uses the Fisher matrix (or the Hessian, if
+// n.UseHessian is set).
+//
+// Anything which only depends on the rollouts, such as
+// the advantages for the Hessian, is computed once and
+// then reused for every product.
+func (n *NaturalPG) curvature(r *anyrl.RolloutSet) curvatureFunc {
+	if n.UseHessian {
+		var advantages lazyseq.Tape
+		return func(grad anydiff.Grad, oldOuts lazyseq.Rereader) anydiff.Grad {
+			if advantages == nil {
+				judger := (&PG{ActionJudger: n.ActionJudger}).actionJudger()
+				advantages = r.ApplyWeights(judger.JudgeActions(r)).Tape(r.Creator())
+			}
+			return n.applyHessian(r, grad, oldOuts, advantages)
+		}
+	}
+	return func(grad anydiff.Grad, oldOuts lazyseq.Rereader) anydiff.Grad {
+		return n.applyFisher(r, grad, oldOuts)
+	}
 }
 
+// applyFisher computes the product of the Fisher matrix
+// with grad.
 func (n *NaturalPG) applyFisher(r *anyrl.RolloutSet, grad anydiff.Grad,
 	oldOuts lazyseq.Rereader) anydiff.Grad {
+	if fisher, ok := n.ActionSpace.(anyrl.AnalyticFisher); ok {
+		return n.analyticFisher(r, grad, oldOuts, fisher)
+	}
+	tapes := []lazyseq.Tape{n.klWeights(r).Tape(r.Creator())}
+	return n.curvatureProduct(r, grad, oldOuts, tapes, false,
+		func(num int, oldOut, out anydiff.Res, v []anydiff.Res) anydiff.Res {
+			return anydiff.Mul(n.kl(oldOut, out, num), v[0])
+		})
+}
+
+// applyHessian computes the product of the Hessian of
+// the negative surrogate objective with grad.
+//
+// The advantages should already include the episode
+// weights of the rollouts.
+func (n *NaturalPG) applyHessian(r *anyrl.RolloutSet, grad anydiff.Grad,
+	oldOuts lazyseq.Rereader, advantages lazyseq.Tape) anydiff.Grad {
+	tapes := []lazyseq.Tape{r.Actions, advantages}
+	return n.curvatureProduct(r, grad, oldOuts, tapes, true,
+		func(num int, oldOut, out anydiff.Res, v []anydiff.Res) anydiff.Res {
+			actions, advs := v[0].Output(), v[1]
+			ratios := anydiff.Exp(anydiff.Sub(
+				n.ActionSpace.LogProb(out, actions, num),
+				n.ActionSpace.LogProb(oldOut, actions, num),
+			))
+			return anydiff.Scale(anydiff.Mul(ratios, advs),
+				ratios.Output().Creator().MakeNumeric(-1))
+		})
+}
+
+// curvatureProduct computes the product of grad with the
+// Hessian of the mean of a per-timestep function, taken
+// at the current parameters.
+//
+// The function f is passed the current policy outputs
+// as constants, the differentiable policy outputs, and
+// the values of the extra tapes.
+//
+// If secondOrder is false, the second derivatives of the
+// policy itself are skipped, giving a Gauss-Newton
+// product.
+// This is only exact when the gradient of f with respect
+// to the policy outputs is zero, as it is for the KL
+// divergence at the current parameters.
+// Otherwise, forward auto-diff is used for the entire
+// backward pass.
+func (n *NaturalPG) curvatureProduct(r *anyrl.RolloutSet, grad anydiff.Grad,
+	oldOuts lazyseq.Rereader, tapes []lazyseq.Tape, secondOrder bool,
+	f func(num int, oldOut, out anydiff.Res, v []anydiff.Res) anydiff.Res) anydiff.Grad {
 	c := &anyfwd.Creator{
 		ValueCreator: r.Creator(),
 		GradSize:     1,
@@ -167,18 +469,26 @@ func (n *NaturalPG) applyFisher(r *anyrl.RolloutSet, grad anydiff.Grad,
 	fwdBlock, paramMap := n.makeFwd(c, grad)
 	fwdIn := &makeFwdTape{Tape: r.Inputs, creator: c}
 
-	outSeq := &unfwdRereader{
-		Fwd:          n.apply(lazyseq.TapeRereader(fwdIn), fwdBlock),
-		Regular:      oldOuts,
-		FwdToRegular: paramMap,
+	outSeq := n.apply(lazyseq.TapeRereader(fwdIn), fwdBlock)
+	if !secondOrder {
+		outSeq = &unfwdRereader{
+			Fwd:          outSeq,
+			Regular:      oldOuts,
+			FwdToRegular: paramMap,
+		}
+	}
+	seqs := []lazyseq.Rereader{outSeq}
+	for _, tape := range tapes {
+		seqs = append(seqs, lazyseq.TapeRereader(&makeFwdTape{Tape: tape, creator: c}))
 	}
-	klSeq := lazyseq.Map(outSeq, func(v anydiff.Res, num int) anydiff.Res {
-		zeroGrad := c.ValueCreator.MakeVector(v.Output().Len())
-		constVec := v.Output().Copy()
+	termSeq := lazyseq.MapN(func(num int, v ...anydiff.Res) anydiff.Res {
+		out := v[0]
+		zeroGrad := c.ValueCreator.MakeVector(out.Output().Len())
+		constVec := out.Output().Copy()
 		constVec.(*anyfwd.Vector).Jacobian[0].Set(zeroGrad)
-		return n.ActionSpace.KL(anydiff.NewConst(constVec), v, num)
-	})
-	kl := lazyseq.Mean(klSeq)
+		return f(num, anydiff.NewConst(constVec), out, v[1:])
+	}, seqs...)
+	mean := lazyseq.Mean(termSeq)
 
 	newGrad := anydiff.Grad{}
 	for newParam, oldParam := range paramMap {
@@ -189,22 +499,124 @@ func (n *NaturalPG) applyFisher(r *anyrl.RolloutSet, grad anydiff.Grad,
 
 	one := c.MakeVector(1)
 	one.AddScalar(c.MakeNumeric(1))
-	kl.Propagate(one, newGrad)
+	mean.Propagate(one, newGrad)
 
 	out := anydiff.Grad{}
 	for newParam, paramGrad := range newGrad {
 		oldParam := paramMap[newParam]
 		out[oldParam] = paramGrad.(*anyfwd.Vector).Jacobian[0]
-		if n.Damping > 0 {
-			scaledOld := grad[oldParam].Copy()
-			scaledOld.Scale(c.ValueCreator.MakeNumeric(n.Damping))
-			out[oldParam].Add(scaledOld)
-		}
 	}
+	n.addDamping(out, grad)
+
+	return out
+}
+
+// analyticFisher computes the product of the Fisher
+// matrix with grad as J'*F*J*grad, where J is the
+// Jacobian of the policy outputs and F is the Fisher
+// matrix of the action distributions.
+//
+// Only J*grad requires forward auto-diff, and the
+// product with J' is a regular backward pass.
+// Like curvatureProduct, this is done one timestep at a
+// time, so nothing beyond what n.apply stores is kept in
+// memory.
+func (n *NaturalPG) analyticFisher(r *anyrl.RolloutSet, grad anydiff.Grad,
+	oldOuts lazyseq.Rereader, fisher anyrl.AnalyticFisher) anydiff.Grad {
+	c := &anyfwd.Creator{
+		ValueCreator: r.Creator(),
+		GradSize:     1,
+	}
+	fwdBlock, _ := n.makeFwd(c, grad)
+	fwdIn := &makeFwdTape{Tape: r.Inputs, creator: c}
+	fwdOut := &constRereader{n.apply(lazyseq.TapeRereader(fwdIn), fwdBlock)}
+	weights := lazyseq.TapeRereader(n.klWeights(r).Tape(r.Creator()))
+
+	termSeq := lazyseq.MapN(func(num int, v ...anydiff.Res) anydiff.Res {
+		// F*J*grad, scaled by the KL weights.
+		fwd := v[1].Output().(*anyfwd.Vector)
+		product := fisher.FisherProduct(fwd.Values, fwd.Jacobian[0], num)
+		anyvec.ScaleChunks(product, v[2].Output())
+		return batchedDot(v[0], anydiff.NewConst(product), num)
+	}, oldOuts, fwdOut, weights)
+
+	out := zeroGrad(grad)
+	lazyseq.Mean(termSeq).Propagate(anyvec.Make(r.Creator(), []float64{1}), out)
+	n.addDamping(out, grad)
 
 	return out
 }
 
+// addDamping adds n.Damping times grad to a curvature
+// product.
+func (n *NaturalPG) addDamping(product, grad anydiff.Grad) {
+	if n.Damping <= 0 {
+		return
+	}
+	for variable, vec := range product {
+		scaledOld := grad[variable].Copy()
+		scaledOld.Scale(scaledOld.Creator().MakeNumeric(n.Damping))
+		vec.Add(scaledOld)
+	}
+}
+
+// klWeights computes the weight of every timestep's KL
+// divergence when computing the mean KL divergence.
+// This accounts for the episode weights of the rollouts.
+func (n *NaturalPG) klWeights(r *anyrl.RolloutSet) anyrl.Rewards {
+	if n.KLWeights != nil {
+		res := n.KLWeights(r)
+		for i, seq := range res {
+			res[i] = append([]float64{}, seq...)
+			for t := range seq {
+				res[i][t] *= r.Weight(i)
+			}
+		}
+		return res
+	}
+
+	var numEpisodes int
+	for _, seq := range r.Rewards {
+		if len(seq) > 0 {
+			numEpisodes++
+		}
+	}
+	numSteps := float64(r.NumSteps())
+
+	// Compute the relative weights within each episode,
+	// then normalize them so that the weights sum to the
+	// number of timesteps, either per episode or overall.
+	res := make(anyrl.Rewards, len(r.Rewards))
+	var total float64
+	for i, seq := range r.Rewards {
+		res[i] = make([]float64, len(seq))
+		weight := 1.0
+		var episodeTotal float64
+		for t := range seq {
+			res[i][t] = weight
+			episodeTotal += weight
+			if n.KLDiscount != 0 {
+				weight *= n.KLDiscount
+			}
+		}
+		if n.EpisodeKL {
+			for t := range seq {
+				res[i][t] *= numSteps / (float64(numEpisodes) * episodeTotal)
+			}
+		}
+		total += episodeTotal
+	}
+	for i, seq := range res {
+		for t := range seq {
+			if !n.EpisodeKL {
+				seq[t] *= numSteps / total
+			}
+			seq[t] *= r.Weight(i)
+		}
+	}
+	return res
+}
+
 func (n *NaturalPG) apply(in lazyseq.Rereader, b anyrnn.Block) lazyseq.Rereader {
 	if n.ApplyPolicy == nil {
 		tape, writer := lazyseq.ReferenceTape(in.Creator())
@@ -216,23 +628,22 @@ func (n *NaturalPG) apply(in lazyseq.Rereader, b anyrnn.Block) lazyseq.Rereader
 
 func (n *NaturalPG) makeFwd(c *anyfwd.Creator, derivs anydiff.Grad) (anyrnn.Block,
 	map[*anydiff.Var]*anydiff.Var) {
-	fwdBlock, err := serializer.Copy(n.Policy)
-	if err != nil {
-		panic(err)
-	}
-	anyfwd.MakeFwd(c, fwdBlock)
-
-	newToOld := map[*anydiff.Var]*anydiff.Var{}
-	oldParams := anynet.AllParameters(n.Policy)
-	for i, newParam := range anynet.AllParameters(fwdBlock) {
-		oldParam := oldParams[i]
-		newToOld[newParam] = oldParam
+	fwdBlock, newToOld := copyPolicy(c, n.Policy)
+	for newParam, oldParam := range newToOld {
 		if deriv, ok := derivs[oldParam]; ok {
 			newParam.Vector.(*anyfwd.Vector).Jacobian[0].Set(deriv)
 		}
 	}
+	return fwdBlock, newToOld
+}
 
-	return fwdBlock.(anyrnn.Block), newToOld
+func (n *NaturalPG) reducer() func(in *anyrl.RolloutSet) *anyrl.RolloutSet {
+	if n.Reduce != nil {
+		return n.Reduce
+	} else if n.FisherFrac != 0 {
+		return (&anyrl.FracReducer{Frac: n.FisherFrac}).Reduce
+	}
+	return nil
 }
 
 func (n *NaturalPG) iters() int {
@@ -251,6 +662,21 @@ type naturalPGRes struct {
 	// Always non-nil, but may equal the unreduced version.
 	ReducedOut      lazyseq.Reuser
 	ReducedRollouts *anyrl.RolloutSet
+
+	// Curvature computes products with the curvature
+	// matrix for ReducedRollouts.
+	Curvature curvatureFunc
+
+	CGIters    int
+	CGResidual anyvec.Numeric
+}
+
+func (n *naturalPGRes) stats() *NaturalPGStats {
+	return &NaturalPGStats{
+		ZeroGrad:   n.ZeroGrad,
+		CGIters:    n.CGIters,
+		CGResidual: n.CGResidual,
+	}
 }
 
 func (n *naturalPGRes) Creator() anyvec.Creator {
@@ -337,6 +763,21 @@ func (u *unfwdRereader) Propagate(upstream <-chan *anyseq.Batch, grad lazyseq.Gr
 	})
 }
 
+// constRereader hides the variables of a Rereader so
+// that nothing is back-propagated through it.
+type constRereader struct {
+	lazyseq.Rereader
+}
+
+func (c *constRereader) Vars() anydiff.VarSet {
+	return anydiff.VarSet{}
+}
+
+func (c *constRereader) Propagate(upstream <-chan *anyseq.Batch, grad lazyseq.Grad) {
+	for _ = range upstream {
+	}
+}
+
 type surrogateGrad struct {
 	OrigGrad     lazyseq.Grad
 	FwdToRegular map[*anydiff.Var]*anydiff.Var
@@ -104,7 +104,177 @@ func TestFisher(t *testing.T) {
 	}
 }
 
+func TestAnalyticFisher(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+
+	block := &anyrnn.LayerBlock{
+		Layer: anynet.Net{
+			anynet.NewFC(c, 3, 2),
+			anynet.Tanh,
+			anynet.NewFC(c, 2, 4),
+		},
+	}
+
+	npg := &NaturalPG{
+		Policy:      block,
+		Params:      block.Parameters(),
+		ActionSpace: anyrl.Gaussian{},
+		Damping:     0.1,
+	}
+
+	inGrad := anydiff.NewGrad(block.Parameters()...)
+	for _, vec := range inGrad {
+		anyvec.Rand(vec, anyvec.Normal, nil)
+	}
+	outSeq := lazyseq.MakeReuser(npg.apply(lazyseq.TapeRereader(r.Inputs),
+		npg.Policy))
+
+	actual := npg.applyFisher(r, inGrad, outSeq)
+
+	// Compare to the Hessian of the KL divergence.
+	outSeq.Reuse()
+	tapes := []lazyseq.Tape{npg.klWeights(r).Tape(c)}
+	expected := npg.curvatureProduct(r, inGrad, outSeq, tapes, false,
+		func(num int, oldOut, out anydiff.Res, v []anydiff.Res) anydiff.Res {
+			return anydiff.Mul(npg.ActionSpace.KL(oldOut, out, num), v[0])
+		})
+
+	for variable, actualVec := range actual {
+		diff := actualVec.Copy()
+		diff.Sub(expected[variable])
+		if anyvec.AbsMax(diff).(float64) > 1e-5 {
+			t.Errorf("expected %v but got %v", expected[variable].Data(),
+				actualVec.Data())
+		}
+	}
+}
+
+func TestHessian(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+
+	block := &anyrnn.LayerBlock{
+		Layer: anynet.Net{
+			anynet.NewFC(c, 3, 2),
+			anynet.Tanh,
+			anynet.NewFC(c, 2, 2),
+		},
+	}
+
+	npg := &NaturalPG{
+		Policy:      block,
+		Params:      block.Parameters(),
+		ActionSpace: anyrl.Softmax{},
+		UseHessian:  true,
+	}
+
+	inGrad := anydiff.NewGrad(block.Parameters()...)
+	for _, vec := range inGrad {
+		anyvec.Rand(vec, anyvec.Normal, nil)
+	}
+	outSeq := lazyseq.MakeReuser(npg.apply(lazyseq.TapeRereader(r.Inputs),
+		npg.Policy))
+	oldOuts, writer := lazyseq.ReferenceTape(c)
+	for item := range outSeq.Forward() {
+		writer <- item.Reduce(item.Present)
+	}
+	close(writer)
+	outSeq.Reuse()
+
+	actual := npg.curvature(r)(inGrad, outSeq)
+
+	// Compare to finite differences of the gradient of the
+	// negative surrogate objective.
+	advantages := (&PG{}).actionJudger().JudgeActions(r).Tape(c)
+	surrogateGrad := func() anydiff.Grad {
+		outs := npg.apply(lazyseq.TapeRereader(r.Inputs), block)
+		terms := lazyseq.MapN(func(num int, v ...anydiff.Res) anydiff.Res {
+			actions := v[1].Output()
+			ratios := anydiff.Exp(anydiff.Sub(
+				npg.ActionSpace.LogProb(v[0], actions, num),
+				npg.ActionSpace.LogProb(v[2], actions, num),
+			))
+			return anydiff.Scale(anydiff.Mul(ratios, v[3]), c.MakeNumeric(-1))
+		}, outs, lazyseq.TapeRereader(r.Actions), lazyseq.TapeRereader(oldOuts),
+			lazyseq.TapeRereader(advantages))
+		grad := anydiff.NewGrad(block.Parameters()...)
+		lazyseq.Mean(terms).Propagate(anyvec.Make(c, []float64{1}), grad)
+		return grad
+	}
+
+	const epsilon = 1e-4
+	step := copyGrad(inGrad)
+	step.Scale(c.MakeNumeric(epsilon))
+	step.AddToVars()
+	expected := surrogateGrad()
+	step.Scale(c.MakeNumeric(-2))
+	step.AddToVars()
+	subFromGrad(expected, surrogateGrad())
+	step.Scale(c.MakeNumeric(-0.5))
+	step.AddToVars()
+	expected.Scale(c.MakeNumeric(1 / (2 * epsilon)))
+
+	for variable, actualVec := range actual {
+		diff := actualVec.Copy()
+		diff.Sub(expected[variable])
+		if anyvec.AbsMax(diff).(float64) > 1e-5 {
+			t.Errorf("expected %v but got %v", expected[variable].Data(),
+				actualVec.Data())
+		}
+	}
+}
+
+func TestKLWeights(t *testing.T) {
+	r := &anyrl.RolloutSet{
+		Rewards: anyrl.Rewards{{0, 0, 0}, {}, {0}},
+		Weights: []float64{1, 1, 2},
+	}
+	cases := []struct {
+		NPG      *NaturalPG
+		Expected anyrl.Rewards
+	}{
+		{&NaturalPG{}, anyrl.Rewards{{1, 1, 1}, {}, {2}}},
+		{&NaturalPG{EpisodeKL: true}, anyrl.Rewards{{2.0 / 3, 2.0 / 3, 2.0 / 3}, {}, {4}}},
+		{&NaturalPG{KLDiscount: 0.5}, anyrl.Rewards{{16.0 / 11, 8.0 / 11, 4.0 / 11}, {}, {32.0 / 11}}},
+		{
+			&NaturalPG{EpisodeKL: true, KLDiscount: 0.5},
+			anyrl.Rewards{{8.0 / 7, 4.0 / 7, 2.0 / 7}, {}, {4}},
+		},
+		{
+			&NaturalPG{
+				EpisodeKL: true,
+				KLWeights: func(r *anyrl.RolloutSet) anyrl.Rewards {
+					return anyrl.Rewards{{3, 2, 1}, {}, {1}}
+				},
+			},
+			anyrl.Rewards{{3, 2, 1}, {}, {2}},
+		},
+	}
+	for i, c := range cases {
+		actual := c.NPG.klWeights(r)
+		for j, seq := range c.Expected {
+			if len(actual[j]) != len(seq) {
+				t.Fatalf("case %d: expected %v but got %v", i, c.Expected, actual)
+			}
+			for k, x := range seq {
+				if math.Abs(actual[j][k]-x) > 1e-8 {
+					t.Errorf("case %d: expected %v but got %v", i, c.Expected, actual)
+				}
+			}
+		}
+	}
+}
+
 func TestConjugateGradients(t *testing.T) {
+	testConjugateGradients(t, false)
+}
+
+func TestConjugateGradientsDiagPrecond(t *testing.T) {
+	testConjugateGradients(t, true)
+}
+
+func testConjugateGradients(t *testing.T, diagPrecond bool) {
 	c := anyvec64.DefaultCreator{}
 	r := rolloutsForTest(c)
 
@@ -121,6 +291,7 @@ func TestConjugateGradients(t *testing.T) {
 		Params:      block.Parameters(),
 		ActionSpace: anyrl.Softmax{},
 		Iters:       14,
+		DiagPrecond: diagPrecond,
 	}
 
 	// We have to use the actual gradient to avoid the
@@ -136,7 +307,7 @@ func TestConjugateGradients(t *testing.T) {
 	solvedGrad := copyGrad(inGrad)
 
 	outSeq := lazyseq.MakeReuser(npg.apply(lazyseq.TapeRereader(r.Inputs), npg.Policy))
-	npg.conjugateGradients(r, outSeq, solvedGrad)
+	npg.conjugateGradients(r, npg.curvature(r), outSeq, solvedGrad)
 
 	// Check that F*solvedGrad = inGrad.
 	outSeq.Reuse()
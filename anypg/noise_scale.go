@@ -0,0 +1,114 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+)
+
+// DefaultNoiseScaleSmallBatch is the default number of
+// episodes in each small batch for NoiseScale.
+const DefaultNoiseScaleSmallBatch = 1
+
+// NoiseScale estimates the gradient noise scale, which
+// approximates the critical batch size beyond which
+// larger batches yield diminishing returns.
+//
+// The estimate compares the norm of the gradient for a
+// full batch of rollouts to the norms of gradients for
+// smaller subsets of its episodes.
+// Batch sizes are measured in episodes.
+//
+// See https://arxiv.org/abs/1812.06162.
+type NoiseScale struct {
+	// Grad computes a gradient for a batch of rollouts,
+	// e.g. by calling PG.Run.
+	//
+	// The gradient must not depend on statistics of the
+	// batch as a whole.
+	// In particular, advantages should not be normalized,
+	// so PG.Run should be used with an unnormalized judger
+	// such as QJudger rather than the default judger.
+	// With normalized advantages, the gradient of a
+	// single episode is always zero.
+	Grad func(r *anyrl.RolloutSet) anydiff.Grad
+
+	// SmallBatch is the number of episodes in each small
+	// batch.
+	//
+	// If 0, DefaultNoiseScaleSmallBatch is used.
+	SmallBatch int
+
+	// Smoothing, if non-zero, is the decay rate for
+	// exponential moving averages of the squared gradient
+	// norm and the gradient variance.
+	// The ratio of these averages is much less noisy than
+	// the ratio for a single batch.
+	Smoothing float64
+
+	// Log, if non-nil, is called with every estimate.
+	Log func(scale, gradSq, trace float64)
+
+	gradSq float64
+	trace  float64
+	init   bool
+}
+
+// Estimate estimates the noise scale using a batch of
+// rollouts.
+//
+// The batch must contain more than SmallBatch episodes.
+//
+// Estimate panics if every small batch has a zero
+// gradient while the full batch does not, since this
+// indicates that Grad normalizes its advantages.
+func (n *NoiseScale) Estimate(r *anyrl.RolloutSet) float64 {
+	bigBatch := len(r.Rewards)
+	smallBatch := n.smallBatch()
+	if bigBatch <= smallBatch {
+		panic("batch must be larger than the small batch size")
+	}
+
+	bigSq := math.Pow(GradNorm(n.Grad(r)), 2)
+
+	var smallSq float64
+	var numSmall int
+	for start := 0; start+smallBatch <= bigBatch; start += smallBatch {
+		indices := make([]int, smallBatch)
+		for i := range indices {
+			indices[i] = start + i
+		}
+		smallSq += math.Pow(GradNorm(n.Grad(anyrl.SelectRollouts(r, indices))), 2)
+		numSmall++
+	}
+	smallSq /= float64(numSmall)
+	if smallSq == 0 && bigSq != 0 {
+		panic("small batch gradients are zero (are advantages normalized?)")
+	}
+
+	b1, b2 := float64(smallBatch), float64(bigBatch)
+	gradSq := (b2*bigSq - b1*smallSq) / (b2 - b1)
+	trace := (smallSq - bigSq) / (1/b1 - 1/b2)
+
+	if n.Smoothing != 0 && n.init {
+		n.gradSq = n.Smoothing*n.gradSq + (1-n.Smoothing)*gradSq
+		n.trace = n.Smoothing*n.trace + (1-n.Smoothing)*trace
+	} else {
+		n.gradSq, n.trace = gradSq, trace
+		n.init = true
+	}
+
+	scale := n.trace / n.gradSq
+	if n.Log != nil {
+		n.Log(scale, n.gradSq, n.trace)
+	}
+	return scale
+}
+
+func (n *NoiseScale) smallBatch() int {
+	if n.SmallBatch == 0 {
+		return DefaultNoiseScaleSmallBatch
+	}
+	return n.SmallBatch
+}
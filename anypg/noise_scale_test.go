@@ -0,0 +1,81 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/lazyseq"
+)
+
+func TestNoiseScale(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	param := anydiff.NewVar(anyvec.Make(c, []float64{0}))
+	ns := &NoiseScale{
+		Grad: func(r *anyrl.RolloutSet) anydiff.Grad {
+			// Each episode contributes its total reward.
+			return anydiff.Grad{
+				param: anyvec.Make(c, []float64{r.Rewards.Mean()}),
+			}
+		},
+	}
+	r := &anyrl.RolloutSet{
+		Inputs:  anyrl.Rewards{{0}, {0}}.Tape(c),
+		Actions: anyrl.Rewards{{0}, {0}}.Tape(c),
+		Rewards: anyrl.Rewards{{1}, {3}},
+	}
+	if actual := ns.Estimate(r); math.Abs(actual-2.0/3) > 1e-8 {
+		t.Errorf("expected %f but got %f", 2.0/3, actual)
+	}
+}
+
+func TestNoiseScalePG(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+
+	block := &anyrnn.LayerBlock{
+		Layer: anynet.Net{
+			anynet.NewFC(c, 3, 2),
+			anynet.Tanh,
+			anynet.NewFC(c, 2, 2),
+		},
+	}
+	pg := &PG{
+		Policy: func(in lazyseq.Rereader) lazyseq.Rereader {
+			return lazyseq.Lazify(anyrnn.Map(lazyseq.Unlazify(in), block))
+		},
+		Params:       anynet.AllParameters(block),
+		ActionSpace:  anyrl.Softmax{},
+		ActionJudger: &QJudger{},
+	}
+
+	var gradSq, trace float64
+	ns := &NoiseScale{
+		Grad: pg.Run,
+		Log: func(scale, g, tr float64) {
+			gradSq, trace = g, tr
+		},
+	}
+	scale := ns.Estimate(r)
+	if math.IsNaN(scale) || math.IsInf(scale, 0) {
+		t.Errorf("invalid estimate: %f", scale)
+	}
+	if gradSq == 0 || trace == 0 {
+		t.Errorf("unexpected terms: gradSq=%f trace=%f", gradSq, trace)
+	}
+
+	// The default judger normalizes advantages, making
+	// single-episode gradients zero.
+	pg.ActionJudger = nil
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for normalized advantages")
+		}
+	}()
+	ns.Estimate(r)
+}
@@ -0,0 +1,144 @@
+package anypg
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// DefaultKLPenalty is the default KL coefficient for
+// KLPenaltyObjective.
+const DefaultKLPenalty = 1.0
+
+// An Objective computes a surrogate objective for policy
+// gradient methods, which is maximized during training.
+//
+// The params argument stores the current policy's action
+// space parameters, while oldParams stores the parameters
+// of the policy which produced the actions.
+// The advs argument stores an advantage for each action.
+//
+// The output vector contains one component per action.
+type Objective interface {
+	Objective(space anyrl.LogProber, params, oldParams anydiff.Res,
+		actions anyvec.Vector, advs anydiff.Res, n int) anydiff.Res
+}
+
+// spaceChecker is implemented by Objectives which only
+// support some action spaces.
+type spaceChecker interface {
+	checkSpace(space anyrl.LogProber)
+}
+
+// checkObjective panics if an Objective does not support
+// an action space.
+// It should be called before the objective is used, so
+// that misconfigurations fail early with a clear message.
+func checkObjective(obj Objective, space anyrl.LogProber) {
+	if s, ok := obj.(spaceChecker); ok {
+		s.checkSpace(space)
+	}
+}
+
+// VanillaObjective is the standard policy gradient
+// objective, the advantage-weighted log-likelihood.
+//
+// It does not use the old parameters, which may be nil.
+type VanillaObjective struct{}
+
+// Objective computes the objective.
+func (v VanillaObjective) Objective(space anyrl.LogProber, params, oldParams anydiff.Res,
+	actions anyvec.Vector, advs anydiff.Res, n int) anydiff.Res {
+	return anydiff.Mul(space.LogProb(params, actions, n), advs)
+}
+
+// ClippedObjective is the clipped surrogate objective
+// from PPO.
+// See PPOObjective for details.
+type ClippedObjective struct {
+	// Epsilon is the amount by which the probability
+	// ratio should change.
+	//
+	// If 0, DefaultPPOEpsilon is used.
+	Epsilon float64
+
+	// DualClip, if non-zero, bounds the objective for
+	// negative advantages from below by DualClip times
+	// the advantage, preventing huge probability ratios
+	// from dominating the update.
+	// It should be greater than 1.
+	//
+	// See https://arxiv.org/abs/1912.09729.
+	DualClip float64
+}
+
+// Objective computes the objective.
+func (c *ClippedObjective) Objective(space anyrl.LogProber, params, oldParams anydiff.Res,
+	actions anyvec.Vector, advs anydiff.Res, n int) anydiff.Res {
+	eps := c.Epsilon
+	if eps == 0 {
+		eps = DefaultPPOEpsilon
+	}
+	ratios := probRatios(space, params, oldParams, actions, n)
+	if c.DualClip == 0 {
+		return PPOObjective(actions.Creator().MakeNumeric(eps), ratios, advs)
+	}
+	return anydiff.Pool(advs, func(advs anydiff.Res) anydiff.Res {
+		obj := PPOObjective(actions.Creator().MakeNumeric(eps), ratios, advs)
+		return dualClip(obj, advs, c.DualClip)
+	})
+}
+
+// dualClip applies max(obj, coeff*adv) wherever the
+// advantage is negative.
+func dualClip(obj, advs anydiff.Res, coeff float64) anydiff.Res {
+	c := advs.Output().Creator()
+	negMask := advs.Output().Copy()
+	anyvec.LessThan(negMask, c.MakeNumeric(0))
+	return anydiff.Pool(obj, func(obj anydiff.Res) anydiff.Res {
+		bounded := anydiff.ElemMax(obj, anydiff.Scale(advs, c.MakeNumeric(coeff)))
+		return anydiff.Add(obj, anydiff.Mul(anydiff.Sub(bounded, obj),
+			anydiff.NewConst(negMask)))
+	})
+}
+
+// KLPenaltyObjective is the surrogate objective with a KL
+// penalty, as proposed as an alternative to clipping in
+// https://arxiv.org/abs/1707.06347.
+//
+// The action space must implement anyrl.KLer.
+type KLPenaltyObjective struct {
+	// Beta is the coefficient for the KL divergence from
+	// the old policy to the new one.
+	//
+	// If 0, DefaultKLPenalty is used.
+	Beta float64
+}
+
+// Objective computes the objective.
+func (k *KLPenaltyObjective) Objective(space anyrl.LogProber, params, oldParams anydiff.Res,
+	actions anyvec.Vector, advs anydiff.Res, n int) anydiff.Res {
+	beta := k.Beta
+	if beta == 0 {
+		beta = DefaultKLPenalty
+	}
+	return anydiff.Pool(params, func(params anydiff.Res) anydiff.Res {
+		surrogate := anydiff.Mul(probRatios(space, params, oldParams, actions, n), advs)
+		kl := space.(anyrl.KLer).KL(oldParams, params, n)
+		return anydiff.Sub(surrogate, anydiff.Scale(kl, actions.Creator().MakeNumeric(beta)))
+	})
+}
+
+func (k *KLPenaltyObjective) checkSpace(space anyrl.LogProber) {
+	if _, ok := space.(anyrl.KLer); !ok {
+		panic("KLPenaltyObjective requires an action space which implements anyrl.KLer")
+	}
+}
+
+func probRatios(space anyrl.LogProber, params, oldParams anydiff.Res,
+	actions anyvec.Vector, n int) anydiff.Res {
+	return anydiff.Exp(anydiff.Sub(
+		space.LogProb(params, actions, n),
+		space.LogProb(oldParams, actions, n),
+	))
+}
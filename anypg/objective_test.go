@@ -0,0 +1,79 @@
+package anypg
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestDualClip(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	obj := anydiff.NewConst(anyvec.Make(c, []float64{-5, 1, -1, 4}))
+	advs := anydiff.NewConst(anyvec.Make(c, []float64{-1, 1, -2, 2}))
+	actual := c.Float64Slice(dualClip(obj, advs, 3).Output().Data())
+	expected := []float64{-3, 1, -1, 4}
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-8 {
+			t.Errorf("expected %v but got %v", expected, actual)
+			break
+		}
+	}
+}
+
+func TestKLPenaltyObjective(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	oldLogits := []float64{0, 1, 2, 0}
+	newLogits := []float64{1, 0, 2, 1}
+	actions := []float64{1, 0, 0, 1}
+	advs := []float64{2, -1}
+
+	obj := &KLPenaltyObjective{Beta: 0.5}
+	actual := c.Float64Slice(obj.Objective(anyrl.Softmax{},
+		anydiff.NewConst(anyvec.Make(c, newLogits)),
+		anydiff.NewConst(anyvec.Make(c, oldLogits)),
+		anyvec.Make(c, actions),
+		anydiff.NewConst(anyvec.Make(c, advs)), 2).Output().Data())
+
+	for i := 0; i < 2; i++ {
+		p := softmaxSlice(oldLogits[i*2 : i*2+2])
+		q := softmaxSlice(newLogits[i*2 : i*2+2])
+		var kl, ratio float64
+		for j := range p {
+			kl += p[j] * math.Log(p[j]/q[j])
+			if actions[i*2+j] == 1 {
+				ratio = q[j] / p[j]
+			}
+		}
+		expected := ratio*advs[i] - 0.5*kl
+		if math.Abs(actual[i]-expected) > 1e-8 {
+			t.Errorf("action %d: expected %f but got %f", i, expected, actual[i])
+		}
+	}
+}
+
+func TestKLPenaltyObjectiveSpace(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	pg := &PG{
+		Policy:      layerPolicy(anynet.NewFC(c, 3, 2)),
+		ActionSpace: logProbOnly{anyrl.Softmax{}},
+		Objective:   &KLPenaltyObjective{},
+	}
+	defer func() {
+		if msg, ok := recover().(string); !ok || !strings.Contains(msg, "KLer") {
+			t.Errorf("expected a panic about anyrl.KLer but got %v", msg)
+		}
+	}()
+	pg.Run(rolloutsForTest(c))
+}
+
+// logProbOnly hides every method of an action space
+// except for LogProb.
+type logProbOnly struct {
+	anyrl.LogProber
+}
@@ -0,0 +1,93 @@
+package anypg
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// OffPolicyPG implements importance-sampled policy
+// gradients, allowing rollouts from a slightly stale
+// policy to be used for training.
+//
+// The rollouts must have AgentOuts, which are treated as
+// the parameters of the behavior policy.
+// Each log-likelihood term is weighted by the ratio
+// between the current and behavior probabilities of the
+// sampled action.
+type OffPolicyPG struct {
+	// Policy applies the policy to a sequence of inputs.
+	Policy func(s lazyseq.Rereader) lazyseq.Rereader
+
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// ActionSpace determines log-likelihoods of actions.
+	ActionSpace anyrl.LogProber
+
+	// ActionJudger is used to judge actions.
+	//
+	// If nil, TotalJudger is used.
+	ActionJudger ActionJudger
+
+	// Regularizer is used to regularize the action space.
+	//
+	// If nil, no regularization is used.
+	Regularizer Regularizer
+
+	// MaxWeight, if non-zero, is an upper bound on the
+	// importance weights.
+	// Truncating the weights reduces variance at the cost
+	// of some bias.
+	MaxWeight float64
+
+	// Clipper, if non-nil, is used to clip gradients
+	// before they are returned.
+	Clipper *GradClipper
+}
+
+// Run performs importance-sampled policy gradients on the
+// rollouts.
+func (o *OffPolicyPG) Run(r *anyrl.RolloutSet) anydiff.Grad {
+	grad := anydiff.NewGrad(o.Params...)
+	if len(grad) == 0 {
+		return grad
+	}
+	c := r.Creator()
+
+	judger := o.ActionJudger
+	if judger == nil {
+		judger = &TotalJudger{Normalize: true}
+	}
+	judgements := r.ApplyWeights(judger.JudgeActions(r))
+
+	scores := lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		actionParams, oldParams := v[0], v[1]
+		selected, rewards := v[2].Output(), v[3]
+		return anydiff.Pool(o.ActionSpace.LogProb(actionParams, selected, n),
+			func(logProb anydiff.Res) anydiff.Res {
+				oldLogProb := o.ActionSpace.LogProb(oldParams, selected, n)
+				weights := anydiff.Exp(anydiff.Sub(logProb, oldLogProb)).Output().Copy()
+				if o.MaxWeight != 0 {
+					anyvec.ClipRange(weights, c.MakeNumeric(0), c.MakeNumeric(o.MaxWeight))
+				}
+				cost := anydiff.Mul(anydiff.Mul(logProb, anydiff.NewConst(weights)), rewards)
+				if o.Regularizer != nil {
+					cost = anydiff.Add(cost, o.Regularizer.Regularize(actionParams, n))
+				}
+				return cost
+			})
+	}, o.Policy(lazyseq.TapeRereader(r.Inputs)), lazyseq.TapeRereader(r.AgentOuts),
+		lazyseq.TapeRereader(r.Actions), lazyseq.TapeRereader(judgements.Tape(c)))
+
+	score := lazyseq.Mean(scores)
+	score.Propagate(anyvec.Ones(c, 1), grad)
+
+	if o.Clipper != nil {
+		o.Clipper.Clip(grad)
+	}
+
+	return grad
+}
@@ -0,0 +1,82 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestOffPolicyPG(t *testing.T) {
+	for _, maxWeight := range []float64{0, 1} {
+		c := anyvec64.DefaultCreator{}
+		r := rolloutsForTest(c)
+		current := anynet.NewFC(c, 3, 2)
+		behavior := anynet.NewFC(c, 3, 2)
+		r.AgentOuts = layerTape(behavior, r.Inputs)
+
+		opg := &OffPolicyPG{
+			Policy:       layerPolicy(current),
+			Params:       current.Parameters(),
+			ActionSpace:  anyrl.Softmax{},
+			ActionJudger: &QJudger{},
+			MaxWeight:    maxWeight,
+		}
+		actual := opg.Run(r)
+
+		// Compute the importance weights by hand and use them
+		// to scale the advantages of a regular PG.
+		advs := (&QJudger{}).JudgeActions(r)
+		curOuts := tapeSteps(layerTape(current, r.Inputs), len(r.Rewards))
+		oldOuts := tapeSteps(r.AgentOuts, len(r.Rewards))
+		actions := tapeSteps(r.Actions, len(r.Rewards))
+		var numClipped int
+		for i, seq := range advs {
+			for step := range seq {
+				p := softmaxSlice(curOuts[i][step])
+				q := softmaxSlice(oldOuts[i][step])
+				for j, a := range actions[i][step] {
+					if a == 1 {
+						weight := p[j] / q[j]
+						if maxWeight != 0 && weight > maxWeight {
+							weight = maxWeight
+							numClipped++
+						}
+						seq[step] *= weight
+					}
+				}
+			}
+		}
+		if maxWeight != 0 && numClipped == 0 {
+			t.Fatal("no weights were clipped")
+		}
+		pg := &PG{
+			Policy:       opg.Policy,
+			Params:       opg.Params,
+			ActionSpace:  opg.ActionSpace,
+			ActionJudger: fixedJudger(advs),
+		}
+		expected := pg.Run(r)
+
+		for _, v := range opg.Params {
+			a := c.Float64Slice(actual[v].Data())
+			e := c.Float64Slice(expected[v].Data())
+			for i, x := range e {
+				if math.Abs(a[i]-x) > 1e-8 {
+					t.Errorf("max weight %f: expected %v but got %v", maxWeight, e, a)
+					break
+				}
+			}
+		}
+	}
+}
+
+// fixedJudger is an ActionJudger which always returns the
+// same judgements.
+type fixedJudger anyrl.Rewards
+
+func (f fixedJudger) JudgeActions(r *anyrl.RolloutSet) anyrl.Rewards {
+	return anyrl.Rewards(f)
+}
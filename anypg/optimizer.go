@@ -0,0 +1,83 @@
+package anypg
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+// An Optimizer applies the ascent directions produced by
+// trainers like PG, PPO, and TRPO to the parameters.
+//
+// It composes the directions with anysgd Transformers
+// (e.g. anysgd.Adam, anysgd.RMSProp, or anysgd.Momentum),
+// a learning rate schedule, and weight decay.
+type Optimizer struct {
+	// Transformers are applied to every direction, in
+	// order.
+	Transformers []anysgd.Transformer
+
+	// StepSize is the learning rate.
+	//
+	// If 0, a step size of 1 is used, which is suitable
+	// for directions which are already scaled (e.g. the
+	// ones from TRPO).
+	StepSize float64
+
+	// Rater, if non-nil, determines the learning rate
+	// given the number of steps taken so far.
+	// If it is set, StepSize is ignored.
+	//
+	// See WarmupRater, CosineRater, and StepRater for
+	// common schedules.
+	Rater anysgd.Rater
+
+	// WeightDecay, if non-zero, is the rate at which
+	// parameters decay towards zero.
+	// It is scaled by the learning rate, but it is not
+	// affected by the Transformers.
+	WeightDecay float64
+
+	numSteps int
+}
+
+// Step transforms the direction and adds it to the
+// parameters.
+//
+// The direction may be modified.
+func (o *Optimizer) Step(dir anydiff.Grad) {
+	if len(dir) == 0 {
+		return
+	}
+	step := dir
+	for _, t := range o.Transformers {
+		step = t.Transform(step)
+	}
+
+	stepSize := o.stepSize()
+	for v, vec := range step {
+		c := vec.Creator()
+		vec.Scale(c.MakeNumeric(stepSize))
+		if o.WeightDecay != 0 {
+			decay := v.Vector.Copy()
+			decay.Scale(c.MakeNumeric(-stepSize * o.WeightDecay))
+			vec.Add(decay)
+		}
+	}
+	step.AddToVars()
+	o.numSteps++
+}
+
+// NumSteps returns the number of steps taken so far.
+func (o *Optimizer) NumSteps() int {
+	return o.numSteps
+}
+
+func (o *Optimizer) stepSize() float64 {
+	if o.Rater != nil {
+		return o.Rater.Rate(float64(o.numSteps))
+	} else if o.StepSize == 0 {
+		return 1
+	} else {
+		return o.StepSize
+	}
+}
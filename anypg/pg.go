@@ -27,10 +27,30 @@ type PG struct {
 	//
 	// If nil, no regularization is used.
 	Regularizer Regularizer
+
+	// Objective is the objective to maximize.
+	// Objectives other than VanillaObjective use the
+	// AgentOuts of the rollouts as the old parameters.
+	//
+	// If nil, VanillaObjective is used.
+	Objective Objective
+
+	// Clipper, if non-nil, is used to clip gradients
+	// before they are returned.
+	Clipper *GradClipper
+
+	// Modes, if non-nil, is switched to TrainMode before
+	// the policy is applied.
+	Modes *anyrl.ModeController
 }
 
 // Run performs policy gradients on the rollouts.
 func (p *PG) Run(r *anyrl.RolloutSet) anydiff.Grad {
+	checkObjective(p.objective(), p.ActionSpace)
+	if p.Modes != nil {
+		p.Modes.Set(anyrl.TrainMode)
+	}
+
 	grad := anydiff.NewGrad(p.Params...)
 	if len(grad) == 0 {
 		return grad
@@ -40,28 +60,49 @@ func (p *PG) Run(r *anyrl.RolloutSet) anydiff.Grad {
 	policyOut := p.Policy(lazyseq.TapeRereader(r.Inputs))
 
 	selectedOuts := lazyseq.TapeRereader(r.Actions)
-	rewards := lazyseq.TapeRereader(p.actionJudger().JudgeActions(r).Tape(c))
+	judgements := r.ApplyWeights(p.actionJudger().JudgeActions(r))
+	rewards := lazyseq.TapeRereader(judgements.Tape(c))
+
+	seqs := []lazyseq.Rereader{policyOut, selectedOuts, rewards}
+	if _, ok := p.objective().(VanillaObjective); !ok {
+		seqs = append(seqs, lazyseq.TapeRereader(r.AgentOuts))
+	}
 
 	scores := lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
 		actionParams := v[0]
 		selected := v[1]
 		rewards := v[2]
-		logProb := p.ActionSpace.LogProb(actionParams, selected.Output(), n)
-		cost := anydiff.Mul(logProb, rewards)
+		var oldParams anydiff.Res
+		if len(v) > 3 {
+			oldParams = v[3]
+		}
+		cost := p.objective().Objective(p.ActionSpace, actionParams, oldParams,
+			selected.Output(), rewards, n)
 		if p.Regularizer != nil {
 			cost = anydiff.Add(cost, p.Regularizer.Regularize(actionParams, n))
 		}
 		return cost
-	}, policyOut, selectedOuts, rewards)
+	}, seqs...)
 
 	score := lazyseq.Mean(scores)
 	one := c.MakeVector(1)
 	one.AddScalar(c.MakeNumeric(1))
 	score.Propagate(one, grad)
 
+	if p.Clipper != nil {
+		p.Clipper.Clip(grad)
+	}
+
 	return grad
 }
 
+func (p *PG) objective() Objective {
+	if p.Objective == nil {
+		return VanillaObjective{}
+	}
+	return p.Objective
+}
+
 func (p *PG) actionJudger() ActionJudger {
 	if p.ActionJudger == nil {
 		return &TotalJudger{Normalize: true}
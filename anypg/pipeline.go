@@ -0,0 +1,156 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anyrl"
+)
+
+// A RewardTransform transforms per-timestep values, such
+// as rewards or advantages, for a batch of rollouts.
+//
+// Transform must not modify its arguments.
+type RewardTransform interface {
+	Transform(r *anyrl.RolloutSet, values anyrl.Rewards) anyrl.Rewards
+}
+
+// Pipeline is an ActionJudger which processes rewards in
+// a declared order.
+//
+// First, the rewards are passed through every transform
+// in Rewards.
+// Then the result is judged with Judger (e.g. to apply a
+// discount or GAE).
+// Finally, the judgements are passed through every
+// transform in Advantages (e.g. to normalize them).
+type Pipeline struct {
+	Rewards []RewardTransform
+
+	// Judger judges the transformed rewards.
+	//
+	// If nil, QJudger is used with no discount.
+	Judger ActionJudger
+
+	Advantages []RewardTransform
+}
+
+// JudgeActions runs the pipeline.
+func (p *Pipeline) JudgeActions(r *anyrl.RolloutSet) anyrl.Rewards {
+	rewards := r.Rewards
+	for _, t := range p.Rewards {
+		rewards = t.Transform(r, rewards)
+	}
+
+	judger := p.Judger
+	if judger == nil {
+		judger = &QJudger{}
+	}
+	transformed := *r
+	transformed.Rewards = rewards
+	res := judger.JudgeActions(&transformed)
+
+	for _, t := range p.Advantages {
+		res = t.Transform(r, res)
+	}
+	return res
+}
+
+// RewardClip is a RewardTransform which clips values to
+// the range [Min, Max].
+type RewardClip struct {
+	Min float64
+	Max float64
+}
+
+// Transform clips the values.
+func (c *RewardClip) Transform(r *anyrl.RolloutSet, values anyrl.Rewards) anyrl.Rewards {
+	return mapRewards(values, func(x float64) float64 {
+		return math.Max(c.Min, math.Min(c.Max, x))
+	})
+}
+
+// RewardScale is a RewardTransform which scales values by
+// a constant.
+type RewardScale struct {
+	Scale float64
+}
+
+// Transform scales the values.
+func (s *RewardScale) Transform(r *anyrl.RolloutSet, values anyrl.Rewards) anyrl.Rewards {
+	return mapRewards(values, func(x float64) float64 {
+		return x * s.Scale
+	})
+}
+
+// IntrinsicSum is a RewardTransform which adds intrinsic
+// rewards (e.g. curiosity bonuses) to the values.
+type IntrinsicSum struct {
+	// Intrinsic computes the intrinsic rewards for a
+	// batch of rollouts.
+	//
+	// If nil, the rollouts' Intrinsic field is used.
+	// Missing intrinsic rewards are treated as 0.
+	Intrinsic func(r *anyrl.RolloutSet) anyrl.Rewards
+
+	// Coeff scales the intrinsic rewards.
+	//
+	// If 0, a default of 1 is used.
+	Coeff float64
+}
+
+// Transform adds the intrinsic rewards to the values.
+func (i *IntrinsicSum) Transform(r *anyrl.RolloutSet, values anyrl.Rewards) anyrl.Rewards {
+	coeff := i.Coeff
+	if coeff == 0 {
+		coeff = 1
+	}
+	intrinsic := r.Intrinsic
+	if i.Intrinsic != nil {
+		intrinsic = i.Intrinsic(r)
+	}
+	res := make(anyrl.Rewards, len(values))
+	for j, seq := range values {
+		res[j] = make([]float64, len(seq))
+		for t, x := range seq {
+			res[j][t] = x
+			if j < len(intrinsic) && t < len(intrinsic[j]) {
+				res[j][t] += coeff * intrinsic[j][t]
+			}
+		}
+	}
+	return res
+}
+
+// RewardNormalize is a RewardTransform which normalizes
+// the values across all timesteps to have a mean of zero
+// and a standard deviation of 1.
+type RewardNormalize struct {
+	// Epsilon is a small fudge factor used to prevent
+	// numerical issues when dividing by the standard
+	// deviation.
+	//
+	// If this is 0, a reasonably small value is used.
+	Epsilon float64
+}
+
+// Transform normalizes the values.
+func (n *RewardNormalize) Transform(r *anyrl.RolloutSet, values anyrl.Rewards) anyrl.Rewards {
+	res := mapRewards(values, func(x float64) float64 {
+		return x
+	})
+	flat := flattenRewards(res)
+	(&TotalJudger{Normalize: true, Epsilon: n.Epsilon}).normalize(flat)
+	unflattenRewards(res, flat)
+	return res
+}
+
+func mapRewards(values anyrl.Rewards, f func(x float64) float64) anyrl.Rewards {
+	res := make(anyrl.Rewards, len(values))
+	for i, seq := range values {
+		res[i] = make([]float64, len(seq))
+		for t, x := range seq {
+			res[i][t] = f(x)
+		}
+	}
+	return res
+}
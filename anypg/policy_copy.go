@@ -0,0 +1,181 @@
+package anypg
+
+import (
+	"reflect"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyfwd"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/serializer"
+)
+
+// FwdDiffable is implemented by policies which know how
+// to copy themselves for NaturalPG and TRPO.
+//
+// Policies which implement neither FwdDiffable nor
+// serializer.Serializer are copied with reflection.
+type FwdDiffable interface {
+	// CopyWithCreator creates a deep copy of the block
+	// whose parameters use the creator c.
+	// It returns the copy and a mapping from each of the
+	// copy's parameters to the original parameter.
+	CopyWithCreator(c anyvec.Creator) (anyrnn.Block, map[*anydiff.Var]*anydiff.Var)
+}
+
+// A CreatorConverter is a layer or block with internal
+// vectors which are not parameters, such as running
+// statistics or lookup tables.
+//
+// When NaturalPG and TRPO copy a policy, they convert the
+// parameters to a new creator (e.g. an *anyfwd.Creator)
+// and then call ConvertCreator so that the remaining
+// vectors can be converted as well.
+// CreatorConverters are found inside anynet.Nets,
+// anyrnn.Stacks, and anyrnn.LayerBlocks.
+type CreatorConverter interface {
+	// ConvertCreator replaces the internal vectors with
+	// equivalent vectors from the creator c.
+	//
+	// The vectors should be replaced rather than
+	// modified in place, since a copied policy may share
+	// them with the original.
+	ConvertCreator(c anyvec.Creator)
+}
+
+// copyPolicy creates a deep copy of a policy whose
+// parameters use the creator c, along with a mapping from
+// new parameters to old ones.
+func copyPolicy(c anyvec.Creator, b anyrnn.Block) (anyrnn.Block,
+	map[*anydiff.Var]*anydiff.Var) {
+	if f, ok := b.(FwdDiffable); ok {
+		return f.CopyWithCreator(c)
+	}
+
+	fwdCreator, isFwd := c.(*anyfwd.Creator)
+	newToOld := map[*anydiff.Var]*anydiff.Var{}
+
+	if s, ok := b.(serializer.Serializer); ok {
+		copied, err := serializer.Copy(s)
+		if err != nil {
+			panic(err)
+		}
+		if isFwd {
+			anyfwd.MakeFwd(fwdCreator, copied)
+		}
+		oldParams := anynet.AllParameters(b)
+		for i, newParam := range anynet.AllParameters(copied) {
+			newToOld[newParam] = oldParams[i]
+		}
+		convertCreator(copied, c)
+		return copied.(anyrnn.Block), newToOld
+	}
+
+	copier := &deepCopier{
+		pointers: map[uintptr]reflect.Value{},
+		newToOld: newToOld,
+	}
+	copied := copier.Copy(reflect.ValueOf(b)).Interface().(anyrnn.Block)
+	if isFwd {
+		for newParam := range newToOld {
+			anyfwd.MakeFwd(fwdCreator, newParam)
+		}
+	}
+	convertCreator(copied, c)
+	return copied, newToOld
+}
+
+// convertCreator calls ConvertCreator on every
+// CreatorConverter in obj.
+func convertCreator(obj interface{}, c anyvec.Creator) {
+	switch obj := obj.(type) {
+	case CreatorConverter:
+		obj.ConvertCreator(c)
+	case anynet.Net:
+		for _, sub := range obj {
+			convertCreator(sub, c)
+		}
+	case anyrnn.Stack:
+		for _, sub := range obj {
+			convertCreator(sub, c)
+		}
+	case *anyrnn.LayerBlock:
+		convertCreator(obj.Layer, c)
+	}
+}
+
+// deepCopier copies arbitrary values with reflection.
+//
+// Pointers are copied at most once, so shared structure
+// is preserved.
+// Unexported struct fields are copied shallowly.
+type deepCopier struct {
+	pointers map[uintptr]reflect.Value
+	newToOld map[*anydiff.Var]*anydiff.Var
+}
+
+func (d *deepCopier) Copy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		if res, ok := d.pointers[v.Pointer()]; ok {
+			return res
+		}
+		if oldVar, ok := v.Interface().(*anydiff.Var); ok {
+			newVar := anydiff.NewVar(oldVar.Vector.Copy())
+			d.newToOld[newVar] = oldVar
+			res := reflect.ValueOf(newVar)
+			d.pointers[v.Pointer()] = res
+			return res
+		}
+		res := reflect.New(v.Type().Elem())
+		d.pointers[v.Pointer()] = res
+		res.Elem().Set(d.Copy(v.Elem()))
+		return res
+	case reflect.Struct:
+		res := reflect.New(v.Type()).Elem()
+		res.Set(v)
+		for i := 0; i < res.NumField(); i++ {
+			if res.Field(i).CanSet() {
+				res.Field(i).Set(d.Copy(v.Field(i)))
+			}
+		}
+		return res
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(d.Copy(v.Index(i)))
+		}
+		return res
+	case reflect.Array:
+		res := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(d.Copy(v.Index(i)))
+		}
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.MakeMap(v.Type())
+		for _, key := range v.MapKeys() {
+			res.SetMapIndex(key, d.Copy(v.MapIndex(key)))
+		}
+		return res
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		res := reflect.New(v.Type()).Elem()
+		res.Set(d.Copy(v.Elem()))
+		return res
+	default:
+		return v
+	}
+}
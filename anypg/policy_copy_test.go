@@ -0,0 +1,124 @@
+package anypg
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyfwd"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+// unserializableBlock is a custom Block which does not
+// implement serializer.Serializer.
+type unserializableBlock struct {
+	Inner  anyrnn.Block
+	Shared *anydiff.Var
+	Alias  *anydiff.Var
+}
+
+func (u *unserializableBlock) Start(n int) anyrnn.State {
+	return u.Inner.Start(n)
+}
+
+func (u *unserializableBlock) PropagateStart(s anyrnn.StateGrad, g anydiff.Grad) {
+	u.Inner.PropagateStart(s, g)
+}
+
+func (u *unserializableBlock) Step(s anyrnn.State, in anyvec.Vector) anyrnn.Res {
+	return u.Inner.Step(s, in)
+}
+
+func (u *unserializableBlock) Parameters() []*anydiff.Var {
+	return append(anynet.AllParameters(u.Inner), u.Shared)
+}
+
+func TestCopyPolicyReflection(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	shared := anydiff.NewVar(anyvec.Make(c, []float64{1, 2}))
+	block := &unserializableBlock{
+		Inner:  &anyrnn.LayerBlock{Layer: anynet.NewFC(c, 2, 3)},
+		Shared: shared,
+		Alias:  shared,
+	}
+
+	copied, newToOld := copyPolicy(c, block)
+	copiedBlock := copied.(*unserializableBlock)
+	if copiedBlock == block || copiedBlock.Inner == block.Inner {
+		t.Fatal("block was not deeply copied")
+	}
+	if copiedBlock.Shared == shared {
+		t.Error("parameter was not copied")
+	}
+	if copiedBlock.Shared != copiedBlock.Alias {
+		t.Error("shared parameter was copied twice")
+	}
+	if len(newToOld) != 3 {
+		t.Errorf("expected 3 parameters but got %d", len(newToOld))
+	}
+	for newParam, oldParam := range newToOld {
+		if newParam == oldParam {
+			t.Error("parameter maps to itself")
+		}
+		newData := newParam.Vector.Data().([]float64)
+		oldData := oldParam.Vector.Data().([]float64)
+		for i, x := range oldData {
+			if newData[i] != x {
+				t.Errorf("parameter values differ: %v vs %v", newData, oldData)
+				break
+			}
+		}
+	}
+
+	fwdCreator := &anyfwd.Creator{ValueCreator: c, GradSize: 1}
+	fwdBlock, fwdMap := copyPolicy(fwdCreator, block)
+	for newParam := range fwdMap {
+		if _, ok := newParam.Vector.(*anyfwd.Vector); !ok {
+			t.Errorf("expected forward vector but got %T", newParam.Vector)
+		}
+	}
+	if fwdBlock.(*unserializableBlock).Shared == shared {
+		t.Error("forward copy shares parameters with the original")
+	}
+}
+
+// tableBlock is a block with a non-parameter vector.
+type tableBlock struct {
+	unserializableBlock
+	Table anyvec.Vector
+}
+
+func (t *tableBlock) ConvertCreator(c anyvec.Creator) {
+	data := t.Table.Creator().Float64Slice(t.Table.Data())
+	t.Table = anyvec.Make(c, data)
+}
+
+func TestCopyPolicyConvertCreator(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	block := &tableBlock{
+		unserializableBlock: unserializableBlock{
+			Inner:  &anyrnn.LayerBlock{Layer: anynet.NewFC(c, 2, 3)},
+			Shared: anydiff.NewVar(anyvec.Make(c, []float64{1, 2})),
+		},
+		Table: anyvec.Make(c, []float64{3, 4, 5}),
+	}
+
+	fwdCreator := &anyfwd.Creator{ValueCreator: c, GradSize: 1}
+	copied, _ := copyPolicy(fwdCreator, block)
+	table := copied.(*tableBlock).Table
+	if _, ok := table.(*anyfwd.Vector); !ok {
+		t.Fatalf("expected forward vector but got %T", table)
+	}
+	if _, ok := block.Table.(*anyfwd.Vector); ok {
+		t.Error("original table was converted")
+	}
+	actual := c.Float64Slice(table.(*anyfwd.Vector).Values.Data())
+	for i, x := range []float64{3, 4, 5} {
+		if actual[i] != x {
+			t.Errorf("expected %v but got %v", []float64{3, 4, 5}, actual)
+			break
+		}
+	}
+}
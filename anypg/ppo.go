@@ -47,6 +47,11 @@ type PPOTerms struct {
 	MeanAdvantage      anyvec.Numeric
 	MeanCritic         anyvec.Numeric
 	MeanRegularization anyvec.Numeric
+
+	// AuxLosses contains the mean loss of each auxiliary
+	// task.
+	// These are not part of the sum above.
+	AuxLosses []anyvec.Numeric
 }
 
 // PPO implements Proximal Policy Optimization.
@@ -78,6 +83,11 @@ type PPO struct {
 	// If 0, a default of 1 is used.
 	CriticWeight float64
 
+	// Loss is used to fit the critic.
+	//
+	// If nil, anyrl.SquareLoss is used.
+	Loss anyrl.Loss
+
 	// Regularizer can be used to encourage exploration.
 	Regularizer Regularizer
 
@@ -93,6 +103,25 @@ type PPO struct {
 	// If 0, DefaultPPOEpsilon is used.
 	Epsilon float64
 
+	// Objective is the surrogate objective for the
+	// policy.
+	//
+	// If nil, ClippedObjective is used with Epsilon and
+	// DualClip.
+	Objective Objective
+
+	// DualClip is passed to the default
+	// ClippedObjective.
+	// It is ignored if Objective is non-nil.
+	DualClip float64
+
+	// ValueClip is the amount by which the critic's
+	// outputs may move away from the old values in
+	// RunValueClipped.
+	//
+	// If 0, the PPO epsilon is used.
+	ValueClip float64
+
 	// PoolBase, if true, indicates that the output of the
 	// Base function should be pooled to prevent multiple
 	// forward/backward Base evaluations.
@@ -100,6 +129,32 @@ type PPO struct {
 	// If this is true, then the entire output of Base is
 	// stored in memory.
 	PoolBase bool
+
+	// Auxiliary contains auxiliary tasks which are
+	// trained on the same rollouts alongside the main
+	// objective.
+	Auxiliary []AuxTerm
+
+	// GradSurgery, if true, combines the gradients of
+	// the main objective and the auxiliary tasks with
+	// PCGrad instead of summing them.
+	GradSurgery bool
+
+	// Clipper, if non-nil, is used to clip gradients
+	// before they are returned.
+	Clipper *GradClipper
+
+	// Telemetry, if non-nil, records the norms of the
+	// gradient before clipping.
+	Telemetry *Telemetry
+
+	// Refit, if non-nil, is used by RefitAdvantage to
+	// refit the critic before computing advantages.
+	Refit *CriticRefit
+
+	// Modes, if non-nil, is switched to TrainMode before
+	// the policy is applied.
+	Modes *anyrl.ModeController
 }
 
 // Advantage computes the GAE estimator for a batch.
@@ -108,13 +163,14 @@ type PPO struct {
 // You should not call it between training steps in the
 // same batch, since the advantage estimator will change
 // as the value function is trained.
+//
+// See RefitAdvantage for a variant which fits the value
+// function to the batch first.
 func (p *PPO) Advantage(r *anyrl.RolloutSet) lazyseq.Tape {
 	judger := &GAEJudger{
-		ValueFunc: func(inputs lazyseq.Rereader) <-chan *anyseq.Batch {
-			return p.Critic(p.applyBaseIn(inputs)).Forward()
-		},
-		Discount: p.Discount,
-		Lambda:   p.Lambda,
+		ValueFunc: p.valueFunc,
+		Discount:  p.Discount,
+		Lambda:    p.Lambda,
 	}
 	return judger.JudgeActions(r).Tape(r.Inputs.Creator())
 }
@@ -129,36 +185,91 @@ func (p *PPO) Advantage(r *anyrl.RolloutSet) lazyseq.Tape {
 // If p.Params is empty, then an empty gradient and nil
 // PPOTerms are returned.
 func (p *PPO) Run(r *anyrl.RolloutSet, adv lazyseq.Tape) (anydiff.Grad, *PPOTerms) {
+	return p.run(r, adv, nil)
+}
+
+// Values computes the critic's value estimates for a
+// batch.
+//
+// Like Advantage, this should be called once per batch,
+// before any training steps.
+// The result can be passed to RunValueClipped.
+func (p *PPO) Values(r *anyrl.RolloutSet) lazyseq.Tape {
+	values := criticValues(p.valueFunc, r)
+	return anyrl.Rewards(values).Tape(r.Creator())
+}
+
+// valueFunc applies the critic for an ActionJudger.
+func (p *PPO) valueFunc(inputs lazyseq.Rereader) <-chan *anyseq.Batch {
+	return p.Critic(p.applyBaseIn(inputs)).Forward()
+}
+
+// targetJudger computes the critic's targets, which are
+// the discounted returns.
+// The returns of truncated episodes are bootstrapped
+// with the current critic.
+func (p *PPO) targetJudger() ActionJudger {
+	return &QJudger{Discount: p.Discount, ValueFunc: p.valueFunc}
+}
+
+// RunValueClipped is like Run, but it uses the clipped
+// value loss, which is the maximum of the regular loss
+// and the loss of the values clipped to within ValueClip
+// of oldValues.
+//
+// The oldValues should be computed with Values before the
+// first training step on the batch.
+func (p *PPO) RunValueClipped(r *anyrl.RolloutSet, adv,
+	oldValues lazyseq.Tape) (anydiff.Grad, *PPOTerms) {
+	return p.run(r, adv, oldValues)
+}
+
+func (p *PPO) run(r *anyrl.RolloutSet, adv,
+	oldValues lazyseq.Tape) (anydiff.Grad, *PPOTerms) {
+	checkObjective(p.objective(), p.ActionSpace)
+	if p.Modes != nil {
+		p.Modes.Set(anyrl.TrainMode)
+	}
+
 	grad := anydiff.NewGrad(p.Params...)
 	if len(grad) == 0 {
 		return grad, nil
 	}
 	c := r.Creator()
-	targetValues := (&QJudger{Discount: p.Discount}).JudgeActions(r)
+	targetValues := p.targetJudger().JudgeActions(r)
 
 	objective := p.runActorCritic(r, func(actor, critic lazyseq.Rereader) anydiff.Res {
+		seqs := []lazyseq.Rereader{
+			actor,
+			critic,
+			lazyseq.TapeRereader(r.AgentOuts),
+			lazyseq.TapeRereader(r.Actions),
+			lazyseq.TapeRereader(adv),
+			lazyseq.TapeRereader(targetValues.Tape(c)),
+		}
+		if oldValues != nil {
+			seqs = append(seqs, lazyseq.TapeRereader(oldValues))
+		}
 		obj := lazyseq.MapN(
 			func(n int, v ...anydiff.Res) anydiff.Res {
 				actor, critic := v[0], v[1]
 				oldOuts, actions := v[2], v[3]
 				advantage, targets := v[4], v[5]
 
-				ratios := anydiff.Exp(
-					anydiff.Sub(
-						p.ActionSpace.LogProb(actor, actions.Output(), n),
-						p.ActionSpace.LogProb(oldOuts, actions.Output(), n),
-					),
-				)
-				advTerm := p.clippedObjective(ratios, advantage)
+				advTerm := p.objective().Objective(p.ActionSpace, actor, oldOuts,
+					actions.Output(), advantage, n)
 
 				criticCoeff := -1.0
 				if p.CriticWeight != 0 {
 					criticCoeff *= p.CriticWeight
 				}
-				criticTerm := anydiff.Scale(
-					anydiff.Square(anydiff.Sub(critic, targets)),
-					c.MakeNumeric(criticCoeff),
-				)
+				var criticLoss anydiff.Res
+				if oldValues != nil {
+					criticLoss = p.clippedValueLoss(critic, v[6], targets)
+				} else {
+					criticLoss = p.loss().Loss(critic, targets)
+				}
+				criticTerm := anydiff.Scale(criticLoss, c.MakeNumeric(criticCoeff))
 
 				var regTerm anydiff.Res
 				if p.Regularizer != nil {
@@ -170,21 +281,26 @@ func (p *PPO) Run(r *anyrl.RolloutSet, adv lazyseq.Tape) (anydiff.Grad, *PPOTerm
 				cm := anynet.ConcatMixer{}
 				return cm.Mix(cm.Mix(advTerm, criticTerm, n), regTerm, n)
 			},
-			actor,
-			critic,
-			lazyseq.TapeRereader(r.AgentOuts),
-			lazyseq.TapeRereader(r.Actions),
-			lazyseq.TapeRereader(adv),
-			lazyseq.TapeRereader(targetValues.Tape(c)),
+			seqs...,
 		)
 		return lazyseq.Mean(obj)
 	})
 	objective.Propagate(anyvec.Ones(c, 3), grad)
 
+	auxLosses := applyAux(grad, r, p.Auxiliary, p.GradSurgery)
+
+	if p.Telemetry != nil {
+		p.Telemetry.RecordGrad(grad)
+	}
+	if p.Clipper != nil {
+		p.Clipper.Clip(grad)
+	}
+
 	terms := &PPOTerms{
 		MeanAdvantage:      anyvec.Sum(objective.Output().Slice(0, 1)),
 		MeanCritic:         anyvec.Sum(objective.Output().Slice(1, 2)),
 		MeanRegularization: anyvec.Sum(objective.Output().Slice(2, 3)),
+		AuxLosses:          auxLosses,
 	}
 
 	return grad, terms
@@ -224,11 +340,38 @@ func (p *PPO) applyBaseIn(in lazyseq.Rereader) lazyseq.Rereader {
 	}
 }
 
-func (p *PPO) clippedObjective(ratios, advantages anydiff.Res) anydiff.Res {
-	epsilon := p.Epsilon
-	if epsilon == 0 {
-		epsilon = DefaultPPOEpsilon
+func (p *PPO) objective() Objective {
+	if p.Objective == nil {
+		return &ClippedObjective{Epsilon: p.Epsilon, DualClip: p.DualClip}
+	}
+	return p.Objective
+}
+
+func (p *PPO) clippedValueLoss(critic, oldValues, targets anydiff.Res) anydiff.Res {
+	c := critic.Output().Creator()
+	clip := p.ValueClip
+	if clip == 0 {
+		clip = p.Epsilon
+		if clip == 0 {
+			clip = DefaultPPOEpsilon
+		}
+	}
+	return anydiff.Pool(critic, func(critic anydiff.Res) anydiff.Res {
+		clipped := anydiff.Add(oldValues, anydiff.ClipRange(
+			anydiff.Sub(critic, oldValues),
+			c.MakeNumeric(-clip),
+			c.MakeNumeric(clip),
+		))
+		return anydiff.ElemMax(
+			p.loss().Loss(critic, targets),
+			p.loss().Loss(clipped, targets),
+		)
+	})
+}
+
+func (p *PPO) loss() anyrl.Loss {
+	if p.Loss == nil {
+		return anyrl.SquareLoss{}
 	}
-	c := ratios.Output().Creator()
-	return PPOObjective(c.MakeNumeric(epsilon), ratios, advantages)
+	return p.Loss
 }
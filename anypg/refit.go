@@ -0,0 +1,129 @@
+package anypg
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// CriticRefit configures retroactive advantage
+// recomputation for PPO.
+//
+// Normally, advantages are computed with a critic that
+// has never seen the current batch.
+// With a CriticRefit, the critic is first fit to the
+// batch's lambda-returns (the advantages plus the value
+// estimates), and the advantages are recomputed with the
+// refit critic before the policy is updated.
+// Since the lambda-returns depend on the critic, this can
+// be iterated.
+// This tends to stabilize training with small batches.
+type CriticRefit struct {
+	// Params specifies which parameters to update while
+	// refitting the critic.
+	//
+	// If nil, the PPO's Params are used.
+	Params []*anydiff.Var
+
+	// Optimizer applies the critic updates.
+	Optimizer *Optimizer
+
+	// Steps is the number of critic updates per
+	// iteration.
+	//
+	// If 0, 1 is used.
+	Steps int
+
+	// Iters is the number of times to refit the critic
+	// and recompute the advantages.
+	//
+	// If 0, 1 is used.
+	Iters int
+}
+
+// RefitAdvantage refits the critic according to
+// p.Refit and then computes the GAE estimator for the
+// batch.
+//
+// If p.Refit is nil, this is equivalent to Advantage.
+func (p *PPO) RefitAdvantage(r *anyrl.RolloutSet) lazyseq.Tape {
+	adv := p.Advantage(r)
+	if p.Refit == nil {
+		return adv
+	}
+	for i := 0; i < p.Refit.iters(); i++ {
+		targets := p.lambdaReturns(r, adv)
+		for j := 0; j < p.Refit.steps(); j++ {
+			grad, _ := p.CriticRun(r, p.Refit.Params, targets)
+			p.Refit.Optimizer.Step(grad)
+		}
+		adv = p.Advantage(r)
+	}
+	return adv
+}
+
+// CriticRun computes the gradient of the critic's
+// objective alone, along with the mean critic loss.
+//
+// If params is nil, p.Params is used.
+// If targets is nil, the critic is fit to the discounted
+// returns, as in Run.
+func (p *PPO) CriticRun(r *anyrl.RolloutSet, params []*anydiff.Var,
+	targets lazyseq.Tape) (anydiff.Grad, anyvec.Numeric) {
+	if params == nil {
+		params = p.Params
+	}
+	grad := anydiff.NewGrad(params...)
+	c := r.Creator()
+	if targets == nil {
+		targets = p.targetJudger().JudgeActions(r).Tape(c)
+	}
+	losses := lazyseq.MapN(
+		func(n int, v ...anydiff.Res) anydiff.Res {
+			return p.loss().Loss(v[0], v[1])
+		},
+		p.Critic(p.applyBase(r)),
+		lazyseq.TapeRereader(targets),
+	)
+	loss := lazyseq.Mean(losses)
+	if len(grad) > 0 {
+		loss.Propagate(anyvec.Make(c, []float64{-1}), grad)
+	}
+	return grad, anyvec.Sum(loss.Output())
+}
+
+// lambdaReturns computes the sum of the advantages and
+// the current value estimates.
+//
+// The result is computed eagerly, so it is not affected
+// by later changes to the critic.
+func (p *PPO) lambdaReturns(r *anyrl.RolloutSet, adv lazyseq.Tape) lazyseq.Tape {
+	res, writer := lazyseq.ReferenceTape(r.Creator())
+	advCh := adv.ReadTape(0, -1)
+	for valueBatch := range p.Critic(p.applyBase(r)).Forward() {
+		advBatch := <-advCh
+		packed := valueBatch.Packed.Copy()
+		packed.Add(advBatch.Packed)
+		writer <- &anyseq.Batch{Packed: packed, Present: valueBatch.Present}
+	}
+	for _ = range advCh {
+	}
+	close(writer)
+	return res
+}
+
+func (c *CriticRefit) steps() int {
+	if c.Steps == 0 {
+		return 1
+	}
+	return c.Steps
+}
+
+func (c *CriticRefit) iters() int {
+	if c.Iters == 0 {
+		return 1
+	}
+	return c.Iters
+}
@@ -0,0 +1,107 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestCriticRun(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+	critic := anynet.NewFC(c, 3, 1)
+	ppo := refitPPOForTest(critic)
+
+	returns := (&QJudger{Discount: ppo.Discount}).JudgeActions(r)
+	values := tapeSteps(layerTape(critic, r.Inputs), len(r.Rewards))
+	var expected float64
+	for i, seq := range returns {
+		for step, ret := range seq {
+			expected += math.Pow(values[i][step][0]-ret, 2)
+		}
+	}
+	expected /= float64(r.NumSteps())
+
+	grad, loss := ppo.CriticRun(r, nil, nil)
+	if math.Abs(loss.(float64)-expected) > 1e-8 {
+		t.Errorf("expected loss %f but got %f", expected, loss)
+	}
+	if len(grad) != len(critic.Parameters()) {
+		t.Errorf("expected %d gradient entries but got %d", len(critic.Parameters()),
+			len(grad))
+	}
+
+	_, loss = ppo.CriticRun(r, nil, returns.Tape(c))
+	if math.Abs(loss.(float64)-expected) > 1e-8 {
+		t.Errorf("explicit targets: expected loss %f but got %f", expected, loss)
+	}
+}
+
+func TestLambdaReturns(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+	critic := anynet.NewFC(c, 3, 1)
+	ppo := refitPPOForTest(critic)
+
+	adv := anyrl.Rewards{{1, 2, 3}, {}, {4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}}
+	actual := tapeSteps(ppo.lambdaReturns(r, adv.Tape(c)), len(r.Rewards))
+	values := tapeSteps(layerTape(critic, r.Inputs), len(r.Rewards))
+	for i, seq := range adv {
+		if len(actual[i]) != len(seq) {
+			t.Fatalf("episode %d: expected length %d but got %d", i, len(seq),
+				len(actual[i]))
+		}
+		for step, x := range seq {
+			expected := x + values[i][step][0]
+			if math.Abs(actual[i][step][0]-expected) > 1e-8 {
+				t.Errorf("episode %d step %d: expected %f but got %f", i, step,
+					expected, actual[i][step][0])
+			}
+		}
+	}
+}
+
+func TestRefitAdvantage(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+	critic := anynet.NewFC(c, 3, 1)
+	ppo := refitPPOForTest(critic)
+
+	// Without a refit, the advantages should be unchanged.
+	expected := tapeSteps(ppo.Advantage(r), len(r.Rewards))
+	actual := tapeSteps(ppo.RefitAdvantage(r), len(r.Rewards))
+	for i, seq := range expected {
+		for step, x := range seq {
+			if math.Abs(actual[i][step][0]-x[0]) > 1e-8 {
+				t.Errorf("episode %d step %d: expected %f but got %f", i, step,
+					x[0], actual[i][step][0])
+			}
+		}
+	}
+
+	targets := ppo.lambdaReturns(r, ppo.Advantage(r))
+	_, oldLoss := ppo.CriticRun(r, nil, targets)
+	ppo.Refit = &CriticRefit{
+		Optimizer: &Optimizer{StepSize: 0.01},
+		Steps:     5,
+	}
+	ppo.RefitAdvantage(r)
+	_, newLoss := ppo.CriticRun(r, nil, targets)
+	if newLoss.(float64) >= oldLoss.(float64) {
+		t.Errorf("critic loss went from %f to %f", oldLoss, newLoss)
+	}
+}
+
+func refitPPOForTest(critic *anynet.FC) *PPO {
+	return &PPO{
+		Params:      critic.Parameters(),
+		Actor:       layerPolicy(anynet.NewFC(critic.Weights.Vector.Creator(), 3, 2)),
+		Critic:      layerPolicy(critic),
+		ActionSpace: anyrl.Softmax{},
+		Discount:    0.9,
+		Lambda:      0.95,
+	}
+}
@@ -0,0 +1,46 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestRegularizersFloat32(t *testing.T) {
+	params := []float64{0.5, -1, 2, 0.1, 0.3, -0.2}
+	regs := map[string]func(c anyvec.Creator) Regularizer{
+		"EntropyReg": func(c anyvec.Creator) Regularizer {
+			return &EntropyReg{Entropyer: anyrl.Softmax{}, Coeff: 0.5}
+		},
+		"InvEntropyReg": func(c anyvec.Creator) Regularizer {
+			return &InvEntropyReg{Entropyer: anyrl.Softmax{}, Coeff: 0.5}
+		},
+		"KLReg": func(c anyvec.Creator) Regularizer {
+			return &KLReg{
+				KLer:  anyrl.Softmax{},
+				Base:  anyvec.Make(c, []float64{1, 0, -1}),
+				Coeff: 0.5,
+			}
+		},
+	}
+	for name, makeReg := range regs {
+		var results [][]float64
+		for _, c := range []anyvec.Creator{anyvec32.CurrentCreator(), anyvec64.CurrentCreator()} {
+			in := anydiff.NewConst(anyvec.Make(c, params))
+			out := makeReg(c).Regularize(in, 2).Output()
+			results = append(results, c.Float64Slice(out.Data()))
+		}
+		for i, x := range results[0] {
+			if math.Abs(x-results[1][i]) > 1e-4 {
+				t.Errorf("%s: float32 gave %v but float64 gave %v", name,
+					results[0], results[1])
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anyrl"
+)
+
+// DefaultRolloutMaxGrowth is the default limit on how
+// much a RolloutController may change the number of
+// episodes in one update.
+const DefaultRolloutMaxGrowth = 2
+
+// A RolloutController adjusts the number of episodes
+// gathered per training iteration based on the gradient
+// noise scale.
+//
+// When updates are noisy, the noise scale is large and
+// more episodes are collected; when updates are stable,
+// fewer episodes are needed.
+//
+// A training loop should gather Episodes() episodes, then
+// pass the resulting rollouts to Update.
+type RolloutController struct {
+	// NoiseScale estimates the noise scale.
+	NoiseScale *NoiseScale
+
+	// MinEpisodes and MaxEpisodes bound the number of
+	// episodes per iteration.
+	// MinEpisodes must be larger than the NoiseScale's
+	// small batch size.
+	MinEpisodes int
+	MaxEpisodes int
+
+	// Multiplier scales the noise scale to get the
+	// target number of episodes.
+	//
+	// If 0, 1 is used.
+	Multiplier float64
+
+	// MaxGrowth is the largest factor by which the number
+	// of episodes may grow or shrink in one update.
+	//
+	// If 0, DefaultRolloutMaxGrowth is used.
+	MaxGrowth float64
+
+	// Log, if non-nil, is called after every update with
+	// the noise scale estimate and the new number of
+	// episodes.
+	Log func(noiseScale float64, episodes int)
+
+	episodes int
+}
+
+// Episodes returns the number of episodes to gather for
+// the next iteration.
+func (r *RolloutController) Episodes() int {
+	if r.episodes == 0 {
+		return r.MinEpisodes
+	}
+	return r.episodes
+}
+
+// Update estimates the noise scale from a batch of
+// rollouts and adjusts the number of episodes.
+//
+// If the estimate is not positive, which can happen when
+// the gradient is dominated by noise, the number of
+// episodes increases as much as MaxGrowth allows.
+//
+// It returns the new number of episodes.
+func (r *RolloutController) Update(rollouts *anyrl.RolloutSet) int {
+	current := float64(r.Episodes())
+	scale := r.NoiseScale.Estimate(rollouts)
+
+	target := current * r.maxGrowth()
+	if scale > 0 && !math.IsInf(scale, 0) {
+		target = math.Min(target, scale*r.multiplier())
+		target = math.Max(target, current/r.maxGrowth())
+	}
+	target = math.Max(target, float64(r.MinEpisodes))
+	if r.MaxEpisodes != 0 {
+		target = math.Min(target, float64(r.MaxEpisodes))
+	}
+
+	r.episodes = int(math.Ceil(target))
+	if r.Log != nil {
+		r.Log(scale, r.episodes)
+	}
+	return r.episodes
+}
+
+func (r *RolloutController) multiplier() float64 {
+	if r.Multiplier == 0 {
+		return 1
+	}
+	return r.Multiplier
+}
+
+func (r *RolloutController) maxGrowth() float64 {
+	if r.MaxGrowth == 0 {
+		return DefaultRolloutMaxGrowth
+	}
+	return r.MaxGrowth
+}
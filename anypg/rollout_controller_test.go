@@ -0,0 +1,50 @@
+package anypg
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestRolloutController(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	param := anydiff.NewVar(anyvec.Make(c, []float64{0}))
+	controller := &RolloutController{
+		NoiseScale: &NoiseScale{
+			Grad: func(r *anyrl.RolloutSet) anydiff.Grad {
+				return anydiff.Grad{
+					param: anyvec.Make(c, []float64{r.Rewards.Mean()}),
+				}
+			},
+		},
+		MinEpisodes: 2,
+		MaxEpisodes: 18,
+
+		// The noise scale is 2/3, so the target is 20.
+		Multiplier: 30,
+	}
+	r := &anyrl.RolloutSet{
+		Inputs:  anyrl.Rewards{{0}, {0}}.Tape(c),
+		Actions: anyrl.Rewards{{0}, {0}}.Tape(c),
+		Rewards: anyrl.Rewards{{1}, {3}},
+	}
+
+	if controller.Episodes() != 2 {
+		t.Fatalf("expected 2 initial episodes but got %d", controller.Episodes())
+	}
+	for i, expected := range []int{4, 8, 16, 18, 18} {
+		if actual := controller.Update(r); actual != expected {
+			t.Errorf("update %d: expected %d but got %d", i, expected, actual)
+		}
+	}
+
+	controller.Multiplier = 3
+	for i, expected := range []int{9, 5, 3, 2} {
+		if actual := controller.Update(r); actual != expected {
+			t.Errorf("shrink %d: expected %d but got %d", i, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+// WarmupRater is an anysgd.Rater which linearly ramps up
+// the learning rate of another Rater.
+//
+// It is meant to be used as an Optimizer's Rater, in which
+// case the epoch is the number of steps taken so far.
+type WarmupRater struct {
+	// Steps is the number of steps over which the rate
+	// rises from zero to the full rate.
+	Steps float64
+
+	// Rater produces the rate after warmup.
+	// It is given the number of steps since the end of
+	// the warmup period.
+	Rater anysgd.Rater
+}
+
+// Rate computes the learning rate.
+func (w *WarmupRater) Rate(epoch float64) float64 {
+	if epoch < w.Steps {
+		return w.Rater.Rate(0) * (epoch + 1) / (w.Steps + 1)
+	}
+	return w.Rater.Rate(epoch - w.Steps)
+}
+
+// CosineRater is an anysgd.Rater which anneals the
+// learning rate along half a cosine wave.
+type CosineRater struct {
+	// Initial is the rate at epoch 0.
+	Initial float64
+
+	// Final is the rate after Steps epochs.
+	Final float64
+
+	// Steps is the length of the schedule.
+	// After Steps epochs, the rate stays at Final.
+	Steps float64
+}
+
+// Rate computes the learning rate.
+func (c *CosineRater) Rate(epoch float64) float64 {
+	if epoch >= c.Steps {
+		return c.Final
+	}
+	frac := 0.5 * (1 + math.Cos(math.Pi*epoch/c.Steps))
+	return c.Final + (c.Initial-c.Final)*frac
+}
+
+// StepRater is an anysgd.Rater which multiplies the
+// learning rate by a constant factor at regular
+// intervals.
+type StepRater struct {
+	// Initial is the rate at epoch 0.
+	Initial float64
+
+	// Factor is multiplied into the rate at the end of
+	// every interval.
+	Factor float64
+
+	// Interval is the number of epochs between decays.
+	Interval float64
+}
+
+// Rate computes the learning rate.
+func (s *StepRater) Rate(epoch float64) float64 {
+	return s.Initial * math.Pow(s.Factor, math.Floor(epoch/s.Interval))
+}
+
+// DiscountSchedule anneals a discount factor, for example
+// from 0.99 to 0.999 over the course of training.
+//
+// Interpolation is done geometrically on the effective
+// horizon 1/(1-discount), so the horizon grows by the
+// same factor in every epoch.
+//
+// A DiscountSchedule is an anysgd.Rater, but it is meant
+// to set the Discount field of a trainer or judger before
+// each batch, e.g.
+//
+//	ppo.Discount = schedule.Rate(float64(batchIdx))
+type DiscountSchedule struct {
+	// Initial is the discount at epoch 0.
+	Initial float64
+
+	// Final is the discount after Steps epochs.
+	Final float64
+
+	// Steps is the length of the schedule.
+	// After Steps epochs, the discount stays at Final.
+	Steps float64
+}
+
+// Rate computes the discount factor for the epoch.
+func (d *DiscountSchedule) Rate(epoch float64) float64 {
+	if epoch >= d.Steps {
+		return d.Final
+	}
+	frac := epoch / d.Steps
+	initialLog := math.Log(1 - d.Initial)
+	finalLog := math.Log(1 - d.Final)
+	return 1 - math.Exp(initialLog+frac*(finalLog-initialLog))
+}
@@ -0,0 +1,43 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+func TestSchedules(t *testing.T) {
+	cosine := &CosineRater{Initial: 1, Final: 0.1, Steps: 10}
+	warmup := &WarmupRater{Steps: 4, Rater: cosine}
+	step := &StepRater{Initial: 1, Factor: 0.5, Interval: 3}
+	discount := &DiscountSchedule{Initial: 0.99, Final: 0.9999, Steps: 10}
+
+	cases := []struct {
+		Rater    anysgd.Rater
+		Epoch    float64
+		Expected float64
+	}{
+		{cosine, 0, 1},
+		{cosine, 5, 0.55},
+		{cosine, 10, 0.1},
+		{cosine, 20, 0.1},
+		{warmup, 0, 0.2},
+		{warmup, 3, 0.8},
+		{warmup, 4, 1},
+		{warmup, 9, 0.55},
+		{step, 2, 1},
+		{step, 3, 0.5},
+		{step, 7, 0.25},
+		{discount, 0, 0.99},
+		{discount, 5, 0.999},
+		{discount, 10, 0.9999},
+		{discount, 15, 0.9999},
+	}
+	for i, c := range cases {
+		actual := c.Rater.Rate(c.Epoch)
+		if math.Abs(actual-c.Expected) > 1e-8 {
+			t.Errorf("case %d: expected %f but got %f", i, c.Expected, actual)
+		}
+	}
+}
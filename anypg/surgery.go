@@ -0,0 +1,61 @@
+package anypg
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+)
+
+// PCGrad combines the gradients of several objectives
+// using gradient surgery.
+//
+// Before the gradients are summed, each gradient is
+// projected onto the normal plane of every other gradient
+// it conflicts with (i.e. has a negative dot product
+// with), in a random order.
+// This prevents one objective from undoing the progress
+// of another.
+//
+// See https://arxiv.org/abs/2001.06782.
+//
+// The gradients must all contain the same variables.
+// They are not modified.
+func PCGrad(grads []anydiff.Grad) anydiff.Grad {
+	if len(grads) == 0 {
+		return anydiff.Grad{}
+	}
+	var projected []anydiff.Grad
+	for i, grad := range grads {
+		proj := copyGrad(grad)
+		for _, j := range rand.Perm(len(grads)) {
+			if j == i {
+				continue
+			}
+			other := grads[j]
+			c := gradCreator(other)
+			ops := c.NumOps()
+			dot := dotGrad(proj, other)
+			if !ops.Less(dot, c.MakeNumeric(0)) {
+				continue
+			}
+			normSq := dotGrad(other, other)
+			scaled := copyGrad(other)
+			scaled.Scale(ops.Div(dot, normSq))
+			subFromGrad(proj, scaled)
+		}
+		projected = append(projected, proj)
+	}
+	res := projected[0]
+	for _, proj := range projected[1:] {
+		addToGrad(res, proj)
+	}
+	return res
+}
+
+func gradCreator(g anydiff.Grad) anyvec.Creator {
+	for _, vec := range g {
+		return vec.Creator()
+	}
+	panic("cannot get creator of empty gradient")
+}
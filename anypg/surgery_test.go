@@ -0,0 +1,37 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestPCGrad(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	v := anydiff.NewVar(anyvec.Make(c, []float64{0, 0}))
+	grads := []anydiff.Grad{
+		{v: anyvec.Make(c, []float64{1, 0})},
+		{v: anyvec.Make(c, []float64{-1, 1})},
+	}
+	actual := PCGrad(grads)[v].Data().([]float64)
+	expected := []float64{0.5, 1.5}
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-8 {
+			t.Errorf("expected %v but got %v", expected, actual)
+			break
+		}
+	}
+	if first := grads[0][v].Data().([]float64); first[0] != 1 || first[1] != 0 {
+		t.Errorf("input gradient was modified: %v", first)
+	}
+
+	// Non-conflicting gradients are simply summed.
+	grads[1][v] = anyvec.Make(c, []float64{1, 1})
+	actual = PCGrad(grads)[v].Data().([]float64)
+	if actual[0] != 2 || actual[1] != 1 {
+		t.Errorf("expected [2 1] but got %v", actual)
+	}
+}
@@ -0,0 +1,161 @@
+package anypg
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+)
+
+// A ParamBlock is a named group of parameters, such as
+// the parameters of a single layer.
+type ParamBlock struct {
+	Name   string
+	Params []*anydiff.Var
+}
+
+// LayerBlocks creates one ParamBlock per parameterized
+// layer in a network.
+// The blocks are named "layer0", "layer1", etc., where
+// the number is the layer's index in the network.
+func LayerBlocks(net anynet.Net) []*ParamBlock {
+	var res []*ParamBlock
+	for i, layer := range net {
+		if p, ok := layer.(anynet.Parameterizer); ok {
+			res = append(res, &ParamBlock{
+				Name:   "layer" + strconv.Itoa(i),
+				Params: p.Parameters(),
+			})
+		}
+	}
+	return res
+}
+
+// BlockNorms stores the norms measured for a ParamBlock
+// during a training step.
+//
+// Norms which were not measured are NaN.
+type BlockNorms struct {
+	Name string
+
+	// Grad is the norm of the raw policy gradient,
+	// before any clipping.
+	Grad float64
+
+	// Natural is the norm of the natural gradient.
+	Natural float64
+
+	// Update is the norm of the change in the parameters.
+	Update float64
+}
+
+// Telemetry records per-block gradient and update norms,
+// which can be used to spot dead layers or exploding
+// recurrent weights.
+//
+// Trainers with a Telemetry field record the norms they
+// compute.
+// Update norms can also be measured directly by calling
+// Snapshot before an optimizer step and RecordChange
+// after it.
+// Call Flush once per step to retrieve the norms.
+type Telemetry struct {
+	Blocks []*ParamBlock
+
+	// Log, if non-nil, is called by Flush with the norms
+	// for the step.
+	Log func(norms []*BlockNorms)
+
+	norms    []*BlockNorms
+	snapshot map[*anydiff.Var]anyvec.Vector
+}
+
+// RecordGrad records the norms of a policy gradient.
+func (t *Telemetry) RecordGrad(g anydiff.Grad) {
+	for i, norm := range t.blockNorms(g) {
+		t.current()[i].Grad = norm
+	}
+}
+
+// RecordNatural records the norms of a natural gradient.
+func (t *Telemetry) RecordNatural(g anydiff.Grad) {
+	for i, norm := range t.blockNorms(g) {
+		t.current()[i].Natural = norm
+	}
+}
+
+// RecordUpdate records the norms of a step which will be
+// added directly to the parameters.
+func (t *Telemetry) RecordUpdate(g anydiff.Grad) {
+	for i, norm := range t.blockNorms(g) {
+		t.current()[i].Update = norm
+	}
+}
+
+// Snapshot saves the current parameter values so that
+// RecordChange can measure how much they change.
+func (t *Telemetry) Snapshot() {
+	t.snapshot = map[*anydiff.Var]anyvec.Vector{}
+	for _, block := range t.Blocks {
+		for _, p := range block.Params {
+			t.snapshot[p] = p.Vector.Copy()
+		}
+	}
+}
+
+// RecordChange records the update norms as the difference
+// between the current parameters and the last Snapshot.
+func (t *Telemetry) RecordChange() {
+	if t.snapshot == nil {
+		panic("no snapshot to compare against")
+	}
+	diff := anydiff.Grad{}
+	for p, old := range t.snapshot {
+		d := p.Vector.Copy()
+		d.Sub(old)
+		diff[p] = d
+	}
+	t.RecordUpdate(diff)
+	t.snapshot = nil
+}
+
+// Flush returns the norms recorded since the last call to
+// Flush and passes them to t.Log.
+func (t *Telemetry) Flush() []*BlockNorms {
+	res := t.current()
+	t.norms = nil
+	if t.Log != nil {
+		t.Log(res)
+	}
+	return res
+}
+
+func (t *Telemetry) current() []*BlockNorms {
+	if t.norms == nil {
+		for _, block := range t.Blocks {
+			t.norms = append(t.norms, &BlockNorms{
+				Name:    block.Name,
+				Grad:    math.NaN(),
+				Natural: math.NaN(),
+				Update:  math.NaN(),
+			})
+		}
+	}
+	return t.norms
+}
+
+func (t *Telemetry) blockNorms(g anydiff.Grad) []float64 {
+	res := make([]float64, len(t.Blocks))
+	for i, block := range t.Blocks {
+		sub := anydiff.Grad{}
+		for _, p := range block.Params {
+			if v, ok := g[p]; ok {
+				sub[p] = v
+			}
+		}
+		res[i] = GradNorm(sub)
+	}
+	return res
+}
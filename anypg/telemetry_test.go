@@ -0,0 +1,52 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestTelemetry(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	v1 := anydiff.NewVar(anyvec.Make(c, []float64{1, 2}))
+	v2 := anydiff.NewVar(anyvec.Make(c, []float64{3}))
+	tel := &Telemetry{Blocks: []*ParamBlock{
+		{Name: "a", Params: []*anydiff.Var{v1}},
+		{Name: "b", Params: []*anydiff.Var{v2}},
+	}}
+
+	tel.RecordGrad(anydiff.Grad{
+		v1: anyvec.Make(c, []float64{3, 4}),
+		v2: anyvec.Make(c, []float64{-2}),
+	})
+	tel.Snapshot()
+	v1.Vector.Add(anyvec.Make(c, []float64{0, 1}))
+	tel.RecordChange()
+
+	var logged []*BlockNorms
+	tel.Log = func(n []*BlockNorms) {
+		logged = n
+	}
+	norms := tel.Flush()
+	if len(logged) != 2 {
+		t.Fatal("log was not called")
+	}
+	expected := []*BlockNorms{
+		{Name: "a", Grad: 5, Natural: math.NaN(), Update: 1},
+		{Name: "b", Grad: 2, Natural: math.NaN(), Update: 0},
+	}
+	for i, x := range expected {
+		a := norms[i]
+		if a.Name != x.Name || math.Abs(a.Grad-x.Grad) > 1e-8 ||
+			!math.IsNaN(a.Natural) || math.Abs(a.Update-x.Update) > 1e-8 {
+			t.Errorf("block %d: expected %v but got %v", i, x, a)
+		}
+	}
+
+	if !math.IsNaN(tel.Flush()[0].Grad) {
+		t.Error("norms were not reset")
+	}
+}
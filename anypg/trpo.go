@@ -2,12 +2,10 @@ package anypg
 
 import (
 	"github.com/unixpickle/anydiff"
-	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anyrnn"
 	"github.com/unixpickle/anyrl"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/lazyseq"
-	"github.com/unixpickle/serializer"
 )
 
 // Default settings for TRPO.
@@ -31,8 +29,17 @@ type TRPO struct {
 	// If 0, DefaultTargetKL is used.
 	TargetKL float64
 
+	// MaxKL is the largest average KL divergence that the
+	// line search will accept.
+	// Setting it above TargetKL lets the line search keep
+	// steps which slightly overshoot the quadratic
+	// approximation.
+	//
+	// If 0, TargetKL is used.
+	MaxKL float64
+
 	// LineSearchDecay is an exponential decay factor
-	// used to decay the step size until TargetKL is
+	// used to decay the step size until MaxKL is
 	// satisfied and the approximate loss has improved.
 	// It should be between 0 and 1.
 	//
@@ -47,6 +54,14 @@ type TRPO struct {
 	// If 0, DefaultMaxLineSearch is used.
 	MaxLineSearch int
 
+	// AcceptRatio is the fraction of the improvement
+	// predicted by the linear approximation of the
+	// objective which a step must achieve to be accepted
+	// by the line search.
+	//
+	// If 0, any improvement is accepted.
+	AcceptRatio float64
+
 	// LogLineSearch is called after each iteration of the
 	// objective line search.
 	//
@@ -57,30 +72,50 @@ type TRPO struct {
 // Run computes a step to improve the agent's performance
 // on the rollouts.
 func (t *TRPO) Run(r *anyrl.RolloutSet) anydiff.Grad {
+	grad, _ := t.RunStats(r)
+	return grad
+}
+
+// RunStats is like Run, but it also returns diagnostics
+// about the step and the line search.
+func (t *TRPO) RunStats(r *anyrl.RolloutSet) (anydiff.Grad, *NaturalPGStats) {
 	res := t.NaturalPG.run(r)
+	stats := res.stats()
 	if res.ZeroGrad {
-		return res.Grad
+		return res.Grad, stats
 	}
 	c := r.Creator()
 	stepSize := t.stepSize(res)
 
 	res.Grad.Scale(stepSize)
+	minImprovement := t.minImprovement(c, stepSize)
 
+	stats.StepScale = 1
 	for i := 0; i < t.maxLineSearch(); i++ {
-		if t.acceptable(r, res) {
+		var ok bool
+		ok, stats.MeanKL, stats.Improvement = t.acceptable(r, res, minImprovement)
+		if ok {
 			break
 		}
-		res.Grad.Scale(c.MakeNumeric(t.lineSearchDecay()))
+		decay := c.MakeNumeric(t.lineSearchDecay())
+		res.Grad.Scale(decay)
+		minImprovement = c.NumOps().Mul(minImprovement, decay)
+		stats.LineSearchIters++
+		stats.StepScale *= t.lineSearchDecay()
 	}
 
-	return res.Grad
+	if t.Telemetry != nil {
+		t.Telemetry.RecordUpdate(res.Grad)
+	}
+
+	return res.Grad, stats
 }
 
 func (t *TRPO) stepSize(r *naturalPGRes) anyvec.Numeric {
 	c := r.Creator()
 	ops := c.NumOps()
 	r.ReducedOut.Reuse()
-	dotProd := dotGrad(r.Grad, t.applyFisher(r.ReducedRollouts, r.Grad, r.ReducedOut))
+	dotProd := dotGrad(r.Grad, r.Curvature(r.Grad, r.ReducedOut))
 	zero := c.MakeNumeric(0)
 
 	// The fisher-vector product might be less than zero due
@@ -95,12 +130,30 @@ func (t *TRPO) stepSize(r *naturalPGRes) anyvec.Numeric {
 	)
 }
 
-func (t *TRPO) acceptable(r *anyrl.RolloutSet, npg *naturalPGRes) bool {
+// minImprovement computes the improvement required by
+// AcceptRatio for a step of the given size.
+//
+// Since the natural gradient x satisfies Fx = g, the
+// predicted improvement of the step is stepSize*x'Fx,
+// which is 2*TargetKL/stepSize.
+func (t *TRPO) minImprovement(c anyvec.Creator, stepSize anyvec.Numeric) anyvec.Numeric {
+	ops := c.NumOps()
+	zero := c.MakeNumeric(0)
+	if ops.Equal(stepSize, zero) {
+		return zero
+	}
+	return ops.Div(c.MakeNumeric(2*t.targetKL()*t.acceptRatio()), stepSize)
+}
+
+func (t *TRPO) acceptable(r *anyrl.RolloutSet, npg *naturalPGRes,
+	minImprovement anyvec.Numeric) (ok bool, kl, improvement anyvec.Numeric) {
 	c := npg.Creator()
 	inSeq := lazyseq.TapeRereader(r.Inputs)
-	rewardSeq := lazyseq.TapeRereader(t.actionJudger().JudgeActions(r).Tape(c))
+	judgements := r.ApplyWeights(t.actionJudger().JudgeActions(r))
+	rewardSeq := lazyseq.TapeRereader(judgements.Tape(c))
 	newOutSeq := t.apply(inSeq, t.steppedPolicy(npg.Grad))
 	sampledOut := lazyseq.TapeRereader(r.Actions)
+	weightSeq := lazyseq.TapeRereader(t.klWeights(r).Tape(c))
 	npg.PolicyOut.Reuse()
 
 	// At each timestep, compute a pair <improvement, kl divergence>.
@@ -109,6 +162,7 @@ func (t *TRPO) acceptable(r *anyrl.RolloutSet, npg *naturalPGRes) bool {
 		oldOut := v[1]
 		newOut := v[2]
 		sampled := v[3].Output()
+		weight := v[4]
 
 		// Importance sampling
 		probRatio := anydiff.Exp(anydiff.Sub(
@@ -117,7 +171,15 @@ func (t *TRPO) acceptable(r *anyrl.RolloutSet, npg *naturalPGRes) bool {
 		))
 
 		rewardChange := anydiff.Sub(anydiff.Mul(probRatio, reward), reward)
-		kl := t.ActionSpace.KL(oldOut, newOut, n)
+		if t.Regularizer != nil {
+			// The regularization term is part of the objective
+			// whose gradient produced the step.
+			rewardChange = anydiff.Add(rewardChange, anydiff.Sub(
+				t.Regularizer.Regularize(newOut, n),
+				t.Regularizer.Regularize(oldOut, n),
+			))
+		}
+		kl := anydiff.Mul(t.kl(oldOut, newOut, n), weight)
 
 		// Put the rewards and kl divergences side-by-side.
 		joined := c.Concat(rewardChange.Output(), kl.Output())
@@ -125,41 +187,35 @@ func (t *TRPO) acceptable(r *anyrl.RolloutSet, npg *naturalPGRes) bool {
 		anyvec.Transpose(joined, transposed, 2)
 
 		return anydiff.NewConst(transposed)
-	}, rewardSeq, npg.PolicyOut, newOutSeq, sampledOut)
+	}, rewardSeq, npg.PolicyOut, newOutSeq, sampledOut, weightSeq)
 
 	outStats := lazyseq.Mean(mappedOut).Output()
-	improvement := anyvec.Sum(outStats.Slice(0, 1))
-	kl := anyvec.Sum(outStats.Slice(1, 2))
+	improvement = anyvec.Sum(outStats.Slice(0, 1))
+	kl = anyvec.Sum(outStats.Slice(1, 2))
 
 	if t.LogLineSearch != nil {
 		t.LogLineSearch(kl, improvement)
 	}
 
-	targetImprovement := c.MakeNumeric(0)
-	targetKL := c.MakeNumeric(t.targetKL())
+	maxKL := c.MakeNumeric(t.maxKL())
 	ops := c.NumOps()
-	return ops.Greater(improvement, targetImprovement) &&
-		ops.Less(kl, targetKL)
+	ok = ops.Greater(improvement, minImprovement) && ops.Less(kl, maxKL)
+	return
 }
 
 func (t *TRPO) steppedPolicy(step anydiff.Grad) anyrnn.Block {
-	copied, err := serializer.Copy(t.Policy)
-	if err != nil {
-		panic(err)
-	}
-	newParams := anynet.AllParameters(copied)
-	oldParams := anynet.AllParameters(t.Policy)
+	copied, newToOld := copyPolicy(gradCreator(step), t.Policy)
 	newGrad := anydiff.Grad{}
-	for i, old := range oldParams {
+	for newParam, old := range newToOld {
 		if gradVal, ok := step[old]; ok {
-			newGrad[newParams[i]] = gradVal
+			newGrad[newParam] = gradVal
 		}
 	}
 	if len(newGrad) != len(step) {
-		panic("not all parameters are visible to anynet.AllParameters")
+		panic("not all parameters are visible to the policy copy")
 	}
 	newGrad.AddToVars()
-	return copied.(anyrnn.Block)
+	return copied
 }
 
 func (t *TRPO) targetKL() float64 {
@@ -170,6 +226,14 @@ func (t *TRPO) targetKL() float64 {
 	}
 }
 
+func (t *TRPO) maxKL() float64 {
+	if t.MaxKL == 0 {
+		return t.targetKL()
+	} else {
+		return t.MaxKL
+	}
+}
+
 func (t *TRPO) lineSearchDecay() float64 {
 	if t.LineSearchDecay == 0 {
 		return DefaultLineSearchDecay
@@ -186,6 +250,14 @@ func (t *TRPO) maxLineSearch() int {
 	}
 }
 
+func (t *TRPO) acceptRatio() float64 {
+	if t.AcceptRatio < 0 {
+		return 0
+	} else {
+		return t.AcceptRatio
+	}
+}
+
 func (t *TRPO) actionJudger() ActionJudger {
 	if t.ActionJudger == nil {
 		return &TotalJudger{Normalize: true}
@@ -1,11 +1,14 @@
 package anypg
 
 import (
+	"math"
 	"testing"
 
+	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anyrnn"
 	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/anyvec/anyvec64"
 	"github.com/unixpickle/lazyseq"
 )
@@ -45,3 +48,177 @@ func TestTRPOImprovement(t *testing.T) {
 		t.Errorf("TRPO gave a direction of decrease")
 	}
 }
+
+func TestCPORecovery(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+
+	block := &anyrnn.LayerBlock{
+		Layer: anynet.Net{
+			anynet.NewFC(c, 3, 2),
+			anynet.Tanh,
+			anynet.NewFC(c, 2, 2),
+		},
+	}
+
+	// Use the rewards as costs with an unattainable limit,
+	// so that CPO must decrease the costs.
+	costs := r.Rewards
+	cpo := &CPO{
+		TRPO: TRPO{
+			NaturalPG: NaturalPG{
+				Policy:      block,
+				Params:      block.Parameters(),
+				ActionSpace: anyrl.Softmax{},
+				Iters:       14,
+			},
+		},
+		CostLimit: -1000,
+	}
+	grad := cpo.Run(r, costs)
+
+	costRollouts := *r
+	costRollouts.Rewards = costs
+	pg := &PG{
+		Policy: func(in lazyseq.Rereader) lazyseq.Rereader {
+			return lazyseq.Lazify(anyrnn.Map(lazyseq.Unlazify(in), block))
+		},
+		Params:       anynet.AllParameters(block),
+		ActionSpace:  cpo.ActionSpace,
+		ActionJudger: &QJudger{},
+	}
+	costGrad := pg.Run(&costRollouts)
+
+	if dotGrad(grad, costGrad).(float64) > 0 {
+		t.Errorf("CPO gave a direction of cost increase")
+	}
+}
+
+func TestTRPOMaxKL(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+
+	block := &anyrnn.LayerBlock{
+		Layer: anynet.Net{
+			anynet.NewFC(c, 3, 2),
+			anynet.Tanh,
+			anynet.NewFC(c, 2, 2),
+		},
+	}
+
+	// With MaxKL well below TargetKL, the first step of
+	// the line search should be rejected.
+	trpo := TRPO{
+		NaturalPG: NaturalPG{
+			Policy:      block,
+			Params:      block.Parameters(),
+			ActionSpace: anyrl.Softmax{},
+			Iters:       14,
+		},
+		TargetKL: 0.01,
+		MaxKL:    0.0025,
+	}
+	var kls []float64
+	trpo.LogLineSearch = func(kl, improvement anyvec.Numeric) {
+		kls = append(kls, kl.(float64))
+	}
+	_, stats := trpo.RunStats(r)
+	if stats.LineSearchIters == 0 {
+		t.Errorf("expected line search to reject steps (KLs: %v)", kls)
+	}
+	if stats.MeanKL.(float64) >= trpo.MaxKL {
+		t.Errorf("accepted KL %f exceeds MaxKL", stats.MeanKL)
+	}
+
+	kls = nil
+	cpo := &CPO{TRPO: trpo, CostLimit: 1000}
+	cpo.Run(r, r.Rewards)
+	if len(kls) < 2 {
+		t.Errorf("expected CPO line search to reject steps (KLs: %v)", kls)
+	} else if kls[len(kls)-1] >= trpo.MaxKL {
+		t.Errorf("CPO accepted KL %f exceeds MaxKL", kls[len(kls)-1])
+	}
+}
+
+func TestTRPOMinImprovement(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	for _, testCase := range []struct {
+		AcceptRatio float64
+		Expected    float64
+	}{
+		{0.5, 0.005},
+		{0, 0},
+		{-1, 0},
+	} {
+		trpo := &TRPO{TargetKL: 0.01, AcceptRatio: testCase.AcceptRatio}
+		actual := trpo.minImprovement(c, c.MakeNumeric(2)).(float64)
+		if math.Abs(actual-testCase.Expected) > 1e-8 {
+			t.Errorf("ratio %f: expected %f but got %f", testCase.AcceptRatio,
+				testCase.Expected, actual)
+		}
+	}
+
+	r := rolloutsForTest(c)
+	block := &anyrnn.LayerBlock{
+		Layer: anynet.Net{
+			anynet.NewFC(c, 3, 2),
+			anynet.Tanh,
+			anynet.NewFC(c, 2, 2),
+		},
+	}
+
+	// No step can improve the objective by more than the
+	// linear approximation predicts, to within the
+	// second-order error.
+	trpo := &TRPO{
+		NaturalPG: NaturalPG{
+			Policy:      block,
+			Params:      block.Parameters(),
+			ActionSpace: anyrl.Softmax{},
+			Iters:       14,
+		},
+		AcceptRatio: 100,
+	}
+	_, stats := trpo.RunStats(r)
+	if stats.LineSearchIters != DefaultMaxLineSearch {
+		t.Errorf("expected %d line search iterations but got %d",
+			DefaultMaxLineSearch, stats.LineSearchIters)
+	}
+}
+
+func TestTrustRegionValueFit(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+
+	layer := anynet.Net{anynet.NewFC(c, 3, 1)}
+	fit := &TrustRegionValueFit{
+		Critic: &anyrnn.LayerBlock{Layer: layer},
+		Params: layer.Parameters(),
+	}
+
+	meanLoss := func() float64 {
+		var sum float64
+		var count int
+		var step int
+		for batch := range r.Inputs.ReadTape(0, -1) {
+			out := layer.Apply(anydiff.NewConst(batch.Packed), batch.NumPresent())
+			values := c.Float64Slice(out.Output().Data())
+			for i, pres := range batch.Present {
+				if pres {
+					diff := values[0] - r.Rewards[i][step]
+					sum += diff * diff
+					count++
+					values = values[1:]
+				}
+			}
+			step++
+		}
+		return sum / float64(count)
+	}
+
+	oldLoss := meanLoss()
+	fit.Run(r, r.Rewards).AddToVars()
+	if newLoss := meanLoss(); newLoss >= oldLoss {
+		t.Errorf("loss went from %f to %f", oldLoss, newLoss)
+	}
+}
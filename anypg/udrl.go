@@ -0,0 +1,200 @@
+package anypg
+
+import (
+	"math"
+	"sort"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// UDRL implements upside-down reinforcement learning, in
+// which a policy is conditioned on a command (a desired
+// return and a horizon) and trained with supervised
+// learning to reproduce the actions from replayed
+// episodes that achieved those commands.
+//
+// Since no value function or importance weights are
+// involved, UDRL works well with offline data.
+//
+// The policy's inputs are the observations followed by
+// the scaled desired return and the scaled remaining
+// horizon.
+// Use a CommandEnv to act with the policy.
+//
+// See https://arxiv.org/abs/1912.02875.
+type UDRL struct {
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// Policy applies the policy to a sequence of
+	// command-augmented inputs.
+	Policy func(in lazyseq.Rereader) lazyseq.Rereader
+
+	// ActionSpace is used to compute the log-likelihoods
+	// of the replayed actions.
+	ActionSpace anyrl.LogProber
+
+	// ReturnScale and HorizonScale scale the command
+	// inputs.
+	//
+	// If 0, 1 is used.
+	ReturnScale  float64
+	HorizonScale float64
+}
+
+// Inputs creates a tape of command-augmented inputs for
+// the rollouts, where every timestep is commanded to
+// achieve the return and horizon that actually followed
+// it.
+func (u *UDRL) Inputs(r *anyrl.RolloutSet) lazyseq.Tape {
+	returns := make(anyrl.Rewards, len(r.Rewards))
+	horizons := make(anyrl.Rewards, len(r.Rewards))
+	for i, seq := range r.Rewards {
+		returns[i] = make([]float64, len(seq))
+		horizons[i] = make([]float64, len(seq))
+		var sum float64
+		for t := len(seq) - 1; t >= 0; t-- {
+			sum += seq[t]
+			returns[i][t] = sum * u.returnScale()
+			horizons[i][t] = float64(len(seq)-t) * u.horizonScale()
+		}
+	}
+	c := r.Creator()
+	return anyrl.ConcatTapes(r.Inputs, returns.Tape(c), horizons.Tape(c))
+}
+
+// Run computes a gradient which increases the mean
+// log-likelihood of the replayed actions given the
+// command-augmented inputs.
+//
+// It also returns the mean log-likelihood before the
+// update.
+//
+// If u.Params is empty, then an empty gradient and a nil
+// log-likelihood are returned.
+func (u *UDRL) Run(r *anyrl.RolloutSet) (anydiff.Grad, anyvec.Numeric) {
+	grad := anydiff.NewGrad(u.Params...)
+	if len(grad) == 0 {
+		return grad, nil
+	}
+	c := r.Creator()
+
+	logProbs := lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		return u.ActionSpace.LogProb(v[0], v[1].Output(), n)
+	}, u.Policy(lazyseq.TapeRereader(u.Inputs(r))), lazyseq.TapeRereader(r.Actions))
+	mean := lazyseq.Mean(logProbs)
+	mean.Propagate(anyvec.Ones(c, 1), grad)
+
+	return grad, anyvec.Sum(mean.Output())
+}
+
+// ExploreCommand picks a command for gathering new
+// episodes, based on the best topK episodes in r.
+//
+// The horizon is the mean length of those episodes, and
+// the return is sampled uniformly between their mean
+// return and their mean plus one standard deviation.
+func (u *UDRL) ExploreCommand(r *anyrl.RolloutSet, topK int,
+	rand func() float64) (ret, horizon float64) {
+	totals := r.Rewards.Totals()
+	indices := make([]int, len(totals))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return totals[indices[i]] > totals[indices[j]]
+	})
+	if topK > len(indices) {
+		topK = len(indices)
+	}
+	if topK == 0 {
+		return 0, 0
+	}
+
+	var sum, sqSum float64
+	for _, idx := range indices[:topK] {
+		sum += totals[idx]
+		sqSum += totals[idx] * totals[idx]
+		horizon += float64(len(r.Rewards[idx]))
+	}
+	mean := sum / float64(topK)
+	std := math.Sqrt(math.Max(0, sqSum/float64(topK)-mean*mean))
+	return mean + std*rand(), horizon / float64(topK)
+}
+
+// CommandEnv wraps an environment to produce the
+// command-augmented observations expected by a UDRL
+// policy.
+//
+// The desired return is decreased by every reward and the
+// horizon is decreased every timestep, as in training.
+type CommandEnv struct {
+	Env  anyrl.Env
+	UDRL *UDRL
+
+	// Return and Horizon are the command for the next
+	// episode.
+	Return  float64
+	Horizon float64
+
+	curReturn  float64
+	curHorizon float64
+}
+
+// Reset resets the environment and the command.
+func (c *CommandEnv) Reset() ([]float64, error) {
+	obs, err := c.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	c.curReturn = c.Return
+	c.curHorizon = c.Horizon
+	return c.augment(obs), nil
+}
+
+// Step steps the environment and updates the command.
+func (c *CommandEnv) Step(action []float64) (obs []float64, reward float64,
+	done bool, err error) {
+	obs, reward, done, err = c.Env.Step(action)
+	if err != nil {
+		return
+	}
+	c.curReturn -= reward
+	c.curHorizon = math.Max(1, c.curHorizon-1)
+	obs = c.augment(obs)
+	return
+}
+
+// Truncated returns whether the wrapped environment
+// truncated the episode.
+func (c *CommandEnv) Truncated() bool {
+	return anyrl.EnvTruncated(c.Env)
+}
+
+// Metadata returns the wrapped environment's metadata.
+func (c *CommandEnv) Metadata() map[string]float64 {
+	return anyrl.EnvMetadata(c.Env)
+}
+
+func (c *CommandEnv) augment(obs []float64) []float64 {
+	return append(append([]float64{}, obs...), c.curReturn*c.UDRL.returnScale(),
+		c.curHorizon*c.UDRL.horizonScale())
+}
+
+func (u *UDRL) returnScale() float64 {
+	if u.ReturnScale == 0 {
+		return 1
+	}
+	return u.ReturnScale
+}
+
+func (u *UDRL) horizonScale() float64 {
+	if u.HorizonScale == 0 {
+		return 1
+	}
+	return u.HorizonScale
+}
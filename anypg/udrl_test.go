@@ -0,0 +1,35 @@
+package anypg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestUDRLInputs(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := &anyrl.RolloutSet{
+		Inputs:  anyrl.Rewards{{1, 2, 3}, {4}}.Tape(c),
+		Rewards: anyrl.Rewards{{1, 2, 3}, {5}},
+	}
+	u := &UDRL{HorizonScale: 0.5}
+	var actual [][]float64
+	for batch := range u.Inputs(r).ReadTape(0, -1) {
+		actual = append(actual, batch.Packed.Data().([]float64))
+	}
+	expected := [][]float64{
+		{1, 6, 1.5, 4, 5, 0.5},
+		{2, 5, 1},
+		{3, 3, 0.5},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+
+	ret, horizon := u.ExploreCommand(r, 1, func() float64 { return 0.5 })
+	if ret != 6 || horizon != 3 {
+		t.Errorf("unexpected command: return=%f horizon=%f", ret, horizon)
+	}
+}
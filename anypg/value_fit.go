@@ -0,0 +1,165 @@
+package anypg
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// TrustRegionValueFit fits a value function to returns
+// with a trust-region constrained regression, as in
+// https://arxiv.org/abs/1506.02438.
+//
+// The value function is treated as the mean of a Gaussian
+// whose variance is the mean squared error of the old
+// value function.
+// The step minimizes the squared error subject to a bound
+// on the mean KL divergence between the old and new
+// Gaussians, using the same Conjugate Gradients and line
+// search machinery as TRPO.
+type TrustRegionValueFit struct {
+	// Critic produces one value estimate per timestep.
+	Critic anyrnn.Block
+	Params []*anydiff.Var
+
+	// MaxDivergence is the largest average KL divergence
+	// allowed between the old and new value functions.
+	//
+	// If 0, DefaultTargetKL is used.
+	MaxDivergence float64
+
+	// ApplyCritic applies the critic to an input sequence.
+	// If nil, back-propagation through time is used.
+	ApplyCritic func(s lazyseq.Rereader, b anyrnn.Block) lazyseq.Rereader
+
+	// Iters specifies the number of iterations of the
+	// Conjugate Gradients algorithm.
+	// If 0, DefaultConjGradIters is used.
+	Iters int
+
+	// Damping is the multiple of the identity matrix to
+	// add to the curvature matrix.
+	Damping float64
+
+	// LineSearchDecay is used to shrink steps which do not
+	// reduce the loss or which violate MaxDivergence.
+	//
+	// If 0, DefaultLineSearchDecay is used.
+	LineSearchDecay float64
+
+	// MaxLineSearch is the maximum number of line-search
+	// iterations to take.
+	//
+	// If 0, DefaultMaxLineSearch is used.
+	MaxLineSearch int
+}
+
+// Run computes a step for the critic's parameters which
+// decreases the squared error between the critic and the
+// returns.
+//
+// Like the trainers, the result should be added to the
+// parameters.
+func (t *TrustRegionValueFit) Run(r *anyrl.RolloutSet, returns anyrl.Rewards) anydiff.Grad {
+	c := r.Creator()
+	targets := returns.Tape(c)
+	trpo := t.trpo()
+
+	oldOuts := lazyseq.MakeReuser(trpo.apply(lazyseq.TapeRereader(r.Inputs), t.Critic))
+	loss := lazyseq.Mean(lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		return anydiff.Square(anydiff.Sub(v[0], v[1]))
+	}, oldOuts, lazyseq.TapeRereader(targets)))
+	grad := anydiff.NewGrad(t.Params...)
+	if len(grad) == 0 {
+		return grad
+	}
+	loss.Propagate(anyvec.Make(c, []float64{-1}), grad)
+	if allZeros(grad) {
+		return grad
+	}
+	oldLoss := anyvec.Sum(loss.Output())
+	trpo.ActionSpace = &valueGaussian{Variance: c.Float64(oldLoss)}
+
+	res := &naturalPGRes{
+		Grad:            grad,
+		PolicyOut:       oldOuts,
+		ReducedOut:      oldOuts,
+		ReducedRollouts: r,
+		Curvature:       trpo.curvature(r),
+	}
+	trpo.conjugateGradients(r, res.Curvature, oldOuts, grad)
+	grad.Scale(trpo.stepSize(res))
+
+	for i := 0; i < trpo.maxLineSearch(); i++ {
+		if t.acceptable(trpo, r, targets, oldOuts, grad, oldLoss) {
+			break
+		}
+		grad.Scale(c.MakeNumeric(trpo.lineSearchDecay()))
+	}
+
+	return grad
+}
+
+// acceptable checks if a step decreases the loss without
+// violating the trust region.
+func (t *TrustRegionValueFit) acceptable(trpo *TRPO, r *anyrl.RolloutSet,
+	targets lazyseq.Tape, oldOuts lazyseq.Reuser, step anydiff.Grad,
+	oldLoss anyvec.Numeric) bool {
+	c := r.Creator()
+	newOuts := trpo.apply(lazyseq.TapeRereader(r.Inputs), trpo.steppedPolicy(step))
+	weights := lazyseq.TapeRereader(trpo.klWeights(r).Tape(c))
+	oldOuts.Reuse()
+
+	// At each timestep, compute a pair <loss, kl divergence>.
+	mapped := lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		newOut, oldOut, target, weight := v[0], v[1], v[2], v[3]
+		loss := anydiff.Square(anydiff.Sub(newOut, target))
+		kl := anydiff.Mul(trpo.ActionSpace.KL(oldOut, newOut, n), weight)
+		joined := c.Concat(loss.Output(), kl.Output())
+		transposed := c.MakeVector(joined.Len())
+		anyvec.Transpose(joined, transposed, 2)
+		return anydiff.NewConst(transposed)
+	}, newOuts, oldOuts, lazyseq.TapeRereader(targets), weights)
+
+	stats := lazyseq.Mean(mapped).Output()
+	newLoss := anyvec.Sum(stats.Slice(0, 1))
+	kl := anyvec.Sum(stats.Slice(1, 2))
+
+	ops := c.NumOps()
+	return ops.Less(newLoss, oldLoss) && ops.Less(kl, c.MakeNumeric(trpo.targetKL()))
+}
+
+func (t *TrustRegionValueFit) trpo() *TRPO {
+	return &TRPO{
+		NaturalPG: NaturalPG{
+			Policy:      t.Critic,
+			Params:      t.Params,
+			Iters:       t.Iters,
+			Damping:     t.Damping,
+			ApplyPolicy: t.ApplyCritic,
+		},
+		TargetKL:        t.MaxDivergence,
+		LineSearchDecay: t.LineSearchDecay,
+		MaxLineSearch:   t.MaxLineSearch,
+	}
+}
+
+// valueGaussian is a fixed-variance Gaussian over scalar
+// value estimates.
+type valueGaussian struct {
+	Variance float64
+}
+
+func (v *valueGaussian) LogProb(params anydiff.Res, output anyvec.Vector,
+	batchSize int) anydiff.Res {
+	diff := anydiff.Sub(params, anydiff.NewConst(output))
+	scale := params.Output().Creator().MakeNumeric(-1 / (2 * v.Variance))
+	return anydiff.Scale(anydiff.Square(diff), scale)
+}
+
+func (v *valueGaussian) KL(params1, params2 anydiff.Res, batchSize int) anydiff.Res {
+	scale := params1.Output().Creator().MakeNumeric(1 / (2 * v.Variance))
+	return anydiff.Scale(anydiff.Square(anydiff.Sub(params1, params2)), scale)
+}
@@ -0,0 +1,175 @@
+package anypg
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// Default settings for WarmStart.
+const (
+	DefaultLeash      = 0.1
+	DefaultLeashDecay = 0.95
+)
+
+// WarmStart pre-trains a policy with behavioral cloning
+// and then fine-tunes it with policy gradients, keeping
+// it close to the cloned policy with a KL "leash" whose
+// strength decays over time.
+//
+// Training has two phases.
+// First, call Clone repeatedly on demonstrations.
+// Then, call Freeze with a frozen copy of the cloned
+// policy and fine-tune with PPO or TRPO, calling Step
+// after every iteration to decay the leash.
+//
+// The leash can be applied in two ways.
+// With PPO or A2C, use the WarmStart as an AuxTask (with
+// a coefficient of 1) to penalize the KL divergence
+// directly.
+// With TRPO, use it as a RewardTransform in a Pipeline to
+// penalize the log-likelihood ratio between the current
+// and cloned policies in the rewards.
+// Either way, the penalty is the KL divergence from the
+// current policy to the cloned policy, KL(current||cloned),
+// which is the direction that can be estimated from
+// actions sampled by the current policy.
+type WarmStart struct {
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// Policy applies the policy being trained.
+	Policy func(obses lazyseq.Rereader) lazyseq.Rereader
+
+	ActionSpace NaturalActionSpace
+
+	// Leash is the initial coefficient for the KL
+	// penalty.
+	//
+	// If 0, DefaultLeash is used.
+	Leash float64
+
+	// LeashDecay is the factor by which the coefficient
+	// decays on every Step.
+	//
+	// If 0, DefaultLeashDecay is used.
+	LeashDecay float64
+
+	cloned func(obses lazyseq.Rereader) lazyseq.Rereader
+	steps  int
+}
+
+// Clone computes a gradient which increases the mean
+// log-likelihood of the demonstrated actions.
+//
+// It also returns the mean log-likelihood before the
+// update.
+func (w *WarmStart) Clone(demos *anyrl.RolloutSet) (anydiff.Grad, anyvec.Numeric) {
+	grad := anydiff.NewGrad(w.Params...)
+	c := demos.Creator()
+	logProbs := lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		return w.ActionSpace.LogProb(v[0], v[1].Output(), n)
+	}, w.Policy(lazyseq.TapeRereader(demos.Inputs)), lazyseq.TapeRereader(demos.Actions))
+	mean := lazyseq.Mean(logProbs)
+	if len(grad) > 0 {
+		mean.Propagate(anyvec.Ones(c, 1), grad)
+	}
+	return grad, anyvec.Sum(mean.Output())
+}
+
+// Freeze ends the cloning phase.
+//
+// The cloned policy must be a frozen copy of the policy
+// (e.g. made with serializer.Copy), since it must not
+// change during fine-tuning.
+func (w *WarmStart) Freeze(cloned func(obses lazyseq.Rereader) lazyseq.Rereader) {
+	w.cloned = cloned
+	w.steps = 0
+}
+
+// FineTuning returns true once Freeze has been called.
+func (w *WarmStart) FineTuning() bool {
+	return w.cloned != nil
+}
+
+// Step decays the leash.
+// It should be called once per fine-tuning iteration.
+func (w *WarmStart) Step() {
+	w.steps++
+}
+
+// Coeff returns the current leash coefficient.
+func (w *WarmStart) Coeff() float64 {
+	return w.leash() * math.Pow(w.leashDecay(), float64(w.steps))
+}
+
+// AuxLoss computes the mean KL divergence from the
+// current policy to the cloned policy, scaled by Coeff.
+//
+// This implements AuxTask.
+func (w *WarmStart) AuxLoss(r *anyrl.RolloutSet) anydiff.Res {
+	w.checkFrozen()
+	c := r.Creator()
+	inputs := lazyseq.TapeRereader(r.Inputs)
+	kls := lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+		return w.ActionSpace.KL(v[0], anydiff.NewConst(v[1].Output()), n)
+	}, w.Policy(inputs), w.cloned(inputs))
+	return anydiff.Scale(lazyseq.Mean(kls), c.MakeNumeric(w.Coeff()))
+}
+
+// Transform subtracts the scaled log-likelihood ratio
+// between the rollout policy and the cloned policy from
+// every value.
+//
+// In expectation, this penalizes the KL divergence from
+// the current policy to the cloned policy.
+// The rollouts must have AgentOuts.
+//
+// This implements RewardTransform.
+func (w *WarmStart) Transform(r *anyrl.RolloutSet, values anyrl.Rewards) anyrl.Rewards {
+	w.checkFrozen()
+	ratios := criticValues(func(inputs lazyseq.Rereader) <-chan *anyseq.Batch {
+		return lazyseq.MapN(func(n int, v ...anydiff.Res) anydiff.Res {
+			actions := v[2].Output()
+			return anydiff.Sub(
+				w.ActionSpace.LogProb(v[0], actions, n),
+				w.ActionSpace.LogProb(v[1], actions, n),
+			)
+		}, lazyseq.TapeRereader(r.AgentOuts), w.cloned(inputs),
+			lazyseq.TapeRereader(r.Actions)).Forward()
+	}, r)
+	coeff := w.Coeff()
+	res := make(anyrl.Rewards, len(values))
+	for i, seq := range values {
+		res[i] = make([]float64, len(seq))
+		for t, x := range seq {
+			res[i][t] = x - coeff*ratios[i][t]
+		}
+	}
+	return res
+}
+
+func (w *WarmStart) checkFrozen() {
+	if w.cloned == nil {
+		panic("cloned policy has not been frozen")
+	}
+}
+
+func (w *WarmStart) leash() float64 {
+	if w.Leash == 0 {
+		return DefaultLeash
+	}
+	return w.Leash
+}
+
+func (w *WarmStart) leashDecay() float64 {
+	if w.LeashDecay == 0 {
+		return DefaultLeashDecay
+	}
+	return w.LeashDecay
+}
@@ -0,0 +1,153 @@
+package anypg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/lazyseq"
+)
+
+func TestWarmStartClone(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	demos := rolloutsForTest(c)
+	layer := anynet.NewFC(c, 3, 2)
+	ws := &WarmStart{
+		Params:      layer.Parameters(),
+		Policy:      layerPolicy(layer),
+		ActionSpace: anyrl.Softmax{},
+	}
+	grad, oldLL := ws.Clone(demos)
+	grad.Scale(c.MakeNumeric(0.1))
+	grad.AddToVars()
+	if _, newLL := ws.Clone(demos); newLL.(float64) <= oldLL.(float64) {
+		t.Errorf("log-likelihood went from %f to %f", oldLL, newLL)
+	}
+}
+
+func TestWarmStartLeash(t *testing.T) {
+	ws := &WarmStart{Leash: 0.5, LeashDecay: 0.5}
+	ws.Freeze(layerPolicy(anynet.NewFC(anyvec64.DefaultCreator{}, 3, 2)))
+	ws.Step()
+	ws.Step()
+	if coeff := ws.Coeff(); math.Abs(coeff-0.125) > 1e-8 {
+		t.Errorf("expected coefficient 0.125 but got %f", coeff)
+	}
+	ws.Freeze(ws.cloned)
+	if coeff := ws.Coeff(); coeff != 0.5 {
+		t.Errorf("expected coefficient 0.5 after Freeze but got %f", coeff)
+	}
+	if coeff := (&WarmStart{}).Coeff(); coeff != DefaultLeash {
+		t.Errorf("expected default coefficient but got %f", coeff)
+	}
+}
+
+func TestWarmStartPenalties(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	r := rolloutsForTest(c)
+	current := anynet.NewFC(c, 3, 2)
+	cloned := anynet.NewFC(c, 3, 2)
+	r.AgentOuts = layerTape(current, r.Inputs)
+
+	ws := &WarmStart{
+		Policy:      layerPolicy(current),
+		ActionSpace: anyrl.Softmax{},
+		Leash:       0.5,
+	}
+	ws.Freeze(layerPolicy(cloned))
+
+	// Compute KL(current||cloned) and the log-likelihood
+	// ratios of the sampled actions by hand.
+	var klSum float64
+	var ratios [][]float64
+	curOuts := tapeSteps(layerTape(current, r.Inputs), len(r.Rewards))
+	clonedOuts := tapeSteps(layerTape(cloned, r.Inputs), len(r.Rewards))
+	actions := tapeSteps(r.Actions, len(r.Rewards))
+	for i := range r.Rewards {
+		ratios = append(ratios, nil)
+		for step := range r.Rewards[i] {
+			p := softmaxSlice(curOuts[i][step])
+			q := softmaxSlice(clonedOuts[i][step])
+			for j := range p {
+				klSum += p[j] * math.Log(p[j]/q[j])
+				if actions[i][step][j] == 1 {
+					ratios[i] = append(ratios[i], math.Log(p[j]/q[j]))
+				}
+			}
+		}
+	}
+
+	auxLoss := c.Float64(anyvec.Sum(ws.AuxLoss(r).Output()))
+	expected := 0.5 * klSum / float64(r.NumSteps())
+	if math.Abs(auxLoss-expected) > 1e-8 {
+		t.Errorf("expected aux loss %f but got %f", expected, auxLoss)
+	}
+
+	values := make(anyrl.Rewards, len(r.Rewards))
+	for i, seq := range r.Rewards {
+		values[i] = make([]float64, len(seq))
+	}
+	transformed := ws.Transform(r, values)
+	for i, seq := range ratios {
+		for step, ratio := range seq {
+			if math.Abs(transformed[i][step]+0.5*ratio) > 1e-8 {
+				t.Errorf("episode %d step %d: expected %f but got %f", i, step,
+					-0.5*ratio, transformed[i][step])
+			}
+		}
+	}
+}
+
+func layerPolicy(layer anynet.Layer) func(lazyseq.Rereader) lazyseq.Rereader {
+	return func(in lazyseq.Rereader) lazyseq.Rereader {
+		return lazyseq.Map(in, layer.Apply)
+	}
+}
+
+func layerTape(layer anynet.Layer, inputs lazyseq.Tape) lazyseq.Tape {
+	tape, writer := lazyseq.ReferenceTape(inputs.Creator())
+	for batch := range inputs.ReadTape(0, -1) {
+		writer <- &anyseq.Batch{
+			Present: batch.Present,
+			Packed:  layer.Apply(anydiff.NewConst(batch.Packed), batch.NumPresent()).Output(),
+		}
+	}
+	close(writer)
+	return tape
+}
+
+// tapeSteps splits a tape into the data for each
+// timestep of each episode.
+func tapeSteps(t lazyseq.Tape, numEpisodes int) [][][]float64 {
+	c := t.Creator()
+	res := make([][][]float64, numEpisodes)
+	for batch := range t.ReadTape(0, -1) {
+		chunkSize := batch.Packed.Len() / batch.NumPresent()
+		data := c.Float64Slice(batch.Packed.Data())
+		for i, pres := range batch.Present {
+			if pres {
+				res[i] = append(res[i], data[:chunkSize])
+				data = data[chunkSize:]
+			}
+		}
+	}
+	return res
+}
+
+func softmaxSlice(logits []float64) []float64 {
+	var sum float64
+	res := make([]float64, len(logits))
+	for i, x := range logits {
+		res[i] = math.Exp(x)
+		sum += res[i]
+	}
+	for i := range res {
+		res[i] /= sum
+	}
+	return res
+}
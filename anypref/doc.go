@@ -0,0 +1,6 @@
+// Package anypref implements reward learning from
+// pairwise preferences between trajectory segments.
+//
+// For more on the approach, see
+// https://arxiv.org/abs/1706.03741.
+package anypref
@@ -0,0 +1,158 @@
+package anypref
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/essentials"
+)
+
+// A Labeler assigns preferences to pairs of segments.
+//
+// It should set the Pref field of every pair it labels.
+// Pairs which cannot be compared should be excluded from
+// the result.
+type Labeler interface {
+	Label(pairs []*Pair) ([]*Pair, error)
+}
+
+// LabelFunc is a Labeler which calls a function for each
+// pair.
+//
+// The function returns the probability that the first
+// segment is preferred, or a negative value if the pair
+// cannot be compared.
+type LabelFunc func(p *Pair) (float64, error)
+
+// Label labels the pairs by calling the function.
+func (l LabelFunc) Label(pairs []*Pair) (labeled []*Pair, err error) {
+	defer essentials.AddCtxTo("label pairs", &err)
+	for _, p := range pairs {
+		pref, err := l(p)
+		if err != nil {
+			return nil, err
+		}
+		if pref >= 0 {
+			p.Pref = pref
+			labeled = append(labeled, p)
+		}
+	}
+	return labeled, nil
+}
+
+// RewardLabeler is a Labeler which prefers the segment
+// with a higher total of some known reward.
+//
+// It is mostly useful for testing reward learning on
+// environments which already have a reward function.
+type RewardLabeler struct {
+	// Rewards contains the true rewards for the rollouts
+	// from which the segments were taken.
+	Rewards anyrl.Rewards
+}
+
+// Label prefers the segment with the higher total reward.
+// Ties are labeled with a preference of 0.5.
+func (r *RewardLabeler) Label(pairs []*Pair) ([]*Pair, error) {
+	for _, p := range pairs {
+		totalA, totalB := r.total(p.A), r.total(p.B)
+		if totalA > totalB {
+			p.Pref = 1
+		} else if totalA < totalB {
+			p.Pref = 0
+		} else {
+			p.Pref = 0.5
+		}
+	}
+	return pairs, nil
+}
+
+func (r *RewardLabeler) total(s *Segment) float64 {
+	var sum float64
+	for _, x := range r.Rewards[s.Episode][s.Start : s.Start+s.Len()] {
+		sum += x
+	}
+	return sum
+}
+
+// WritePairs writes a JSON description of each pair to w,
+// one pair per line, so that the pairs can be labeled by
+// an external tool.
+//
+// Each line is an object with the fields "index",
+// "a_episode", "a_start", "b_episode", "b_start", and
+// "length".
+func WritePairs(w io.Writer, pairs []*Pair) (err error) {
+	defer essentials.AddCtxTo("write pairs", &err)
+	enc := json.NewEncoder(w)
+	for i, p := range pairs {
+		obj := map[string]int{
+			"index":     i,
+			"a_episode": p.A.Episode,
+			"a_start":   p.A.Start,
+			"b_episode": p.B.Episode,
+			"b_start":   p.B.Start,
+			"length":    p.A.Len(),
+		}
+		if err := enc.Encode(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadLabels reads preferences for the pairs from r and
+// returns the pairs which were labeled.
+//
+// The input should contain one line per pair, in the
+// same order as the pairs.
+// Each line is either "left" (A is preferred), "right"
+// (B is preferred), "equal", "skip", or a number
+// indicating the probability that A is preferred.
+func ReadLabels(r io.Reader, pairs []*Pair) (labeled []*Pair, err error) {
+	defer essentials.AddCtxTo("read labels", &err)
+	scanner := bufio.NewScanner(r)
+	var idx int
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if idx >= len(pairs) {
+			return nil, fmt.Errorf("too many labels (expected %d)", len(pairs))
+		}
+		p := pairs[idx]
+		idx++
+		switch line {
+		case "left":
+			p.Pref = 1
+		case "right":
+			p.Pref = 0
+		case "equal":
+			p.Pref = 0.5
+		case "skip":
+			continue
+		default:
+			pref, err := strconv.ParseFloat(line, 64)
+			if err != nil {
+				return nil, err
+			} else if pref < 0 || pref > 1 {
+				return nil, fmt.Errorf("preference out of range: %v", pref)
+			}
+			p.Pref = pref
+		}
+		labeled = append(labeled, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if idx != len(pairs) {
+		return nil, fmt.Errorf("expected %d labels but got %d", len(pairs), idx)
+	}
+	return labeled, nil
+}
@@ -0,0 +1,37 @@
+package anypref
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadLabels(t *testing.T) {
+	var pairs []*Pair
+	for i := 0; i < 5; i++ {
+		pairs = append(pairs, &Pair{A: &Segment{}, B: &Segment{}})
+	}
+	input := "left\nright\n\nskip\nequal\n0.25\n"
+	labeled, err := ReadLabels(strings.NewReader(input), pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Pair{pairs[0], pairs[1], pairs[3], pairs[4]}
+	if len(labeled) != len(expected) {
+		t.Fatalf("expected %d labels but got %d", len(expected), len(labeled))
+	}
+	for i, p := range expected {
+		if labeled[i] != p {
+			t.Errorf("label %d: wrong pair", i)
+		}
+	}
+	prefs := []float64{1, 0, 0.5, 0.25}
+	for i, pref := range prefs {
+		if labeled[i].Pref != pref {
+			t.Errorf("label %d: expected %f but got %f", i, pref, labeled[i].Pref)
+		}
+	}
+
+	if _, err := ReadLabels(strings.NewReader("left\n"), pairs); err == nil {
+		t.Error("expected error for missing labels")
+	}
+}
@@ -0,0 +1,171 @@
+package anypref
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// RewardModel is a learned reward function which predicts
+// a reward for each timestep.
+//
+// The probability that one segment is preferred over
+// another is modeled as the logistic sigmoid of the
+// difference between the segments' predicted rewards
+// (the Bradley-Terry model).
+type RewardModel struct {
+	// Model maps a batch of timesteps to a batch of
+	// scalar rewards.
+	//
+	// If UseActions is false, each timestep is an
+	// observation vector.
+	// Otherwise, each timestep is an observation vector
+	// followed by an action vector.
+	Model anynet.Layer
+
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// UseActions indicates whether actions are fed to
+	// the model alongside observations.
+	UseActions bool
+}
+
+// Train computes a gradient for the model on a batch of
+// labeled pairs.
+//
+// The gradient is for the log-likelihood of the labels,
+// so it should be added to the parameters to improve the
+// model.
+// The mean cross-entropy loss is also returned.
+//
+// If r.Params is empty or there are no pairs, then an
+// empty gradient and a nil loss are returned.
+func (r *RewardModel) Train(pairs []*Pair) (grad anydiff.Grad, loss anyvec.Numeric) {
+	grad = anydiff.NewGrad(r.Params...)
+	if len(grad) == 0 || len(pairs) == 0 {
+		return grad, nil
+	}
+	c := pairs[0].A.Inputs[0].Creator()
+
+	var segs []*Segment
+	var prefs []float64
+	for _, p := range pairs {
+		segs = append(segs, p.A)
+		prefs = append(prefs, p.Pref)
+	}
+	for _, p := range pairs {
+		segs = append(segs, p.B)
+	}
+
+	sums := r.segmentSums(c, segs)
+	n := len(pairs)
+	logits := anydiff.Pool(sums, func(sums anydiff.Res) anydiff.Res {
+		return anydiff.Sub(anydiff.Slice(sums, 0, n), anydiff.Slice(sums, n, 2*n))
+	})
+	costs := anynet.SigmoidCE{}.Cost(anydiff.NewConst(anyvec.Make(c, prefs)), logits, n)
+	meanCost := anydiff.Scale(anydiff.Sum(costs), c.MakeNumeric(1/float64(n)))
+
+	upstream := c.MakeVector(1)
+	upstream.AddScalar(c.MakeNumeric(-1))
+	meanCost.Propagate(upstream, grad)
+
+	return grad, anyvec.Sum(meanCost.Output())
+}
+
+// Rewards computes the predicted rewards for every
+// timestep in the rollouts.
+func (r *RewardModel) Rewards(rollouts *anyrl.RolloutSet) anyrl.Rewards {
+	res := make(anyrl.Rewards, len(rollouts.Rewards))
+
+	inputs := rollouts.Inputs.ReadTape(0, -1)
+	var actions <-chan *anyseq.Batch
+	if r.UseActions {
+		actions = rollouts.Actions.ReadTape(0, -1)
+	}
+
+	for inBatch := range inputs {
+		n := inBatch.NumPresent()
+		var vecs []anyvec.Vector
+		if r.UseActions {
+			actBatch := <-actions
+			vecs = joinTimesteps(splitBatch(inBatch, n), splitBatch(actBatch, n))
+		} else {
+			vecs = []anyvec.Vector{inBatch.Packed}
+		}
+		c := inBatch.Packed.Creator()
+		out := r.Model.Apply(anydiff.NewConst(c.Concat(vecs...)), n).Output()
+		values := c.Float64Slice(out.Data())
+		for i, pres := range inBatch.Present {
+			if pres {
+				res[i] = append(res[i], values[0])
+				values = values[1:]
+			}
+		}
+	}
+
+	if r.UseActions {
+		// Drain the channel so the tape is not blocked.
+		for _ = range actions {
+		}
+	}
+
+	return res
+}
+
+// Substitute produces a copy of the rollouts in which the
+// rewards have been replaced by the model's predictions.
+//
+// The tapes are shared with the original RolloutSet.
+func (r *RewardModel) Substitute(rollouts *anyrl.RolloutSet) *anyrl.RolloutSet {
+	res := *rollouts
+	res.Rewards = r.Rewards(rollouts)
+	return &res
+}
+
+// segmentSums computes the total predicted reward for
+// each segment.
+func (r *RewardModel) segmentSums(c anyvec.Creator, segs []*Segment) anydiff.Res {
+	var vecs []anyvec.Vector
+	var numSteps int
+	for _, seg := range segs {
+		if r.UseActions {
+			vecs = append(vecs, joinTimesteps(seg.Inputs, seg.Actions)...)
+		} else {
+			vecs = append(vecs, seg.Inputs...)
+		}
+		numSteps += seg.Len()
+	}
+	out := r.Model.Apply(anydiff.NewConst(c.Concat(vecs...)), numSteps)
+	return anydiff.Pool(out, func(out anydiff.Res) anydiff.Res {
+		var sums []anydiff.Res
+		var offset int
+		for _, seg := range segs {
+			sums = append(sums, anydiff.Sum(anydiff.Slice(out, offset, offset+seg.Len())))
+			offset += seg.Len()
+		}
+		return anydiff.Concat(sums...)
+	})
+}
+
+// joinTimesteps interleaves observations and actions so
+// that each observation is followed by its action.
+func joinTimesteps(inputs, actions []anyvec.Vector) []anyvec.Vector {
+	var res []anyvec.Vector
+	for i, in := range inputs {
+		res = append(res, in, actions[i])
+	}
+	return res
+}
+
+func splitBatch(b *anyseq.Batch, n int) []anyvec.Vector {
+	chunkSize := b.Packed.Len() / n
+	res := make([]anyvec.Vector, n)
+	for i := range res {
+		res[i] = b.Packed.Slice(i*chunkSize, (i+1)*chunkSize)
+	}
+	return res
+}
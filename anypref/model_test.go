@@ -0,0 +1,96 @@
+package anypref
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestRewardModelTrain(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	fc := anynet.NewFC(c, 1, 1)
+	fc.Weights.Vector.SetData(c.MakeNumericList([]float64{0.3}))
+	fc.Biases.Vector.SetData(c.MakeNumericList([]float64{0.7}))
+	model := &RewardModel{Model: fc, Params: fc.Parameters()}
+
+	segment := func(xs ...float64) *Segment {
+		res := &Segment{}
+		for _, x := range xs {
+			res.Inputs = append(res.Inputs, anyvec.Make(c, []float64{x}))
+		}
+		return res
+	}
+	pairs := []*Pair{
+		{A: segment(1, 2), B: segment(0, 1), Pref: 1},
+		{A: segment(5, 0), B: segment(1, 0), Pref: 0.25},
+	}
+
+	// The bias cancels out, so the logits are the
+	// differences in input sums times the weight.
+	sigmoid := func(x float64) float64 {
+		return 1 / (1 + math.Exp(-x))
+	}
+	diffs := []float64{2, 4}
+	var expectedLoss, expectedGrad float64
+	for i, p := range pairs {
+		prob := sigmoid(0.3 * diffs[i])
+		expectedLoss -= p.Pref*math.Log(prob) + (1-p.Pref)*math.Log(1-prob)
+		expectedGrad += (p.Pref - prob) * diffs[i]
+	}
+	expectedLoss /= 2
+	expectedGrad /= 2
+
+	grad, loss := model.Train(pairs)
+	if math.Abs(loss.(float64)-expectedLoss) > 1e-8 {
+		t.Errorf("expected loss %f but got %f", expectedLoss, loss)
+	}
+	if actual := c.Float64Slice(grad[fc.Weights].Data())[0]; math.Abs(actual-expectedGrad) > 1e-8 {
+		t.Errorf("expected weight gradient %f but got %f", expectedGrad, actual)
+	}
+	if actual := c.Float64Slice(grad[fc.Biases].Data())[0]; math.Abs(actual) > 1e-8 {
+		t.Errorf("expected zero bias gradient but got %f", actual)
+	}
+}
+
+func TestRewardModelRewards(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	rollouts := &anyrl.RolloutSet{
+		Inputs:  anyrl.Rewards{{1, 2}, {3}}.Tape(c),
+		Actions: anyrl.Rewards{{4, 5}, {6}}.Tape(c),
+		Rewards: anyrl.Rewards{{0, 0}, {0}},
+	}
+
+	fc := anynet.NewFC(c, 1, 1)
+	fc.Weights.Vector.SetData(c.MakeNumericList([]float64{2}))
+	fc.Biases.Vector.SetData(c.MakeNumericList([]float64{1}))
+	model := &RewardModel{Model: fc}
+	checkRewards(t, model.Rewards(rollouts), anyrl.Rewards{{3, 5}, {7}})
+
+	fc = anynet.NewFC(c, 2, 1)
+	fc.Weights.Vector.SetData(c.MakeNumericList([]float64{1, 10}))
+	fc.Biases.Vector.SetData(c.MakeNumericList([]float64{0}))
+	model = &RewardModel{Model: fc, UseActions: true}
+	checkRewards(t, model.Rewards(rollouts), anyrl.Rewards{{41, 52}, {63}})
+}
+
+func checkRewards(t *testing.T, actual, expected anyrl.Rewards) {
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d episodes but got %d", len(expected), len(actual))
+	}
+	for i, seq := range expected {
+		if len(actual[i]) != len(seq) {
+			t.Errorf("episode %d: expected %v but got %v", i, seq, actual[i])
+			continue
+		}
+		for j, x := range seq {
+			if math.Abs(actual[i][j]-x) > 1e-8 {
+				t.Errorf("episode %d: expected %v but got %v", i, seq, actual[i])
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package anypref
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// A Segment is a contiguous chunk of an episode.
+type Segment struct {
+	// Episode is the index of the episode in the
+	// RolloutSet from which the segment was taken.
+	Episode int
+
+	// Start is the timestep within the episode at which
+	// the segment begins.
+	Start int
+
+	// Inputs and Actions store the observations and the
+	// actions at each timestep in the segment.
+	Inputs  []anyvec.Vector
+	Actions []anyvec.Vector
+}
+
+// Len returns the number of timesteps in the segment.
+func (s *Segment) Len() int {
+	return len(s.Inputs)
+}
+
+// A Pair is a pair of segments to be compared.
+type Pair struct {
+	A *Segment
+	B *Segment
+
+	// Pref is the probability that A is preferred over B.
+	// A value of 0.5 indicates that the segments are
+	// equally good.
+	Pref float64
+}
+
+// SampleSegments samples n random segments of the given
+// length from the rollouts.
+//
+// Episodes which are shorter than length are never used.
+// If no episodes are long enough, nil is returned.
+func SampleSegments(r *anyrl.RolloutSet, length, n int) []*Segment {
+	var candidates []int
+	for i, seq := range r.Rewards {
+		if len(seq) >= length {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	inputs := tapeEpisodes(r.Inputs, len(r.Rewards))
+	actions := tapeEpisodes(r.Actions, len(r.Rewards))
+
+	var res []*Segment
+	for i := 0; i < n; i++ {
+		ep := candidates[rand.Intn(len(candidates))]
+		start := rand.Intn(len(r.Rewards[ep]) - length + 1)
+		res = append(res, &Segment{
+			Episode: ep,
+			Start:   start,
+			Inputs:  inputs[ep][start : start+length],
+			Actions: actions[ep][start : start+length],
+		})
+	}
+	return res
+}
+
+// SamplePairs samples n random pairs of segments from the
+// rollouts.
+//
+// The Pref field of each pair is left at 0 until the pair
+// is labeled.
+func SamplePairs(r *anyrl.RolloutSet, length, n int) []*Pair {
+	segs := SampleSegments(r, length, n*2)
+	if segs == nil {
+		return nil
+	}
+	res := make([]*Pair, n)
+	for i := range res {
+		res[i] = &Pair{A: segs[i*2], B: segs[i*2+1]}
+	}
+	return res
+}
+
+// tapeEpisodes splits a tape up into a list of timestep
+// vectors for each episode.
+func tapeEpisodes(t lazyseq.Tape, numEpisodes int) [][]anyvec.Vector {
+	res := make([][]anyvec.Vector, numEpisodes)
+	for batch := range t.ReadTape(0, -1) {
+		chunkSize := batch.Packed.Len() / batch.NumPresent()
+		var offset int
+		for i, pres := range batch.Present {
+			if pres {
+				vec := batch.Packed.Slice(offset, offset+chunkSize)
+				res[i] = append(res[i], vec)
+				offset += chunkSize
+			}
+		}
+	}
+	return res
+}
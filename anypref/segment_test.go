@@ -0,0 +1,50 @@
+package anypref
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestSampleSegments(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+
+	// Each input encodes its episode and timestep.
+	inputs := anyrl.Rewards{{0, 1, 2, 3, 4}, {10, 11}, {20, 21, 22}}
+	rollouts := &anyrl.RolloutSet{
+		Inputs:  inputs.Tape(c),
+		Actions: inputs.Tape(c),
+		Rewards: inputs,
+	}
+
+	segs := SampleSegments(rollouts, 3, 100)
+	if len(segs) != 100 {
+		t.Fatalf("expected 100 segments but got %d", len(segs))
+	}
+	for i, seg := range segs {
+		if seg.Len() != 3 || len(seg.Actions) != 3 {
+			t.Errorf("segment %d: expected length 3 but got %d", i, seg.Len())
+			continue
+		}
+		if seg.Episode == 1 {
+			t.Errorf("segment %d: sampled from an episode which is too short", i)
+			continue
+		}
+		if seg.Start < 0 || seg.Start+3 > len(inputs[seg.Episode]) {
+			t.Errorf("segment %d: start %d is out of bounds", i, seg.Start)
+			continue
+		}
+		for j, vec := range seg.Inputs {
+			expected := inputs[seg.Episode][seg.Start+j]
+			if actual := c.Float64Slice(vec.Data())[0]; actual != expected {
+				t.Errorf("segment %d step %d: expected %f but got %f", i, j,
+					expected, actual)
+			}
+		}
+	}
+
+	if segs := SampleSegments(rollouts, 6, 10); segs != nil {
+		t.Errorf("expected nil but got %d segments", len(segs))
+	}
+}
@@ -0,0 +1,218 @@
+package anyq
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// DefaultBCQThreshold is the default relative probability
+// threshold used by BCQ.
+const DefaultBCQThreshold = 0.3
+
+// BCQTerms stores the current values of the terms in the
+// BCQ loss function.
+type BCQTerms struct {
+	MeanTDLoss       anyvec.Numeric
+	MeanBehaviorLoss anyvec.Numeric
+}
+
+// BCQ implements discrete Batch-Constrained Q-learning.
+//
+// A generative model of the behavior policy is trained
+// alongside the Q-function, and only actions which are
+// likely enough under the behavior model are considered
+// when maximizing over Q-values.
+//
+// See https://arxiv.org/abs/1910.01708.
+type BCQ struct {
+	// Q maps a batch of observations to a batch of
+	// Q-value vectors, one value per action.
+	Q anynet.Layer
+
+	// Target is used to compute the Q-values for the
+	// next states in the bootstrapped targets.
+	//
+	// If nil, Q is used.
+	Target anynet.Layer
+
+	// Behavior maps a batch of observations to a batch
+	// of softmax logits for the behavior policy.
+	Behavior anynet.Layer
+
+	// Params specifies which parameters to include in
+	// the gradients.
+	// This should include the parameters of both Q and
+	// Behavior.
+	Params []*anydiff.Var
+
+	// Discount is the reward discount factor.
+	Discount float64
+
+	// Threshold is the minimum ratio between an action's
+	// behavior probability and the most likely action's
+	// probability for the action to be considered.
+	//
+	// If 0, DefaultBCQThreshold is used.
+	Threshold float64
+
+	// Loss is used to fit the Q-values to their targets.
+	//
+	// If nil, anyrl.SquareLoss is used.
+	Loss anyrl.Loss
+
+	// TargetGroup, if greater than 1, causes the targets
+	// to be averaged over consecutive groups of this many
+	// transitions.
+	// This should be set to DrQ.NumAugmentations() when
+	// training on batches from DrQ.Augment.
+	TargetGroup int
+}
+
+// Run computes a gradient for a batch of transitions.
+//
+// The gradient is for the negative loss, so it should be
+// added to the parameters to improve both the Q-function
+// and the behavior model.
+//
+// If b.Params is empty, then an empty gradient and nil
+// BCQTerms are returned.
+func (b *BCQ) Run(batch []*Transition) (anydiff.Grad, *BCQTerms) {
+	grad := anydiff.NewGrad(b.Params...)
+	if len(grad) == 0 || len(batch) == 0 {
+		return grad, nil
+	}
+	c := b.Params[0].Vector.Creator()
+	n := len(batch)
+
+	obs, actions := joinTransitions(c, batch)
+	obsRes := anydiff.NewConst(obs)
+	actionRes := anydiff.NewConst(actions)
+	targetValues := averageGroups(b.targets(c, batch), b.TargetGroup)
+	targets := anydiff.NewConst(anyvec.Make(c, targetValues))
+
+	selected := batchedDot(b.Q.Apply(obsRes, n), actionRes, n)
+	tdLoss := b.loss().Loss(selected, targets)
+
+	logits := b.Behavior.Apply(obsRes, n)
+	logProbs := anydiff.LogSoftmax(logits, logits.Output().Len()/n)
+	behaviorLoss := anydiff.Scale(batchedDot(logProbs, actionRes, n), c.MakeNumeric(-1))
+
+	terms := anydiff.Concat(
+		anydiff.Scale(anydiff.Sum(tdLoss), c.MakeNumeric(1/float64(n))),
+		anydiff.Scale(anydiff.Sum(behaviorLoss), c.MakeNumeric(1/float64(n))),
+	)
+	terms.Propagate(anyvec.Make(c, []float64{-1, -1}), grad)
+
+	return grad, &BCQTerms{
+		MeanTDLoss:       anyvec.Sum(terms.Output().Slice(0, 1)),
+		MeanBehaviorLoss: anyvec.Sum(terms.Output().Slice(1, 2)),
+	}
+}
+
+// Policy returns a layer which maps observations to
+// Q-values in which the actions that are too unlikely
+// under the behavior model are masked out.
+//
+// The result can be combined with Greedy to act in an
+// environment, e.g. for evaluation-only rollouts.
+// The layer's outputs are constant with respect to the
+// parameters.
+func (b *BCQ) Policy() anynet.Layer {
+	return &bcqPolicy{BCQ: b}
+}
+
+// targets computes the bootstrapped Q-value targets.
+func (b *BCQ) targets(c anyvec.Creator, batch []*Transition) []float64 {
+	res := make([]float64, len(batch))
+	var nextObs []float64
+	var numNext int
+	for i, t := range batch {
+		res[i] = t.Reward
+		if !t.Done {
+			nextObs = append(nextObs, t.Next...)
+			numNext++
+		}
+	}
+	if numNext == 0 {
+		return res
+	}
+
+	nextIn := anydiff.NewConst(anyvec.Make(c, nextObs))
+	allowed := b.allowedQ(nextIn, numNext)
+
+	target := b.Target
+	if target == nil {
+		target = b.Q
+	}
+	targetQ := c.Float64Slice(target.Apply(nextIn, numNext).Output().Data())
+	numActions := len(targetQ) / numNext
+
+	var nextIdx int
+	for i, t := range batch {
+		if !t.Done {
+			row := allowed[nextIdx*numActions : (nextIdx+1)*numActions]
+			action := argmax(row)
+			res[i] += b.Discount * targetQ[nextIdx*numActions+action]
+			nextIdx++
+		}
+	}
+	return res
+}
+
+// allowedQ computes Q-values with disallowed actions set
+// to negative infinity.
+func (b *BCQ) allowedQ(obs anydiff.Res, n int) []float64 {
+	c := obs.Output().Creator()
+	qValues := c.Float64Slice(b.Q.Apply(obs, n).Output().Data())
+	logits := b.Behavior.Apply(obs, n).Output().Copy()
+	numActions := logits.Len() / n
+	anyvec.LogSoftmax(logits, numActions)
+	logProbs := c.Float64Slice(logits.Data())
+
+	threshold := math.Log(b.threshold())
+	for i := 0; i < n; i++ {
+		row := logProbs[i*numActions : (i+1)*numActions]
+		maxLog := row[argmax(row)]
+		for j, logProb := range row {
+			if logProb-maxLog < threshold {
+				qValues[i*numActions+j] = math.Inf(-1)
+			}
+		}
+	}
+	return qValues
+}
+
+func (b *BCQ) threshold() float64 {
+	if b.Threshold == 0 {
+		return DefaultBCQThreshold
+	}
+	return b.Threshold
+}
+
+type bcqPolicy struct {
+	BCQ *BCQ
+}
+
+func (b *bcqPolicy) Apply(in anydiff.Res, n int) anydiff.Res {
+	c := in.Output().Creator()
+	values := b.BCQ.allowedQ(in, n)
+	for i, x := range values {
+		// Avoid infinities, which samplers like
+		// anyrl.Softmax cannot handle.
+		if math.IsInf(x, -1) {
+			values[i] = -math.MaxFloat32
+		}
+	}
+	return anydiff.NewConst(anyvec.Make(c, values))
+}
+
+func (b *BCQ) loss() anyrl.Loss {
+	if b.Loss == nil {
+		return anyrl.SquareLoss{}
+	}
+	return b.Loss
+}
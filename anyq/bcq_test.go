@@ -0,0 +1,90 @@
+package anyq
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestBCQPolicy(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+
+	// Action 2 has the highest Q-value, but it is only
+	// 0.2 times as likely as action 0 under the behavior
+	// model.
+	bcq := &BCQ{
+		Q:        constFC(c, 1, 2, 5),
+		Behavior: constFC(c, 0, math.Log(0.5), math.Log(0.2)),
+	}
+	in := anydiff.NewConst(anyvec.Make(c, []float64{1}))
+	for _, testCase := range []struct {
+		Threshold float64
+		Allowed   []bool
+	}{
+		{0, []bool{true, true, false}},
+		{0.6, []bool{true, false, false}},
+		{0.1, []bool{true, true, true}},
+	} {
+		bcq.Threshold = testCase.Threshold
+		values := c.Float64Slice(bcq.Policy().Apply(in, 1).Output().Data())
+		for i, allowed := range testCase.Allowed {
+			if allowed != (values[i] > -math.MaxFloat32) {
+				t.Errorf("threshold %f: action %d allowed=%v but got value %f",
+					testCase.Threshold, i, allowed, values[i])
+			}
+		}
+	}
+}
+
+func TestBCQTargets(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	bcq := &BCQ{
+		Q:        constFC(c, 1, 2, 5),
+		Behavior: constFC(c, 0, math.Log(0.5), math.Log(0.2)),
+		Discount: 0.5,
+	}
+	batch := []*Transition{
+		{Obs: []float64{1}, Action: []float64{1, 0, 0}, Reward: 1, Next: []float64{1}},
+		{Obs: []float64{1}, Action: []float64{0, 1, 0}, Reward: 3, Done: true},
+	}
+
+	// The best allowed action is 1, even though action 2
+	// has a higher Q-value.
+	expected := []float64{1 + 0.5*2, 3}
+	if actual := bcq.targets(c, batch); !floatsClose(actual, expected) {
+		t.Errorf("expected targets %v but got %v", expected, actual)
+	}
+
+	// The target network evaluates the action which the
+	// online network selects.
+	bcq.Target = constFC(c, 10, 20, 30)
+	expected = []float64{1 + 0.5*20, 3}
+	if actual := bcq.targets(c, batch); !floatsClose(actual, expected) {
+		t.Errorf("expected targets %v but got %v", expected, actual)
+	}
+}
+
+// constFC creates a layer which maps a one-dimensional
+// input of 1 to the given values.
+func constFC(c anyvec.Creator, values ...float64) *anynet.FC {
+	fc := anynet.NewFC(c, 1, len(values))
+	fc.Weights.Vector.Scale(c.MakeNumeric(0))
+	fc.Biases.Vector.SetData(c.MakeNumericList(values))
+	return fc
+}
+
+func floatsClose(actual, expected []float64) bool {
+	if len(actual) != len(expected) {
+		return false
+	}
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-8 {
+			return false
+		}
+	}
+	return true
+}
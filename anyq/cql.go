@@ -0,0 +1,176 @@
+package anyq
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// DefaultCQLAlpha is the default weight of the
+// conservative penalty in CQL.
+const DefaultCQLAlpha = 1.0
+
+// CQLTerms stores the current values of the terms in the
+// CQL loss function.
+type CQLTerms struct {
+	MeanTDLoss  anyvec.Numeric
+	MeanPenalty anyvec.Numeric
+}
+
+// CQL implements Conservative Q-Learning for training on
+// fixed datasets without environment interaction.
+//
+// See https://arxiv.org/abs/2006.04779.
+type CQL struct {
+	// Q maps a batch of observations to a batch of
+	// Q-value vectors, one value per action.
+	Q anynet.Layer
+
+	// Target is used to compute the Q-values for the
+	// next states in the bootstrapped targets.
+	//
+	// If nil, Q is used.
+	Target anynet.Layer
+
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// Discount is the reward discount factor.
+	Discount float64
+
+	// Alpha is the weight of the conservative penalty,
+	// which pushes down the Q-values of actions that do
+	// not appear in the dataset.
+	//
+	// If 0, DefaultCQLAlpha is used.
+	Alpha float64
+
+	// Loss is used to fit the Q-values to their targets.
+	//
+	// If nil, anyrl.SquareLoss is used.
+	Loss anyrl.Loss
+
+	// TargetGroup, if greater than 1, causes the targets
+	// to be averaged over consecutive groups of this many
+	// transitions.
+	// This should be set to DrQ.NumAugmentations() when
+	// training on batches from DrQ.Augment.
+	TargetGroup int
+}
+
+// Run computes a gradient for a batch of transitions.
+//
+// The gradient is for the negative loss, so it should be
+// added to the parameters to improve the Q-function.
+//
+// If c.Params is empty, then an empty gradient and nil
+// CQLTerms are returned.
+func (c *CQL) Run(batch []*Transition) (anydiff.Grad, *CQLTerms) {
+	grad := anydiff.NewGrad(c.Params...)
+	if len(grad) == 0 || len(batch) == 0 {
+		return grad, nil
+	}
+	cr := c.Params[0].Vector.Creator()
+	n := len(batch)
+
+	obs, actions := joinTransitions(cr, batch)
+	targetValues := averageGroups(c.targets(cr, batch), c.TargetGroup)
+	targets := anydiff.NewConst(anyvec.Make(cr, targetValues))
+
+	qValues := c.Q.Apply(anydiff.NewConst(obs), n)
+	terms := anydiff.Pool(qValues, func(qValues anydiff.Res) anydiff.Res {
+		numActions := qValues.Output().Len() / n
+		selected := batchedDot(qValues, anydiff.NewConst(actions), n)
+		tdLoss := c.loss().Loss(selected, targets)
+
+		logProbs := anydiff.LogSoftmax(qValues, numActions)
+		penalty := anydiff.Scale(
+			batchedDot(logProbs, anydiff.NewConst(actions), n),
+			cr.MakeNumeric(-1),
+		)
+
+		return anydiff.Concat(
+			anydiff.Scale(anydiff.Sum(tdLoss), cr.MakeNumeric(1/float64(n))),
+			anydiff.Scale(anydiff.Sum(penalty), cr.MakeNumeric(1/float64(n))),
+		)
+	})
+
+	upstream := anyvec.Make(cr, []float64{-1, -c.alpha()})
+	terms.Propagate(upstream, grad)
+
+	return grad, &CQLTerms{
+		MeanTDLoss:  anyvec.Sum(terms.Output().Slice(0, 1)),
+		MeanPenalty: anyvec.Sum(terms.Output().Slice(1, 2)),
+	}
+}
+
+// targets computes the bootstrapped Q-value targets.
+func (c *CQL) targets(cr anyvec.Creator, batch []*Transition) []float64 {
+	res := make([]float64, len(batch))
+	var nextObs []float64
+	var numNext int
+	for i, t := range batch {
+		res[i] = t.Reward
+		if !t.Done {
+			nextObs = append(nextObs, t.Next...)
+			numNext++
+		}
+	}
+	if numNext == 0 {
+		return res
+	}
+
+	target := c.Target
+	if target == nil {
+		target = c.Q
+	}
+	nextQ := target.Apply(anydiff.NewConst(anyvec.Make(cr, nextObs)), numNext).Output()
+	values := cr.Float64Slice(nextQ.Data())
+	numActions := len(values) / numNext
+
+	var nextIdx int
+	for i, t := range batch {
+		if !t.Done {
+			row := values[nextIdx*numActions : (nextIdx+1)*numActions]
+			res[i] += c.Discount * row[argmax(row)]
+			nextIdx++
+		}
+	}
+	return res
+}
+
+func (c *CQL) alpha() float64 {
+	if c.Alpha == 0 {
+		return DefaultCQLAlpha
+	}
+	return c.Alpha
+}
+
+// joinTransitions packs the observations and actions of
+// the transitions into two vectors.
+func joinTransitions(c anyvec.Creator, batch []*Transition) (obs, actions anyvec.Vector) {
+	var allObs, allActions []float64
+	for _, t := range batch {
+		allObs = append(allObs, t.Obs...)
+		allActions = append(allActions, t.Action...)
+	}
+	return anyvec.Make(c, allObs), anyvec.Make(c, allActions)
+}
+
+func batchedDot(vecs1, vecs2 anydiff.Res, batchSize int) anydiff.Res {
+	products := anydiff.Mul(vecs1, vecs2)
+	return anydiff.SumCols(&anydiff.Matrix{
+		Data: products,
+		Rows: batchSize,
+		Cols: vecs1.Output().Len() / batchSize,
+	})
+}
+
+func (c *CQL) loss() anyrl.Loss {
+	if c.Loss == nil {
+		return anyrl.SquareLoss{}
+	}
+	return c.Loss
+}
@@ -0,0 +1,62 @@
+package anyq
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestCQLPenalty(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+
+	// A tabular Q-function with a single state and three
+	// actions, where the dataset only contains action 0.
+	// Every transition is terminal with zero reward, so the
+	// TD loss is zero and only the penalty matters.
+	fc := anynet.NewFC(c, 1, 3)
+	fc.Weights.Vector.Scale(c.MakeNumeric(0))
+	cql := &CQL{
+		Q:      fc,
+		Params: []*anydiff.Var{fc.Biases},
+		Alpha:  2,
+	}
+	batch := []*Transition{
+		{Obs: []float64{1}, Action: []float64{1, 0, 0}, Done: true},
+		{Obs: []float64{1}, Action: []float64{1, 0, 0}, Done: true},
+	}
+
+	fc.Biases.Vector.Scale(c.MakeNumeric(0))
+	grad, terms := cql.Run(batch)
+	if math.Abs(terms.MeanTDLoss.(float64)) > 1e-8 {
+		t.Errorf("expected zero TD loss but got %f", terms.MeanTDLoss)
+	}
+	if math.Abs(terms.MeanPenalty.(float64)-math.Log(3)) > 1e-8 {
+		t.Errorf("expected penalty %f but got %f", math.Log(3), terms.MeanPenalty)
+	}
+
+	// The gradient of -alpha*(logsumexp(Q)-Q[0]).
+	expected := []float64{2 * 2.0 / 3, -2.0 / 3, -2.0 / 3}
+	actual := c.Float64Slice(grad[fc.Biases].Data())
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-8 {
+			t.Fatalf("expected gradient %v but got %v", expected, actual)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		grad, _ := cql.Run(batch)
+		grad.Scale(c.MakeNumeric(0.1))
+		grad.AddToVars()
+	}
+	q := c.Float64Slice(fc.Apply(anydiff.NewConst(anyvec.Make(c, []float64{1})), 1).Output().Data())
+	if q[1] >= q[0] || q[2] >= q[0] {
+		t.Errorf("unseen actions should have lower Q-values: %v", q)
+	}
+	if q[1] >= 0 || q[2] >= 0 {
+		t.Errorf("unseen actions should be pushed down: %v", q)
+	}
+}
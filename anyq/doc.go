@@ -0,0 +1,4 @@
+// Package anyq implements value-based Reinforcement
+// Learning algorithms which learn Q-functions over
+// discrete action spaces.
+package anyq
@@ -0,0 +1,81 @@
+package anyq
+
+import "github.com/unixpickle/anyrl"
+
+// DefaultDrQAugmentations is the default number of
+// augmentations used by DrQ.
+const DefaultDrQAugmentations = 2
+
+// DrQ implements data-regularized Q-learning, where
+// observations are augmented at training time.
+//
+// To use DrQ, augment each training batch with Augment
+// and set the trainer's TargetGroup to the number of
+// augmentations.
+// The Q-value losses are then averaged over the
+// augmentations, and so are the bootstrapped targets.
+//
+// See https://arxiv.org/abs/2004.13649.
+type DrQ struct {
+	Augmenter anyrl.Augmenter
+
+	// K is the number of augmentations per transition.
+	//
+	// If 0, DefaultDrQAugmentations is used.
+	K int
+}
+
+// Augment creates K augmented copies of each transition,
+// storing the copies of each transition consecutively.
+//
+// The observations and next observations are augmented
+// independently.
+func (d *DrQ) Augment(batch []*Transition) []*Transition {
+	var res []*Transition
+	for _, t := range batch {
+		for i := 0; i < d.NumAugmentations(); i++ {
+			aug := *t
+			aug.Obs = d.Augmenter.Augment(t.Obs)
+			if t.Next != nil {
+				aug.Next = d.Augmenter.Augment(t.Next)
+			}
+			res = append(res, &aug)
+		}
+	}
+	return res
+}
+
+// NumAugmentations returns the number of augmentations per
+// transition, taking defaults into account.
+// This is the value to use for TargetGroup.
+func (d *DrQ) NumAugmentations() int {
+	if d.K == 0 {
+		return DefaultDrQAugmentations
+	}
+	return d.K
+}
+
+// averageGroups averages the values in each consecutive
+// group of the given size.
+// If the group size is less than 2, the values are
+// returned unchanged.
+func averageGroups(values []float64, group int) []float64 {
+	if group < 2 {
+		return values
+	}
+	res := make([]float64, len(values))
+	for i := 0; i < len(values); i += group {
+		end := i + group
+		if end > len(values) {
+			end = len(values)
+		}
+		var sum float64
+		for _, x := range values[i:end] {
+			sum += x
+		}
+		for j := i; j < end; j++ {
+			res[j] = sum / float64(end-i)
+		}
+	}
+	return res
+}
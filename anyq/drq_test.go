@@ -0,0 +1,43 @@
+package anyq
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anyrl"
+)
+
+func TestDrQAugment(t *testing.T) {
+	drq := &DrQ{
+		Augmenter: &anyrl.ImageShift{Width: 3, Height: 2, Depth: 1, Pad: 1},
+		K:         3,
+	}
+	batch := []*Transition{
+		{Obs: []float64{1, 2, 3, 4, 5, 6}, Reward: 1, Next: []float64{1, 1, 1, 1, 1, 1}},
+		{Obs: []float64{1, 1, 1, 1, 1, 1}, Reward: 2, Done: true},
+	}
+	augmented := drq.Augment(batch)
+	if len(augmented) != 6 {
+		t.Fatalf("expected 6 transitions but got %d", len(augmented))
+	}
+	for i, trans := range augmented {
+		orig := batch[i/3]
+		if trans.Reward != orig.Reward || trans.Done != orig.Done {
+			t.Errorf("transition %d: metadata mismatch", i)
+		}
+		if (trans.Next == nil) != (orig.Next == nil) {
+			t.Errorf("transition %d: next mismatch", i)
+		}
+	}
+	if !reflect.DeepEqual(batch[0].Obs, []float64{1, 2, 3, 4, 5, 6}) {
+		t.Error("original observation was modified")
+	}
+}
+
+func TestAverageGroups(t *testing.T) {
+	actual := averageGroups([]float64{1, 3, 2, 4, 5}, 2)
+	expected := []float64{2, 2, 3, 3, 5}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
@@ -0,0 +1,123 @@
+package anyq
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// FailureSampler samples transitions from a Dataset with
+// extra emphasis on transitions from episodes that went
+// poorly, i.e. episodes with low returns or episodes that
+// terminated early.
+//
+// The transitions in the Dataset must be grouped into
+// episodes, as described in Dataset.Episodes.
+//
+// The sampling weights are computed on the first call to
+// Sample and cached.
+// If any fields are changed afterwards, call Update.
+type FailureSampler struct {
+	Dataset Dataset
+
+	// Priorities, if non-nil, specifies a base sampling
+	// weight for each transition, such as a priority from
+	// prioritized replay.
+	// The failure boosts are multiplied by these weights.
+	//
+	// If nil, every transition has a base weight of 1.
+	Priorities []float64
+
+	// ReturnBoost determines how much more likely the
+	// lowest-return episode is to be sampled than the
+	// highest-return episode.
+	// An episode with the lowest return has its weight
+	// multiplied by 1+ReturnBoost, and the boost is
+	// linearly interpolated for other returns.
+	ReturnBoost float64
+
+	// LengthBoost is like ReturnBoost, but for episode
+	// lengths rather than returns.
+	// Episodes which are shorter than the longest episode
+	// receive a boost proportional to how early they
+	// terminated.
+	LengthBoost float64
+
+	cumulative []float64
+}
+
+// Weights computes the sampling weight of every
+// transition.
+func (f *FailureSampler) Weights() []float64 {
+	episodes := f.Dataset.Episodes()
+	var returns []float64
+	var maxLen int
+	for _, ep := range episodes {
+		var total float64
+		for _, t := range ep {
+			total += t.Reward
+		}
+		returns = append(returns, total)
+		if len(ep) > maxLen {
+			maxLen = len(ep)
+		}
+	}
+	minRet, maxRet := returnRange(returns)
+
+	var res []float64
+	for i, ep := range episodes {
+		boost := 1.0
+		if maxRet > minRet {
+			boost *= 1 + f.ReturnBoost*(maxRet-returns[i])/(maxRet-minRet)
+		}
+		boost *= 1 + f.LengthBoost*float64(maxLen-len(ep))/float64(maxLen)
+		for range ep {
+			weight := boost
+			if f.Priorities != nil {
+				weight *= f.Priorities[len(res)]
+			}
+			res = append(res, weight)
+		}
+	}
+	return res
+}
+
+// Update recomputes the cached sampling weights.
+func (f *FailureSampler) Update() {
+	f.cumulative = nil
+	var sum float64
+	for _, w := range f.Weights() {
+		sum += w
+		f.cumulative = append(f.cumulative, sum)
+	}
+}
+
+// Sample selects n transitions at random (with
+// replacement) according to the sampling weights.
+func (f *FailureSampler) Sample(n int) []*Transition {
+	if f.cumulative == nil {
+		f.Update()
+	}
+	total := f.cumulative[len(f.cumulative)-1]
+	res := make([]*Transition, n)
+	for i := range res {
+		x := rand.Float64() * total
+		idx := sort.SearchFloat64s(f.cumulative, x)
+		if idx == len(f.cumulative) {
+			idx--
+		}
+		res[i] = f.Dataset[idx]
+	}
+	return res
+}
+
+func returnRange(returns []float64) (min, max float64) {
+	for i, r := range returns {
+		if i == 0 || r < min {
+			min = r
+		}
+		if i == 0 || r > max {
+			max = r
+		}
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package anyq
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFailureSamplerWeights(t *testing.T) {
+	d := Dataset{
+		{Reward: 1}, {Reward: 1}, {Reward: 1, Done: true},
+		{Reward: -1, Done: true},
+		{Reward: 0}, {Reward: 1, Done: true},
+	}
+	sampler := &FailureSampler{
+		Dataset:     d,
+		Priorities:  []float64{1, 2, 1, 1, 1, 1},
+		ReturnBoost: 1,
+		LengthBoost: 3,
+	}
+	actual := sampler.Weights()
+	expected := []float64{1, 2, 1, 2 * 3, 1.5 * 2, 1.5 * 2}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+
+	counts := map[*Transition]int{}
+	const numSamples = 100000
+	for _, trans := range sampler.Sample(numSamples) {
+		counts[trans]++
+	}
+	var total float64
+	for _, w := range expected {
+		total += w
+	}
+	for i, trans := range d {
+		frac := float64(counts[trans]) / numSamples
+		if math.Abs(frac-expected[i]/total) > 0.01 {
+			t.Errorf("transition %d: expected frequency %f but got %f", i,
+				expected[i]/total, frac)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package anyq
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"reflect"
+)
+
+// A FrameBuffer stores transitions with frame-stacked
+// observations compactly.
+//
+// Every unique frame is stored once, and each transition
+// refers to its frames by index.
+// With the usual setup of four stacked frames, adjacent
+// observations share all but one frame, so this uses a
+// fraction of the memory of a Dataset.
+//
+// Observations are assumed to be Stack frames of
+// FrameSize components each, concatenated one after
+// another.
+//
+// Once the buffer is full, the oldest transitions are
+// evicted first, and frames are freed once no remaining
+// transition refers to them.
+type FrameBuffer struct {
+	FrameSize int
+	Stack     int
+
+	// Capacity is the maximum number of transitions.
+	// If 0, the buffer is unbounded.
+	Capacity int
+
+	frames      [][]float64
+	frameRefs   []int
+	freeFrames  []int32
+	frameHashes map[uint64][]int32
+
+	// transitions is a ring buffer starting at start.
+	transitions []*frameTransition
+	start       int
+}
+
+type frameTransition struct {
+	Obs       []int32
+	Next      []int32
+	Action    []float64
+	Reward    float64
+	Done      bool
+	Truncated bool
+}
+
+// Add adds transitions to the buffer.
+func (f *FrameBuffer) Add(ts ...*Transition) {
+	for _, t := range ts {
+		ft := &frameTransition{
+			Obs:       f.addObs(t.Obs),
+			Action:    t.Action,
+			Reward:    t.Reward,
+			Done:      t.Done,
+			Truncated: t.Truncated,
+		}
+		if t.Next != nil {
+			ft.Next = f.addObs(t.Next)
+		}
+		if f.Capacity == 0 || len(f.transitions) < f.Capacity {
+			f.transitions = append(f.transitions, ft)
+		} else {
+			f.removeObs(f.transitions[f.start].Obs)
+			f.removeObs(f.transitions[f.start].Next)
+			f.transitions[f.start] = ft
+			f.start = (f.start + 1) % f.Capacity
+		}
+	}
+}
+
+// Len returns the number of transitions.
+func (f *FrameBuffer) Len() int {
+	return len(f.transitions)
+}
+
+// NumFrames returns the number of unique frames stored.
+func (f *FrameBuffer) NumFrames() int {
+	return len(f.frames) - len(f.freeFrames)
+}
+
+// Transition reconstructs the transition at the given
+// index.
+func (f *FrameBuffer) Transition(i int) *Transition {
+	ft := f.transitions[(f.start+i)%len(f.transitions)]
+	return &Transition{
+		Obs:       f.obs(ft.Obs),
+		Action:    ft.Action,
+		Reward:    ft.Reward,
+		Next:      f.obs(ft.Next),
+		Done:      ft.Done,
+		Truncated: ft.Truncated,
+	}
+}
+
+// Sample selects n transitions uniformly at random (with
+// replacement).
+func (f *FrameBuffer) Sample(n int) []*Transition {
+	res := make([]*Transition, n)
+	for i := range res {
+		res[i] = f.Transition(rand.Intn(len(f.transitions)))
+	}
+	return res
+}
+
+// Dataset reconstructs every transition.
+func (f *FrameBuffer) Dataset() Dataset {
+	res := make(Dataset, len(f.transitions))
+	for i := range res {
+		res[i] = f.Transition(i)
+	}
+	return res
+}
+
+func (f *FrameBuffer) addObs(obs []float64) []int32 {
+	if len(obs) != f.FrameSize*f.Stack {
+		panic("observation size does not match frame layout")
+	}
+	res := make([]int32, f.Stack)
+	for i := range res {
+		res[i] = f.addFrame(obs[i*f.FrameSize : (i+1)*f.FrameSize])
+	}
+	return res
+}
+
+func (f *FrameBuffer) addFrame(frame []float64) int32 {
+	if f.frameHashes == nil {
+		f.frameHashes = map[uint64][]int32{}
+	}
+	hash := hashFrame(frame)
+	for _, idx := range f.frameHashes[hash] {
+		if reflect.DeepEqual(f.frames[idx], frame) {
+			f.frameRefs[idx]++
+			return idx
+		}
+	}
+	frame = append([]float64{}, frame...)
+	var idx int32
+	if n := len(f.freeFrames); n > 0 {
+		idx = f.freeFrames[n-1]
+		f.freeFrames = f.freeFrames[:n-1]
+		f.frames[idx] = frame
+		f.frameRefs[idx] = 1
+	} else {
+		idx = int32(len(f.frames))
+		f.frames = append(f.frames, frame)
+		f.frameRefs = append(f.frameRefs, 1)
+	}
+	f.frameHashes[hash] = append(f.frameHashes[hash], idx)
+	return idx
+}
+
+func (f *FrameBuffer) removeObs(indices []int32) {
+	for _, idx := range indices {
+		f.frameRefs[idx]--
+		if f.frameRefs[idx] == 0 {
+			f.removeFrame(idx)
+		}
+	}
+}
+
+func (f *FrameBuffer) removeFrame(idx int32) {
+	hash := hashFrame(f.frames[idx])
+	bucket := f.frameHashes[hash]
+	for i, x := range bucket {
+		if x == idx {
+			bucket = append(bucket[:i], bucket[i+1:]...)
+			break
+		}
+	}
+	if len(bucket) == 0 {
+		delete(f.frameHashes, hash)
+	} else {
+		f.frameHashes[hash] = bucket
+	}
+	f.frames[idx] = nil
+	f.freeFrames = append(f.freeFrames, idx)
+}
+
+func (f *FrameBuffer) obs(indices []int32) []float64 {
+	if indices == nil {
+		return nil
+	}
+	res := make([]float64, 0, f.FrameSize*f.Stack)
+	for _, idx := range indices {
+		res = append(res, f.frames[idx]...)
+	}
+	return res
+}
+
+func hashFrame(frame []float64) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, x := range frame {
+		bits := math.Float64bits(x)
+		for i := range buf {
+			buf[i] = byte(bits >> uint(8*i))
+		}
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,55 @@
+package anyq
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrameBuffer(t *testing.T) {
+	dataset := Dataset{
+		{Obs: []float64{0, 0, 1, 1}, Action: []float64{1}, Reward: 1, Next: []float64{1, 1, 2, 2}},
+		{Obs: []float64{1, 1, 2, 2}, Action: []float64{0}, Reward: 2, Next: []float64{2, 2, 3, 3}},
+		{Obs: []float64{2, 2, 3, 3}, Action: []float64{1}, Reward: 3, Done: true},
+	}
+	buf := &FrameBuffer{FrameSize: 2, Stack: 2}
+	buf.Add(dataset...)
+	if buf.Len() != 3 {
+		t.Errorf("expected 3 transitions but got %d", buf.Len())
+	}
+	if buf.NumFrames() != 4 {
+		t.Errorf("expected 4 frames but got %d", buf.NumFrames())
+	}
+	if actual := buf.Dataset(); !reflect.DeepEqual(actual, dataset) {
+		t.Errorf("expected %v but got %v", dataset, actual)
+	}
+}
+
+func TestFrameBufferCapacity(t *testing.T) {
+	buf := &FrameBuffer{FrameSize: 1, Stack: 2, Capacity: 3}
+	var dataset Dataset
+	for i := 0; i < 100; i++ {
+		trans := &Transition{
+			Obs:    []float64{float64(i), float64(i + 1)},
+			Action: []float64{1},
+			Reward: float64(i),
+			Next:   []float64{float64(i + 1), float64(i + 2)},
+		}
+		dataset = append(dataset, trans)
+		buf.Add(trans)
+		if buf.Len() > 3 {
+			t.Fatalf("buffer has %d transitions", buf.Len())
+		}
+		// New frames are stored before old ones are freed,
+		// so one extra frame slot may be allocated.
+		if buf.NumFrames() > 5 || len(buf.frames) > 6 || len(buf.frameHashes) > 5 {
+			t.Fatalf("step %d: frames were not freed (%d frames, %d stored, %d hashes)",
+				i, buf.NumFrames(), len(buf.frames), len(buf.frameHashes))
+		}
+	}
+	if buf.NumFrames() != 5 {
+		t.Errorf("expected 5 frames but got %d", buf.NumFrames())
+	}
+	if actual := buf.Dataset(); !reflect.DeepEqual(actual, dataset[97:]) {
+		t.Errorf("expected %v but got %v", dataset[97:], actual)
+	}
+}
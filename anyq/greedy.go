@@ -0,0 +1,47 @@
+package anyq
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anyvec"
+)
+
+// Greedy is an anyrl.Sampler which selects the action
+// with the highest Q-value, producing one-hot vectors.
+//
+// It can be used with anyrl.RNNRoller to run a Q-network
+// as a policy, e.g. for evaluation-only rollouts.
+type Greedy struct {
+	// Epsilon is the probability of selecting a uniformly
+	// random action instead of the greedy one.
+	Epsilon float64
+}
+
+// Sample selects an action for each Q-value vector in
+// the batch.
+func (g *Greedy) Sample(params anyvec.Vector, batch int) anyvec.Vector {
+	c := params.Creator()
+	numActions := params.Len() / batch
+	values := c.Float64Slice(params.Data())
+	oneHots := make([]float64, len(values))
+	for i := 0; i < batch; i++ {
+		var idx int
+		if g.Epsilon > 0 && rand.Float64() < g.Epsilon {
+			idx = rand.Intn(numActions)
+		} else {
+			idx = argmax(values[i*numActions : (i+1)*numActions])
+		}
+		oneHots[i*numActions+idx] = 1
+	}
+	return anyvec.Make(c, oneHots)
+}
+
+func argmax(values []float64) int {
+	var idx int
+	for i, x := range values {
+		if x > values[idx] {
+			idx = i
+		}
+	}
+	return idx
+}
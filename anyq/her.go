@@ -0,0 +1,126 @@
+package anyq
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anyrl"
+)
+
+// DefaultHERRelabels is the default number of relabeled
+// transitions HER creates per transition.
+const DefaultHERRelabels = 4
+
+// HER implements hindsight experience replay with the
+// "future" relabeling strategy.
+//
+// Observations are expected to end with the goal, as
+// produced by anyrl.GoalObsEnv.
+// Transitions are relabeled with goals that were achieved
+// later in the same episode, turning failed episodes into
+// useful experience for other goals.
+type HER struct {
+	// GoalSize is the number of components at the end of
+	// each observation which make up the goal.
+	GoalSize int
+
+	// Achieved computes the goal achieved in a state.
+	// It is passed an observation with the goal removed.
+	Achieved func(state []float64) []float64
+
+	// Reward computes the reward for a transition into a
+	// state with the achieved goal.
+	//
+	// If nil, anyrl.GoalReward is used with Tolerance.
+	Reward func(achieved, goal []float64) float64
+
+	// Tolerance is used by the default reward function.
+	Tolerance float64
+
+	// Relabels is the number of relabeled copies to add
+	// for each transition.
+	//
+	// If 0, DefaultHERRelabels is used.
+	Relabels int
+}
+
+// Relabel creates a new Dataset containing the original
+// transitions plus relabeled copies.
+//
+// The transitions in d must be grouped into episodes, in
+// order, with each episode ending in a Done or Truncated
+// transition, as is the case for the result of RolloutDataset.
+//
+// Every original episode is followed by its relabeled
+// copies, each of which is grouped into an episode of its
+// own, so the result can be split with Episodes.
+// Terminal transitions are not relabeled, since their
+// resulting states are not recorded.
+// As a result, the last transition of each copy is marked
+// as Truncated rather than Done.
+func (h *HER) Relabel(d Dataset) Dataset {
+	var res Dataset
+	for _, episode := range d.Episodes() {
+		res = append(res, episode...)
+		for _, relabeled := range h.relabelEpisode(episode) {
+			res = append(res, relabeled...)
+		}
+	}
+	return res
+}
+
+func (h *HER) relabelEpisode(episode []*Transition) [][]*Transition {
+	// Only transitions with a next observation achieve a
+	// goal, so achieved is indexed separately from the
+	// episode.
+	var achieved [][]float64
+	for _, t := range episode {
+		if t.Next != nil {
+			achieved = append(achieved, h.Achieved(h.state(t.Next)))
+		}
+	}
+	var res [][]*Transition
+	for j := 0; j < h.relabels(); j++ {
+		var relabeled []*Transition
+		var idx int
+		for _, t := range episode {
+			if t.Next == nil {
+				continue
+			}
+			goal := achieved[idx+rand.Intn(len(achieved)-idx)]
+			relabeled = append(relabeled, &Transition{
+				Obs:    h.withGoal(t.Obs, goal),
+				Action: t.Action,
+				Reward: h.reward(achieved[idx], goal),
+				Next:   h.withGoal(t.Next, goal),
+			})
+			idx++
+		}
+		if len(relabeled) > 0 {
+			relabeled[len(relabeled)-1].Truncated = true
+			res = append(res, relabeled)
+		}
+	}
+	return res
+}
+
+func (h *HER) state(obs []float64) []float64 {
+	return obs[:len(obs)-h.GoalSize]
+}
+
+func (h *HER) withGoal(obs, goal []float64) []float64 {
+	return append(append([]float64{}, h.state(obs)...), goal...)
+}
+
+func (h *HER) reward(achieved, goal []float64) float64 {
+	if h.Reward == nil {
+		return anyrl.GoalReward(achieved, goal, h.Tolerance)
+	}
+	return h.Reward(achieved, goal)
+}
+
+func (h *HER) relabels() int {
+	if h.Relabels == 0 {
+		return DefaultHERRelabels
+	}
+	return h.Relabels
+}
@@ -0,0 +1,203 @@
+package anyq
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestHER(t *testing.T) {
+	// A 1-D walk towards a goal of 10 which never gets
+	// there.
+	var d Dataset
+	for ep := 0; ep < 2; ep++ {
+		for i := 0; i < 3; i++ {
+			trans := &Transition{
+				Obs:    []float64{float64(i), 10},
+				Action: []float64{1},
+				Reward: -1,
+				Done:   i == 2,
+			}
+			if !trans.Done {
+				trans.Next = []float64{float64(i + 1), 10}
+			}
+			d = append(d, trans)
+		}
+	}
+
+	her := &HER{
+		GoalSize: 1,
+		Achieved: func(state []float64) []float64 {
+			return state
+		},
+		Relabels: 3,
+	}
+	relabeled := her.Relabel(d)
+	if len(relabeled) != 2*(3+2*3) {
+		t.Fatalf("unexpected number of transitions: %d", len(relabeled))
+	}
+	for i, trans := range relabeled[3:9] {
+		goal := trans.Obs[1]
+		if goal != trans.Next[1] {
+			t.Errorf("transition %d: goal mismatch", i)
+		}
+		if goal <= trans.Obs[0] || goal > 2 {
+			t.Errorf("transition %d: goal %f is not a future state", i, goal)
+		}
+		expected := -1.0
+		if goal == trans.Next[0] {
+			expected = 0
+		}
+		if trans.Reward != expected {
+			t.Errorf("transition %d: expected reward %f but got %f", i,
+				expected, trans.Reward)
+		}
+	}
+}
+
+func TestHERTruncated(t *testing.T) {
+	// Two truncated episodes should not be merged, or
+	// goals from the second episode would leak into the
+	// first.
+	c := anyvec64.DefaultCreator{}
+	obs := anyrl.Rewards{{0, 1, 2}, {100, 101, 102}}
+	r := &anyrl.RolloutSet{
+		Inputs:    obs.Tape(c),
+		Actions:   obs.Tape(c),
+		Rewards:   anyrl.Rewards{{-1, -1, -1}, {-1, -1, -1}},
+		Truncated: []bool{true, true},
+	}
+	her := &HER{
+		GoalSize: 0,
+		Achieved: func(state []float64) []float64 {
+			return state
+		},
+		Relabels: 10,
+	}
+	d := RolloutDataset(r)
+	if len(d.Episodes()) != 2 {
+		t.Fatalf("expected 2 episodes but got %d", len(d.Episodes()))
+	}
+	relabeled := her.Relabel(d)
+	if len(relabeled) != 2*(2+2*10) {
+		t.Fatalf("unexpected number of transitions: %d", len(relabeled))
+	}
+	for i, trans := range relabeled {
+		if len(trans.Obs) == 1 {
+			continue
+		}
+		goal := trans.Obs[1]
+		if goal <= trans.Obs[0] || goal > trans.Obs[0]+2 {
+			t.Errorf("transition %d: goal %f is not from the same episode", i, goal)
+		}
+	}
+}
+
+func TestHEREpisodes(t *testing.T) {
+	var d Dataset
+	for ep := 0; ep < 2; ep++ {
+		for i := 0; i < 3; i++ {
+			trans := &Transition{
+				Obs:       []float64{float64(10*ep + i), 100},
+				Action:    []float64{1},
+				Reward:    -1,
+				Next:      []float64{float64(10*ep + i + 1), 100},
+				Truncated: ep == 0 && i == 2,
+				Done:      ep == 1 && i == 2,
+			}
+			if trans.Done {
+				trans.Next = nil
+			}
+			d = append(d, trans)
+		}
+	}
+
+	her := &HER{
+		GoalSize: 1,
+		Achieved: func(state []float64) []float64 {
+			return state
+		},
+		Relabels: 3,
+	}
+	episodes := her.Relabel(d).Episodes()
+	if len(episodes) != 2*(1+3) {
+		t.Fatalf("expected %d episodes but got %d", 2*(1+3), len(episodes))
+	}
+	for i, episode := range episodes {
+		original := i%4 == 0
+		expectedLen := 3
+		if !original && i >= 4 {
+			expectedLen = 2
+		}
+		if len(episode) != expectedLen {
+			t.Errorf("episode %d: expected length %d but got %d", i, expectedLen,
+				len(episode))
+			continue
+		}
+		last := episode[len(episode)-1]
+		if original {
+			if last.Done != (i == 4) || last.Truncated != (i == 0) {
+				t.Errorf("episode %d: original flags were not preserved", i)
+			}
+			continue
+		}
+		if last.Done || !last.Truncated {
+			t.Errorf("episode %d: copy should end with a truncated transition", i)
+		}
+		start := float64(10 * (i / 4))
+		for j, trans := range episode {
+			goal := trans.Obs[1]
+			if goal < start || goal > start+3 {
+				t.Errorf("episode %d transition %d: goal %f is from another episode",
+					i, j, goal)
+			}
+		}
+	}
+}
+
+func TestHERMissingNext(t *testing.T) {
+	// The second transition has no next observation, so
+	// it achieves no goal and must not shift the goals of
+	// the later transitions.
+	var d Dataset
+	for i := 0; i < 4; i++ {
+		trans := &Transition{
+			Obs:    []float64{float64(i), 10},
+			Action: []float64{1},
+			Reward: -1,
+			Next:   []float64{float64(i + 1), 10},
+		}
+		if i == 1 {
+			trans.Next = nil
+		}
+		d = append(d, trans)
+	}
+	d[len(d)-1].Truncated = true
+
+	her := &HER{
+		GoalSize: 1,
+		Achieved: func(state []float64) []float64 {
+			return state
+		},
+		Relabels: 20,
+	}
+	relabeled := her.Relabel(d)
+	if len(relabeled) != 4+20*3 {
+		t.Fatalf("unexpected number of transitions: %d", len(relabeled))
+	}
+	for i, trans := range relabeled[4:] {
+		goal := trans.Obs[1]
+		if goal <= trans.Obs[0] || goal > 4 {
+			t.Errorf("transition %d: goal %f is not a future state", i, goal)
+		}
+		expected := -1.0
+		if goal == trans.Next[0] {
+			expected = 0
+		}
+		if trans.Reward != expected {
+			t.Errorf("transition %d: expected reward %f but got %f", i,
+				expected, trans.Reward)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package anyq
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/unixpickle/anyrl"
+)
+
+// A ReplayBuffer stores transitions for off-policy
+// training.
+//
+// FrameBuffer and UniformBuffer are ReplayBuffers.
+type ReplayBuffer interface {
+	Add(ts ...*Transition)
+	Len() int
+	Sample(n int) []*Transition
+}
+
+// AddRollouts converts on-policy rollouts to transitions
+// (see RolloutDataset) and adds them to a buffer.
+//
+// This makes it possible to reuse experience gathered for
+// policy gradient training in off-policy updates.
+func AddRollouts(b ReplayBuffer, rs ...*anyrl.RolloutSet) {
+	b.Add(RolloutDataset(rs...)...)
+}
+
+// UniformBuffer is a ReplayBuffer with a fixed capacity
+// which samples transitions uniformly.
+//
+// Once the buffer is full, the oldest transitions are
+// replaced first.
+//
+// It is safe to use a UniformBuffer from multiple
+// Goroutines.
+type UniformBuffer struct {
+	// Capacity is the maximum number of transitions.
+	// If 0, the buffer is unbounded.
+	Capacity int
+
+	lock        sync.RWMutex
+	transitions []*Transition
+	next        int
+}
+
+// Add adds transitions to the buffer.
+func (u *UniformBuffer) Add(ts ...*Transition) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	for _, t := range ts {
+		if u.Capacity == 0 || len(u.transitions) < u.Capacity {
+			u.transitions = append(u.transitions, t)
+		} else {
+			u.transitions[u.next] = t
+			u.next = (u.next + 1) % u.Capacity
+		}
+	}
+}
+
+// Len returns the number of stored transitions.
+func (u *UniformBuffer) Len() int {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	return len(u.transitions)
+}
+
+// Sample selects n transitions uniformly at random (with
+// replacement).
+func (u *UniformBuffer) Sample(n int) []*Transition {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	res := make([]*Transition, n)
+	for i := range res {
+		res[i] = u.transitions[rand.Intn(len(u.transitions))]
+	}
+	return res
+}
+
+// Dataset returns the stored transitions.
+func (u *UniformBuffer) Dataset() Dataset {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	return append(Dataset{}, u.transitions...)
+}
@@ -0,0 +1,44 @@
+package anyq
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestUniformBufferRollouts(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	obs := anyrl.Rewards{{1, 2, 3}, {4}}
+	r := &anyrl.RolloutSet{
+		Inputs:  obs.Tape(c),
+		Actions: obs.Tape(c),
+		Rewards: anyrl.Rewards{{0.5, 1, 1.5}, {2}},
+	}
+
+	buf := &UniformBuffer{Capacity: 3}
+	AddRollouts(buf, r)
+	if buf.Len() != 3 {
+		t.Fatalf("expected 3 transitions but got %d", buf.Len())
+	}
+
+	// The first transition should have been replaced by
+	// the last one.
+	var rewards []float64
+	for _, trans := range buf.Dataset() {
+		rewards = append(rewards, trans.Reward)
+	}
+	expected := []float64{2, 1, 1.5}
+	for i, x := range expected {
+		if rewards[i] != x {
+			t.Errorf("expected rewards %v but got %v", expected, rewards)
+			break
+		}
+	}
+
+	for _, trans := range buf.Sample(10) {
+		if trans == nil {
+			t.Fatal("sampled nil transition")
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package anyq
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Default settings for SequenceBuffer.
+const (
+	DefaultSequenceEta   = 0.9
+	DefaultSequenceAlpha = 0.6
+	DefaultSequenceBeta  = 0.4
+)
+
+// A SequenceSample is a sequence of consecutive
+// transitions sampled from a SequenceBuffer.
+type SequenceSample struct {
+	Transitions []*Transition
+
+	// Weight is the importance sampling weight which
+	// corrects for the non-uniform sampling.
+	// The largest possible weight is 1.
+	Weight float64
+
+	id int64
+}
+
+// SequencePriority computes the priority of a sequence
+// from its TD errors, as in R2D2
+// (https://openreview.net/forum?id=r1lyTjAqYX).
+//
+// The priority is eta times the maximum absolute error
+// plus 1-eta times the mean absolute error.
+func SequencePriority(tdErrors []float64, eta float64) float64 {
+	if len(tdErrors) == 0 {
+		return 0
+	}
+	var max, sum float64
+	for _, x := range tdErrors {
+		max = math.Max(max, math.Abs(x))
+		sum += math.Abs(x)
+	}
+	return eta*max + (1-eta)*sum/float64(len(tdErrors))
+}
+
+// SequenceBuffer is a prioritized replay buffer for
+// sequences of consecutive transitions, as needed to
+// train recurrent agents off-policy.
+//
+// Sequences are sampled with probability proportional to
+// their priority raised to the power Alpha.
+// New sequences get the largest priority seen so far, so
+// that they are likely to be sampled at least once.
+//
+// It is safe to use a SequenceBuffer from multiple
+// Goroutines.
+type SequenceBuffer struct {
+	// Capacity is the maximum number of sequences.
+	// Once the buffer is full, the oldest sequences are
+	// replaced first.
+	// If 0, the buffer is unbounded.
+	Capacity int
+
+	// Length is the number of transitions per sequence
+	// when episodes are added.
+	// Episodes are split into consecutive sequences, and
+	// the final sequence of an episode may be shorter.
+	// If 0, every episode is stored as one sequence.
+	Length int
+
+	// Eta is passed to SequencePriority.
+	//
+	// If 0, DefaultSequenceEta is used.
+	Eta float64
+
+	// Alpha is the exponent applied to priorities.
+	//
+	// If 0, DefaultSequenceAlpha is used.
+	Alpha float64
+
+	// Beta is the exponent for importance sampling
+	// weights.
+	//
+	// If 0, DefaultSequenceBeta is used.
+	Beta float64
+
+	lock        sync.RWMutex
+	sequences   []*storedSequence
+	next        int
+	nextID      int64
+	maxPriority float64
+}
+
+type storedSequence struct {
+	Transitions []*Transition
+	Priority    float64
+	ID          int64
+}
+
+// AddEpisodes splits episodes into sequences and adds
+// them to the buffer.
+//
+// See Dataset.Episodes for a way to obtain episodes.
+func (s *SequenceBuffer) AddEpisodes(episodes ...[]*Transition) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	priority := s.maxPriority
+	if priority == 0 {
+		priority = 1
+	}
+	for _, episode := range episodes {
+		for len(episode) > 0 {
+			size := len(episode)
+			if s.Length != 0 && size > s.Length {
+				size = s.Length
+			}
+			s.add(&storedSequence{
+				Transitions: episode[:size],
+				Priority:    priority,
+				ID:          s.nextID,
+			})
+			s.nextID++
+			episode = episode[size:]
+		}
+	}
+}
+
+// Len returns the number of stored sequences.
+func (s *SequenceBuffer) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return len(s.sequences)
+}
+
+// Sample selects n sequences at random (with
+// replacement) according to their priorities.
+//
+// If the buffer is empty, nil is returned.
+// If the scaled priorities sum to zero, e.g. because of
+// underflow with a large Alpha, sequences are sampled
+// uniformly.
+func (s *SequenceBuffer) Sample(n int) []*SequenceSample {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if len(s.sequences) == 0 {
+		return nil
+	}
+
+	priorities := make([]float64, len(s.sequences))
+	var total float64
+	for i, seq := range s.sequences {
+		priorities[i] = s.scaledPriority(seq.Priority)
+		total += priorities[i]
+	}
+	if total == 0 {
+		for i := range priorities {
+			priorities[i] = 1
+		}
+		total = float64(len(priorities))
+	}
+
+	cumulative := make([]float64, len(priorities))
+	minProb := math.Inf(1)
+	var sum float64
+	for i, p := range priorities {
+		sum += p
+		cumulative[i] = sum
+		minProb = math.Min(minProb, p/total)
+	}
+	maxWeight := math.Pow(float64(len(s.sequences))*minProb, -s.beta())
+
+	res := make([]*SequenceSample, n)
+	for i := range res {
+		idx := sort.SearchFloat64s(cumulative, rand.Float64()*total)
+		if idx == len(cumulative) {
+			idx--
+		}
+		seq := s.sequences[idx]
+		prob := priorities[idx] / total
+		res[i] = &SequenceSample{
+			Transitions: seq.Transitions,
+			Weight:      math.Pow(float64(len(s.sequences))*prob, -s.beta()) / maxWeight,
+			id:          seq.ID,
+		}
+	}
+	return res
+}
+
+// UpdatePriorities sets the priorities of sampled
+// sequences from their new TD errors, which should be
+// computed after each training pass.
+//
+// There is one slice of TD errors per sample.
+// Samples whose sequences have since been replaced are
+// ignored.
+func (s *SequenceBuffer) UpdatePriorities(samples []*SequenceSample, tdErrors [][]float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	byID := map[int64]*storedSequence{}
+	for _, seq := range s.sequences {
+		byID[seq.ID] = seq
+	}
+	for i, sample := range samples {
+		if seq, ok := byID[sample.id]; ok {
+			seq.Priority = SequencePriority(tdErrors[i], s.eta())
+			s.maxPriority = math.Max(s.maxPriority, seq.Priority)
+		}
+	}
+}
+
+func (s *SequenceBuffer) add(seq *storedSequence) {
+	if s.Capacity == 0 || len(s.sequences) < s.Capacity {
+		s.sequences = append(s.sequences, seq)
+	} else {
+		s.sequences[s.next] = seq
+		s.next = (s.next + 1) % s.Capacity
+	}
+}
+
+// scaledPriority applies the Alpha exponent to a
+// priority.
+//
+// A small constant keeps sequences with zero error from
+// becoming impossible to sample.
+func (s *SequenceBuffer) scaledPriority(p float64) float64 {
+	const epsilon = 1e-6
+	return math.Pow(p+epsilon, s.alpha())
+}
+
+func (s *SequenceBuffer) eta() float64 {
+	if s.Eta == 0 {
+		return DefaultSequenceEta
+	}
+	return s.Eta
+}
+
+func (s *SequenceBuffer) alpha() float64 {
+	if s.Alpha == 0 {
+		return DefaultSequenceAlpha
+	}
+	return s.Alpha
+}
+
+func (s *SequenceBuffer) beta() float64 {
+	if s.Beta == 0 {
+		return DefaultSequenceBeta
+	}
+	return s.Beta
+}
@@ -0,0 +1,91 @@
+package anyq
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSequencePriority(t *testing.T) {
+	actual := SequencePriority([]float64{1, -3, 2}, 0.9)
+	if expected := 0.9*3 + 0.1*2; math.Abs(actual-expected) > 1e-8 {
+		t.Errorf("expected %f but got %f", expected, actual)
+	}
+}
+
+func TestSequenceBuffer(t *testing.T) {
+	var episode []*Transition
+	for i := 0; i < 5; i++ {
+		episode = append(episode, &Transition{Reward: float64(i), Done: i == 4})
+	}
+	buf := &SequenceBuffer{Length: 2, Alpha: 1, Beta: 1}
+	buf.AddEpisodes(episode)
+	if buf.Len() != 3 {
+		t.Fatalf("expected 3 sequences but got %d", buf.Len())
+	}
+
+	// Give all the priority to the first sequence.
+	samples := buf.Sample(100)
+	tdErrors := make([][]float64, len(samples))
+	for i, sample := range samples {
+		if sample.Transitions[0].Reward == 0 {
+			tdErrors[i] = []float64{1, 1}
+		} else {
+			tdErrors[i] = make([]float64, len(sample.Transitions))
+		}
+	}
+	buf.UpdatePriorities(samples, tdErrors)
+
+	counts := map[float64]int{}
+	for _, sample := range buf.Sample(1000) {
+		counts[sample.Transitions[0].Reward]++
+		if sample.Transitions[0].Reward == 0 {
+			if math.Abs(sample.Weight-1e-6/(1+1e-6)) > 1e-8 {
+				t.Errorf("unexpected weight: %f", sample.Weight)
+			}
+		} else if sample.Weight != 1 {
+			t.Errorf("unexpected weight: %f", sample.Weight)
+		}
+	}
+	if counts[0] < 990 {
+		t.Errorf("unexpected sample counts: %v", counts)
+	}
+
+	// New sequences get the maximum priority.
+	buf.AddEpisodes([]*Transition{{Reward: 10, Done: true}})
+	counts = map[float64]int{}
+	for _, sample := range buf.Sample(1000) {
+		counts[sample.Transitions[0].Reward]++
+	}
+	if counts[10] < 400 || counts[0] < 400 {
+		t.Errorf("unexpected sample counts: %v", counts)
+	}
+}
+
+func TestSequenceBufferDegenerate(t *testing.T) {
+	buf := &SequenceBuffer{Length: 1, Alpha: 100, Beta: 1}
+	if samples := buf.Sample(3); samples != nil {
+		t.Errorf("expected nil from an empty buffer but got %d samples", len(samples))
+	}
+
+	// With zero priorities and a large Alpha, the scaled
+	// priorities underflow to zero.
+	buf.AddEpisodes([]*Transition{{Reward: 0}, {Reward: 1, Done: true}})
+	samples := buf.Sample(10)
+	tdErrors := make([][]float64, len(samples))
+	for i := range tdErrors {
+		tdErrors[i] = []float64{0}
+	}
+	buf.UpdatePriorities(samples, tdErrors)
+
+	counts := map[float64]int{}
+	for _, sample := range buf.Sample(1000) {
+		counts[sample.Transitions[0].Reward]++
+		if sample.Weight != 1 {
+			t.Errorf("unexpected weight: %f", sample.Weight)
+			break
+		}
+	}
+	if counts[0] < 400 || counts[1] < 400 {
+		t.Errorf("unexpected sample counts: %v", counts)
+	}
+}
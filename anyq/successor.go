@@ -0,0 +1,181 @@
+package anyq
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// SuccessorFeatures learns successor features for a
+// policy which is greedy with respect to a reward weight
+// vector.
+//
+// Rewards are assumed to be (approximately) linear in
+// some features, r = phi(s, a, s') . w, so the Q-values
+// for any weight vector w can be computed from the
+// successor features as Q(s, a) = psi(s, a) . w.
+//
+// See https://arxiv.org/abs/1606.05312.
+type SuccessorFeatures struct {
+	// Psi maps a batch of observations to a batch of
+	// successor features.
+	// For each observation, the output contains one
+	// feature vector per action, in order.
+	Psi anynet.Layer
+
+	// Target is used to compute the successor features
+	// for the next states in the bootstrapped targets.
+	//
+	// If nil, Psi is used.
+	Target anynet.Layer
+
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// NumActions is the number of discrete actions.
+	NumActions int
+
+	// Features computes the features phi for a
+	// transition.
+	Features func(t *Transition) []float64
+
+	// Weights is the reward weight vector which this
+	// policy maximizes.
+	Weights []float64
+
+	// Discount is the reward discount factor.
+	Discount float64
+
+	// Loss is used to fit the successor features to their
+	// targets.
+	//
+	// If nil, anyrl.SquareLoss is used.
+	Loss anyrl.Loss
+}
+
+// Run computes a gradient for a batch of transitions.
+//
+// The gradient is for the negative loss, so it should be
+// added to the parameters.
+// The mean loss per transition is also returned.
+//
+// If s.Params is empty, then an empty gradient and a nil
+// loss are returned.
+func (s *SuccessorFeatures) Run(batch []*Transition) (anydiff.Grad, anyvec.Numeric) {
+	grad := anydiff.NewGrad(s.Params...)
+	if len(grad) == 0 || len(batch) == 0 {
+		return grad, nil
+	}
+	c := s.Params[0].Vector.Creator()
+	n := len(batch)
+
+	obs, _ := joinTransitions(c, batch)
+	psi := s.Psi.Apply(anydiff.NewConst(obs), n)
+	size := psi.Output().Len() / (n * s.NumActions)
+	selected := anydiff.Pool(psi, func(psi anydiff.Res) anydiff.Res {
+		var parts []anydiff.Res
+		for i, t := range batch {
+			start := (i*s.NumActions + argmax(t.Action)) * size
+			parts = append(parts, anydiff.Slice(psi, start, start+size))
+		}
+		return anydiff.Concat(parts...)
+	})
+	targets := anydiff.NewConst(anyvec.Make(c, s.targets(c, batch, size)))
+
+	loss := s.Loss
+	if loss == nil {
+		loss = anyrl.SquareLoss{}
+	}
+	mean := anydiff.Scale(anydiff.Sum(loss.Loss(selected, targets)),
+		c.MakeNumeric(1/float64(n)))
+	mean.Propagate(anyvec.Make(c, []float64{-1}), grad)
+
+	return grad, anyvec.Sum(mean.Output())
+}
+
+// QValues computes the Q-values of a batch of successor
+// features for the given reward weights.
+func (s *SuccessorFeatures) QValues(psi anydiff.Res, n int, weights []float64) anydiff.Res {
+	c := psi.Output().Creator()
+	return anydiff.MatMul(false, false,
+		&anydiff.Matrix{Data: psi, Rows: n * s.NumActions, Cols: len(weights)},
+		&anydiff.Matrix{
+			Data: anydiff.NewConst(anyvec.Make(c, weights)),
+			Rows: len(weights),
+			Cols: 1,
+		},
+	).Data
+}
+
+// targets computes the bootstrapped successor feature
+// targets, where the next action is greedy with respect
+// to s.Weights.
+func (s *SuccessorFeatures) targets(c anyvec.Creator, batch []*Transition,
+	size int) []float64 {
+	var res []float64
+	var nextObs []float64
+	var numNext int
+	for _, t := range batch {
+		res = append(res, s.Features(t)...)
+		if !t.Done {
+			nextObs = append(nextObs, t.Next...)
+			numNext++
+		}
+	}
+	if numNext == 0 {
+		return res
+	}
+
+	target := s.Target
+	if target == nil {
+		target = s.Psi
+	}
+	nextPsi := target.Apply(anydiff.NewConst(anyvec.Make(c, nextObs)), numNext)
+	psiValues := c.Float64Slice(nextPsi.Output().Data())
+	qValues := c.Float64Slice(s.QValues(nextPsi, numNext, s.Weights).Output().Data())
+
+	var nextIdx int
+	for i, t := range batch {
+		if !t.Done {
+			action := argmax(qValues[nextIdx*s.NumActions : (nextIdx+1)*s.NumActions])
+			start := (nextIdx*s.NumActions + action) * size
+			for j, x := range psiValues[start : start+size] {
+				res[i*size+j] += s.Discount * x
+			}
+			nextIdx++
+		}
+	}
+	return res
+}
+
+// GPI is an anynet.Layer which performs generalized
+// policy improvement over a set of policies with learned
+// successor features.
+//
+// For a new task with reward weights Weights, the output
+// Q-value of each action is the highest Q-value that any
+// of the policies assigns to it.
+// The outputs can be fed to Greedy to act on the new
+// task, and they are constant with respect to the
+// parameters.
+type GPI struct {
+	Policies []*SuccessorFeatures
+	Weights  []float64
+}
+
+// Apply computes the GPI Q-values for the observations.
+func (g *GPI) Apply(in anydiff.Res, n int) anydiff.Res {
+	var res anyvec.Vector
+	for _, p := range g.Policies {
+		psi := anydiff.NewConst(p.Psi.Apply(in, n).Output())
+		q := p.QValues(psi, n, g.Weights).Output()
+		if res == nil {
+			res = q.Copy()
+		} else {
+			res = anydiff.ElemMax(anydiff.NewConst(res), anydiff.NewConst(q)).Output()
+		}
+	}
+	return anydiff.NewConst(res)
+}
@@ -0,0 +1,53 @@
+package anyq
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestSuccessorFeatures(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+
+	// Two actions, each yielding a different feature,
+	// from a single state.
+	fc := anynet.NewFC(c, 1, 4)
+	sf := &SuccessorFeatures{
+		Psi:        fc,
+		Params:     fc.Parameters(),
+		NumActions: 2,
+		Features: func(t *Transition) []float64 {
+			return t.Action
+		},
+		Weights:  []float64{1, 0},
+		Discount: 0.5,
+	}
+	batch := []*Transition{
+		{Obs: []float64{1}, Action: []float64{1, 0}, Next: []float64{1}},
+		{Obs: []float64{1}, Action: []float64{0, 1}, Next: []float64{1}},
+	}
+	for i := 0; i < 2000; i++ {
+		grad, _ := sf.Run(batch)
+		grad.Scale(c.MakeNumeric(0.05))
+		grad.AddToVars()
+	}
+
+	// Always taking action 0 afterwards.
+	expected := []float64{2, 0, 1, 1}
+	psi := c.Float64Slice(fc.Apply(anydiff.NewConst(anyvec.Make(c, []float64{1})), 1).Output().Data())
+	for i, x := range expected {
+		if math.Abs(psi[i]-x) > 1e-2 {
+			t.Fatalf("expected psi %v but got %v", expected, psi)
+		}
+	}
+
+	gpi := &GPI{Policies: []*SuccessorFeatures{sf}, Weights: []float64{0, 1}}
+	q := c.Float64Slice(gpi.Apply(anydiff.NewConst(anyvec.Make(c, []float64{1})), 1).Output().Data())
+	if math.Abs(q[0]-0) > 1e-2 || math.Abs(q[1]-1) > 1e-2 {
+		t.Errorf("unexpected GPI Q-values: %v", q)
+	}
+}
@@ -0,0 +1,70 @@
+package anyq
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+// TargetNet maintains a copy of a network whose
+// parameters lag behind the parameters of the original
+// (online) network.
+//
+// A TargetNet is an anynet.Layer which applies the copy,
+// so it can be used as the Target of CQL or BCQ.
+type TargetNet struct {
+	Online anynet.Layer
+	Target anynet.Layer
+}
+
+// NewTargetNet creates a TargetNet by copying the online
+// network.
+// The online network must be serializable.
+func NewTargetNet(online anynet.Layer) (target *TargetNet, err error) {
+	defer essentials.AddCtxTo("create target network", &err)
+	copied, err := serializer.Copy(online)
+	if err != nil {
+		return nil, err
+	}
+	return &TargetNet{Online: online, Target: copied.(anynet.Layer)}, nil
+}
+
+// Apply applies the target network.
+// The result is constant with respect to the parameters.
+func (t *TargetNet) Apply(in anydiff.Res, n int) anydiff.Res {
+	return anydiff.NewConst(t.Target.Apply(in, n).Output())
+}
+
+// Sync copies the online parameters into the target
+// network.
+func (t *TargetNet) Sync() {
+	online, target := t.params()
+	for i, p := range online {
+		target[i].Vector.Set(p.Vector)
+	}
+}
+
+// SoftUpdate moves the target parameters towards the
+// online parameters using Polyak averaging:
+//
+//	target = (1-tau)*target + tau*online
+func (t *TargetNet) SoftUpdate(tau float64) {
+	online, target := t.params()
+	for i, p := range online {
+		c := p.Vector.Creator()
+		target[i].Vector.Scale(c.MakeNumeric(1 - tau))
+		scaled := p.Vector.Copy()
+		scaled.Scale(c.MakeNumeric(tau))
+		target[i].Vector.Add(scaled)
+	}
+}
+
+func (t *TargetNet) params() (online, target []*anydiff.Var) {
+	online = anynet.AllParameters(t.Online)
+	target = anynet.AllParameters(t.Target)
+	if len(online) != len(target) {
+		panic("online and target networks have different parameters")
+	}
+	return
+}
@@ -0,0 +1,56 @@
+package anyq
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestTargetNet(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	online := anynet.Net{anynet.NewFC(c, 3, 2)}
+	target, err := NewTargetNet(online)
+	if err != nil {
+		t.Fatal(err)
+	}
+	onlineParams, targetParams := target.params()
+
+	// Perturb the online network so the two differ.
+	for _, p := range onlineParams {
+		p.Vector.AddScalar(c.MakeNumeric(1))
+	}
+	var oldTarget [][]float64
+	for _, p := range targetParams {
+		oldTarget = append(oldTarget, c.Float64Slice(p.Vector.Data()))
+	}
+
+	target.SoftUpdate(0.25)
+	for i, p := range targetParams {
+		actual := c.Float64Slice(p.Vector.Data())
+		on := c.Float64Slice(onlineParams[i].Vector.Data())
+		for j, x := range actual {
+			expected := 0.25*on[j] + 0.75*oldTarget[i][j]
+			if math.Abs(x-expected) > 1e-8 {
+				t.Errorf("param %d: soft update gave %f but expected %f", i, x, expected)
+				break
+			}
+		}
+	}
+
+	target.Sync()
+	for i, p := range targetParams {
+		actual := c.Float64Slice(p.Vector.Data())
+		on := c.Float64Slice(onlineParams[i].Vector.Data())
+		for j, x := range actual {
+			if x != on[j] {
+				t.Errorf("param %d: sync gave %f but expected %f", i, x, on[j])
+				break
+			}
+		}
+	}
+	if p := targetParams[0]; p.Vector == onlineParams[0].Vector {
+		t.Error("sync should copy parameters rather than share them")
+	}
+}
@@ -0,0 +1,141 @@
+package anyq
+
+import (
+	"encoding/gob"
+	"io"
+	"math/rand"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/lazyseq"
+)
+
+// A Transition is a single step of experience.
+type Transition struct {
+	Obs    []float64
+	Action []float64
+	Reward float64
+
+	// Next is the observation after the step.
+	// It is nil if Done is true.
+	Next []float64
+	Done bool
+
+	// Truncated is true if the episode was cut off after
+	// this transition without reaching a terminal state.
+	// Like Done, it marks the end of an episode.
+	Truncated bool
+}
+
+// A Dataset is a fixed set of transitions, such as a set
+// of logged experience used for offline training.
+type Dataset []*Transition
+
+// RolloutDataset converts RolloutSets into a Dataset.
+//
+// The last timestep of every episode is treated as a
+// terminal transition, except for truncated episodes.
+// The last timestep of a truncated episode uses the
+// episode's final input as its next observation, or is
+// dropped if the final input was not recorded.
+// Either way, the last transition of a truncated episode
+// is marked as Truncated.
+func RolloutDataset(rs ...*anyrl.RolloutSet) Dataset {
+	var res Dataset
+	for _, r := range rs {
+		c := r.Creator()
+		inputs := tapeSteps(r.Inputs, len(r.Rewards))
+		actions := tapeSteps(r.Actions, len(r.Rewards))
+		for ep, rewards := range r.Rewards {
+			var episode []*Transition
+			truncated := r.IsTruncated(ep)
+			for t, rew := range rewards {
+				last := t+1 == len(rewards)
+				trans := &Transition{
+					Obs:    c.Float64Slice(inputs[ep][t]),
+					Action: c.Float64Slice(actions[ep][t]),
+					Reward: rew,
+					Done:   last && !truncated,
+				}
+				if !last {
+					trans.Next = c.Float64Slice(inputs[ep][t+1])
+				} else if truncated {
+					final := r.FinalInput(ep)
+					if final == nil {
+						break
+					}
+					trans.Next = c.Float64Slice(final.Data())
+				}
+				episode = append(episode, trans)
+			}
+			if truncated && len(episode) > 0 {
+				episode[len(episode)-1].Truncated = true
+			}
+			res = append(res, episode...)
+		}
+	}
+	return res
+}
+
+// LoadDataset reads a Dataset that was saved with Save.
+func LoadDataset(r io.Reader) (d Dataset, err error) {
+	defer essentials.AddCtxTo("load dataset", &err)
+	err = gob.NewDecoder(r).Decode(&d)
+	return
+}
+
+// Save writes the Dataset to w.
+func (d Dataset) Save(w io.Writer) (err error) {
+	defer essentials.AddCtxTo("save dataset", &err)
+	return gob.NewEncoder(w).Encode(d)
+}
+
+// Sample selects n transitions uniformly at random (with
+// replacement).
+func (d Dataset) Sample(n int) []*Transition {
+	res := make([]*Transition, n)
+	for i := range res {
+		res[i] = d[rand.Intn(len(d))]
+	}
+	return res
+}
+
+// Episodes splits the Dataset into episodes, assuming
+// that transitions are grouped by episode and that each
+// episode ends with a Done or Truncated transition.
+//
+// If the last episode is incomplete, it is still
+// included.
+func (d Dataset) Episodes() [][]*Transition {
+	var res [][]*Transition
+	var episode []*Transition
+	for _, t := range d {
+		episode = append(episode, t)
+		if t.Done || t.Truncated {
+			res = append(res, episode)
+			episode = nil
+		}
+	}
+	if len(episode) > 0 {
+		res = append(res, episode)
+	}
+	return res
+}
+
+// tapeSteps splits a tape up into the data for each
+// timestep of each episode.
+func tapeSteps(t lazyseq.Tape, numEpisodes int) [][]interface{} {
+	res := make([][]interface{}, numEpisodes)
+	for batch := range t.ReadTape(0, -1) {
+		chunkSize := batch.Packed.Len() / batch.NumPresent()
+		var offset int
+		for i, pres := range batch.Present {
+			if pres {
+				vec := batch.Packed.Slice(offset, offset+chunkSize)
+				res[i] = append(res[i], vec.Data())
+				offset += chunkSize
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,73 @@
+package anyq
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestRolloutDataset(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	obs := anyrl.Rewards{{1, 2, 3}, {4}}
+	acts := anyrl.Rewards{{5, 6, 7}, {8}}
+	r := &anyrl.RolloutSet{
+		Inputs:  obs.Tape(c),
+		Actions: acts.Tape(c),
+		Rewards: anyrl.Rewards{{0.5, 1, 1.5}, {2}},
+	}
+	expected := Dataset{
+		{Obs: []float64{1}, Action: []float64{5}, Reward: 0.5, Next: []float64{2}},
+		{Obs: []float64{2}, Action: []float64{6}, Reward: 1, Next: []float64{3}},
+		{Obs: []float64{3}, Action: []float64{7}, Reward: 1.5, Done: true},
+		{Obs: []float64{4}, Action: []float64{8}, Reward: 2, Done: true},
+	}
+	actual := RolloutDataset(r)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v but got %v", expected, actual)
+	}
+
+	var buf bytes.Buffer
+	if err := actual.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadDataset(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("expected %v but got %v", expected, loaded)
+	}
+}
+
+func TestRolloutDatasetTruncated(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	obs := anyrl.Rewards{{1, 2}, {3, 4}, {5}}
+	acts := anyrl.Rewards{{6, 7}, {8, 9}, {10}}
+	r := &anyrl.RolloutSet{
+		Inputs:      obs.Tape(c),
+		Actions:     acts.Tape(c),
+		Rewards:     anyrl.Rewards{{1, 2}, {3, 4}, {5}},
+		Truncated:   []bool{true, true, false},
+		FinalInputs: []anyvec.Vector{c.MakeVectorData([]float64{11}), nil, nil},
+	}
+	expected := Dataset{
+		{Obs: []float64{1}, Action: []float64{6}, Reward: 1, Next: []float64{2}},
+		{Obs: []float64{2}, Action: []float64{7}, Reward: 2, Next: []float64{11},
+			Truncated: true},
+		{Obs: []float64{3}, Action: []float64{8}, Reward: 3, Next: []float64{4},
+			Truncated: true},
+		{Obs: []float64{5}, Action: []float64{10}, Reward: 5, Done: true},
+	}
+	actual := RolloutDataset(r)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v but got %v", expected, actual)
+	}
+	episodes := actual.Episodes()
+	if len(episodes) != 3 {
+		t.Errorf("expected 3 episodes but got %d", len(episodes))
+	}
+}
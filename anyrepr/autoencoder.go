@@ -0,0 +1,55 @@
+package anyrepr
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// Autoencoder pretrains an encoder by reconstructing
+// observations from their embeddings.
+type Autoencoder struct {
+	Encoder anynet.Layer
+	Decoder anynet.Layer
+
+	// Params specifies which parameters to include in
+	// the gradients.
+	// This should include the parameters of both the
+	// encoder and the decoder.
+	Params []*anydiff.Var
+
+	// Loss is the reconstruction loss.
+	//
+	// If nil, anyrl.SquareLoss is used.
+	Loss anyrl.Loss
+}
+
+// Train computes a gradient for a batch of observations.
+//
+// The gradient is for the negative loss, so it should be
+// added to the parameters.
+// The mean reconstruction loss is also returned.
+//
+// If a.Params is empty, then an empty gradient and a nil
+// loss are returned.
+func (a *Autoencoder) Train(obs [][]float64) (anydiff.Grad, anyvec.Numeric) {
+	grad := anydiff.NewGrad(a.Params...)
+	if len(grad) == 0 || len(obs) == 0 {
+		return grad, nil
+	}
+	c := a.Params[0].Vector.Creator()
+	n := len(obs)
+
+	in := anydiff.NewConst(anyvec.Make(c, joinObs(obs)))
+	recon := a.Decoder.Apply(a.Encoder.Apply(in, n), n)
+	loss := a.Loss
+	if loss == nil {
+		loss = anyrl.SquareLoss{}
+	}
+	total := anydiff.Sum(loss.Loss(recon, in))
+	mean := anydiff.Scale(total, c.MakeNumeric(1/float64(n)))
+	mean.Propagate(anyvec.Make(c, []float64{-1}), grad)
+
+	return grad, anyvec.Sum(mean.Output())
+}
@@ -0,0 +1,77 @@
+package anyrepr
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestObservations(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	inputs := anyrl.Rewards{{1, 2}, {3}}
+	r := &anyrl.RolloutSet{Inputs: inputs.Tape(c), Rewards: inputs}
+	actual := Observations(r)
+	expected := [][]float64{{1}, {3}, {2}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
+
+func TestAutoencoder(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	enc := anynet.Net{anynet.NewFC(c, 3, 2)}
+	dec := anynet.Net{anynet.NewFC(c, 2, 3)}
+	ae := &Autoencoder{
+		Encoder: enc,
+		Decoder: dec,
+		Params:  anynet.AllParameters(enc, dec),
+	}
+	obs := [][]float64{{1, 2, 3}, {2, 4, 6}, {-1, -2, -3}}
+
+	_, initLoss := ae.Train(obs)
+	for i := 0; i < 500; i++ {
+		grad, _ := ae.Train(obs)
+		grad.Scale(c.MakeNumeric(0.01))
+		grad.AddToVars()
+	}
+	_, finalLoss := ae.Train(obs)
+	if finalLoss.(float64) > initLoss.(float64)/10 {
+		t.Errorf("loss went from %f to %f", initLoss, finalLoss)
+	}
+}
+
+func TestContrastiveLoss(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	fc := anynet.NewFC(c, 2, 2)
+	fc.Weights.Vector.SetData(c.MakeNumericList([]float64{1, 0, 0, 1}))
+	fc.Biases.Vector.SetData(c.MakeNumericList([]float64{0, 0}))
+	con := &Contrastive{
+		Encoder:     fc,
+		Params:      fc.Parameters(),
+		Augmenter:   identityAugmenter{},
+		Temperature: 1,
+	}
+	_, loss := con.Train([][]float64{{1, 0}, {0, 1}})
+	expected := -math.Log(math.E / (math.E + 1))
+	if math.Abs(loss.(float64)-expected) > 1e-8 {
+		t.Errorf("expected loss %f but got %f", expected, loss)
+	}
+
+	frozen := &Frozen{Layer: fc}
+	out := frozen.Apply(anydiff.NewVar(anyvec.Make(c, []float64{1, 2})), 1)
+	if len(out.Vars()) != 0 {
+		t.Error("frozen layer should not depend on variables")
+	}
+}
+
+type identityAugmenter struct{}
+
+func (identityAugmenter) Augment(obs []float64) []float64 {
+	return obs
+}
@@ -0,0 +1,85 @@
+package anyrepr
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// DefaultTemperature is the default softmax temperature
+// for Contrastive.
+const DefaultTemperature = 0.1
+
+// Contrastive pretrains an encoder with the InfoNCE loss,
+// as in CURL.
+//
+// Two augmented views are produced for each observation,
+// and the encoder is trained to match each view with the
+// other view of the same observation among all of the
+// views in the batch.
+//
+// Similarities are dot products between embeddings, so
+// the encoder may want to normalize its outputs.
+type Contrastive struct {
+	Encoder anynet.Layer
+
+	// Params specifies which parameters to include in
+	// the gradients.
+	Params []*anydiff.Var
+
+	// Augmenter produces the views of each observation.
+	Augmenter anyrl.Augmenter
+
+	// Temperature scales the similarities before the
+	// softmax.
+	//
+	// If 0, DefaultTemperature is used.
+	Temperature float64
+}
+
+// Train computes a gradient for a batch of observations.
+//
+// The gradient is for the negative loss, so it should be
+// added to the parameters.
+// The mean InfoNCE loss is also returned.
+//
+// If c.Params is empty, then an empty gradient and a nil
+// loss are returned.
+func (c *Contrastive) Train(obs [][]float64) (anydiff.Grad, anyvec.Numeric) {
+	grad := anydiff.NewGrad(c.Params...)
+	if len(grad) == 0 || len(obs) == 0 {
+		return grad, nil
+	}
+	cr := c.Params[0].Vector.Creator()
+	n := len(obs)
+
+	var views1, views2 [][]float64
+	for _, o := range obs {
+		views1 = append(views1, c.Augmenter.Augment(o))
+		views2 = append(views2, c.Augmenter.Augment(o))
+	}
+	z1 := c.Encoder.Apply(anydiff.NewConst(anyvec.Make(cr, joinObs(views1))), n)
+	z2 := c.Encoder.Apply(anydiff.NewConst(anyvec.Make(cr, joinObs(views2))), n)
+	size := z1.Output().Len() / n
+
+	logits := anydiff.MatMul(false, true,
+		&anydiff.Matrix{Data: z1, Rows: n, Cols: size},
+		&anydiff.Matrix{Data: z2, Rows: n, Cols: size},
+	).Data
+	temp := c.Temperature
+	if temp == 0 {
+		temp = DefaultTemperature
+	}
+	logProbs := anydiff.LogSoftmax(anydiff.Scale(logits, cr.MakeNumeric(1/temp)), n)
+
+	identity := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		identity[i*n+i] = 1
+	}
+	correct := anydiff.Sum(anydiff.Mul(logProbs, anydiff.NewConst(anyvec.Make(cr, identity))))
+	loss := anydiff.Scale(correct, cr.MakeNumeric(-1/float64(n)))
+	loss.Propagate(anyvec.Make(cr, []float64{-1}), grad)
+
+	return grad, anyvec.Sum(loss.Output())
+}
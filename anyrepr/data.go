@@ -0,0 +1,60 @@
+package anyrepr
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl"
+)
+
+// Observations gathers every input from a set of
+// rollouts, for use as pretraining data.
+func Observations(rs ...*anyrl.RolloutSet) [][]float64 {
+	var res [][]float64
+	for _, r := range rs {
+		c := r.Creator()
+		for batch := range r.Inputs.ReadTape(0, -1) {
+			n := batch.NumPresent()
+			if n == 0 {
+				continue
+			}
+			data := c.Float64Slice(batch.Packed.Data())
+			size := len(data) / n
+			for i := 0; i < n; i++ {
+				res = append(res, data[i*size:(i+1)*size])
+			}
+		}
+	}
+	return res
+}
+
+// Sample selects n observations uniformly at random (with
+// replacement).
+func Sample(obs [][]float64, n int) [][]float64 {
+	res := make([][]float64, n)
+	for i := range res {
+		res[i] = obs[rand.Intn(len(obs))]
+	}
+	return res
+}
+
+// Frozen is an anynet.Layer which applies a layer without
+// propagating gradients through it, so that a pretrained
+// encoder can be used as a fixed feature extractor.
+type Frozen struct {
+	Layer anynet.Layer
+}
+
+// Apply applies the layer and returns a constant.
+func (f *Frozen) Apply(in anydiff.Res, n int) anydiff.Res {
+	return anydiff.NewConst(f.Layer.Apply(in, n).Output())
+}
+
+func joinObs(obs [][]float64) []float64 {
+	var res []float64
+	for _, o := range obs {
+		res = append(res, o...)
+	}
+	return res
+}
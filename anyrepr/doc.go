@@ -0,0 +1,7 @@
+// Package anyrepr implements self-supervised pretraining
+// of observation encoders on stored rollouts.
+//
+// A pretrained encoder can be reused by a policy, either
+// frozen (see Frozen) or fine-tuned along with the rest of
+// the policy.
+package anyrepr
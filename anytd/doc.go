@@ -0,0 +1,8 @@
+// Package anytd implements classic temporal-difference
+// control algorithms (SARSA, Q-learning, and expected
+// SARSA) with tabular and linear value functions.
+//
+// These algorithms are useful for solving simple problems
+// and for sanity checks, since they do not require neural
+// networks.
+package anytd
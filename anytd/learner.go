@@ -0,0 +1,123 @@
+package anytd
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/essentials"
+)
+
+// Method is a temporal-difference control algorithm.
+type Method int
+
+// These are the supported control algorithms.
+const (
+	SARSA Method = iota
+	QLearning
+	ExpectedSARSA
+)
+
+// Learner trains a ValueFunc online while acting in an
+// environment with an epsilon-greedy policy.
+//
+// Actions are one-hot vectors, like the ones produced by
+// anyrl.Softmax.
+type Learner struct {
+	Q      ValueFunc
+	Method Method
+
+	// Discount is the reward discount factor.
+	Discount float64
+
+	// StepSize is the learning rate for value updates.
+	StepSize float64
+
+	// Epsilon is the probability of taking a uniformly
+	// random action.
+	Epsilon float64
+}
+
+// Episode runs and learns from an episode in env.
+//
+// It returns the total reward of the episode.
+func (l *Learner) Episode(env anyrl.Env) (total float64, err error) {
+	defer essentials.AddCtxTo("run TD episode", &err)
+
+	obs, err := env.Reset()
+	if err != nil {
+		return 0, err
+	}
+	numActions := len(l.Q.Values(obs))
+	action := l.Act(obs)
+	for {
+		nextObs, reward, done, err := env.Step(oneHot(numActions, action))
+		if err != nil {
+			return 0, err
+		}
+		total += reward
+
+		if done {
+			l.Q.Update(obs, action, reward, l.StepSize)
+			return total, nil
+		}
+
+		nextAction := l.Act(nextObs)
+		target := reward + l.Discount*l.nextValue(nextObs, nextAction)
+		l.Q.Update(obs, action, target, l.StepSize)
+
+		obs, action = nextObs, nextAction
+	}
+}
+
+// Act selects an action with the epsilon-greedy policy.
+func (l *Learner) Act(obs []float64) int {
+	values := l.Q.Values(obs)
+	if l.Epsilon > 0 && rand.Float64() < l.Epsilon {
+		return rand.Intn(len(values))
+	}
+	return argmax(values)
+}
+
+// Greedy selects the action with the highest Q-value.
+func (l *Learner) Greedy(obs []float64) int {
+	return argmax(l.Q.Values(obs))
+}
+
+func (l *Learner) nextValue(obs []float64, action int) float64 {
+	values := l.Q.Values(obs)
+	switch l.Method {
+	case SARSA:
+		return values[action]
+	case QLearning:
+		return values[argmax(values)]
+	case ExpectedSARSA:
+		best := argmax(values)
+		var sum float64
+		for i, x := range values {
+			prob := l.Epsilon / float64(len(values))
+			if i == best {
+				prob += 1 - l.Epsilon
+			}
+			sum += prob * x
+		}
+		return sum
+	default:
+		panic("unknown method")
+	}
+}
+
+func oneHot(numActions, action int) []float64 {
+	res := make([]float64, numActions)
+	res[action] = 1
+	return res
+}
+
+func argmax(values []float64) int {
+	var idx int
+	for i, x := range values {
+		if x > values[idx] {
+			idx = i
+		}
+	}
+	return idx
+}
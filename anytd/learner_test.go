@@ -0,0 +1,66 @@
+package anytd
+
+import "testing"
+
+func TestLearnerChain(t *testing.T) {
+	for _, method := range []Method{SARSA, QLearning, ExpectedSARSA} {
+		table := &Table{NumActions: 2}
+		linear := &Linear{
+			NumActions: 2,
+			Features: func(obs []float64) []float64 {
+				features := make([]float64, chainLength)
+				features[int(obs[0])] = 1
+				return features
+			},
+		}
+		for _, q := range []ValueFunc{table, linear} {
+			learner := &Learner{
+				Q:        q,
+				Method:   method,
+				Discount: 0.9,
+				StepSize: 0.1,
+				Epsilon:  0.5,
+			}
+			for i := 0; i < 500; i++ {
+				if _, err := learner.Episode(&chainEnv{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for pos := 0; pos < chainLength; pos++ {
+				if action := learner.Greedy([]float64{float64(pos)}); action != 1 {
+					t.Errorf("method %d, %T: position %d: expected action 1 but got %d",
+						method, q, pos, action)
+				}
+			}
+		}
+	}
+}
+
+const chainLength = 4
+
+// chainEnv is a chain of states where action 1 moves
+// forward and action 0 moves backward.
+// Reaching the end of the chain gives a reward of 1.
+type chainEnv struct {
+	pos   int
+	steps int
+}
+
+func (c *chainEnv) Reset() ([]float64, error) {
+	c.pos = 0
+	c.steps = 0
+	return []float64{0}, nil
+}
+
+func (c *chainEnv) Step(action []float64) ([]float64, float64, bool, error) {
+	c.steps++
+	if action[1] == 1 {
+		c.pos++
+	} else if c.pos > 0 {
+		c.pos--
+	}
+	if c.pos == chainLength {
+		return []float64{0}, 1, true, nil
+	}
+	return []float64{float64(c.pos)}, 0, c.steps == 50, nil
+}
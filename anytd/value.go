@@ -0,0 +1,126 @@
+package anytd
+
+import "fmt"
+
+// A ValueFunc estimates Q-values for a discrete set of
+// actions.
+type ValueFunc interface {
+	// Values computes the Q-value of every action for an
+	// observation.
+	Values(obs []float64) []float64
+
+	// Update moves the Q-value of an action towards a
+	// target value.
+	Update(obs []float64, action int, target, stepSize float64)
+}
+
+// Table is a tabular ValueFunc.
+type Table struct {
+	NumActions int
+
+	// Key maps observations to table keys.
+	// Observations with the same key share Q-values.
+	//
+	// If nil, the formatted observation is used.
+	Key func(obs []float64) string
+
+	// Init is the initial Q-value for every entry.
+	Init float64
+
+	entries map[string][]float64
+}
+
+// Values returns the table entry for the observation.
+func (t *Table) Values(obs []float64) []float64 {
+	return append([]float64{}, t.entry(obs)...)
+}
+
+// Update moves the table entry towards the target.
+func (t *Table) Update(obs []float64, action int, target, stepSize float64) {
+	entry := t.entry(obs)
+	entry[action] += stepSize * (target - entry[action])
+}
+
+// NumEntries returns the number of visited table entries.
+func (t *Table) NumEntries() int {
+	return len(t.entries)
+}
+
+func (t *Table) entry(obs []float64) []float64 {
+	if t.entries == nil {
+		t.entries = map[string][]float64{}
+	}
+	var key string
+	if t.Key != nil {
+		key = t.Key(obs)
+	} else {
+		key = fmt.Sprint(obs)
+	}
+	entry, ok := t.entries[key]
+	if !ok {
+		entry = make([]float64, t.NumActions)
+		for i := range entry {
+			entry[i] = t.Init
+		}
+		t.entries[key] = entry
+	}
+	return entry
+}
+
+// Linear is a ValueFunc which is linear in a set of
+// features.
+// Each action has its own weight vector.
+type Linear struct {
+	NumActions int
+
+	// Features computes a feature vector for an
+	// observation.
+	// Every feature vector should have the same length.
+	Features func(obs []float64) []float64
+
+	// Weights stores one weight vector per action.
+	//
+	// If nil, it is initialized to zeros on first use.
+	Weights [][]float64
+}
+
+// Values computes the dot product of each action's
+// weights with the features.
+func (l *Linear) Values(obs []float64) []float64 {
+	features := l.Features(obs)
+	l.init(len(features))
+	res := make([]float64, l.NumActions)
+	for i, weights := range l.Weights {
+		res[i] = dot(weights, features)
+	}
+	return res
+}
+
+// Update performs a semi-gradient step on the squared
+// error between the action's Q-value and the target.
+func (l *Linear) Update(obs []float64, action int, target, stepSize float64) {
+	features := l.Features(obs)
+	l.init(len(features))
+	weights := l.Weights[action]
+	scale := stepSize * (target - dot(weights, features))
+	for i, f := range features {
+		weights[i] += scale * f
+	}
+}
+
+func (l *Linear) init(numFeatures int) {
+	if l.Weights == nil {
+		l.Weights = make([][]float64, l.NumActions)
+		for i := range l.Weights {
+			l.Weights[i] = make([]float64, numFeatures)
+		}
+	}
+}
+
+func dot(v1, v2 []float64) float64 {
+	var res float64
+	for i, x := range v1 {
+		res += x * v2[i]
+	}
+	return res
+}
@@ -0,0 +1,245 @@
+package anyrl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/essentials"
+)
+
+// Default thresholds for an EnvAuditor.
+const (
+	DefaultAuditObsScale    = 10
+	DefaultAuditRewardScale = 10
+)
+
+// An EnvAudit summarizes the behavior of an environment
+// under a random policy.
+type EnvAudit struct {
+	Steps int
+
+	// ObsMin and ObsMax are the per-component bounds of
+	// the finite observations.
+	ObsMin []float64
+	ObsMax []float64
+
+	// ObsNaN counts observation components which were NaN
+	// or infinite.
+	ObsNaN int
+
+	// Statistics of the finite per-step rewards.
+	RewardMean float64
+	RewardStd  float64
+	RewardMin  float64
+	RewardMax  float64
+
+	// RewardNaN counts rewards which were NaN or infinite.
+	RewardNaN int
+
+	// EpisodeLens contains the length of every episode
+	// which finished during the audit.
+	EpisodeLens []int
+
+	// Warnings describes problems which should likely be
+	// addressed with wrappers (e.g. observation or reward
+	// normalization).
+	Warnings []string
+}
+
+// MeanEpisodeLen computes the mean of EpisodeLens.
+// It returns 0 if no episodes finished.
+func (e *EnvAudit) MeanEpisodeLen() float64 {
+	if len(e.EpisodeLens) == 0 {
+		return 0
+	}
+	var sum int
+	for _, l := range e.EpisodeLens {
+		sum += l
+	}
+	return float64(sum) / float64(len(e.EpisodeLens))
+}
+
+// An EnvAuditor rolls out a random policy in environments
+// to find observations and rewards which need
+// normalization before training.
+type EnvAuditor struct {
+	// ActionSpace and ParamSize are used to sample random
+	// actions by passing all-zero parameters to the
+	// action space (e.g. a uniform Softmax or a
+	// unit-variance Gaussian).
+	//
+	// If ActionSpace is nil, the environment must be a
+	// SpaceEnv, and ActionSpaceFor and ActionFilterFor
+	// are used to pick the action space.
+	ActionSpace Sampler
+	ParamSize   int
+
+	// ObsScale is the largest absolute observation which
+	// is considered normalized.
+	//
+	// If 0, DefaultAuditObsScale is used.
+	ObsScale float64
+
+	// RewardScale is the largest absolute reward which is
+	// considered normalized.
+	//
+	// If 0, DefaultAuditRewardScale is used.
+	RewardScale float64
+}
+
+// Audit runs numSteps timesteps of a random policy in the
+// environment and summarizes the results.
+func (e *EnvAuditor) Audit(env Env, numSteps int) (audit *EnvAudit, err error) {
+	defer essentials.AddCtxTo("audit environment", &err)
+
+	sampler, paramSize, filter, err := e.actionSampler(env)
+	if err != nil {
+		return nil, err
+	}
+	c := anyvec64.DefaultCreator{}
+	params := c.MakeVector(paramSize)
+
+	audit = &EnvAudit{}
+	var rewardSum, rewardSqSum float64
+	var numRewards int
+	var epLen int
+
+	obs, err := env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	audit.recordObs(obs)
+	for audit.Steps < numSteps {
+		action := sampler.Sample(params, 1)
+		if filter != nil {
+			action = filter.FilterActions(action, 1)
+		}
+		var reward float64
+		var done bool
+		obs, reward, done, err = env.Step(c.Float64Slice(action.Data()))
+		if err != nil {
+			return nil, err
+		}
+		audit.Steps++
+		epLen++
+		audit.recordObs(obs)
+
+		if math.IsNaN(reward) || math.IsInf(reward, 0) {
+			audit.RewardNaN++
+		} else {
+			if numRewards == 0 || reward < audit.RewardMin {
+				audit.RewardMin = reward
+			}
+			if numRewards == 0 || reward > audit.RewardMax {
+				audit.RewardMax = reward
+			}
+			rewardSum += reward
+			rewardSqSum += reward * reward
+			numRewards++
+		}
+
+		if done {
+			audit.EpisodeLens = append(audit.EpisodeLens, epLen)
+			epLen = 0
+			if audit.Steps < numSteps {
+				obs, err = env.Reset()
+				if err != nil {
+					return nil, err
+				}
+				audit.recordObs(obs)
+			}
+		}
+	}
+
+	if numRewards > 0 {
+		audit.RewardMean = rewardSum / float64(numRewards)
+		variance := rewardSqSum/float64(numRewards) - audit.RewardMean*audit.RewardMean
+		audit.RewardStd = math.Sqrt(math.Max(0, variance))
+	}
+	e.addWarnings(audit)
+	return audit, nil
+}
+
+func (e *EnvAuditor) actionSampler(env Env) (Sampler, int, ActionFilter, error) {
+	if e.ActionSpace != nil {
+		return e.ActionSpace, e.ParamSize, nil, nil
+	}
+	spaceEnv, ok := env.(SpaceEnv)
+	if !ok {
+		return nil, 0, nil, errors.New("no action space for environment")
+	}
+	actionSpace, paramSize, err := ActionSpaceFor(spaceEnv.ActionSpace())
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	sampler, ok := actionSpace.(Sampler)
+	if !ok {
+		return nil, 0, nil, fmt.Errorf("action space %T cannot sample", actionSpace)
+	}
+	return sampler, paramSize, ActionFilterFor(spaceEnv.ActionSpace()), nil
+}
+
+func (e *EnvAuditor) addWarnings(a *EnvAudit) {
+	if a.ObsNaN > 0 {
+		a.Warnings = append(a.Warnings,
+			fmt.Sprintf("%d non-finite observation components", a.ObsNaN))
+	}
+	if a.RewardNaN > 0 {
+		a.Warnings = append(a.Warnings, fmt.Sprintf("%d non-finite rewards", a.RewardNaN))
+	}
+	var maxObs float64
+	for i, low := range a.ObsMin {
+		if low > a.ObsMax[i] {
+			// The component was never finite.
+			continue
+		}
+		maxObs = math.Max(maxObs, math.Max(math.Abs(low), math.Abs(a.ObsMax[i])))
+	}
+	if maxObs > e.obsScale() {
+		a.Warnings = append(a.Warnings,
+			fmt.Sprintf("observations reach %g; consider ObsNorm", maxObs))
+	}
+	maxReward := math.Max(math.Abs(a.RewardMin), math.Abs(a.RewardMax))
+	if maxReward > e.rewardScale() {
+		a.Warnings = append(a.Warnings,
+			fmt.Sprintf("rewards reach %g; consider scaling rewards", maxReward))
+	}
+	if len(a.EpisodeLens) == 0 {
+		a.Warnings = append(a.Warnings, "no episodes finished; consider MaxStepsEnv")
+	}
+}
+
+func (e *EnvAuditor) obsScale() float64 {
+	if e.ObsScale == 0 {
+		return DefaultAuditObsScale
+	}
+	return e.ObsScale
+}
+
+func (e *EnvAuditor) rewardScale() float64 {
+	if e.RewardScale == 0 {
+		return DefaultAuditRewardScale
+	}
+	return e.RewardScale
+}
+
+func (e *EnvAudit) recordObs(obs []float64) {
+	if e.ObsMin == nil {
+		e.ObsMin = make([]float64, len(obs))
+		e.ObsMax = make([]float64, len(obs))
+		for i := range obs {
+			e.ObsMin[i] = math.Inf(1)
+			e.ObsMax[i] = math.Inf(-1)
+		}
+	}
+	for i, x := range obs {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			e.ObsNaN++
+			continue
+		}
+		e.ObsMin[i] = math.Min(e.ObsMin[i], x)
+		e.ObsMax[i] = math.Max(e.ObsMax[i], x)
+	}
+}
@@ -0,0 +1,38 @@
+package anyrl
+
+import "testing"
+
+func TestEnvAuditor(t *testing.T) {
+	env := &rnnTestEnv{RewardScale: 100, EpLen: 4, Observation: []float64{1, -20, 0}}
+	auditor := &EnvAuditor{ActionSpace: Softmax{}, ParamSize: 1}
+	audit, err := auditor.Audit(env, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audit.Steps != 10 {
+		t.Errorf("expected 10 steps but got %d", audit.Steps)
+	}
+	if len(audit.EpisodeLens) != 2 || audit.MeanEpisodeLen() != 4 {
+		t.Errorf("unexpected episode lengths: %v", audit.EpisodeLens)
+	}
+	expectedMin := []float64{1, -80, 0}
+	expectedMax := []float64{4, -20, 0}
+	for i, x := range expectedMin {
+		if audit.ObsMin[i] != x || audit.ObsMax[i] != expectedMax[i] {
+			t.Errorf("component %d: expected range [%f, %f] but got [%f, %f]", i,
+				x, expectedMax[i], audit.ObsMin[i], audit.ObsMax[i])
+		}
+	}
+	// With one action, the reward is always 0.
+	if audit.RewardMin != 0 || audit.RewardMax != 0 || audit.RewardStd != 0 {
+		t.Errorf("unexpected reward statistics: %+v", audit)
+	}
+	if audit.ObsNaN != 0 || audit.RewardNaN != 0 {
+		t.Error("unexpected non-finite values")
+	}
+
+	// Only the observations are too large.
+	if len(audit.Warnings) != 1 {
+		t.Errorf("unexpected warnings: %v", audit.Warnings)
+	}
+}
@@ -0,0 +1,68 @@
+package anyrl
+
+import "math/rand"
+
+// DefaultImageShiftPad is the default padding used by
+// ImageShift.
+const DefaultImageShiftPad = 4
+
+// An Augmenter randomly perturbs observations, e.g. for
+// data augmentation at training time.
+//
+// Augment must not modify its argument.
+type Augmenter interface {
+	Augment(obs []float64) []float64
+}
+
+// ImageShift is an Augmenter which randomly shifts image
+// observations, as in DrQ.
+//
+// Images are stored in row-major order with interleaved
+// channels, as in the anyconv package.
+// Shifting is equivalent to padding the image by
+// replicating its edges and then randomly cropping it
+// back to the original size.
+type ImageShift struct {
+	Width  int
+	Height int
+	Depth  int
+
+	// Pad is the maximum shift in each direction.
+	//
+	// If 0, DefaultImageShiftPad is used.
+	Pad int
+}
+
+// Augment randomly shifts the image.
+func (i *ImageShift) Augment(obs []float64) []float64 {
+	pad := i.Pad
+	if pad == 0 {
+		pad = DefaultImageShiftPad
+	}
+	dx := rand.Intn(2*pad+1) - pad
+	dy := rand.Intn(2*pad+1) - pad
+	return i.shift(obs, dx, dy)
+}
+
+func (i *ImageShift) shift(obs []float64, dx, dy int) []float64 {
+	res := make([]float64, len(obs))
+	for y := 0; y < i.Height; y++ {
+		srcY := clampInt(y+dy, 0, i.Height-1)
+		for x := 0; x < i.Width; x++ {
+			srcX := clampInt(x+dx, 0, i.Width-1)
+			src := (srcY*i.Width + srcX) * i.Depth
+			dst := (y*i.Width + x) * i.Depth
+			copy(res[dst:dst+i.Depth], obs[src:src+i.Depth])
+		}
+	}
+	return res
+}
+
+func clampInt(x, min, max int) int {
+	if x < min {
+		return min
+	} else if x > max {
+		return max
+	}
+	return x
+}
@@ -0,0 +1,25 @@
+package anyrl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImageShift(t *testing.T) {
+	shift := &ImageShift{Width: 3, Height: 2, Depth: 2}
+	image := []float64{
+		1, -1, 2, -2, 3, -3,
+		4, -4, 5, -5, 6, -6,
+	}
+	actual := shift.shift(image, 1, -1)
+	expected := []float64{
+		2, -2, 3, -3, 3, -3,
+		2, -2, 3, -3, 3, -3,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+	if !reflect.DeepEqual(shift.shift(image, 0, 0), image) {
+		t.Error("zero shift should be the identity")
+	}
+}
@@ -0,0 +1,107 @@
+package anyrl
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+)
+
+// Autoregressive is a multi-discrete action space in
+// which later components of an action are conditioned on
+// the components sampled before them.
+//
+// The agent's output is treated as a context vector.
+// Components are sampled one at a time (in Order), and
+// each component's logits are produced by a small head
+// network from the context concatenated with the one-hot
+// vectors of the previously sampled components.
+//
+// Samples are concatenated one-hot vectors, laid out in
+// the order of Sizes regardless of the sampling order.
+// The log-probability of a sample is the exact joint
+// log-probability, computed with the same conditioning
+// that was used while sampling.
+type Autoregressive struct {
+	// Sizes contains the number of choices for each
+	// component.
+	Sizes []int
+
+	// Order is the order in which components are
+	// sampled.
+	//
+	// If nil, components are sampled in the order of
+	// Sizes.
+	Order []int
+
+	// Heads contains one network per component, in
+	// sampling order.
+	// Heads[k] maps the context followed by the one-hot
+	// vectors of the first k sampled components (in
+	// sampling order) to the logits for component
+	// Order[k].
+	Heads []anynet.Layer
+}
+
+// Sample samples a batch of actions.
+func (a *Autoregressive) Sample(params anyvec.Vector, batch int) anyvec.Vector {
+	samples := make([]anyvec.Vector, len(a.Sizes))
+	cond := params
+	for k, comp := range a.order() {
+		logits := a.Heads[k].Apply(anydiff.NewConst(cond), batch).Output()
+		samples[comp] = Softmax{}.Sample(logits, batch)
+		cond = packTuples([]anyvec.Vector{cond, samples[comp]}, batch)
+	}
+	return packTuples(samples, batch)
+}
+
+// LogProb computes the joint log-probabilities of the
+// actions.
+func (a *Autoregressive) LogProb(params anydiff.Res, output anyvec.Vector,
+	batch int) anydiff.Res {
+	outputs := unpackTuples(anydiff.NewConst(output), a.Sizes, batch)
+	return anydiff.Pool(params, func(params anydiff.Res) anydiff.Res {
+		var prefix []anyvec.Vector
+		var res anydiff.Res
+		for k, comp := range a.order() {
+			cond := params
+			if k > 0 {
+				cond = anynet.ConcatMixer{}.Mix(params,
+					anydiff.NewConst(packTuples(prefix, batch)), batch)
+			}
+			logits := a.Heads[k].Apply(cond, batch)
+			logProb := Softmax{}.LogProb(logits, outputs[comp].Output(), batch)
+			if res == nil {
+				res = logProb
+			} else {
+				res = anydiff.Add(res, logProb)
+			}
+			prefix = append(prefix, outputs[comp].Output())
+		}
+		return res
+	})
+}
+
+// Parameters returns the parameters of the heads.
+func (a *Autoregressive) Parameters() []*anydiff.Var {
+	var res []*anydiff.Var
+	for _, head := range a.Heads {
+		if p, ok := head.(anynet.Parameterizer); ok {
+			res = append(res, p.Parameters()...)
+		}
+	}
+	return res
+}
+
+func (a *Autoregressive) order() []int {
+	if a.Heads != nil && len(a.Heads) != len(a.Sizes) {
+		panic("need one head per component")
+	}
+	if a.Order != nil {
+		return a.Order
+	}
+	res := make([]int, len(a.Sizes))
+	for i := range res {
+		res[i] = i
+	}
+	return res
+}
@@ -0,0 +1,43 @@
+package anyrl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestAutoregressive(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	space := &Autoregressive{
+		Sizes: []int{2, 3},
+		Order: []int{1, 0},
+		Heads: []anynet.Layer{
+			anynet.NewFC(c, 4, 3),
+			anynet.NewFC(c, 7, 2),
+		},
+	}
+	params := anyvec.Make(c, []float64{0.5, -1, 2, 0.3})
+
+	sample := c.Float64Slice(space.Sample(params, 1).Data())
+	if len(sample) != 5 || sample[0]+sample[1] != 1 || sample[2]+sample[3]+sample[4] != 1 {
+		t.Errorf("invalid sample: %v", sample)
+	}
+
+	var total float64
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 3; j++ {
+			action := make([]float64, 5)
+			action[i] = 1
+			action[2+j] = 1
+			logProb := space.LogProb(anydiff.NewConst(params), anyvec.Make(c, action), 1)
+			total += math.Exp(c.Float64Slice(logProb.Output().Data())[0])
+		}
+	}
+	if math.Abs(total-1) > 1e-8 {
+		t.Errorf("probabilities sum to %f", total)
+	}
+}
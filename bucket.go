@@ -0,0 +1,105 @@
+package anyrl
+
+import (
+	"sort"
+
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// BucketRollouts splits a RolloutSet into smaller sets of
+// episodes with similar lengths.
+//
+// Episodes are sorted by length and divided into at most
+// numBuckets buckets of roughly equal size.
+// Training on each bucket separately wastes less
+// computation on timesteps where most episodes have
+// already ended, which helps when episode lengths vary
+// greatly.
+//
+// Empty episodes are dropped.
+func BucketRollouts(r *RolloutSet, numBuckets int) []*RolloutSet {
+	var indices []int
+	for i, seq := range r.Rewards {
+		if len(seq) > 0 {
+			indices = append(indices, i)
+		}
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return len(r.Rewards[indices[i]]) < len(r.Rewards[indices[j]])
+	})
+
+	if numBuckets > len(indices) {
+		numBuckets = len(indices)
+	}
+	var res []*RolloutSet
+	for i := 0; i < numBuckets; i++ {
+		start := i * len(indices) / numBuckets
+		end := (i + 1) * len(indices) / numBuckets
+		res = append(res, SelectRollouts(r, indices[start:end]))
+	}
+	return res
+}
+
+// SelectRollouts creates a RolloutSet containing a subset
+// of the episodes in r, in the given order.
+//
+// Unlike FracReducer, the resulting tapes only contain
+// entries for the selected episodes.
+func SelectRollouts(r *RolloutSet, indices []int) *RolloutSet {
+	res := &RolloutSet{
+		Inputs:  selectTape(r.Inputs, indices),
+		Actions: selectTape(r.Actions, indices),
+	}
+	if r.AgentOuts != nil {
+		res.AgentOuts = selectTape(r.AgentOuts, indices)
+	}
+	for _, idx := range indices {
+		res.Rewards = append(res.Rewards, r.Rewards[idx])
+	}
+	selectEpisodeFields(res, []*RolloutSet{r}, [][]int{indices})
+	return res
+}
+
+func selectTape(t lazyseq.Tape, indices []int) lazyseq.Tape {
+	c := t.Creator()
+	res, writer := lazyseq.ReferenceTape(c)
+	go func() {
+		defer close(writer)
+		for batch := range t.ReadTape(0, -1) {
+			chunks := splitBatch(batch)
+			present := make([]bool, len(indices))
+			var packed []anyvec.Vector
+			for i, idx := range indices {
+				if chunk := chunks[idx]; chunk != nil {
+					present[i] = true
+					packed = append(packed, chunk)
+				}
+			}
+			if len(packed) > 0 {
+				writer <- &anyseq.Batch{Packed: c.Concat(packed...), Present: present}
+			}
+		}
+	}()
+	return res
+}
+
+// splitBatch splits a batch into one vector per sequence,
+// using nil for absent sequences.
+func splitBatch(b *anyseq.Batch) []anyvec.Vector {
+	res := make([]anyvec.Vector, len(b.Present))
+	numPresent := b.NumPresent()
+	if numPresent == 0 {
+		return res
+	}
+	size := b.Packed.Len() / numPresent
+	var offset int
+	for i, pres := range b.Present {
+		if pres {
+			res[i] = b.Packed.Slice(offset, offset+size)
+			offset += size
+		}
+	}
+	return res
+}
@@ -0,0 +1,79 @@
+package anyrl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestBucketRollouts(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	obs := Rewards{{1, 2, 3}, {4}, {}, {5, 6}, {7, 8, 9, 10}}
+	r := &RolloutSet{
+		Inputs:  obs.Tape(c),
+		Actions: obs.Tape(c),
+		Rewards: obs,
+		Weights: []float64{1, 2, 3, 4, 5},
+	}
+	buckets := BucketRollouts(r, 2)
+	expected := []Rewards{
+		{{4}, {5, 6}},
+		{{1, 2, 3}, {7, 8, 9, 10}},
+	}
+	expectedWeights := [][]float64{{2, 4}, {1, 5}}
+	if len(buckets) != len(expected) {
+		t.Fatalf("expected %d buckets but got %d", len(expected), len(buckets))
+	}
+	for i, bucket := range buckets {
+		if !reflect.DeepEqual(bucket.Rewards, expected[i]) {
+			t.Errorf("bucket %d: expected rewards %v but got %v", i, expected[i],
+				bucket.Rewards)
+		}
+		if !reflect.DeepEqual(bucket.Weights, expectedWeights[i]) {
+			t.Errorf("bucket %d: expected weights %v but got %v", i,
+				expectedWeights[i], bucket.Weights)
+		}
+		actualObs := make(Rewards, len(bucket.Rewards))
+		for batch := range bucket.Inputs.ReadTape(0, -1) {
+			if len(batch.Present) != len(actualObs) {
+				t.Fatalf("bucket %d: bad present map %v", i, batch.Present)
+			}
+			values := c.Float64Slice(batch.Packed.Data())
+			for j, pres := range batch.Present {
+				if pres {
+					actualObs[j] = append(actualObs[j], values[0])
+					values = values[1:]
+				}
+			}
+		}
+		if !reflect.DeepEqual(actualObs, expected[i]) {
+			t.Errorf("bucket %d: expected inputs %v but got %v", i, expected[i],
+				actualObs)
+		}
+	}
+}
+
+func TestReturnPruner(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	rewards := Rewards{{1, 2}, {-5}, {3}, {0.5}, {10}}
+	r := &RolloutSet{Inputs: rewards.Tape(c), Actions: rewards.Tape(c), Rewards: rewards}
+
+	pruner := &ReturnPruner{
+		Threshold: func(iter int) float64 {
+			return float64(iter)
+		},
+		MaxFrac: 0.75,
+	}
+	actual := pruner.Prune(r).Rewards
+	expected := Rewards{{1, 2}, {3}, {0.5}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+
+	actual = pruner.Prune(r).Rewards
+	expected = Rewards{{1, 2}, {3}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
@@ -0,0 +1,134 @@
+package anyrl
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A Budget limits how long a training loop runs.
+//
+// The training loop should report its progress with
+// AddSteps (or AddRollouts) and AddUpdate, and it should
+// stop once Exhausted returns true.
+//
+// All of the methods are safe to call concurrently.
+type Budget struct {
+	// MaxSteps, if non-zero, limits the total number of
+	// environment steps.
+	MaxSteps int
+
+	// MaxTime, if non-zero, limits the wall-clock time
+	// since the first call to any of the methods.
+	MaxTime time.Duration
+
+	// MaxUpdates, if non-zero, limits the number of
+	// parameter updates.
+	MaxUpdates int
+
+	// OnExhausted, if non-nil, is called exactly once
+	// when Exhausted first detects that the budget has
+	// run out.
+	// It can be used to save a final checkpoint and log
+	// the summary.
+	OnExhausted func(summary *BudgetSummary)
+
+	lock      sync.Mutex
+	start     time.Time
+	steps     int
+	updates   int
+	exhausted bool
+}
+
+// BudgetSummary summarizes the resources used in a
+// training run.
+type BudgetSummary struct {
+	Steps   int
+	Updates int
+	Elapsed time.Duration
+
+	// Reason describes which limit was reached, or is
+	// empty if the budget is not exhausted.
+	Reason string
+}
+
+// String returns a human-readable summary.
+func (b *BudgetSummary) String() string {
+	res := fmt.Sprintf("steps=%d updates=%d elapsed=%v", b.Steps, b.Updates, b.Elapsed)
+	if b.Reason != "" {
+		res += " (" + b.Reason + ")"
+	}
+	return res
+}
+
+// AddSteps records environment steps.
+func (b *Budget) AddSteps(n int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.startIfNeeded()
+	b.steps += n
+}
+
+// AddRollouts records the environment steps in a batch
+// of rollouts.
+func (b *Budget) AddRollouts(r *RolloutSet) {
+	b.AddSteps(r.NumSteps())
+}
+
+// AddUpdate records a parameter update.
+func (b *Budget) AddUpdate() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.startIfNeeded()
+	b.updates++
+}
+
+// Exhausted checks if any limit has been reached.
+//
+// The first time this returns true, b.OnExhausted is
+// called (if it is non-nil) before returning.
+func (b *Budget) Exhausted() bool {
+	b.lock.Lock()
+	summary := b.summary()
+	if summary.Reason == "" || b.exhausted {
+		b.lock.Unlock()
+		return summary.Reason != ""
+	}
+	b.exhausted = true
+	b.lock.Unlock()
+
+	if b.OnExhausted != nil {
+		b.OnExhausted(summary)
+	}
+	return true
+}
+
+// Summary summarizes the resources used so far.
+func (b *Budget) Summary() *BudgetSummary {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.summary()
+}
+
+func (b *Budget) summary() *BudgetSummary {
+	b.startIfNeeded()
+	res := &BudgetSummary{
+		Steps:   b.steps,
+		Updates: b.updates,
+		Elapsed: time.Since(b.start),
+	}
+	if b.MaxSteps != 0 && res.Steps >= b.MaxSteps {
+		res.Reason = "step limit reached"
+	} else if b.MaxUpdates != 0 && res.Updates >= b.MaxUpdates {
+		res.Reason = "update limit reached"
+	} else if b.MaxTime != 0 && res.Elapsed >= b.MaxTime {
+		res.Reason = "time limit reached"
+	}
+	return res
+}
+
+func (b *Budget) startIfNeeded() {
+	if b.start.IsZero() {
+		b.start = time.Now()
+	}
+}
@@ -0,0 +1,46 @@
+package anyrl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	var calls int
+	var final *BudgetSummary
+	b := &Budget{
+		MaxSteps:   100,
+		MaxUpdates: 3,
+		MaxTime:    time.Hour,
+		OnExhausted: func(s *BudgetSummary) {
+			calls++
+			final = s
+		},
+	}
+	for i := 0; i < 2; i++ {
+		b.AddSteps(30)
+		b.AddUpdate()
+		if b.Exhausted() {
+			t.Fatalf("budget exhausted early at iteration %d", i)
+		}
+	}
+	b.AddSteps(50)
+	for i := 0; i < 2; i++ {
+		if !b.Exhausted() {
+			t.Fatal("budget should be exhausted")
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call but got %d", calls)
+	}
+	if final.Steps != 110 || final.Updates != 2 || final.Reason != "step limit reached" {
+		t.Errorf("unexpected summary: %s", final)
+	}
+
+	b = &Budget{MaxTime: time.Millisecond}
+	b.AddSteps(1)
+	time.Sleep(time.Millisecond * 2)
+	if !b.Exhausted() {
+		t.Error("time limit should be reached")
+	}
+}
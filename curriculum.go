@@ -0,0 +1,141 @@
+package anyrl
+
+import (
+	"sync"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A Curriculum selects or parameterizes environments
+// based on an agent's recent performance.
+type Curriculum interface {
+	// Envs returns the environments to use for the next
+	// batch of rollouts.
+	Envs() ([]Env, error)
+
+	// Update informs the curriculum of the rollouts that
+	// were produced with the latest environments.
+	Update(r *RolloutSet)
+}
+
+// CurriculumRoller gathers rollouts using environments
+// from a Curriculum, automatically feeding the resulting
+// episode statistics back into the Curriculum.
+type CurriculumRoller struct {
+	Roller     *RNNRoller
+	Curriculum Curriculum
+}
+
+// Rollout produces one rollout per environment in the
+// Curriculum's current set of environments.
+func (c *CurriculumRoller) Rollout() (rollouts *RolloutSet, err error) {
+	defer essentials.AddCtxTo("curriculum rollout", &err)
+	envs, err := c.Curriculum.Envs()
+	if err != nil {
+		return nil, err
+	}
+	rollouts, err = c.Roller.Rollout(envs...)
+	if err != nil {
+		return nil, err
+	}
+	c.Curriculum.Update(rollouts)
+	return rollouts, nil
+}
+
+// ThresholdCurriculum is a Curriculum which advances
+// through a fixed sequence of difficulty levels once the
+// success rate at the current level is high enough.
+type ThresholdCurriculum struct {
+	// MakeEnvs creates the environments for a given
+	// difficulty level.
+	MakeEnvs func(level int) ([]Env, error)
+
+	// NumLevels is the number of difficulty levels.
+	// Once the last level is reached, the curriculum
+	// stays there.
+	NumLevels int
+
+	// Success decides if an episode was a success given
+	// its total reward.
+	Success func(total float64) bool
+
+	// Threshold is the success rate required to move on
+	// to the next level.
+	Threshold float64
+
+	// Window is the number of recent episodes used to
+	// compute the success rate.
+	// The level will not advance until at least this many
+	// episodes have been seen at the current level.
+	Window int
+
+	lock      sync.Mutex
+	level     int
+	successes []bool
+	envs      []Env
+}
+
+// Envs returns the environments for the current level.
+//
+// Environments are only created once per level.
+func (t *ThresholdCurriculum) Envs() (envs []Env, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.envs == nil {
+		t.envs, err = t.MakeEnvs(t.level)
+		if err != nil {
+			t.envs = nil
+			return nil, essentials.AddCtx("curriculum envs", err)
+		}
+	}
+	return t.envs, nil
+}
+
+// Update records the successes and failures of the
+// episodes and advances the level if necessary.
+func (t *ThresholdCurriculum) Update(r *RolloutSet) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, total := range r.Rewards.Totals() {
+		t.successes = append(t.successes, t.Success(total))
+	}
+	if len(t.successes) > t.Window {
+		t.successes = t.successes[len(t.successes)-t.Window:]
+	}
+	if len(t.successes) < t.Window || t.level+1 >= t.NumLevels {
+		return
+	}
+	if t.successRate() >= t.Threshold {
+		t.level++
+		t.successes = nil
+		t.envs = nil
+	}
+}
+
+// Level returns the current difficulty level.
+func (t *ThresholdCurriculum) Level() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.level
+}
+
+// SuccessRate returns the success rate over the recent
+// episodes at the current level.
+func (t *ThresholdCurriculum) SuccessRate() float64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.successRate()
+}
+
+func (t *ThresholdCurriculum) successRate() float64 {
+	if len(t.successes) == 0 {
+		return 0
+	}
+	var count int
+	for _, s := range t.successes {
+		if s {
+			count++
+		}
+	}
+	return float64(count) / float64(len(t.successes))
+}
@@ -0,0 +1,46 @@
+package anyrl
+
+import "testing"
+
+func TestThresholdCurriculum(t *testing.T) {
+	var madeLevels []int
+	curr := &ThresholdCurriculum{
+		MakeEnvs: func(level int) ([]Env, error) {
+			madeLevels = append(madeLevels, level)
+			return []Env{nil}, nil
+		},
+		NumLevels: 2,
+		Success: func(total float64) bool {
+			return total > 0
+		},
+		Threshold: 0.5,
+		Window:    4,
+	}
+
+	if _, err := curr.Envs(); err != nil {
+		t.Fatal(err)
+	}
+	curr.Update(&RolloutSet{Rewards: Rewards{{1}, {-1}, {-1}}})
+	if curr.Level() != 0 {
+		t.Fatal("advanced before the window was full")
+	}
+	curr.Update(&RolloutSet{Rewards: Rewards{{-1}}})
+	if curr.Level() != 0 {
+		t.Fatal("advanced below the threshold")
+	}
+	curr.Update(&RolloutSet{Rewards: Rewards{{1}, {2}}})
+	if curr.Level() != 1 {
+		t.Fatal("did not advance above the threshold")
+	}
+	if _, err := curr.Envs(); err != nil {
+		t.Fatal(err)
+	}
+	curr.Update(&RolloutSet{Rewards: Rewards{{1}, {1}, {1}, {1}}})
+	if curr.Level() != 1 {
+		t.Fatal("advanced past the last level")
+	}
+
+	if len(madeLevels) != 2 || madeLevels[0] != 0 || madeLevels[1] != 1 {
+		t.Errorf("unexpected levels created: %v", madeLevels)
+	}
+}
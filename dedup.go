@@ -0,0 +1,83 @@
+package anyrl
+
+import (
+	"hash"
+	"hash/fnv"
+	"math"
+
+	"github.com/unixpickle/lazyseq"
+)
+
+// HashRollouts computes a content hash for every episode
+// in a RolloutSet, covering its inputs, actions, and
+// rewards.
+//
+// If quantum is non-zero, every value is rounded to the
+// nearest multiple of quantum before it is hashed, so
+// that episodes which differ only by small numerical
+// noise usually get the same hash.
+// Values near a rounding boundary may still hash
+// differently.
+func HashRollouts(r *RolloutSet, quantum float64) []uint64 {
+	hashes := make([]hash.Hash64, len(r.Rewards))
+	for i, seq := range r.Rewards {
+		hashes[i] = fnv.New64a()
+		writeFloats(hashes[i], []float64{float64(len(seq))}, 0)
+	}
+	c := r.Creator()
+	for _, tape := range []lazyseq.Tape{r.Inputs, r.Actions} {
+		for batch := range tape.ReadTape(0, -1) {
+			for i, vec := range splitBatch(batch) {
+				if vec != nil {
+					writeFloats(hashes[i], c.Float64Slice(vec.Data()), quantum)
+				}
+			}
+		}
+	}
+	res := make([]uint64, len(hashes))
+	for i, seq := range r.Rewards {
+		writeFloats(hashes[i], seq, quantum)
+		res[i] = hashes[i].Sum64()
+	}
+	return res
+}
+
+// DedupRollouts removes duplicate episodes from a
+// RolloutSet, keeping the first copy of each episode.
+//
+// Episodes are compared with HashRollouts, so quantum
+// controls how similar two episodes must be to count as
+// duplicates.
+// This is useful for cleaning logged datasets before
+// offline training.
+func DedupRollouts(r *RolloutSet, quantum float64) *RolloutSet {
+	seen := map[uint64]bool{}
+	var indices []int
+	for i, h := range HashRollouts(r, quantum) {
+		if !seen[h] {
+			seen[h] = true
+			indices = append(indices, i)
+		}
+	}
+	return SelectRollouts(r, indices)
+}
+
+// writeFloats writes the bits of each value to a hash,
+// rounding to a multiple of quantum if it is non-zero.
+func writeFloats(h hash.Hash, values []float64, quantum float64) {
+	var buf [8]byte
+	for _, x := range values {
+		if quantum != 0 {
+			x = math.Floor(x/quantum + 0.5)
+		}
+		if x == 0 {
+			// Treat -0 and +0 the same.
+			x = 0
+		}
+		bits := math.Float64bits(x)
+		for i := range buf {
+			buf[i] = byte(bits >> uint(8*i))
+		}
+		h.Write(buf[:])
+	}
+}
@@ -0,0 +1,42 @@
+package anyrl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestDedupRollouts(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	obs := Rewards{{1, 2, 3}, {1, 2, 3}, {1, 2, 3.001}, {1, 2}, {}, {}}
+	r := &RolloutSet{
+		Inputs:  obs.Tape(c),
+		Actions: obs.Tape(c),
+		Rewards: obs,
+	}
+
+	exact := DedupRollouts(r, 0)
+	expected := Rewards{{1, 2, 3}, {1, 2, 3.001}, {1, 2}, {}}
+	if !reflect.DeepEqual(exact.Rewards, expected) {
+		t.Errorf("expected %v but got %v", expected, exact.Rewards)
+	}
+
+	near := DedupRollouts(r, 0.1)
+	expected = Rewards{{1, 2, 3}, {1, 2}, {}}
+	if !reflect.DeepEqual(near.Rewards, expected) {
+		t.Errorf("expected %v but got %v", expected, near.Rewards)
+	}
+
+	// Episodes with equal rewards but different inputs
+	// are not duplicates.
+	otherObs := Rewards{{1, 2, 3}, {3, 2, 1}}
+	r = &RolloutSet{
+		Inputs:  otherObs.Tape(c),
+		Actions: otherObs.Tape(c),
+		Rewards: Rewards{{0, 0, 0}, {0, 0, 0}},
+	}
+	if hashes := HashRollouts(r, 0); hashes[0] == hashes[1] {
+		t.Error("expected different hashes")
+	}
+}
@@ -0,0 +1,161 @@
+package anyrl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/lazyseq"
+)
+
+// A DictEnv is an environment with structured
+// observations, where each observation is made up of
+// named components (e.g. "image" and "proprio").
+//
+// Use ObsLayout.Env to turn a DictEnv into an Env.
+type DictEnv interface {
+	ResetDict() (observation map[string][]float64, err error)
+	StepDict(action []float64) (observation map[string][]float64,
+		reward float64, done bool, err error)
+}
+
+// ObsLayout describes how named observation components
+// are packed into flat observation vectors.
+//
+// Components are stored in the order of Names, and each
+// component has a fixed size given by the corresponding
+// entry in Sizes.
+type ObsLayout struct {
+	Names []string
+	Sizes []int
+}
+
+// Flatten packs a structured observation into a vector.
+func (o *ObsLayout) Flatten(obs map[string][]float64) ([]float64, error) {
+	var res []float64
+	for i, name := range o.Names {
+		comp, ok := obs[name]
+		if !ok {
+			return nil, fmt.Errorf("flatten observation: missing component %q", name)
+		}
+		if len(comp) != o.Sizes[i] {
+			return nil, fmt.Errorf("flatten observation: component %q should have "+
+				"size %d but has size %d", name, o.Sizes[i], len(comp))
+		}
+		res = append(res, comp...)
+	}
+	return res, nil
+}
+
+// Unflatten splits a flat vector into named components.
+func (o *ObsLayout) Unflatten(obs []float64) (map[string][]float64, error) {
+	if len(obs) != o.size() {
+		return nil, errors.New("unflatten observation: incorrect vector size")
+	}
+	res := map[string][]float64{}
+	var offset int
+	for i, name := range o.Names {
+		res[name] = obs[offset : offset+o.Sizes[i]]
+		offset += o.Sizes[i]
+	}
+	return res, nil
+}
+
+// Env creates an Env which flattens the observations of
+// a DictEnv.
+// The result can be used with RNNRoller and the other
+// tools which expect flat observations.
+func (o *ObsLayout) Env(e DictEnv) Env {
+	return &flatDictEnv{Layout: o, Env: e}
+}
+
+// Split creates one input tape per component from the
+// flat input tape of a RolloutSet.
+func (o *ObsLayout) Split(r *RolloutSet) map[string]lazyseq.Tape {
+	res := map[string]lazyseq.Tape{}
+	var offset int
+	for i, name := range o.Names {
+		res[name] = SliceTape(r.Inputs, offset, offset+o.Sizes[i])
+		offset += o.Sizes[i]
+	}
+	return res
+}
+
+func (o *ObsLayout) size() int {
+	var res int
+	for _, s := range o.Sizes {
+		res += s
+	}
+	return res
+}
+
+type flatDictEnv struct {
+	Layout *ObsLayout
+	Env    DictEnv
+}
+
+func (f *flatDictEnv) Reset() ([]float64, error) {
+	obs, err := f.Env.ResetDict()
+	if err != nil {
+		return nil, err
+	}
+	return f.Layout.Flatten(obs)
+}
+
+func (f *flatDictEnv) Step(action []float64) ([]float64, float64, bool, error) {
+	obs, rew, done, err := f.Env.StepDict(action)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	flat, err := f.Layout.Flatten(obs)
+	return flat, rew, done, err
+}
+
+// DictLayer is an anynet.Layer which combines the
+// components of flat structured observations.
+//
+// Each component is fed through its own encoder, and the
+// encoded components are concatenated (in layout order)
+// to produce the output for each observation.
+// This makes it possible to use different networks for
+// different modalities, e.g. a CNN for images and an MLP
+// for proprioceptive features.
+type DictLayer struct {
+	Layout *ObsLayout
+
+	// Encoders maps component names to layers.
+	// Components without an encoder are passed through
+	// unchanged.
+	Encoders map[string]anynet.Layer
+}
+
+// Apply applies the layer to a batch of flat
+// observations.
+func (d *DictLayer) Apply(in anydiff.Res, batch int) anydiff.Res {
+	return anydiff.Pool(in, func(in anydiff.Res) anydiff.Res {
+		var res anydiff.Res
+		for i, comp := range unpackTuples(in, d.Layout.Sizes, batch) {
+			if enc, ok := d.Encoders[d.Layout.Names[i]]; ok {
+				comp = enc.Apply(comp, batch)
+			}
+			if res == nil {
+				res = comp
+			} else {
+				res = anynet.ConcatMixer{}.Mix(res, comp, batch)
+			}
+		}
+		return res
+	})
+}
+
+// Parameters returns the parameters of the encoders.
+func (d *DictLayer) Parameters() []*anydiff.Var {
+	var res []*anydiff.Var
+	for _, name := range d.Layout.Names {
+		if enc, ok := d.Encoders[name]; ok {
+			res = append(res, anynet.AllParameters(enc)...)
+		}
+	}
+	return res
+}
@@ -0,0 +1,55 @@
+package anyrl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestObsLayoutFlatten(t *testing.T) {
+	layout := &ObsLayout{Names: []string{"image", "proprio"}, Sizes: []int{3, 2}}
+	obs := map[string][]float64{"proprio": {4, 5}, "image": {1, 2, 3}}
+	flat, err := layout.Flatten(obs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(flat, []float64{1, 2, 3, 4, 5}) {
+		t.Errorf("unexpected flat observation: %v", flat)
+	}
+	unflat, err := layout.Unflatten(flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unflat, obs) {
+		t.Errorf("expected %v but got %v", obs, unflat)
+	}
+	if _, err := layout.Flatten(map[string][]float64{"image": {1, 2, 3}}); err == nil {
+		t.Error("expected error for missing component")
+	}
+}
+
+func TestDictLayer(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	layer := &DictLayer{
+		Layout: &ObsLayout{Names: []string{"a", "b"}, Sizes: []int{2, 1}},
+		Encoders: map[string]anynet.Layer{
+			"a": anynet.Net{anynet.NewFC(c, 2, 1)},
+		},
+	}
+	if len(layer.Parameters()) != 2 {
+		t.Fatalf("expected 2 parameters but got %d", len(layer.Parameters()))
+	}
+	fc := layer.Encoders["a"].(anynet.Net)[0].(*anynet.FC)
+	fc.Weights.Vector.SetData(c.MakeNumericList([]float64{1, 2}))
+	fc.Biases.Vector.SetData(c.MakeNumericList([]float64{0.5}))
+
+	in := anydiff.NewConst(c.MakeVectorData(c.MakeNumericList([]float64{1, 1, 3, 2, 0, -1})))
+	actual := c.Float64Slice(layer.Apply(in, 2).Output().Data())
+	expected := []float64{3.5, 3, 2.5, -1}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
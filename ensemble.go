@@ -0,0 +1,187 @@
+package anyrl
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+)
+
+// Ensemble is an anynet.Layer which applies several
+// policies to the same inputs.
+//
+// For each input, the output is the concatenation of the
+// members' outputs, in order.
+// Use EnsembleSpace to sample actions from the result.
+type Ensemble struct {
+	Members []anynet.Layer
+}
+
+// Apply applies every member to the inputs.
+func (e *Ensemble) Apply(in anydiff.Res, batch int) anydiff.Res {
+	return anydiff.Pool(in, func(in anydiff.Res) anydiff.Res {
+		var res anydiff.Res
+		for _, member := range e.Members {
+			out := member.Apply(in, batch)
+			if res == nil {
+				res = out
+			} else {
+				res = anynet.ConcatMixer{}.Mix(res, out, batch)
+			}
+		}
+		return res
+	})
+}
+
+// Parameters returns the parameters of every member.
+func (e *Ensemble) Parameters() []*anydiff.Var {
+	var res []*anydiff.Var
+	for _, member := range e.Members {
+		res = append(res, anynet.AllParameters(member)...)
+	}
+	return res
+}
+
+// EnsembleMode determines how an EnsembleSpace combines
+// the members' action distributions.
+type EnsembleMode int
+
+// These are the supported combination strategies.
+const (
+	// EnsembleMean averages the parameters (e.g. logits)
+	// of the members and samples from the result.
+	EnsembleMean EnsembleMode = iota
+
+	// EnsembleMixture samples from a uniformly random
+	// member for each input.
+	EnsembleMixture
+
+	// EnsembleVote samples from every member and picks
+	// the most common action.
+	// Ties are broken in favor of earlier members.
+	EnsembleVote
+)
+
+// EnsembleSpace is a Sampler for the outputs of an
+// Ensemble.
+type EnsembleSpace struct {
+	// Space is the action space of each member.
+	// It must be a Sampler.
+	Space interface{}
+
+	// Members is the number of ensemble members.
+	Members int
+
+	Mode EnsembleMode
+}
+
+// Sample samples actions according to s.Mode.
+func (s *EnsembleSpace) Sample(params anyvec.Vector, batch int) anyvec.Vector {
+	sampler := s.Space.(Sampler)
+	if s.Mode == EnsembleMean {
+		return sampler.Sample(s.Mean(anydiff.NewConst(params), batch).Output(), batch)
+	}
+
+	var samples []anyvec.Vector
+	for _, member := range s.unpack(anydiff.NewConst(params), batch) {
+		samples = append(samples, sampler.Sample(member.Output(), batch))
+	}
+	c := params.Creator()
+	sampleSize := samples[0].Len() / batch
+	var res []anyvec.Vector
+	for i := 0; i < batch; i++ {
+		var choices []anyvec.Vector
+		for _, sample := range samples {
+			choices = append(choices, sample.Slice(i*sampleSize, (i+1)*sampleSize))
+		}
+		if s.Mode == EnsembleMixture {
+			res = append(res, choices[rand.Intn(len(choices))])
+		} else {
+			res = append(res, majorityVote(c, choices))
+		}
+	}
+	if len(res) == 0 {
+		return c.MakeVector(0)
+	}
+	return c.Concat(res...)
+}
+
+// Mean averages the members' parameters.
+func (s *EnsembleSpace) Mean(params anydiff.Res, batch int) anydiff.Res {
+	return anydiff.Pool(params, func(params anydiff.Res) anydiff.Res {
+		var sum anydiff.Res
+		for _, member := range s.unpack(params, batch) {
+			if sum == nil {
+				sum = member
+			} else {
+				sum = anydiff.Add(sum, member)
+			}
+		}
+		c := params.Output().Creator()
+		return anydiff.Scale(sum, c.MakeNumeric(1/float64(s.Members)))
+	})
+}
+
+// Disagreement measures how much the members disagree
+// about each input, which can be used as an uncertainty
+// signal.
+//
+// It computes the mean KL divergence from each member's
+// distribution to the distribution given by Mean.
+//
+// The member space must be a KLer.
+func (s *EnsembleSpace) Disagreement(params anydiff.Res, batch int) anydiff.Res {
+	kler := s.Space.(KLer)
+	return anydiff.Pool(params, func(params anydiff.Res) anydiff.Res {
+		mean := s.Mean(params, batch)
+		var sum anydiff.Res
+		for _, member := range s.unpack(params, batch) {
+			kl := kler.KL(member, mean, batch)
+			if sum == nil {
+				sum = kl
+			} else {
+				sum = anydiff.Add(sum, kl)
+			}
+		}
+		c := params.Output().Creator()
+		return anydiff.Scale(sum, c.MakeNumeric(1/float64(s.Members)))
+	})
+}
+
+func (s *EnsembleSpace) unpack(params anydiff.Res, batch int) []anydiff.Res {
+	sizes := make([]int, s.Members)
+	for i := range sizes {
+		sizes[i] = params.Output().Len() / (batch * s.Members)
+	}
+	return unpackTuples(params, sizes, batch)
+}
+
+func majorityVote(c anyvec.Creator, choices []anyvec.Vector) anyvec.Vector {
+	var data [][]float64
+	for _, choice := range choices {
+		data = append(data, c.Float64Slice(choice.Data()))
+	}
+	var best, bestCount int
+	for i, x := range data {
+		var count int
+		for _, y := range data {
+			if equalFloats(x, y) {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = i, count
+		}
+	}
+	return choices[best]
+}
+
+func equalFloats(x, y []float64) bool {
+	for i, a := range x {
+		if a != y[i] {
+			return false
+		}
+	}
+	return len(x) == len(y)
+}
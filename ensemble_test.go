@@ -0,0 +1,42 @@
+package anyrl
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestEnsembleSpace(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	params := c.MakeVectorData(c.MakeNumericList([]float64{
+		// Members disagree.
+		0, 100, 0, 100, 100, 0,
+		// Members agree.
+		0, 100, 0, 100, 0, 100,
+	}))
+
+	space := &EnsembleSpace{Space: Softmax{}, Members: 3, Mode: EnsembleVote}
+	for i := 0; i < 10; i++ {
+		actual := c.Float64Slice(space.Sample(params, 2).Data())
+		expected := []float64{0, 1, 0, 1}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("expected %v but got %v", expected, actual)
+		}
+	}
+
+	mean := c.Float64Slice(space.Mean(anydiff.NewConst(params), 2).Output().Data())
+	expected := []float64{100.0 / 3, 200.0 / 3, 0, 100}
+	for i, x := range expected {
+		if math.Abs(mean[i]-x) > 1e-8 {
+			t.Errorf("mean %d: expected %f but got %f", i, x, mean[i])
+		}
+	}
+
+	dis := c.Float64Slice(space.Disagreement(anydiff.NewConst(params), 2).Output().Data())
+	if dis[0] < 1 || math.Abs(dis[1]) > 1e-8 {
+		t.Errorf("unexpected disagreement: %v", dis)
+	}
+}
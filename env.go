@@ -19,12 +19,41 @@ type Env interface {
 		reward float64, done bool, err error)
 }
 
+// A TruncationEnv is an Env which can report whether an
+// episode ended because of a time limit rather than
+// because a terminal state was reached.
+//
+// When an episode is truncated, the final state is not
+// actually terminal, so return computations should
+// bootstrap from a value estimate instead of assuming
+// zero future reward.
+type TruncationEnv interface {
+	Env
+
+	// Truncated reports whether the most recent episode
+	// was ended by a time limit.
+	// It is only meaningful after Step returns done.
+	Truncated() bool
+}
+
+// EnvTruncated checks if an Env is a TruncationEnv whose
+// most recent episode was truncated.
+func EnvTruncated(e Env) bool {
+	if t, ok := e.(TruncationEnv); ok {
+		return t.Truncated()
+	}
+	return false
+}
+
 type gymEnv struct {
 	env    gym.Env
 	render bool
 
 	actConv gymSpaceConverter
 	obsConv gymSpaceConverter
+
+	actSpace Space
+	obsSpace Space
 }
 
 // GymEnv creates an Env from an OpenAI Gym instance.
@@ -34,6 +63,8 @@ type gymEnv struct {
 //
 // If render is true, then the environment will be
 // graphically rendered after every step.
+//
+// The resulting Env is a SpaceEnv.
 func GymEnv(e gym.Env, render bool) (env Env, err error) {
 	defer essentials.AddCtxTo("create gym Env", &err)
 	actionSpace, err := e.ActionSpace()
@@ -52,11 +83,21 @@ func GymEnv(e gym.Env, render bool) (env Env, err error) {
 	if err != nil {
 		return nil, err
 	}
+	actSpaceDesc, err := spaceFromGym(actionSpace)
+	if err != nil {
+		return nil, err
+	}
+	obsSpaceDesc, err := spaceFromGym(obsSpace)
+	if err != nil {
+		return nil, err
+	}
 	return &gymEnv{
-		env:     e,
-		actConv: actConv,
-		obsConv: obsConv,
-		render:  render,
+		env:      e,
+		actConv:  actConv,
+		obsConv:  obsConv,
+		actSpace: actSpaceDesc,
+		obsSpace: obsSpaceDesc,
+		render:   render,
 	}, nil
 }
 
@@ -95,6 +136,14 @@ func (g *gymEnv) Step(action []float64) (obsVec []float64, reward float64,
 	return
 }
 
+func (g *gymEnv) ObservationSpace() Space {
+	return g.obsSpace
+}
+
+func (g *gymEnv) ActionSpace() Space {
+	return g.actSpace
+}
+
 type gymSpaceConverter interface {
 	VecLen() int
 	ToGym(in []float64) (interface{}, error)
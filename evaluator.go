@@ -0,0 +1,161 @@
+package anyrl
+
+import (
+	"log"
+	"sync"
+)
+
+// An EvalResult describes the evaluation of a policy
+// snapshot.
+type EvalResult struct {
+	// Version is the version of the evaluated snapshot.
+	Version int64
+
+	// Rewards contains the total reward of each
+	// evaluation episode.
+	Rewards []float64
+
+	// Mean is the mean of Rewards.
+	Mean float64
+
+	// Err is set if the episodes could not be run, in
+	// which case the other statistics are not set.
+	Err error
+}
+
+// An EvalLogger logs the results of evaluations.
+type EvalLogger interface {
+	LogEval(e *EvalResult)
+}
+
+// StandardEvalLogger is an EvalLogger which uses the log
+// package.
+type StandardEvalLogger struct{}
+
+// LogEval logs the evaluation result.
+func (s StandardEvalLogger) LogEval(e *EvalResult) {
+	if e.Err != nil {
+		log.Printf("eval: version=%d error=%v", e.Version, e.Err)
+	} else {
+		log.Printf("eval: version=%d episodes=%d mean=%f", e.Version, len(e.Rewards),
+			e.Mean)
+	}
+}
+
+// An AsyncEvaluator evaluates policy snapshots in a
+// background Goroutine, so that evaluation episodes never
+// block the training loop.
+//
+// If snapshots are submitted faster than they can be
+// evaluated, snapshots which have not started evaluating
+// are replaced by newer ones.
+type AsyncEvaluator struct {
+	// Roller is used as a template for running the
+	// evaluation episodes.
+	// A copy of it is made for each snapshot, with Block
+	// set to the snapshot's block and with Snapshots,
+	// Modes, and Intrinsic cleared.
+	Roller *RNNRoller
+
+	// Envs are the environments to evaluate in.
+	// They are only used by the evaluator's Goroutine.
+	Envs []Env
+
+	// Logger receives the evaluation results.
+	//
+	// If nil, StandardEvalLogger is used.
+	Logger EvalLogger
+
+	once    sync.Once
+	lock    sync.Mutex
+	pending *PolicySnapshot
+	closed  bool
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+// Submit schedules a snapshot for evaluation and returns
+// immediately.
+//
+// It must not be called after Close.
+func (a *AsyncEvaluator) Submit(s *PolicySnapshot) {
+	a.start()
+	a.lock.Lock()
+	a.pending = s
+	a.lock.Unlock()
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// SubmitLatest submits the current snapshot in a store,
+// if there is one.
+func (a *AsyncEvaluator) SubmitLatest(store *PolicyStore) {
+	if s := store.Current(); s != nil {
+		a.Submit(s)
+	}
+}
+
+// Close waits for the pending evaluation, if any, to
+// finish and stops the background Goroutine.
+func (a *AsyncEvaluator) Close() {
+	a.start()
+	a.lock.Lock()
+	a.closed = true
+	a.lock.Unlock()
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+	<-a.done
+}
+
+func (a *AsyncEvaluator) start() {
+	a.once.Do(func() {
+		a.wake = make(chan struct{}, 1)
+		a.done = make(chan struct{})
+		go a.loop()
+	})
+}
+
+func (a *AsyncEvaluator) loop() {
+	defer close(a.done)
+	for _ = range a.wake {
+		a.lock.Lock()
+		snapshot, closed := a.pending, a.closed
+		a.pending = nil
+		a.lock.Unlock()
+		if snapshot != nil {
+			a.logger().LogEval(a.evaluate(snapshot))
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+func (a *AsyncEvaluator) evaluate(s *PolicySnapshot) *EvalResult {
+	roller := *a.Roller
+	roller.Block = s.Block
+	roller.Snapshots = nil
+	roller.Modes = nil
+	roller.Intrinsic = nil
+
+	res := &EvalResult{Version: s.Version}
+	rollouts, err := roller.Rollout(a.Envs...)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Rewards = rollouts.Rewards.Totals()
+	res.Mean = rollouts.Rewards.Mean()
+	return res
+}
+
+func (a *AsyncEvaluator) logger() EvalLogger {
+	if a.Logger == nil {
+		return StandardEvalLogger{}
+	}
+	return a.Logger
+}
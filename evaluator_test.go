@@ -0,0 +1,63 @@
+package anyrl
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestAsyncEvaluator(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	block := anyrnn.NewLSTM(c, 3, 4)
+	store := &PolicyStore{}
+	logger := &evalTestLogger{}
+	eval := &AsyncEvaluator{
+		Roller: &RNNRoller{ActionSpace: Softmax{}},
+		Envs: []Env{
+			&rnnTestEnv{RewardScale: 1, EpLen: 2, Observation: []float64{1, 2, 3}},
+			&rnnTestEnv{RewardScale: 1, EpLen: 3, Observation: []float64{1, 2, 3}},
+		},
+		Logger: logger,
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := store.Publish(block); err != nil {
+			t.Fatal(err)
+		}
+		eval.SubmitLatest(store)
+	}
+	eval.Close()
+
+	if len(logger.results) == 0 {
+		t.Fatal("no results logged")
+	}
+	var lastVersion int64
+	for _, res := range logger.results {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if res.Version <= lastVersion {
+			t.Errorf("versions out of order: %d after %d", res.Version, lastVersion)
+		}
+		lastVersion = res.Version
+		if len(res.Rewards) != 2 {
+			t.Errorf("expected 2 episodes but got %d", len(res.Rewards))
+		}
+	}
+	if lastVersion != 3 {
+		t.Errorf("expected latest version 3 to be evaluated, got %d", lastVersion)
+	}
+}
+
+type evalTestLogger struct {
+	lock    sync.Mutex
+	results []*EvalResult
+}
+
+func (e *evalTestLogger) LogEval(res *EvalResult) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.results = append(e.results, res)
+}
@@ -0,0 +1,189 @@
+package anyrl
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/lazyseq"
+)
+
+// An Exporter writes rollouts to CSV or JSON files for
+// offline analysis, e.g. with pandas or R.
+//
+// Each record corresponds to one timestep of one episode
+// and always includes the episode index, the timestep,
+// and the reward.
+// The other fields are selected by the Exporter's
+// configuration.
+type Exporter struct {
+	// Observations indicates that observations should be
+	// included.
+	Observations bool
+
+	// ObsStride, if greater than 1, downsamples the
+	// observations by only keeping every ObsStride-th
+	// component.
+	ObsStride int
+
+	// Actions indicates that actions should be included.
+	Actions bool
+
+	// AgentOuts indicates that the agent's outputs should
+	// be included.
+	AgentOuts bool
+
+	// LogProber, if non-nil, is used to include the
+	// log-probability of each action under the agent's
+	// outputs.
+	LogProber LogProber
+}
+
+// ExportStep is the exported data for one timestep.
+//
+// Fields which were not selected are nil.
+type ExportStep struct {
+	Episode  int       `json:"episode"`
+	Step     int       `json:"step"`
+	Reward   float64   `json:"reward"`
+	Obs      []float64 `json:"obs,omitempty"`
+	Action   []float64 `json:"action,omitempty"`
+	AgentOut []float64 `json:"agent_out,omitempty"`
+	LogProb  *float64  `json:"log_prob,omitempty"`
+}
+
+// Steps extracts the selected fields for every timestep,
+// ordered by episode and then by timestep.
+func (e *Exporter) Steps(r *RolloutSet) []*ExportStep {
+	var obs, actions, outs [][][]float64
+	if e.Observations {
+		obs = tapeEpisodes(r.Inputs, len(r.Rewards))
+	}
+	if e.Actions || e.LogProber != nil {
+		actions = tapeEpisodes(r.Actions, len(r.Rewards))
+	}
+	if e.AgentOuts || e.LogProber != nil {
+		outs = tapeEpisodes(r.AgentOuts, len(r.Rewards))
+	}
+
+	c := r.Creator()
+	var res []*ExportStep
+	for ep, rewards := range r.Rewards {
+		for t, rew := range rewards {
+			step := &ExportStep{Episode: ep, Step: t, Reward: rew}
+			if e.Observations {
+				step.Obs = e.downsample(obs[ep][t])
+			}
+			if e.Actions {
+				step.Action = actions[ep][t]
+			}
+			if e.AgentOuts {
+				step.AgentOut = outs[ep][t]
+			}
+			if e.LogProber != nil {
+				params := anydiff.NewConst(anyvec.Make(c, outs[ep][t]))
+				logProb := e.LogProber.LogProb(params, anyvec.Make(c, actions[ep][t]), 1)
+				value := c.Float64Slice(logProb.Output().Data())[0]
+				step.LogProb = &value
+			}
+			res = append(res, step)
+		}
+	}
+	return res
+}
+
+// WriteJSON writes the rollouts as a JSON array of step
+// records.
+func (e *Exporter) WriteJSON(w io.Writer, r *RolloutSet) (err error) {
+	defer essentials.AddCtxTo("export JSON", &err)
+	steps := e.Steps(r)
+	if steps == nil {
+		steps = []*ExportStep{}
+	}
+	return json.NewEncoder(w).Encode(steps)
+}
+
+// WriteCSV writes the rollouts as a CSV file with a
+// header row.
+//
+// Vector fields are expanded into one column per
+// component, named like "obs_0", "obs_1", etc.
+func (e *Exporter) WriteCSV(w io.Writer, r *RolloutSet) (err error) {
+	defer essentials.AddCtxTo("export CSV", &err)
+	steps := e.Steps(r)
+	writer := csv.NewWriter(w)
+
+	header := []string{"episode", "step", "reward"}
+	if len(steps) > 0 {
+		s := steps[0]
+		header = append(header, vectorColumns("obs", len(s.Obs))...)
+		header = append(header, vectorColumns("action", len(s.Action))...)
+		header = append(header, vectorColumns("agent_out", len(s.AgentOut))...)
+		if s.LogProb != nil {
+			header = append(header, "log_prob")
+		}
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, s := range steps {
+		record := []string{strconv.Itoa(s.Episode), strconv.Itoa(s.Step), formatFloat(s.Reward)}
+		for _, vec := range [][]float64{s.Obs, s.Action, s.AgentOut} {
+			for _, x := range vec {
+				record = append(record, formatFloat(x))
+			}
+		}
+		if s.LogProb != nil {
+			record = append(record, formatFloat(*s.LogProb))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (e *Exporter) downsample(obs []float64) []float64 {
+	if e.ObsStride <= 1 {
+		return obs
+	}
+	var res []float64
+	for i := 0; i < len(obs); i += e.ObsStride {
+		res = append(res, obs[i])
+	}
+	return res
+}
+
+func vectorColumns(name string, size int) []string {
+	var res []string
+	for i := 0; i < size; i++ {
+		res = append(res, name+"_"+strconv.Itoa(i))
+	}
+	return res
+}
+
+func formatFloat(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+// tapeEpisodes splits a tape up into the vectors for each
+// timestep of each episode.
+func tapeEpisodes(t lazyseq.Tape, numEpisodes int) [][][]float64 {
+	c := t.Creator()
+	res := make([][][]float64, numEpisodes)
+	for batch := range t.ReadTape(0, -1) {
+		for i, chunk := range splitBatch(batch) {
+			if chunk != nil {
+				res[i] = append(res[i], c.Float64Slice(chunk.Data()))
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,41 @@
+package anyrl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestExporter(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	rewards := Rewards{{1, 2}, {3}}
+	r := &RolloutSet{
+		Inputs:  Rewards{{4, 5}, {6}}.Tape(c),
+		Actions: Rewards{{1, 1}, {1}}.Tape(c),
+		Rewards: rewards,
+	}
+	e := &Exporter{Observations: true, Actions: true}
+
+	var buf bytes.Buffer
+	if err := e.WriteCSV(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	expected := "episode,step,reward,obs_0,action_0\n" +
+		"0,0,1,4,1\n0,1,2,5,1\n1,0,3,6,1\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected CSV:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := e.WriteJSON(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	expected = `[{"episode":0,"step":0,"reward":1,"obs":[4],"action":[1]},` +
+		`{"episode":0,"step":1,"reward":2,"obs":[5],"action":[1]},` +
+		`{"episode":1,"step":0,"reward":3,"obs":[6],"action":[1]}]`
+	if strings.TrimSpace(buf.String()) != expected {
+		t.Errorf("unexpected JSON:\n%s", buf.String())
+	}
+}
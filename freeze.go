@@ -0,0 +1,90 @@
+package anyrl
+
+import (
+	"sync"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+)
+
+// A Freezer keeps track of parameters which should not be
+// trained, such as the parameters of a pretrained
+// encoder.
+//
+// Trainers only allocate gradients for the parameters
+// they are given, so the usual way to use a Freezer is to
+// pass the result of Trainable as a trainer's Params.
+// For NaturalPG and TRPO, frozen parameters then have no
+// effect on the Fisher matrix, since the Fisher-vector
+// products only perturb the parameters in the gradient.
+//
+// It is safe to use a Freezer from multiple Goroutines.
+type Freezer struct {
+	lock   sync.RWMutex
+	frozen map[*anydiff.Var]bool
+}
+
+// Freeze marks the parameters as frozen.
+func (f *Freezer) Freeze(params ...*anydiff.Var) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.frozen == nil {
+		f.frozen = map[*anydiff.Var]bool{}
+	}
+	for _, p := range params {
+		f.frozen[p] = true
+	}
+}
+
+// FreezeAll freezes all of the parameters of a layer,
+// block, or other object supported by
+// anynet.AllParameters.
+func (f *Freezer) FreezeAll(obj interface{}) {
+	f.Freeze(anynet.AllParameters(obj)...)
+}
+
+// Unfreeze marks the parameters as trainable again.
+func (f *Freezer) Unfreeze(params ...*anydiff.Var) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, p := range params {
+		delete(f.frozen, p)
+	}
+}
+
+// Frozen checks if a parameter is frozen.
+func (f *Freezer) Frozen(param *anydiff.Var) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.frozen[param]
+}
+
+// Trainable returns the parameters of obj which are not
+// frozen, in the order of anynet.AllParameters.
+//
+// If obj is a []*anydiff.Var, it is filtered directly.
+func (f *Freezer) Trainable(obj interface{}) []*anydiff.Var {
+	params, ok := obj.([]*anydiff.Var)
+	if !ok {
+		params = anynet.AllParameters(obj)
+	}
+	var res []*anydiff.Var
+	for _, p := range params {
+		if !f.Frozen(p) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// FilterGrad removes frozen parameters from a gradient.
+//
+// This is useful for gradients which were allocated
+// before parameters were frozen.
+func (f *Freezer) FilterGrad(grad anydiff.Grad) {
+	for p := range grad {
+		if f.Frozen(p) {
+			delete(grad, p)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package anyrl
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestFreezer(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	encoder := anynet.NewFC(c, 3, 2)
+	head := anynet.NewFC(c, 2, 1)
+	net := anynet.Net{encoder, anynet.Tanh, head}
+
+	var freezer Freezer
+	freezer.FreezeAll(encoder)
+
+	trainable := freezer.Trainable(net)
+	expected := head.Parameters()
+	if len(trainable) != len(expected) {
+		t.Fatalf("expected %d params but got %d", len(expected), len(trainable))
+	}
+	for i, p := range expected {
+		if trainable[i] != p {
+			t.Errorf("param %d: unexpected variable", i)
+		}
+	}
+
+	grad := anydiff.NewGrad(anynet.AllParameters(net)...)
+	freezer.FilterGrad(grad)
+	if len(grad) != len(expected) {
+		t.Errorf("expected %d gradient entries but got %d", len(expected), len(grad))
+	}
+
+	freezer.Unfreeze(encoder.Weights)
+	if freezer.Frozen(encoder.Weights) || !freezer.Frozen(encoder.Biases) {
+		t.Error("unexpected frozen state after Unfreeze")
+	}
+}
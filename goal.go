@@ -0,0 +1,74 @@
+package anyrl
+
+import "math"
+
+// A GoalEnv is an environment for goal-conditioned tasks,
+// where each episode has a goal for the agent to reach.
+type GoalEnv interface {
+	Env
+
+	// Goal returns the goal for the current episode.
+	// It should be called after Reset.
+	Goal() []float64
+}
+
+// GoalObsEnv wraps a GoalEnv and appends the current goal
+// to every observation, so that a policy can condition
+// on the goal.
+type GoalObsEnv struct {
+	GoalEnv
+}
+
+// Reset resets the environment.
+func (g *GoalObsEnv) Reset() ([]float64, error) {
+	obs, err := g.GoalEnv.Reset()
+	if err != nil {
+		return nil, err
+	}
+	return g.withGoal(obs), nil
+}
+
+// Step takes a step in the environment.
+func (g *GoalObsEnv) Step(action []float64) ([]float64, float64, bool, error) {
+	obs, rew, done, err := g.GoalEnv.Step(action)
+	if err != nil {
+		return nil, rew, done, err
+	}
+	return g.withGoal(obs), rew, done, nil
+}
+
+func (g *GoalObsEnv) withGoal(obs []float64) []float64 {
+	return append(append([]float64{}, obs...), g.GoalEnv.Goal()...)
+}
+
+// GoalDistance computes the Euclidean distance between an
+// achieved goal and a desired goal.
+func GoalDistance(achieved, goal []float64) float64 {
+	var sum float64
+	for i, x := range achieved {
+		d := x - goal[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// GoalReward computes a sparse goal-reaching reward.
+// It returns 0 if the achieved goal is within tolerance
+// of the desired goal, or -1 otherwise.
+func GoalReward(achieved, goal []float64, tolerance float64) float64 {
+	if GoalDistance(achieved, goal) <= tolerance {
+		return 0
+	}
+	return -1
+}
+
+// Truncated reports whether the wrapped environment's
+// most recent episode was truncated.
+func (g *GoalObsEnv) Truncated() bool {
+	return EnvTruncated(g.GoalEnv)
+}
+
+// Metadata returns the wrapped environment's metadata.
+func (g *GoalObsEnv) Metadata() map[string]float64 {
+	return EnvMetadata(g.GoalEnv)
+}
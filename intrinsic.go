@@ -0,0 +1,233 @@
+package anyrl
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+)
+
+// An IntrinsicRewarder computes intrinsic rewards online
+// while rollouts are being collected.
+//
+// Unlike reward transforms which see a finished
+// RolloutSet, an IntrinsicRewarder sees each timestep as
+// it happens, so it can maintain per-episode state such
+// as an episodic memory.
+type IntrinsicRewarder interface {
+	// Reset is called before a batch of numEpisodes
+	// episodes begins.
+	Reset(numEpisodes int)
+
+	// StepRewards is called once per timestep with the
+	// inputs to the agent and the actions it took.
+	// Both batches have the same Present map.
+	//
+	// It returns one reward per present episode.
+	StepRewards(inputs, actions *anyseq.Batch) []float64
+}
+
+// EpisodicCount is an IntrinsicRewarder which rewards
+// observations that have not yet been seen in the current
+// episode.
+//
+// The reward for an observation is 1/sqrt(n), where n is
+// the number of times the exact observation has been seen
+// so far in the episode (including the current visit).
+type EpisodicCount struct {
+	lock   sync.Mutex
+	counts []map[uint64]int
+}
+
+// Reset clears the episodic memories.
+func (e *EpisodicCount) Reset(numEpisodes int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.counts = make([]map[uint64]int, numEpisodes)
+	for i := range e.counts {
+		e.counts[i] = map[uint64]int{}
+	}
+}
+
+// StepRewards computes count-based bonuses for the
+// inputs and records them in the episodic memories.
+func (e *EpisodicCount) StepRewards(inputs, actions *anyseq.Batch) []float64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	obses := splitBatch(inputs)
+	c := inputs.Packed.Creator()
+	var res []float64
+	for i, obs := range obses {
+		if obs == nil {
+			continue
+		}
+		hash := hashObservation(c.Float64Slice(obs.Data()))
+		e.counts[i][hash]++
+		res = append(res, 1/math.Sqrt(float64(e.counts[i][hash])))
+	}
+	return res
+}
+
+func hashObservation(obs []float64) uint64 {
+	h := fnv.New64a()
+	writeFloats(h, obs, 0)
+	return h.Sum64()
+}
+
+// Default settings for EpisodicNovelty.
+const (
+	DefaultNoveltyNeighbors     = 10
+	DefaultNoveltyKernelEpsilon = 1e-3
+	DefaultNoveltyConstant      = 1e-3
+	DefaultNoveltyMaxSimilarity = 8
+)
+
+// EpisodicNovelty is an IntrinsicRewarder which rewards
+// visits to states whose embeddings are far from those
+// already visited in the current episode.
+//
+// The bonus follows Never Give Up
+// (https://arxiv.org/abs/2002.06038): the squared
+// distances to the k nearest neighbors in the episodic
+// memory are normalized by a running mean, passed through
+// an inverse kernel, and the reward is the inverse square
+// root of the summed similarities.
+type EpisodicNovelty struct {
+	// Embedding, if non-nil, maps a batch of inputs to a
+	// batch of embeddings.
+	// If nil, the raw inputs are used.
+	Embedding anynet.Layer
+
+	// Neighbors is the number of nearest neighbors used
+	// to measure similarity.
+	//
+	// If 0, DefaultNoveltyNeighbors is used.
+	Neighbors int
+
+	// KernelEpsilon is the epsilon in the inverse kernel
+	// eps/(d^2+eps).
+	//
+	// If 0, DefaultNoveltyKernelEpsilon is used.
+	KernelEpsilon float64
+
+	// Constant is added to the summed similarities to
+	// bound the reward.
+	//
+	// If 0, DefaultNoveltyConstant is used.
+	Constant float64
+
+	// MaxSimilarity is the similarity above which the
+	// reward is set to zero.
+	//
+	// If 0, DefaultNoveltyMaxSimilarity is used.
+	MaxSimilarity float64
+
+	lock       sync.Mutex
+	memories   [][][]float64
+	meanSqDist float64
+	numDists   float64
+}
+
+// Reset clears the episodic memories.
+//
+// The running mean of squared distances is kept, since it
+// only sets the scale of the embeddings.
+func (e *EpisodicNovelty) Reset(numEpisodes int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.memories = make([][][]float64, numEpisodes)
+}
+
+// StepRewards computes the novelty of each embedded input
+// and adds it to the corresponding episodic memory.
+func (e *EpisodicNovelty) StepRewards(inputs, actions *anyseq.Batch) []float64 {
+	embedded := inputs.Packed
+	if e.Embedding != nil {
+		in := anydiff.NewConst(inputs.Packed)
+		embedded = e.Embedding.Apply(in, inputs.NumPresent()).Output()
+	}
+	c := embedded.Creator()
+	data := c.Float64Slice(embedded.Data())
+	size := len(data) / inputs.NumPresent()
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	var res []float64
+	for i, pres := range inputs.Present {
+		if !pres {
+			continue
+		}
+		embedding := data[:size]
+		data = data[size:]
+		res = append(res, e.novelty(e.memories[i], embedding))
+		e.memories[i] = append(e.memories[i], embedding)
+	}
+	return res
+}
+
+func (e *EpisodicNovelty) novelty(memory [][]float64, embedding []float64) float64 {
+	if len(memory) == 0 {
+		return 1 / e.constant()
+	}
+	dists := make([]float64, len(memory))
+	for i, other := range memory {
+		var sqDist float64
+		for j, x := range other {
+			sqDist += (x - embedding[j]) * (x - embedding[j])
+		}
+		dists[i] = sqDist
+	}
+	sort.Float64s(dists)
+	if len(dists) > e.neighbors() {
+		dists = dists[:e.neighbors()]
+	}
+
+	var similarity float64
+	for _, d := range dists {
+		e.numDists++
+		e.meanSqDist += (d - e.meanSqDist) / e.numDists
+		normalized := d
+		if e.meanSqDist > 0 {
+			normalized /= e.meanSqDist
+		}
+		similarity += e.kernelEpsilon() / (normalized + e.kernelEpsilon())
+	}
+
+	root := math.Sqrt(similarity) + e.constant()
+	if root > e.maxSimilarity() {
+		return 0
+	}
+	return 1 / root
+}
+
+func (e *EpisodicNovelty) neighbors() int {
+	if e.Neighbors == 0 {
+		return DefaultNoveltyNeighbors
+	}
+	return e.Neighbors
+}
+
+func (e *EpisodicNovelty) kernelEpsilon() float64 {
+	if e.KernelEpsilon == 0 {
+		return DefaultNoveltyKernelEpsilon
+	}
+	return e.KernelEpsilon
+}
+
+func (e *EpisodicNovelty) constant() float64 {
+	if e.Constant == 0 {
+		return DefaultNoveltyConstant
+	}
+	return e.Constant
+}
+
+func (e *EpisodicNovelty) maxSimilarity() float64 {
+	if e.MaxSimilarity == 0 {
+		return DefaultNoveltyMaxSimilarity
+	}
+	return e.MaxSimilarity
+}
@@ -0,0 +1,36 @@
+package anyrl
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestEpisodicNovelty(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	novelty := &EpisodicNovelty{}
+	novelty.Reset(2)
+
+	step := func(obs ...float64) []float64 {
+		batch := &anyseq.Batch{
+			Packed:  anyvec.Make(c, obs),
+			Present: []bool{true, true},
+		}
+		return novelty.StepRewards(batch, batch)
+	}
+
+	step(0, 0, 0, 0)
+	step(1, 1, 0, 0)
+	rewards := step(0, 0, 5, 5)
+	if rewards[0] >= rewards[1] {
+		t.Errorf("revisited state should get a smaller bonus: %v", rewards)
+	}
+
+	novelty.Reset(2)
+	rewards = step(0, 0, 0, 0)
+	if rewards[0] != rewards[1] || rewards[0] != 1/DefaultNoveltyConstant {
+		t.Errorf("memory should be cleared on reset: %v", rewards)
+	}
+}
@@ -0,0 +1,72 @@
+package anyrl
+
+import (
+	"github.com/unixpickle/anydiff"
+)
+
+// DefaultHuberDelta is the default threshold for
+// HuberLoss.
+const DefaultHuberDelta = 1.0
+
+// A Loss measures the error between value predictions
+// and their targets, e.g. for fitting a critic or a
+// Q-function.
+type Loss interface {
+	// Loss computes the loss for each component of the
+	// predictions.
+	Loss(actual, target anydiff.Res) anydiff.Res
+}
+
+// SquareLoss is a Loss which computes the squared error.
+type SquareLoss struct{}
+
+// Loss computes the squared error.
+func (s SquareLoss) Loss(actual, target anydiff.Res) anydiff.Res {
+	return anydiff.Square(anydiff.Sub(actual, target))
+}
+
+// HuberLoss is a Loss which is quadratic for small errors
+// and linear for large ones.
+type HuberLoss struct {
+	// Delta is the error magnitude at which the loss
+	// becomes linear.
+	//
+	// If 0, DefaultHuberDelta is used.
+	Delta float64
+}
+
+// Loss computes the Huber loss.
+func (h *HuberLoss) Loss(actual, target anydiff.Res) anydiff.Res {
+	c := actual.Output().Creator()
+	delta := h.Delta
+	if delta == 0 {
+		delta = DefaultHuberDelta
+	}
+	return anydiff.Pool(anydiff.Sub(actual, target), func(diff anydiff.Res) anydiff.Res {
+		// With clipped=clip(diff, -delta, delta), the loss is
+		// clipped*(diff-clipped/2).
+		clipped := anydiff.ClipRange(diff, c.MakeNumeric(-delta), c.MakeNumeric(delta))
+		return anydiff.Mul(clipped, anydiff.Sub(diff, anydiff.Scale(clipped,
+			c.MakeNumeric(0.5))))
+	})
+}
+
+// QuantileLoss is a Loss which trains predictions to
+// approximate a quantile of the targets' distribution.
+type QuantileLoss struct {
+	// Quantile is the quantile to estimate, between 0
+	// and 1.
+	// A value of 0.5 estimates the median.
+	Quantile float64
+}
+
+// Loss computes the quantile regression (pinball) loss.
+func (q *QuantileLoss) Loss(actual, target anydiff.Res) anydiff.Res {
+	c := actual.Output().Creator()
+	return anydiff.Pool(anydiff.Sub(target, actual), func(diff anydiff.Res) anydiff.Res {
+		return anydiff.ElemMax(
+			anydiff.Scale(diff, c.MakeNumeric(q.Quantile)),
+			anydiff.Scale(diff, c.MakeNumeric(q.Quantile-1)),
+		)
+	})
+}
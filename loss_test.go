@@ -0,0 +1,36 @@
+package anyrl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestLosses(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	actual := []float64{0, 0.5, 3, -2}
+	target := []float64{0, 0, 0, 0}
+
+	tests := []struct {
+		loss     Loss
+		expected []float64
+	}{
+		{SquareLoss{}, []float64{0, 0.25, 9, 4}},
+		{&HuberLoss{}, []float64{0, 0.125, 2.5, 1.5}},
+		{&QuantileLoss{Quantile: 0.25}, []float64{0, 0.375, 2.25, 0.5}},
+	}
+	for _, test := range tests {
+		actualRes := anydiff.NewVar(anyvec.Make(c, actual))
+		targetRes := anydiff.NewConst(anyvec.Make(c, target))
+		out := c.Float64Slice(test.loss.Loss(actualRes, targetRes).Output().Data())
+		for i, x := range test.expected {
+			if math.Abs(out[i]-x) > 1e-8 {
+				t.Errorf("%T: expected %v but got %v", test.loss, test.expected, out)
+				break
+			}
+		}
+	}
+}
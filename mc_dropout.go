@@ -0,0 +1,100 @@
+package anyrl
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+)
+
+// DefaultMCDropoutSamples is the default number of forward
+// passes used by MCDropout.
+const DefaultMCDropoutSamples = 10
+
+// MCDropout estimates the uncertainty of a network's
+// outputs (e.g. action parameters or values) by running
+// several stochastic forward passes with dropout enabled.
+//
+// A high variance suggests that an input is unlike the
+// inputs seen during training, which can be used to detect
+// out-of-distribution states at deployment time.
+//
+// Predict temporarily modifies the dropout layers, so it
+// should not be called concurrently with other uses of
+// the network.
+type MCDropout struct {
+	Layer anynet.Layer
+
+	// Dropout contains the dropout layers to enable.
+	//
+	// If nil, DropoutLayers(Layer) is used.
+	Dropout []*anynet.Dropout
+
+	// Samples is the number of forward passes.
+	//
+	// If 0, DefaultMCDropoutSamples is used.
+	Samples int
+}
+
+// Predict computes the mean and variance of each output
+// component across the forward passes.
+func (m *MCDropout) Predict(in anyvec.Vector, batch int) (mean, variance anyvec.Vector) {
+	layers := m.Dropout
+	if layers == nil {
+		layers = DropoutLayers(m.Layer)
+	}
+	oldEnabled := make([]bool, len(layers))
+	for i, layer := range layers {
+		oldEnabled[i] = layer.Enabled
+		layer.Enabled = true
+	}
+	defer func() {
+		for i, layer := range layers {
+			layer.Enabled = oldEnabled[i]
+		}
+	}()
+
+	numSamples := m.Samples
+	if numSamples == 0 {
+		numSamples = DefaultMCDropoutSamples
+	}
+	var sum, sqSum anyvec.Vector
+	for i := 0; i < numSamples; i++ {
+		out := m.Layer.Apply(anydiff.NewConst(in), batch).Output()
+		sq := out.Copy()
+		sq.Mul(out)
+		if sum == nil {
+			sum, sqSum = out.Copy(), sq
+		} else {
+			sum.Add(out)
+			sqSum.Add(sq)
+		}
+	}
+
+	c := in.Creator()
+	scale := c.MakeNumeric(1 / float64(numSamples))
+	mean = sum
+	mean.Scale(scale)
+	variance = sqSum
+	variance.Scale(scale)
+	meanSq := mean.Copy()
+	meanSq.Mul(mean)
+	variance.Sub(meanSq)
+	anyvec.ClipRange(variance, c.MakeNumeric(0), c.MakeNumeric(1e300))
+	return
+}
+
+// DropoutLayers finds all of the dropout layers in a
+// layer, searching recursively through anynet.Nets.
+func DropoutLayers(layer anynet.Layer) []*anynet.Dropout {
+	switch layer := layer.(type) {
+	case *anynet.Dropout:
+		return []*anynet.Dropout{layer}
+	case anynet.Net:
+		var res []*anynet.Dropout
+		for _, sub := range layer {
+			res = append(res, DropoutLayers(sub)...)
+		}
+		return res
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package anyrl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestMCDropout(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	dropout := &anynet.Dropout{KeepProb: 0.5}
+	net := anynet.Net{dropout, anynet.Net{&anynet.Dropout{KeepProb: 0.5}}}
+	if len(DropoutLayers(net)) != 2 {
+		t.Fatalf("expected 2 dropout layers")
+	}
+
+	mc := &MCDropout{Layer: anynet.Net{dropout}, Samples: 5000}
+	in := c.MakeVectorData(c.MakeNumericList([]float64{1, 0}))
+	mean, variance := mc.Predict(in, 1)
+	if dropout.Enabled {
+		t.Error("dropout should be disabled after Predict")
+	}
+
+	// The first output is 0 or 1 with equal probability.
+	meanData := c.Float64Slice(mean.Data())
+	varData := c.Float64Slice(variance.Data())
+	if math.Abs(meanData[0]-0.5) > 0.05 || math.Abs(varData[0]-0.25) > 0.05 {
+		t.Errorf("unexpected statistics: mean=%v variance=%v", meanData, varData)
+	}
+	if meanData[1] != 0 || varData[1] != 0 {
+		t.Errorf("unexpected statistics: mean=%v variance=%v", meanData, varData)
+	}
+}
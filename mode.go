@@ -0,0 +1,118 @@
+package anyrl
+
+import (
+	"sync"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+)
+
+// Mode is a phase of training in which stochastic or
+// stateful layers behave differently.
+type Mode int
+
+// These are the supported modes.
+//
+// In RolloutMode, dropout is disabled and running
+// statistics (e.g. in ObsNorm) are updated.
+// In TrainMode, dropout is enabled and running statistics
+// are frozen, so the network is a fixed function while
+// gradients are computed.
+const (
+	RolloutMode Mode = iota
+	TrainMode
+)
+
+// String returns a human-readable name for the mode.
+func (m Mode) String() string {
+	switch m {
+	case RolloutMode:
+		return "rollout"
+	case TrainMode:
+		return "train"
+	default:
+		return "unknown"
+	}
+}
+
+// A ModeSetter is a layer or block which changes its
+// behavior depending on the Mode.
+type ModeSetter interface {
+	SetMode(m Mode)
+}
+
+// SetMode implements ModeSetter by updating Collecting.
+func (o *ObsNorm) SetMode(m Mode) {
+	o.Collecting = (m == RolloutMode)
+}
+
+// SetMode switches every mode-dependent layer in obj to
+// the given mode.
+//
+// The object may be an anynet.Layer or an anyrnn.Block.
+// The search recurses through anynet.Nets, anyrnn.Stacks,
+// anyrnn.LayerBlocks, and Ensembles.
+// Dropout layers are enabled only in TrainMode, and any
+// ModeSetter has its SetMode method called.
+func SetMode(obj interface{}, m Mode) {
+	switch obj := obj.(type) {
+	case ModeSetter:
+		obj.SetMode(m)
+	case *anynet.Dropout:
+		obj.Enabled = (m == TrainMode)
+	case anynet.Net:
+		for _, sub := range obj {
+			SetMode(sub, m)
+		}
+	case anyrnn.Stack:
+		for _, sub := range obj {
+			SetMode(sub, m)
+		}
+	case *anyrnn.LayerBlock:
+		SetMode(obj.Layer, m)
+	case *Ensemble:
+		for _, sub := range obj.Members {
+			SetMode(sub, m)
+		}
+	}
+}
+
+// A ModeController switches a set of layers and blocks
+// between modes.
+//
+// A single ModeController is typically shared by a roller
+// and a trainer, each of which sets the mode it needs
+// before using the network.
+type ModeController struct {
+	// Targets contains the layers and blocks to update.
+	// See SetMode for the supported types.
+	Targets []interface{}
+
+	lock sync.Mutex
+	mode Mode
+	set  bool
+}
+
+// Set switches all of the targets to the mode.
+//
+// Targets are only updated when the mode changes, so it
+// is cheap to call Set before every batch.
+func (m *ModeController) Set(mode Mode) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.set && m.mode == mode {
+		return
+	}
+	for _, target := range m.Targets {
+		SetMode(target, mode)
+	}
+	m.mode = mode
+	m.set = true
+}
+
+// Mode returns the most recently set mode.
+func (m *ModeController) Mode() Mode {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.mode
+}
@@ -0,0 +1,32 @@
+package anyrl
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+)
+
+func TestModeController(t *testing.T) {
+	norm := &ObsNorm{}
+	dropout := &anynet.Dropout{KeepProb: 0.5}
+	block := anyrnn.Stack{
+		&anyrnn.LayerBlock{Layer: anynet.Net{norm, dropout}},
+	}
+	modes := &ModeController{Targets: []interface{}{block}}
+
+	modes.Set(RolloutMode)
+	if !norm.Collecting || dropout.Enabled {
+		t.Errorf("rollout mode: collecting=%v dropout=%v", norm.Collecting,
+			dropout.Enabled)
+	}
+
+	modes.Set(TrainMode)
+	if norm.Collecting || !dropout.Enabled {
+		t.Errorf("train mode: collecting=%v dropout=%v", norm.Collecting,
+			dropout.Enabled)
+	}
+	if modes.Mode() != TrainMode {
+		t.Errorf("expected %v but got %v", TrainMode, modes.Mode())
+	}
+}
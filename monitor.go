@@ -0,0 +1,232 @@
+package anyrl
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+)
+
+// Default thresholds for a Monitor.
+const (
+	DefaultMonitorVarianceCollapse = 0.01
+	DefaultMonitorEntropyCollapse  = 0.01
+	DefaultMonitorSpikeSteps       = 0.01
+	DefaultMonitorSpikeFrac        = 0.9
+)
+
+// An AlertKind identifies a kind of suspicious training
+// signal.
+type AlertKind int
+
+// These are the kinds of alerts raised by a Monitor.
+const (
+	// VarianceCollapse indicates that the return variance
+	// has dropped far below its initial value, e.g.
+	// because the agent found a degenerate behavior that
+	// always produces the same return.
+	VarianceCollapse AlertKind = iota
+
+	// EntropyCollapse indicates that the policy has
+	// become nearly deterministic.
+	EntropyCollapse
+
+	// RewardSpike indicates that most of the reward comes
+	// from a tiny fraction of timesteps, which is often a
+	// sign of an exploited reward bug.
+	RewardSpike
+)
+
+// String returns a short name for the alert kind.
+func (a AlertKind) String() string {
+	switch a {
+	case VarianceCollapse:
+		return "variance_collapse"
+	case EntropyCollapse:
+		return "entropy_collapse"
+	case RewardSpike:
+		return "reward_spike"
+	default:
+		return fmt.Sprintf("AlertKind(%d)", int(a))
+	}
+}
+
+// An Alert describes a suspicious training signal.
+type Alert struct {
+	Kind AlertKind
+
+	// Value is the statistic which triggered the alert.
+	Value float64
+
+	// Message is a human-readable description.
+	Message string
+}
+
+// An AlertLogger logs alerts produced by a Monitor.
+type AlertLogger interface {
+	LogAlert(a *Alert)
+}
+
+// StandardAlertLogger is an AlertLogger which uses the
+// log package.
+type StandardAlertLogger struct{}
+
+// LogAlert logs the alert.
+func (s StandardAlertLogger) LogAlert(a *Alert) {
+	log.Printf("alert: kind=%s value=%f %s", a.Kind, a.Value, a.Message)
+}
+
+// A Monitor watches batches of rollouts for signs that a
+// policy is exploiting the reward function rather than
+// solving the task.
+//
+// None of the checks are conclusive, but each of them is
+// cheap and worth a closer look when it fires.
+type Monitor struct {
+	// Logger, if non-nil, is sent every alert.
+	Logger AlertLogger
+
+	// VarianceCollapse is the fraction of the first
+	// observed return variance below which the variance
+	// is considered collapsed.
+	//
+	// If 0, DefaultMonitorVarianceCollapse is used.
+	VarianceCollapse float64
+
+	// EntropyCollapse is the mean entropy below which the
+	// policy is considered collapsed.
+	//
+	// If 0, DefaultMonitorEntropyCollapse is used.
+	EntropyCollapse float64
+
+	// SpikeSteps is the fraction of timesteps (with the
+	// largest absolute rewards) to consider when looking
+	// for reward spikes.
+	//
+	// If 0, DefaultMonitorSpikeSteps is used.
+	SpikeSteps float64
+
+	// SpikeFrac is the fraction of the total absolute
+	// reward which, if concentrated in the SpikeSteps
+	// timesteps, causes a RewardSpike alert.
+	//
+	// If 0, DefaultMonitorSpikeFrac is used.
+	SpikeFrac float64
+
+	initVariance float64
+	hasVariance  bool
+}
+
+// Check examines a batch of rollouts and the mean entropy
+// of the policy on those rollouts.
+// Pass math.NaN() for the entropy to skip the entropy
+// check.
+//
+// The resulting alerts are logged and returned.
+func (m *Monitor) Check(r *RolloutSet, entropy float64) []*Alert {
+	var res []*Alert
+	if a := m.checkVariance(r.Rewards); a != nil {
+		res = append(res, a)
+	}
+	if !math.IsNaN(entropy) && entropy < m.entropyCollapse() {
+		res = append(res, &Alert{
+			Kind:    EntropyCollapse,
+			Value:   entropy,
+			Message: fmt.Sprintf("mean entropy %f below %f", entropy, m.entropyCollapse()),
+		})
+	}
+	if a := m.checkSpike(r.Rewards); a != nil {
+		res = append(res, a)
+	}
+	if m.Logger != nil {
+		for _, a := range res {
+			m.Logger.LogAlert(a)
+		}
+	}
+	return res
+}
+
+func (m *Monitor) checkVariance(r Rewards) *Alert {
+	if len(r) < 2 {
+		return nil
+	}
+	variance := r.Variance()
+	if !m.hasVariance {
+		if variance > 0 {
+			m.initVariance = variance
+			m.hasVariance = true
+		}
+		return nil
+	}
+	ratio := variance / m.initVariance
+	if ratio >= m.varianceCollapse() {
+		return nil
+	}
+	return &Alert{
+		Kind:  VarianceCollapse,
+		Value: ratio,
+		Message: fmt.Sprintf("return variance %f is %f of initial variance %f",
+			variance, ratio, m.initVariance),
+	}
+}
+
+func (m *Monitor) checkSpike(r Rewards) *Alert {
+	var mags []float64
+	var total float64
+	for _, seq := range r {
+		for _, x := range seq {
+			mags = append(mags, math.Abs(x))
+			total += math.Abs(x)
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(mags)))
+	numSteps := int(math.Ceil(m.spikeSteps() * float64(len(mags))))
+	if numSteps >= len(mags) {
+		return nil
+	}
+	var top float64
+	for _, x := range mags[:numSteps] {
+		top += x
+	}
+	frac := top / total
+	if frac < m.spikeFrac() {
+		return nil
+	}
+	return &Alert{
+		Kind:  RewardSpike,
+		Value: frac,
+		Message: fmt.Sprintf("%d of %d timesteps account for %f of the reward",
+			numSteps, len(mags), frac),
+	}
+}
+
+func (m *Monitor) varianceCollapse() float64 {
+	if m.VarianceCollapse == 0 {
+		return DefaultMonitorVarianceCollapse
+	}
+	return m.VarianceCollapse
+}
+
+func (m *Monitor) entropyCollapse() float64 {
+	if m.EntropyCollapse == 0 {
+		return DefaultMonitorEntropyCollapse
+	}
+	return m.EntropyCollapse
+}
+
+func (m *Monitor) spikeSteps() float64 {
+	if m.SpikeSteps == 0 {
+		return DefaultMonitorSpikeSteps
+	}
+	return m.SpikeSteps
+}
+
+func (m *Monitor) spikeFrac() float64 {
+	if m.SpikeFrac == 0 {
+		return DefaultMonitorSpikeFrac
+	}
+	return m.SpikeFrac
+}
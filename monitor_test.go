@@ -0,0 +1,31 @@
+package anyrl
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMonitor(t *testing.T) {
+	m := &Monitor{SpikeSteps: 0.1}
+
+	varied := &RolloutSet{Rewards: Rewards{{1, 1, 1, 1, 1}, {2, 2, 2, 2, 2}}}
+	if alerts := m.Check(varied, 1); len(alerts) != 0 {
+		t.Fatalf("unexpected alerts: %v", alerts)
+	}
+
+	collapsed := &RolloutSet{Rewards: Rewards{{1, 1, 1, 1, 1}, {1, 1, 1, 1, 1}}}
+	alerts := m.Check(collapsed, 0.001)
+	if len(alerts) != 2 || alerts[0].Kind != VarianceCollapse ||
+		alerts[1].Kind != EntropyCollapse {
+		t.Fatalf("unexpected alerts: %v", alerts)
+	}
+
+	spiky := &RolloutSet{Rewards: Rewards{
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 100},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 101},
+	}}
+	alerts = m.Check(spiky, math.NaN())
+	if len(alerts) != 1 || alerts[0].Kind != RewardSpike {
+		t.Fatalf("unexpected alerts: %v", alerts)
+	}
+}
@@ -0,0 +1,206 @@
+package anyrl
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/unixpickle/essentials"
+)
+
+// DefaultTaskStatsWindow is the default number of recent
+// episodes used to compute per-task statistics.
+const DefaultTaskStatsWindow = 100
+
+// MultiTaskCollector gathers rollouts from a set of
+// related tasks so that a single policy can be trained on
+// all of them at once.
+//
+// Every observation is augmented (at the end) with an
+// embedding of the current task, allowing the policy to
+// condition its behavior on the task.
+type MultiTaskCollector struct {
+	// Roller is used to run the policy.
+	Roller *RNNRoller
+
+	// Tasks contains one environment per task.
+	Tasks []Env
+
+	// Embeddings contains an embedding vector for each
+	// task.
+	// All embeddings must be the same length.
+	//
+	// If nil, one-hot vectors of length len(Tasks) are
+	// used.
+	Embeddings [][]float64
+
+	// TaskProbs contains the probability of sampling each
+	// task.
+	//
+	// If nil, tasks are sampled uniformly.
+	TaskProbs []float64
+
+	// StatsWindow is the number of recent episodes per
+	// task used to compute statistics.
+	//
+	// If 0, DefaultTaskStatsWindow is used.
+	StatsWindow int
+
+	statsLock sync.Mutex
+	returns   [][]float64
+}
+
+// Rollout samples n tasks and produces one rollout for
+// each of them.
+//
+// It returns the packed rollouts along with the index of
+// the task used for each episode, in the same order as
+// the episodes in the RolloutSet.
+//
+// Since each task only has one environment, episodes for
+// the same task are run in sequential batches.
+func (m *MultiTaskCollector) Rollout(n int) (rollouts *RolloutSet, tasks []int,
+	err error) {
+	defer essentials.AddCtxTo("multi-task rollout", &err)
+
+	remaining := make([]int, len(m.Tasks))
+	for i := 0; i < n; i++ {
+		remaining[m.sampleTask()]++
+	}
+
+	var sets []*RolloutSet
+	for {
+		var envs []Env
+		var batchTasks []int
+		for task, count := range remaining {
+			if count > 0 {
+				remaining[task]--
+				envs = append(envs, &taskEnv{
+					Env:       m.Tasks[task],
+					Embedding: m.embedding(task),
+				})
+				batchTasks = append(batchTasks, task)
+			}
+		}
+		if len(envs) == 0 {
+			break
+		}
+		r, err := m.Roller.Rollout(envs...)
+		if err != nil {
+			return nil, nil, err
+		}
+		m.addStats(batchTasks, r.Rewards.Totals())
+		sets = append(sets, r)
+		tasks = append(tasks, batchTasks...)
+	}
+
+	return PackRolloutSets(m.Roller.creator(), sets), tasks, nil
+}
+
+// MeanReturns computes the mean return of the recent
+// episodes for each task.
+//
+// Tasks with no recorded episodes have a mean of 0.
+func (m *MultiTaskCollector) MeanReturns() []float64 {
+	m.statsLock.Lock()
+	defer m.statsLock.Unlock()
+	res := make([]float64, len(m.Tasks))
+	for task := range res {
+		if task >= len(m.returns) || len(m.returns[task]) == 0 {
+			continue
+		}
+		for _, x := range m.returns[task] {
+			res[task] += x
+		}
+		res[task] /= float64(len(m.returns[task]))
+	}
+	return res
+}
+
+// EpisodeCounts returns the number of recent episodes
+// which are used to compute each task's statistics.
+func (m *MultiTaskCollector) EpisodeCounts() []int {
+	m.statsLock.Lock()
+	defer m.statsLock.Unlock()
+	res := make([]int, len(m.Tasks))
+	for task := range res {
+		if task < len(m.returns) {
+			res[task] = len(m.returns[task])
+		}
+	}
+	return res
+}
+
+func (m *MultiTaskCollector) addStats(tasks []int, totals []float64) {
+	m.statsLock.Lock()
+	defer m.statsLock.Unlock()
+	for len(m.returns) < len(m.Tasks) {
+		m.returns = append(m.returns, nil)
+	}
+	window := m.StatsWindow
+	if window == 0 {
+		window = DefaultTaskStatsWindow
+	}
+	for i, task := range tasks {
+		m.returns[task] = append(m.returns[task], totals[i])
+		if len(m.returns[task]) > window {
+			m.returns[task] = m.returns[task][1:]
+		}
+	}
+}
+
+func (m *MultiTaskCollector) sampleTask() int {
+	if m.TaskProbs == nil {
+		return rand.Intn(len(m.Tasks))
+	}
+	num := rand.Float64()
+	for i, p := range m.TaskProbs {
+		num -= p
+		if num < 0 {
+			return i
+		}
+	}
+	return len(m.TaskProbs) - 1
+}
+
+func (m *MultiTaskCollector) embedding(task int) []float64 {
+	if m.Embeddings != nil {
+		return m.Embeddings[task]
+	}
+	res := make([]float64, len(m.Tasks))
+	res[task] = 1
+	return res
+}
+
+// taskEnv appends a task embedding to every observation
+// from an environment.
+type taskEnv struct {
+	Env
+	Embedding []float64
+}
+
+func (t *taskEnv) Reset() ([]float64, error) {
+	obs, err := t.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]float64{}, obs...), t.Embedding...), nil
+}
+
+func (t *taskEnv) Step(action []float64) ([]float64, float64, bool, error) {
+	obs, rew, done, err := t.Env.Step(action)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return append(append([]float64{}, obs...), t.Embedding...), rew, done, nil
+}
+
+// Truncated reports whether the wrapped environment's
+// most recent episode was truncated.
+func (t *taskEnv) Truncated() bool {
+	return EnvTruncated(t.Env)
+}
+
+// Metadata returns the wrapped environment's metadata.
+func (t *taskEnv) Metadata() map[string]float64 {
+	return EnvMetadata(t.Env)
+}
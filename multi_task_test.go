@@ -0,0 +1,134 @@
+package anyrl
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestMultiTaskSampling(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	collector := &MultiTaskCollector{
+		Roller: &RNNRoller{Block: anyrnn.NewLSTM(c, 4, 2), ActionSpace: Softmax{}},
+		Tasks: []Env{
+			&countingEnv{maxSteps: 1},
+			&countingEnv{maxSteps: 2},
+			&countingEnv{maxSteps: 3},
+		},
+		TaskProbs: []float64{0, 1, 0},
+	}
+	rollouts, tasks, err := collector.Rollout(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tasks, []int{1, 1, 1, 1}) {
+		t.Errorf("unexpected tasks: %v", tasks)
+	}
+	if len(rollouts.Rewards) != 4 {
+		t.Fatalf("expected 4 episodes but got %d", len(rollouts.Rewards))
+	}
+	for i, rew := range rollouts.Rewards {
+		if len(rew) != 2 {
+			t.Errorf("episode %d: expected length 2 but got %d", i, len(rew))
+		}
+	}
+
+	collector.TaskProbs = nil
+	_, tasks, err = collector.Rollout(300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make([]int, 3)
+	for _, task := range tasks {
+		counts[task]++
+	}
+	for task, count := range counts {
+		if count < 50 {
+			t.Errorf("task %d was sampled %d times", task, count)
+		}
+	}
+}
+
+func TestMultiTaskEmbeddings(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	for _, embeddings := range [][][]float64{nil, {{5, 6}, {7, 8}}} {
+		collector := &MultiTaskCollector{
+			Roller:     &RNNRoller{Block: anyrnn.NewLSTM(c, 3, 2), ActionSpace: Softmax{}},
+			Tasks:      []Env{&countingEnv{maxSteps: 2}, &countingEnv{maxSteps: 3}},
+			Embeddings: embeddings,
+		}
+		rollouts, tasks, err := collector.Rollout(6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var step int
+		for batch := range rollouts.Inputs.ReadTape(0, -1) {
+			data := c.Float64Slice(batch.Packed.Data())
+			for i, pres := range batch.Present {
+				if !pres {
+					continue
+				}
+				expected := []float64{float64(step), 0, 0}
+				if embeddings == nil {
+					expected[1+tasks[i]] = 1
+				} else {
+					copy(expected[1:], embeddings[tasks[i]])
+				}
+				if !reflect.DeepEqual(data[:3], expected) {
+					t.Errorf("episode %d step %d: expected %v but got %v", i, step,
+						expected, data[:3])
+				}
+				data = data[3:]
+			}
+			step++
+		}
+	}
+}
+
+func TestMultiTaskStats(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	collector := &MultiTaskCollector{
+		Roller: &RNNRoller{Block: anyrnn.NewLSTM(c, 4, 2), ActionSpace: Softmax{}},
+		Tasks: []Env{
+			&countingEnv{maxSteps: 2},
+			&countingEnv{maxSteps: 3},
+			&countingEnv{maxSteps: 1},
+		},
+		TaskProbs:   []float64{0.5, 0.5, 0},
+		StatsWindow: 5,
+	}
+	var numEpisodes [3]int
+	for i := 0; i < 4; i++ {
+		_, tasks, err := collector.Rollout(4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, task := range tasks {
+			numEpisodes[task]++
+		}
+	}
+
+	// Episodes of countingEnv with n steps have a total
+	// reward of 1+2+...+n.
+	expectedMeans := []float64{3, 6, 0}
+	for task, mean := range collector.MeanReturns() {
+		if numEpisodes[task] == 0 {
+			expectedMeans[task] = 0
+		}
+		if math.Abs(mean-expectedMeans[task]) > 1e-8 {
+			t.Errorf("task %d: expected mean %f but got %f", task, expectedMeans[task], mean)
+		}
+	}
+	for task, count := range collector.EpisodeCounts() {
+		expected := numEpisodes[task]
+		if expected > 5 {
+			expected = 5
+		}
+		if count != expected {
+			t.Errorf("task %d: expected count %d but got %d", task, expected, count)
+		}
+	}
+}
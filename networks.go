@@ -0,0 +1,164 @@
+package anyrl
+
+import (
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// DefaultHiddenSize is the default size of the hidden
+// layers created by BuildNetworks.
+const DefaultHiddenSize = 64
+
+// NetworkConfig configures the networks created by
+// BuildNetworks.
+type NetworkConfig struct {
+	// HiddenSizes contains the sizes of the hidden
+	// layers of the MLP (after the CNN, if there is one).
+	//
+	// If nil, two layers of DefaultHiddenSize are used.
+	HiddenSizes []int
+
+	// Activation is the activation function.
+	//
+	// If nil, anynet.Tanh is used for MLPs and
+	// anynet.ReLU is used for CNNs.
+	Activation anynet.Layer
+
+	// NoCNN, if true, prevents the use of a CNN for
+	// image observations.
+	NoCNN bool
+
+	// InitStd is the initial standard deviation of
+	// Gaussian policies.
+	// See InitOutputLayer.
+	//
+	// If 0, a standard deviation of 1 is used.
+	InitStd float64
+}
+
+// Networks contains a default agent built by
+// BuildNetworks.
+type Networks struct {
+	// Policy maps observations to action space
+	// parameters.
+	Policy anynet.Net
+
+	// Critic maps observations to value estimates.
+	Critic anynet.Net
+
+	// ActionSpace is the action space for Policy's
+	// outputs, as returned by ActionSpaceFor.
+	ActionSpace interface{}
+
+	// Filter is the result of ActionFilterFor, which may
+	// be nil.
+	Filter ActionFilter
+}
+
+// BuildNetworks creates a reasonable default policy and
+// critic for the given observation and action spaces.
+//
+// If the observation space is a Box with a 3-dimensional
+// shape (height, width, depth) that is large enough, the
+// networks start with a small CNN.
+// Otherwise, they are MLPs.
+//
+// The policy's output layer is initialized for the action
+// space with InitOutputLayer, and the critic's output
+// layer starts at zero.
+//
+// If config is nil, the defaults are used.
+func BuildNetworks(c anyvec.Creator, obs, action Space,
+	config *NetworkConfig) (nets *Networks, err error) {
+	defer essentials.AddCtxTo("build networks", &err)
+	if config == nil {
+		config = &NetworkConfig{}
+	}
+	actionSpace, paramSize, err := ActionSpaceFor(action)
+	if err != nil {
+		return nil, err
+	}
+	policy, inSize := config.body(c, obs)
+	policyOut := anynet.NewFC(c, inSize, paramSize)
+	InitOutputLayer(policyOut, actionSpace, config.InitStd)
+	critic, inSize := config.body(c, obs)
+	nets = &Networks{
+		Policy:      append(policy, policyOut),
+		Critic:      append(critic, anynet.NewFCZero(c, inSize, 1)),
+		ActionSpace: actionSpace,
+		Filter:      ActionFilterFor(action),
+	}
+	if err := CheckLayer(nets.Policy, obs, paramSize); err != nil {
+		return nil, err
+	}
+	if err := CheckLayer(nets.Critic, obs, 1); err != nil {
+		return nil, err
+	}
+	return nets, nil
+}
+
+// body creates the network up to (but not including) the
+// output layer and returns the size of its outputs.
+func (n *NetworkConfig) body(c anyvec.Creator, obs Space) (anynet.Net, int) {
+	res, inSize := n.cnn(c, obs)
+	isCNN := res != nil
+	if !isCNN {
+		inSize = obs.VecLen()
+	}
+	for _, size := range n.hiddenSizes() {
+		res = append(res, anynet.NewFC(c, inSize, size), n.activation(isCNN))
+		inSize = size
+	}
+	return res, inSize
+}
+
+// cnn creates the convolutional part of a network, or
+// returns nil if the observations are not images.
+func (n *NetworkConfig) cnn(c anyvec.Creator, obs Space) (anynet.Net, int) {
+	box, ok := obs.(*Box)
+	if n.NoCNN || !ok || len(box.Shape) != 3 || box.Shape[0] < 16 || box.Shape[1] < 16 {
+		return nil, 0
+	}
+	conv1 := &anyconv.Conv{
+		FilterCount:  16,
+		FilterWidth:  8,
+		FilterHeight: 8,
+		StrideX:      4,
+		StrideY:      4,
+		InputHeight:  box.Shape[0],
+		InputWidth:   box.Shape[1],
+		InputDepth:   box.Shape[2],
+	}
+	conv1.InitRand(c)
+	conv2 := &anyconv.Conv{
+		FilterCount:  32,
+		FilterWidth:  4,
+		FilterHeight: 4,
+		StrideX:      2,
+		StrideY:      2,
+		InputHeight:  conv1.OutputHeight(),
+		InputWidth:   conv1.OutputWidth(),
+		InputDepth:   conv1.OutputDepth(),
+	}
+	conv2.InitRand(c)
+	outSize := conv2.OutputWidth() * conv2.OutputHeight() * conv2.OutputDepth()
+	return anynet.Net{conv1, anynet.ReLU, conv2, anynet.ReLU}, outSize
+}
+
+func (n *NetworkConfig) hiddenSizes() []int {
+	if n.HiddenSizes == nil {
+		return []int{DefaultHiddenSize, DefaultHiddenSize}
+	}
+	return n.HiddenSizes
+}
+
+func (n *NetworkConfig) activation(cnn bool) anynet.Layer {
+	if n.Activation != nil {
+		return n.Activation
+	} else if cnn {
+		return anynet.ReLU
+	}
+	return anynet.Tanh
+}
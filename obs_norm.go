@@ -0,0 +1,148 @@
+package anyrl
+
+import (
+	"math"
+	"sync"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	var o ObsNorm
+	serializer.RegisterTypedDeserializer(o.SerializerType(), DeserializeObsNorm)
+}
+
+// DefaultObsNormEpsilon is the default variance fudge
+// factor for ObsNorm.
+const DefaultObsNormEpsilon = 1e-8
+
+// ObsNorm is an anynet.Layer which normalizes each
+// component of its inputs using running estimates of the
+// mean and variance.
+//
+// The statistics are only updated while Collecting is
+// true, which should be the case while gathering rollouts.
+// While computing gradients, Collecting should be false so
+// that the normalization is a constant affine transform.
+// A ModeController can switch Collecting automatically.
+//
+// The statistics are serialized with the layer, so a
+// saved policy normalizes observations the same way it
+// did during training.
+type ObsNorm struct {
+	// Collecting indicates that Apply should update the
+	// statistics with its inputs.
+	Collecting bool
+
+	// Clip, if non-zero, limits the absolute value of
+	// the normalized outputs.
+	Clip float64
+
+	// Epsilon is added to the variance to prevent
+	// division by zero.
+	//
+	// If 0, DefaultObsNormEpsilon is used.
+	Epsilon float64
+
+	// Count, Mean, and M2 store the running statistics,
+	// as in Welford's algorithm.
+	// M2 is the sum of squared deviations from the mean.
+	Count float64
+	Mean  []float64
+	M2    []float64
+
+	lock sync.RWMutex
+}
+
+// DeserializeObsNorm deserializes an ObsNorm.
+func DeserializeObsNorm(d []byte) (*ObsNorm, error) {
+	var res ObsNorm
+	err := serializer.DeserializeAny(d, &res.Collecting, &res.Clip, &res.Epsilon,
+		&res.Count, &res.Mean, &res.M2)
+	if err != nil {
+		return nil, essentials.AddCtx("deserialize ObsNorm", err)
+	}
+	return &res, nil
+}
+
+// Apply normalizes the batch of inputs, updating the
+// statistics first if o.Collecting is true.
+//
+// Until statistics are available, inputs are passed
+// through unchanged.
+func (o *ObsNorm) Apply(in anydiff.Res, n int) anydiff.Res {
+	c := in.Output().Creator()
+	if o.Collecting {
+		o.update(c.Float64Slice(in.Output().Data()), n)
+	}
+
+	o.lock.RLock()
+	if o.Count == 0 {
+		o.lock.RUnlock()
+		return in
+	}
+	scales := make([]float64, len(o.Mean))
+	biases := make([]float64, len(o.Mean))
+	for i, mean := range o.Mean {
+		scales[i] = 1 / math.Sqrt(o.M2[i]/o.Count+o.epsilon())
+		biases[i] = -mean * scales[i]
+	}
+	o.lock.RUnlock()
+
+	res := anydiff.Add(
+		anydiff.Mul(in, anydiff.NewConst(repeatVec(c, scales, n))),
+		anydiff.NewConst(repeatVec(c, biases, n)),
+	)
+	if o.Clip != 0 {
+		res = anydiff.ClipRange(res, c.MakeNumeric(-o.Clip), c.MakeNumeric(o.Clip))
+	}
+	return res
+}
+
+// SerializerType returns the unique ID used to serialize
+// an ObsNorm with the serializer package.
+func (o *ObsNorm) SerializerType() string {
+	return "github.com/unixpickle/anyrl.ObsNorm"
+}
+
+// Serialize serializes the ObsNorm.
+func (o *ObsNorm) Serialize() ([]byte, error) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	return serializer.SerializeAny(o.Collecting, o.Clip, o.Epsilon, o.Count,
+		append([]float64{}, o.Mean...), append([]float64{}, o.M2...))
+}
+
+func (o *ObsNorm) update(data []float64, n int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	size := len(data) / n
+	if o.Mean == nil {
+		o.Mean = make([]float64, size)
+		o.M2 = make([]float64, size)
+	}
+	for i := 0; i < n; i++ {
+		o.Count++
+		for j, x := range data[i*size : (i+1)*size] {
+			delta := x - o.Mean[j]
+			o.Mean[j] += delta / o.Count
+			o.M2[j] += delta * (x - o.Mean[j])
+		}
+	}
+}
+
+func (o *ObsNorm) epsilon() float64 {
+	if o.Epsilon == 0 {
+		return DefaultObsNormEpsilon
+	}
+	return o.Epsilon
+}
+
+func repeatVec(c anyvec.Creator, data []float64, n int) anyvec.Vector {
+	res := c.MakeVector(len(data) * n)
+	anyvec.AddRepeated(res, anyvec.Make(c, data))
+	return res
+}
@@ -0,0 +1,37 @@
+package anyrl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/serializer"
+)
+
+func TestObsNorm(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	layer := &ObsNorm{Collecting: true}
+	layer.Apply(anydiff.NewConst(anyvec.Make(c, []float64{1, 10, 3, 20})), 2)
+	layer.Collecting = false
+
+	copied, err := serializer.Copy(layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []*ObsNorm{layer, copied.(*ObsNorm)} {
+		in := anydiff.NewConst(anyvec.Make(c, []float64{1, 10, 3, 20, 2, 15}))
+		actual := c.Float64Slice(l.Apply(in, 3).Output().Data())
+		expected := []float64{-1, -1, 1, 1, 0, 0}
+		for i, x := range expected {
+			if math.Abs(actual[i]-x) > 1e-4 {
+				t.Errorf("expected %v but got %v", expected, actual)
+				break
+			}
+		}
+		if l.Count != 2 {
+			t.Errorf("expected count 2 but got %f", l.Count)
+		}
+	}
+}
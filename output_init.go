@@ -0,0 +1,62 @@
+package anyrl
+
+import (
+	"math"
+
+	"github.com/unixpickle/anynet"
+)
+
+// DefaultOutputScale is the factor by which InitOutputLayer
+// scales the weights of a policy's output layer.
+const DefaultOutputScale = 0.01
+
+// InitOutputLayer initializes the final layer of a policy
+// for an action space.
+//
+// The weights are scaled by DefaultOutputScale, so the
+// initial policy is close to uniform for Softmax and
+// Bernoulli, and close to zero-mean for Gaussian.
+// The biases are zeroed, except for Gaussian log
+// variances, which are set so that the initial standard
+// deviation is initStd.
+// Tuples are initialized one sub-space at a time.
+//
+// If initStd is 0, a standard deviation of 1 is used.
+func InitOutputLayer(fc *anynet.FC, actionSpace interface{}, initStd float64) {
+	if initStd == 0 {
+		initStd = 1
+	}
+	c := fc.Weights.Vector.Creator()
+	weights := c.Float64Slice(fc.Weights.Vector.Data())
+	biases := c.Float64Slice(fc.Biases.Vector.Data())
+	for i := range weights {
+		weights[i] *= DefaultOutputScale
+	}
+	initOutputBiases(biases, actionSpace, 2*math.Log(initStd))
+	fc.Weights.Vector.SetData(c.MakeNumericList(weights))
+	fc.Biases.Vector.SetData(c.MakeNumericList(biases))
+}
+
+func initOutputBiases(biases []float64, actionSpace interface{}, logVar float64) {
+	switch space := actionSpace.(type) {
+	case Gaussian, *Gaussian:
+		for i := range biases {
+			if i%2 == 0 {
+				biases[i] = 0
+			} else {
+				biases[i] = logVar
+			}
+		}
+	case *Tuple:
+		var offset int
+		for i, sub := range space.Spaces {
+			size := space.ParamSizes[i]
+			initOutputBiases(biases[offset:offset+size], sub, logVar)
+			offset += size
+		}
+	default:
+		for i := range biases {
+			biases[i] = 0
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package anyrl
+
+import "sort"
+
+// ReturnPruner drops episodes from RolloutSets based on
+// their total rewards, e.g. for reward-filtered training.
+type ReturnPruner struct {
+	// Threshold, if non-nil, computes the minimum total
+	// reward for an episode to be kept.
+	// It is passed the number of previous calls to Prune,
+	// so it can be used as a schedule.
+	Threshold func(iter int) float64
+
+	// MinFrac and MaxFrac specify a band of percentiles,
+	// as fractions between 0 and 1.
+	// Only episodes whose ranks (by total reward) fall in
+	// this band are kept.
+	//
+	// If MaxFrac is 0, it is treated as 1.
+	MinFrac float64
+	MaxFrac float64
+
+	iter int
+}
+
+// Prune creates a new RolloutSet with only the episodes
+// that satisfy both the threshold and the percentile band.
+//
+// The result may be empty.
+func (p *ReturnPruner) Prune(r *RolloutSet) *RolloutSet {
+	totals := r.Rewards.Totals()
+	var indices []int
+	for i, seq := range r.Rewards {
+		if len(seq) == 0 {
+			continue
+		}
+		if p.Threshold != nil && totals[i] < p.Threshold(p.iter) {
+			continue
+		}
+		indices = append(indices, i)
+	}
+	p.iter++
+
+	sorted := append([]int{}, indices...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return totals[sorted[i]] < totals[sorted[j]]
+	})
+	maxFrac := p.MaxFrac
+	if maxFrac == 0 {
+		maxFrac = 1
+	}
+	keep := map[int]bool{}
+	for rank, idx := range sorted {
+		frac := (float64(rank) + 0.5) / float64(len(sorted))
+		if frac >= p.MinFrac && frac <= maxFrac {
+			keep[idx] = true
+		}
+	}
+
+	var kept []int
+	for _, idx := range indices {
+		if keep[idx] {
+			kept = append(kept, idx)
+		}
+	}
+	return SelectRollouts(r, kept)
+}
@@ -0,0 +1,93 @@
+package anyrl
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A MetadataEnv is an Env which provides information
+// about the current episode.
+//
+// RNNRoller stores the metadata from each environment in
+// RolloutSet.Metadata after the rollouts finish.
+type MetadataEnv interface {
+	Env
+
+	Metadata() map[string]float64
+}
+
+// EnvMetadata returns the metadata of an Env if it is a
+// MetadataEnv, or nil otherwise.
+func EnvMetadata(e Env) map[string]float64 {
+	if m, ok := e.(MetadataEnv); ok {
+		return m.Metadata()
+	}
+	return nil
+}
+
+// A RandomizableEnv is an Env with adjustable parameters,
+// such as friction or object sizes.
+type RandomizableEnv interface {
+	Env
+
+	// SetParams updates the parameters.
+	// The new parameters should take effect on the next
+	// call to Reset.
+	SetParams(params map[string]float64) error
+}
+
+// A RandomParam declares a range of values for a
+// randomized environment parameter.
+type RandomParam struct {
+	Name string
+	Min  float64
+	Max  float64
+}
+
+// RandomizedEnv implements domain randomization by
+// sampling new parameters for an environment on every
+// reset.
+//
+// Parameters are sampled uniformly from the declared
+// ranges.
+// The sampled parameters are exposed as metadata, so they
+// are recorded in RolloutSets for later analysis.
+type RandomizedEnv struct {
+	Env    RandomizableEnv
+	Params []RandomParam
+
+	current map[string]float64
+}
+
+// Reset samples new parameters and resets the
+// environment.
+func (r *RandomizedEnv) Reset() (obs []float64, err error) {
+	defer essentials.AddCtxTo("reset randomized environment", &err)
+	params := map[string]float64{}
+	for _, p := range r.Params {
+		params[p.Name] = p.Min + rand.Float64()*(p.Max-p.Min)
+	}
+	if err := r.Env.SetParams(params); err != nil {
+		return nil, err
+	}
+	r.current = params
+	return r.Env.Reset()
+}
+
+// Step takes a step in the environment.
+func (r *RandomizedEnv) Step(action []float64) ([]float64, float64, bool, error) {
+	return r.Env.Step(action)
+}
+
+// Metadata returns the parameters sampled on the last
+// reset.
+func (r *RandomizedEnv) Metadata() map[string]float64 {
+	return r.current
+}
+
+// Truncated reports whether the wrapped environment's
+// most recent episode was truncated.
+func (r *RandomizedEnv) Truncated() bool {
+	return EnvTruncated(r.Env)
+}
@@ -31,6 +31,11 @@ type FracReducer struct {
 //
 // The number of rollouts is always rounded up to avoid
 // selecting 0 rollouts.
+//
+// Episodes keep their indices, so per-episode fields
+// like Weights are kept for every episode.
+// Per-timestep fields like Intrinsic are reduced in the
+// same way as Rewards.
 func (f *FracReducer) Reduce(r *RolloutSet) *RolloutSet {
 	numSeqs := len(r.Rewards)
 	numSelected := int(math.Ceil(f.Frac * float64(numSeqs)))
@@ -47,6 +52,10 @@ func (f *FracReducer) Reduce(r *RolloutSet) *RolloutSet {
 	if r.AgentOuts != nil {
 		res.AgentOuts = reduceTape(f.MakeAgentOutTape, r.AgentOuts, present)
 	}
+	selectEpisodeFields(res, []*RolloutSet{r}, [][]int{allEpisodes(r)})
+	if res.Intrinsic != nil {
+		res.Intrinsic = res.Intrinsic.Reduce(present)
+	}
 	return res
 }
 
@@ -0,0 +1,51 @@
+package anyrl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestFracReducerFields(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	rewards := Rewards{{1, 2}, {3}, {4, 5, 6}}
+	r := &RolloutSet{
+		Inputs:    rewards.Tape(c),
+		Actions:   rewards.Tape(c),
+		Rewards:   rewards,
+		Weights:   []float64{1, 2, 3},
+		Metadata:  []map[string]float64{{"x": 1}, {"x": 2}, {"x": 3}},
+		Truncated: []bool{false, true, false},
+		Versions:  []int64{4, 5, 6},
+		Intrinsic: Rewards{{0.1, 0.2}, {0.3}, {0.4, 0.5, 0.6}},
+	}
+	reduced := (&FracReducer{Frac: 0.5}).Reduce(r)
+
+	var numKept int
+	present := make([]bool, len(rewards))
+	for i, seq := range reduced.Rewards {
+		if seq != nil {
+			numKept++
+			present[i] = true
+			if !reflect.DeepEqual(seq, rewards[i]) {
+				t.Errorf("episode %d: expected rewards %v but got %v", i, rewards[i], seq)
+			}
+		}
+		if len(reduced.Intrinsic[i]) != len(seq) {
+			t.Errorf("episode %d: intrinsic rewards have length %d but rewards have %d",
+				i, len(reduced.Intrinsic[i]), len(seq))
+		}
+	}
+	if numKept != 2 {
+		t.Errorf("expected 2 episodes but got %d", numKept)
+	}
+
+	reduced.Inputs, reduced.Actions, reduced.Rewards = nil, nil, nil
+	expected := *r
+	expected.Inputs, expected.Actions, expected.Rewards = nil, nil, nil
+	expected.Intrinsic = r.Intrinsic.Reduce(present)
+	if !reflect.DeepEqual(reduced, &expected) {
+		t.Errorf("expected %+v but got %+v", &expected, reduced)
+	}
+}
@@ -0,0 +1,74 @@
+package anyrl
+
+import "errors"
+
+// RepeatSpace creates an action space for learned action
+// repetition, as in FiGAR.
+//
+// The resulting Tuple combines space, which has the given
+// parameter and sample sizes, with a Softmax over
+// maxRepeat repetition counts.
+// Samples from the space should be fed to a RepeatEnv.
+//
+// Since the result is a Tuple, it can be used with any
+// trainer, and the repetition head is trained jointly with
+// the action head.
+func RepeatSpace(space interface{}, paramSize, sampleSize, maxRepeat int) *Tuple {
+	return &Tuple{
+		Spaces:      []interface{}{space, Softmax{}},
+		ParamSizes:  []int{paramSize, maxRepeat},
+		SampleSizes: []int{sampleSize, maxRepeat},
+	}
+}
+
+// RepeatEnv wraps an Env and repeats each action a number
+// of times chosen by the agent.
+//
+// Each action ends with a one-hot vector of MaxRepeat
+// components, like the ones sampled from a RepeatSpace.
+// If the i-th component is set, then the rest of the
+// action is executed i+1 times.
+//
+// The rewards from the repeated steps are summed, so
+// each agent timestep corresponds to a single decision.
+// The repetition stops early if the episode ends.
+type RepeatEnv struct {
+	Env
+	MaxRepeat int
+}
+
+// Step takes a step in the environment.
+func (r *RepeatEnv) Step(action []float64) (obs []float64, rew float64,
+	done bool, err error) {
+	if len(action) < r.MaxRepeat {
+		return nil, 0, false, errors.New("step: action too short for repetition count")
+	}
+	split := len(action) - r.MaxRepeat
+	count := 1
+	for i, x := range action[split:] {
+		if x != 0 {
+			count = i + 1
+			break
+		}
+	}
+	for i := 0; i < count && !done; i++ {
+		var stepRew float64
+		obs, stepRew, done, err = r.Env.Step(action[:split])
+		if err != nil {
+			return
+		}
+		rew += stepRew
+	}
+	return
+}
+
+// Truncated reports whether the wrapped environment's
+// most recent episode was truncated.
+func (r *RepeatEnv) Truncated() bool {
+	return EnvTruncated(r.Env)
+}
+
+// Metadata returns the wrapped environment's metadata.
+func (r *RepeatEnv) Metadata() map[string]float64 {
+	return EnvMetadata(r.Env)
+}
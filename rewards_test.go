@@ -72,3 +72,18 @@ func TestRewardsReduce(t *testing.T) {
 		t.Errorf("expected %v but got %v", expected, actual)
 	}
 }
+
+func TestRolloutSetApplyWeights(t *testing.T) {
+	r := &RolloutSet{
+		Rewards: Rewards{{1, 2}, {3}},
+		Weights: []float64{0.5, 2},
+	}
+	actual := r.ApplyWeights(Rewards{{2, 4}, {-1}})
+	expected := Rewards{{1, 2}, {-2}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+	if r.Weight(1) != 2 || (&RolloutSet{}).Weight(3) != 1 {
+		t.Error("unexpected episode weight")
+	}
+}
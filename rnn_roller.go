@@ -1,6 +1,7 @@
 package anyrl
 
 import (
+	"errors"
 	"sync"
 
 	"github.com/unixpickle/anydiff/anyseq"
@@ -40,12 +41,50 @@ type RNNRoller struct {
 	MakeInputTape    TapeMaker
 	MakeActionTape   TapeMaker
 	MakeAgentOutTape TapeMaker
+
+	// ActionFilter, if non-nil, is applied to sampled
+	// actions before they are recorded and sent to the
+	// environments.
+	ActionFilter ActionFilter
+
+	// Snapshots, if non-nil, supplies the policy.
+	// Each call to Rollout uses the latest snapshot
+	// instead of Block and records the snapshot's version
+	// in the RolloutSet.
+	Snapshots *PolicyStore
+
+	// Modes, if non-nil, is switched to RolloutMode
+	// before any actions are sampled.
+	Modes *ModeController
+
+	// Intrinsic, if non-nil, is called at every timestep
+	// to compute intrinsic rewards, which are stored in
+	// the RolloutSet's Intrinsic field.
+	Intrinsic IntrinsicRewarder
 }
 
 // Rollout produces one rollout per environment.
 func (r *RNNRoller) Rollout(envs ...Env) (rollouts *RolloutSet, err error) {
 	defer essentials.AddCtxTo("rollout RNN", &err)
 
+	if r.Modes != nil {
+		r.Modes.Set(RolloutMode)
+	}
+
+	block := r.Block
+	var versions []int64
+	if r.Snapshots != nil {
+		snapshot := r.Snapshots.Current()
+		if snapshot == nil {
+			return nil, errors.New("no policy snapshot published")
+		}
+		block = snapshot.Block
+		versions = make([]int64, len(envs))
+		for i := range versions {
+			versions[i] = snapshot.Version
+		}
+	}
+
 	c := r.creator()
 	inputs, inputCh := makeTape(c, r.MakeInputTape)
 	actions, actionCh := makeTape(c, r.MakeActionTape)
@@ -57,52 +96,85 @@ func (r *RNNRoller) Rollout(envs ...Env) (rollouts *RolloutSet, err error) {
 		close(agentOutCh)
 	}()
 
-	rewards, err := r.rolloutChans(inputCh, actionCh, agentOutCh, envs)
+	rewards, intrinsic, finalInputs, err := r.rolloutChans(block, inputCh, actionCh,
+		agentOutCh, envs)
 	if err != nil {
 		return nil, err
 	}
 
 	return &RolloutSet{
-		Inputs:    inputs,
-		Actions:   actions,
-		AgentOuts: agentOuts,
-		Rewards:   rewards,
+		Inputs:      inputs,
+		Actions:     actions,
+		AgentOuts:   agentOuts,
+		Rewards:     rewards,
+		Metadata:    rolloutMetadata(envs),
+		Truncated:   rolloutTruncated(envs),
+		FinalInputs: finalInputs,
+		Versions:    versions,
+		Intrinsic:   intrinsic,
 	}, nil
 }
 
-func (r *RNNRoller) rolloutChans(inputCh, actionCh, agentOutCh chan<- *anyseq.Batch,
-	envs []Env) (Rewards, error) {
+func (r *RNNRoller) rolloutChans(block anyrnn.Block, inputCh, actionCh,
+	agentOutCh chan<- *anyseq.Batch, envs []Env) (rewards, intrinsic Rewards,
+	finalInputs []anyvec.Vector, err error) {
 	if len(envs) == 0 {
-		return nil, nil
+		return nil, nil, nil, nil
 	}
 
 	initBatch, err := rolloutReset(r.creator(), envs)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
+	}
+	rewards = make(Rewards, len(initBatch.Present))
+	if r.Intrinsic != nil {
+		intrinsic = make(Rewards, len(initBatch.Present))
+		r.Intrinsic.Reset(len(envs))
 	}
-	rewards := make(Rewards, len(initBatch.Present))
 
 	inBatch := initBatch
-	state := r.Block.Start(len(initBatch.Present))
+	state := block.Start(len(initBatch.Present))
 	for inBatch.NumPresent() > 0 {
 		inputCh <- inBatch
 
 		if inBatch.NumPresent() < state.Present().NumPresent() {
 			state = state.Reduce(inBatch.Present)
 		}
-		blockRes := r.Block.Step(state, inBatch.Packed)
+		blockRes := block.Step(state, inBatch.Packed)
 		state = blockRes.State()
 
 		out := r.ActionSpace.Sample(blockRes.Output(), inBatch.NumPresent())
+		if r.ActionFilter != nil {
+			out = r.ActionFilter.FilterActions(out, inBatch.NumPresent())
+		}
 		actionBatch := &anyseq.Batch{Packed: out, Present: inBatch.Present}
 
 		actionCh <- actionBatch
 		agentOutCh <- &anyseq.Batch{Packed: blockRes.Output(), Present: inBatch.Present}
 
+		if r.Intrinsic != nil {
+			bonuses := r.Intrinsic.StepRewards(inBatch, actionBatch)
+			for i, pres := range actionBatch.Present {
+				if pres {
+					intrinsic[i] = append(intrinsic[i], bonuses[0])
+					bonuses = bonuses[1:]
+				}
+			}
+		}
+
 		var rewardBatch []float64
-		inBatch, rewardBatch, err = rolloutStep(actionBatch, envs)
+		var finalBatch []anyvec.Vector
+		inBatch, rewardBatch, finalBatch, err = rolloutStep(actionBatch, envs)
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
+		}
+		for i, final := range finalBatch {
+			if final != nil && EnvTruncated(envs[i]) {
+				if finalInputs == nil {
+					finalInputs = make([]anyvec.Vector, len(envs))
+				}
+				finalInputs[i] = final
+			}
 		}
 
 		for i, pres := range actionBatch.Present {
@@ -113,17 +185,54 @@ func (r *RNNRoller) rolloutChans(inputCh, actionCh, agentOutCh chan<- *anyseq.Ba
 		}
 	}
 
-	return rewards, nil
+	return rewards, intrinsic, finalInputs, nil
 }
 
 func (r *RNNRoller) creator() anyvec.Creator {
 	if r.Creator != nil {
 		return r.Creator
+	} else if r.Block == nil && r.Snapshots != nil && r.Snapshots.Current() != nil {
+		return anynet.AllParameters(r.Snapshots.Current().Block)[0].Output().Creator()
 	} else {
 		return anynet.AllParameters(r.Block)[0].Output().Creator()
 	}
 }
 
+// rolloutMetadata gathers the metadata for each
+// environment, or returns nil if no environment provides
+// metadata.
+func rolloutMetadata(envs []Env) []map[string]float64 {
+	res := make([]map[string]float64, len(envs))
+	var found bool
+	for i, e := range envs {
+		if res[i] = EnvMetadata(e); res[i] != nil {
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return res
+}
+
+// rolloutTruncated checks which environments truncated
+// their episodes, or returns nil if no environment
+// supports truncation.
+func rolloutTruncated(envs []Env) []bool {
+	res := make([]bool, len(envs))
+	var found bool
+	for i, e := range envs {
+		if _, ok := e.(TruncationEnv); ok {
+			res[i] = EnvTruncated(e)
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return res
+}
+
 func rolloutReset(c anyvec.Creator, envs []Env) (*anyseq.Batch, error) {
 	initBatch := &anyseq.Batch{
 		Present: make([]bool, len(envs)),
@@ -144,8 +253,13 @@ func rolloutReset(c anyvec.Creator, envs []Env) (*anyseq.Batch, error) {
 	return initBatch, nil
 }
 
+// rolloutStep steps the environments with present
+// actions.
+//
+// For every environment which finished its episode, the
+// final observation is stored in finalObs.
 func rolloutStep(actions *anyseq.Batch, envs []Env) (obs *anyseq.Batch,
-	rewards []float64, err error) {
+	rewards []float64, finalObs []anyvec.Vector, err error) {
 	c := actions.Packed.Creator()
 	obs = &anyseq.Batch{
 		Present: make([]bool, len(actions.Present)),
@@ -169,6 +283,7 @@ func rolloutStep(actions *anyseq.Batch, envs []Env) (obs *anyseq.Batch,
 
 	var presentIdx int
 	var joinObs []float64
+	finalObs = make([]anyvec.Vector, len(envs))
 	for i, pres := range actions.Present {
 		if !pres {
 			continue
@@ -176,11 +291,13 @@ func rolloutStep(actions *anyseq.Batch, envs []Env) (obs *anyseq.Batch,
 		obsVec, done, err := obsVecs[presentIdx], dones[presentIdx], errs[presentIdx]
 		presentIdx++
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		if !done {
 			obs.Present[i] = true
 			joinObs = append(joinObs, obsVec...)
+		} else if obsVec != nil {
+			finalObs[i] = anyvec.Make(c, obsVec)
 		}
 	}
 
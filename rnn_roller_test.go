@@ -127,3 +127,65 @@ func (r *rnnTestEnv) obsVec() []float64 {
 	}
 	return res
 }
+
+func TestRNNRollerActionFilter(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	roller := &RNNRoller{
+		Block:       anyrnn.NewLSTM(c, 3, 4),
+		ActionSpace: Softmax{},
+		ActionFilter: FuncFilter(func(action []float64) []float64 {
+			return []float64{0, 0, 0, 1}
+		}),
+	}
+	env := &rnnTestEnv{RewardScale: 1, EpLen: 5, Observation: []float64{1, 2, 3}}
+	rollouts, err := roller.Rollout(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, rew := range rollouts.Rewards[0] {
+		if rew != 3 {
+			t.Errorf("step %d: expected reward 3 but got %f", i, rew)
+		}
+	}
+	for batch := range rollouts.Actions.ReadTape(0, -1) {
+		actual := batch.Packed.Data().([]float64)
+		if !reflect.DeepEqual(actual, []float64{0, 0, 0, 1}) {
+			t.Errorf("unexpected recorded action: %v", actual)
+		}
+	}
+}
+
+func TestRNNRollerIntrinsic(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	roller := &RNNRoller{
+		Block:       anyrnn.NewLSTM(c, 3, 4),
+		ActionSpace: Softmax{},
+		Intrinsic:   &EpisodicCount{},
+	}
+	envs := []Env{
+		// Zero observations are the same at every step.
+		&rnnTestEnv{RewardScale: 1, EpLen: 3, Observation: []float64{0, 0, 0}},
+		&rnnTestEnv{RewardScale: 1, EpLen: 5, Observation: []float64{0, 0, 0}},
+	}
+	rollouts, err := roller.Rollout(envs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rollouts.Intrinsic) != 2 {
+		t.Fatalf("expected 2 sequences but got %d", len(rollouts.Intrinsic))
+	}
+	for i, seq := range rollouts.Intrinsic {
+		if len(seq) != len(rollouts.Rewards[i]) {
+			t.Errorf("episode %d: expected length %d but got %d", i,
+				len(rollouts.Rewards[i]), len(seq))
+			continue
+		}
+		for step, actual := range seq {
+			expected := 1 / math.Sqrt(float64(step+1))
+			if math.Abs(actual-expected) > 1e-8 {
+				t.Errorf("episode %d step %d: expected %f but got %f", i, step,
+					expected, actual)
+			}
+		}
+	}
+}
@@ -27,6 +27,48 @@ type RolloutSet struct {
 	// This field is mostly meant for agents which are
 	// based on function approximators.
 	AgentOuts lazyseq.Tape
+
+	// Weights, if non-nil, contains a weight for each
+	// episode which scales that episode's contribution
+	// to training.
+	// This can be used for importance-weighted training.
+	//
+	// If nil, every episode has a weight of 1.
+	Weights []float64
+
+	// Metadata, if non-nil, contains information about
+	// each episode, such as randomized environment
+	// parameters.
+	// See MetadataEnv.
+	Metadata []map[string]float64
+
+	// Truncated, if non-nil, indicates which episodes
+	// were ended by a time limit rather than by reaching
+	// a terminal state.
+	// See TruncationEnv.
+	//
+	// If nil, every episode is assumed to have ended in
+	// a terminal state.
+	Truncated []bool
+
+	// FinalInputs, if non-nil, contains the input which
+	// followed the last timestep of each truncated
+	// episode.
+	// It can be used to bootstrap the returns of
+	// truncated episodes from a value estimate.
+	// Entries for other episodes are nil.
+	FinalInputs []anyvec.Vector
+
+	// Versions, if non-nil, contains the version of the
+	// PolicySnapshot which produced each episode.
+	// A version of 0 means that the version is unknown.
+	Versions []int64
+
+	// Intrinsic, if non-nil, contains intrinsic rewards
+	// computed during collection, with the same shape as
+	// Rewards.
+	// See IntrinsicRewarder.
+	Intrinsic Rewards
 }
 
 // PackRolloutSets joins multiple RolloutSets into one
@@ -65,6 +107,12 @@ func PackRolloutSets(c anyvec.Creator, rs []*RolloutSet) *RolloutSet {
 	}
 	res.Rewards = PackRewards(rewards)
 
+	indices := make([][]int, len(rs))
+	for i, r := range rs {
+		indices[i] = allEpisodes(r)
+	}
+	selectEpisodeFields(res, rs, indices)
+
 	return res
 }
 
@@ -82,3 +130,146 @@ func (r *RolloutSet) NumSteps() int {
 	}
 	return count
 }
+
+// Weight returns the weight of the episode at the given
+// index.
+func (r *RolloutSet) Weight(episode int) float64 {
+	if r.Weights == nil {
+		return 1
+	}
+	return r.Weights[episode]
+}
+
+// IsTruncated checks if the episode at the given index
+// was truncated.
+func (r *RolloutSet) IsTruncated(episode int) bool {
+	return r.Truncated != nil && r.Truncated[episode]
+}
+
+// FinalInput returns the input after the last timestep
+// of the episode at the given index, or nil if it was
+// not recorded.
+func (r *RolloutSet) FinalInput(episode int) anyvec.Vector {
+	if r.FinalInputs == nil {
+		return nil
+	}
+	return r.FinalInputs[episode]
+}
+
+// ApplyWeights scales every sequence in a Rewards object
+// by the weight of the corresponding episode.
+//
+// If r.Weights is nil, the values are returned unchanged.
+func (r *RolloutSet) ApplyWeights(values Rewards) Rewards {
+	if r.Weights == nil {
+		return values
+	}
+	res := make(Rewards, len(values))
+	for i, seq := range values {
+		res[i] = make([]float64, len(seq))
+		for t, x := range seq {
+			res[i][t] = x * r.Weights[i]
+		}
+	}
+	return res
+}
+
+// An episodeField is an optional per-episode field of a
+// RolloutSet, such as Weights or Metadata.
+type episodeField struct {
+	// IsSet checks if the field is non-nil.
+	IsSet func(r *RolloutSet) bool
+
+	// Append adds the value of the field for an episode
+	// of src to dst.
+	// If the field is not set in src, a default value is
+	// appended.
+	Append func(dst, src *RolloutSet, episode int)
+}
+
+// episodeFields lists every optional per-episode field.
+//
+// Anything which combines or selects episodes should go
+// through selectEpisodeFields, so that new fields only
+// need to be added here.
+var episodeFields = []episodeField{
+	{
+		IsSet: func(r *RolloutSet) bool { return r.Weights != nil },
+		Append: func(dst, src *RolloutSet, episode int) {
+			dst.Weights = append(dst.Weights, src.Weight(episode))
+		},
+	},
+	{
+		IsSet: func(r *RolloutSet) bool { return r.Metadata != nil },
+		Append: func(dst, src *RolloutSet, episode int) {
+			var info map[string]float64
+			if src.Metadata != nil {
+				info = src.Metadata[episode]
+			}
+			dst.Metadata = append(dst.Metadata, info)
+		},
+	},
+	{
+		IsSet: func(r *RolloutSet) bool { return r.Truncated != nil },
+		Append: func(dst, src *RolloutSet, episode int) {
+			dst.Truncated = append(dst.Truncated, src.IsTruncated(episode))
+		},
+	},
+	{
+		IsSet: func(r *RolloutSet) bool { return r.FinalInputs != nil },
+		Append: func(dst, src *RolloutSet, episode int) {
+			dst.FinalInputs = append(dst.FinalInputs, src.FinalInput(episode))
+		},
+	},
+	{
+		IsSet: func(r *RolloutSet) bool { return r.Versions != nil },
+		Append: func(dst, src *RolloutSet, episode int) {
+			var version int64
+			if src.Versions != nil {
+				version = src.Versions[episode]
+			}
+			dst.Versions = append(dst.Versions, version)
+		},
+	},
+	{
+		IsSet: func(r *RolloutSet) bool { return r.Intrinsic != nil },
+		Append: func(dst, src *RolloutSet, episode int) {
+			if src.Intrinsic != nil {
+				dst.Intrinsic = append(dst.Intrinsic, src.Intrinsic[episode])
+			} else {
+				zeros := make([]float64, len(src.Rewards[episode]))
+				dst.Intrinsic = append(dst.Intrinsic, zeros)
+			}
+		},
+	},
+}
+
+// selectEpisodeFields sets the optional per-episode
+// fields of dst from the given episodes of each source,
+// in order.
+//
+// A field is set in dst if it is set in any source.
+func selectEpisodeFields(dst *RolloutSet, srcs []*RolloutSet, indices [][]int) {
+	for _, field := range episodeFields {
+		var isSet bool
+		for _, src := range srcs {
+			isSet = isSet || field.IsSet(src)
+		}
+		if !isSet {
+			continue
+		}
+		for i, src := range srcs {
+			for _, idx := range indices[i] {
+				field.Append(dst, src, idx)
+			}
+		}
+	}
+}
+
+func allEpisodes(r *RolloutSet) []int {
+	res := make([]int, len(r.Rewards))
+	for i := range res {
+		res[i] = i
+	}
+	return res
+}
@@ -0,0 +1,57 @@
+package anyrl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestPackRolloutSetsFields(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	rewards1 := Rewards{{1, 2}, {3}}
+	rewards2 := Rewards{{4, 5, 6}}
+	r1 := &RolloutSet{
+		Inputs:    rewards1.Tape(c),
+		Actions:   rewards1.Tape(c),
+		Rewards:   rewards1,
+		Weights:   []float64{2, 3},
+		Intrinsic: Rewards{{0.5, 0.25}, {1}},
+	}
+	r2 := &RolloutSet{
+		Inputs:    rewards2.Tape(c),
+		Actions:   rewards2.Tape(c),
+		Rewards:   rewards2,
+		Metadata:  []map[string]float64{{"x": 1}},
+		Truncated: []bool{true},
+		Versions:  []int64{7},
+	}
+	packed := PackRolloutSets(c, []*RolloutSet{r1, r2})
+
+	expected := &RolloutSet{
+		Rewards:   Rewards{{1, 2}, {3}, {4, 5, 6}},
+		Weights:   []float64{2, 3, 1},
+		Metadata:  []map[string]float64{nil, nil, {"x": 1}},
+		Truncated: []bool{false, false, true},
+		Versions:  []int64{0, 0, 7},
+		Intrinsic: Rewards{{0.5, 0.25}, {1}, {0, 0, 0}},
+	}
+	packed.Inputs, packed.Actions = nil, nil
+	if !reflect.DeepEqual(packed, expected) {
+		t.Errorf("expected %+v but got %+v", expected, packed)
+	}
+
+	selected := SelectRollouts(PackRolloutSets(c, []*RolloutSet{r1, r2}), []int{2, 0})
+	expected = &RolloutSet{
+		Rewards:   Rewards{{4, 5, 6}, {1, 2}},
+		Weights:   []float64{1, 2},
+		Metadata:  []map[string]float64{{"x": 1}, nil},
+		Truncated: []bool{true, false},
+		Versions:  []int64{7, 0},
+		Intrinsic: Rewards{{0, 0, 0}, {0.5, 0.25}},
+	}
+	selected.Inputs, selected.Actions = nil, nil
+	if !reflect.DeepEqual(selected, expected) {
+		t.Errorf("expected %+v but got %+v", expected, selected)
+	}
+}
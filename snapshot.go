@@ -0,0 +1,82 @@
+package anyrl
+
+import (
+	"sync"
+
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+// A PolicySnapshot is an immutable copy of a policy.
+type PolicySnapshot struct {
+	// Version identifies the snapshot.
+	// Versions start at 1 and increase with every
+	// published snapshot.
+	Version int64
+
+	Block anyrnn.Block
+}
+
+// A PolicyStore holds the latest published snapshot of a
+// policy.
+//
+// A learner publishes snapshots as it updates the policy,
+// while collectors (e.g. RNNRollers with a Snapshots
+// field) act with the latest snapshot.
+// Since a snapshot is never modified, every rollout is
+// produced by a single, consistent parameter version,
+// even while the learner is changing its weights.
+//
+// All of the methods are safe to call concurrently.
+type PolicyStore struct {
+	lock    sync.RWMutex
+	current *PolicySnapshot
+}
+
+// Publish copies the policy and makes the copy the latest
+// snapshot.
+//
+// The policy must work with serializer.Copy.
+// It should not be modified while Publish is running.
+func (p *PolicyStore) Publish(policy anyrnn.Block) (snapshot *PolicySnapshot, err error) {
+	defer essentials.AddCtxTo("publish policy", &err)
+	copied, err := serializer.Copy(policy)
+	if err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var version int64 = 1
+	if p.current != nil {
+		version = p.current.Version + 1
+	}
+	p.current = &PolicySnapshot{Version: version, Block: copied.(anyrnn.Block)}
+	return p.current, nil
+}
+
+// Current returns the latest snapshot, or nil if nothing
+// has been published.
+func (p *PolicyStore) Current() *PolicySnapshot {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.current
+}
+
+// Staleness returns how many versions behind the latest
+// snapshot each episode in a RolloutSet is.
+//
+// Episodes without a recorded version are reported as 0.
+func (p *PolicyStore) Staleness(r *RolloutSet) []int64 {
+	current := p.Current()
+	res := make([]int64, len(r.Rewards))
+	if current == nil || r.Versions == nil {
+		return res
+	}
+	for i, v := range r.Versions {
+		if v != 0 {
+			res[i] = current.Version - v
+		}
+	}
+	return res
+}
@@ -0,0 +1,52 @@
+package anyrl
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestPolicyStore(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	block := anyrnn.NewLSTM(c, 3, 4)
+	store := &PolicyStore{}
+	roller := &RNNRoller{ActionSpace: Softmax{}, Snapshots: store}
+
+	if _, err := roller.Rollout(&rnnTestEnv{EpLen: 2, Observation: []float64{1, 2, 3}}); err == nil {
+		t.Error("expected error without a snapshot")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := store.Publish(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot := store.Current()
+	if snapshot.Version != 2 {
+		t.Errorf("expected version 2 but got %d", snapshot.Version)
+	}
+	if snapshot.Block == anyrnn.Block(block) {
+		t.Error("snapshot should be a copy")
+	}
+
+	envs := []Env{
+		&rnnTestEnv{EpLen: 2, Observation: []float64{1, 2, 3}},
+		&rnnTestEnv{EpLen: 3, Observation: []float64{1, 2, 3}},
+	}
+	rollouts, err := roller.Rollout(envs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rollouts.Versions) != 2 || rollouts.Versions[0] != 2 || rollouts.Versions[1] != 2 {
+		t.Errorf("unexpected versions: %v", rollouts.Versions)
+	}
+
+	if _, err := store.Publish(block); err != nil {
+		t.Fatal(err)
+	}
+	staleness := store.Staleness(rollouts)
+	if staleness[0] != 1 || staleness[1] != 1 {
+		t.Errorf("unexpected staleness: %v", staleness)
+	}
+}
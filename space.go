@@ -0,0 +1,225 @@
+package anyrl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	gym "github.com/unixpickle/gym-socket-api/binding-go"
+)
+
+// A Space describes the observations or actions of an
+// environment.
+//
+// Every space is represented as a flat vector of VecLen
+// components.
+type Space interface {
+	VecLen() int
+}
+
+// A SpaceEnv is an Env which describes its observation
+// and action spaces.
+type SpaceEnv interface {
+	Env
+
+	ObservationSpace() Space
+	ActionSpace() Space
+}
+
+// Box is a space of real-valued vectors.
+type Box struct {
+	// Shape is the shape of the (flattened) vectors.
+	Shape []int
+
+	// Low and High contain the bounds of each component.
+	// A nil slice indicates that there is no bound.
+	Low  []float64
+	High []float64
+}
+
+// VecLen returns the product of the shape.
+func (b *Box) VecLen() int {
+	res := 1
+	for _, x := range b.Shape {
+		res *= x
+	}
+	return res
+}
+
+// Bounded checks if the box has finite bounds on every
+// component.
+func (b *Box) Bounded() bool {
+	if b.Low == nil || b.High == nil {
+		return false
+	}
+	for i, low := range b.Low {
+		if math.IsInf(low, 0) || math.IsInf(b.High[i], 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// Discrete is a space of N choices, represented as
+// one-hot vectors.
+type Discrete struct {
+	N int
+}
+
+// VecLen returns N.
+func (d *Discrete) VecLen() int {
+	return d.N
+}
+
+// MultiBinary is a space of N binary values.
+type MultiBinary struct {
+	N int
+}
+
+// VecLen returns N.
+func (m *MultiBinary) VecLen() int {
+	return m.N
+}
+
+// Dict is a space made up of named sub-spaces, which are
+// concatenated in order.
+type Dict struct {
+	Names  []string
+	Spaces []Space
+}
+
+// VecLen returns the total size of the sub-spaces.
+func (d *Dict) VecLen() int {
+	var res int
+	for _, s := range d.Spaces {
+		res += s.VecLen()
+	}
+	return res
+}
+
+// Layout creates an ObsLayout for the sub-spaces.
+func (d *Dict) Layout() *ObsLayout {
+	res := &ObsLayout{Names: d.Names}
+	for _, s := range d.Spaces {
+		res.Sizes = append(res.Sizes, s.VecLen())
+	}
+	return res
+}
+
+// ActionSpaceFor selects an action space which samples
+// from the given Space.
+//
+// It returns the action space (e.g. Softmax or Gaussian)
+// and the number of parameters the policy must produce.
+//
+// Boxes are mapped to Gaussian distributions, which may
+// sample values outside of the box; see ActionFilterFor.
+func ActionSpaceFor(s Space) (actionSpace interface{}, paramSize int, err error) {
+	defer essentials.AddCtxTo("select action space", &err)
+	switch s := s.(type) {
+	case *Discrete:
+		return Softmax{}, s.N, nil
+	case *MultiBinary:
+		return &Bernoulli{}, s.N, nil
+	case *Box:
+		return Gaussian{}, 2 * s.VecLen(), nil
+	case *Dict:
+		res := &Tuple{}
+		var totalSize int
+		for _, sub := range s.Spaces {
+			subSpace, subSize, err := ActionSpaceFor(sub)
+			if err != nil {
+				return nil, 0, err
+			}
+			res.Spaces = append(res.Spaces, subSpace)
+			res.ParamSizes = append(res.ParamSizes, subSize)
+			res.SampleSizes = append(res.SampleSizes, sub.VecLen())
+			totalSize += subSize
+		}
+		return res, totalSize, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported space: %T", s)
+	}
+}
+
+// ActionFilterFor creates an ActionFilter which keeps
+// actions inside a Space.
+//
+// It returns nil if no filtering is needed.
+func ActionFilterFor(s Space) ActionFilter {
+	if b, ok := s.(*Box); ok && (b.Low != nil || b.High != nil) {
+		return &ClampFilter{Low: b.Low, High: b.High}
+	}
+	return nil
+}
+
+// CheckLayer validates that a layer maps vectors from the
+// observation space to paramSize outputs.
+func CheckLayer(layer anynet.Layer, obs Space, paramSize int) (err error) {
+	defer essentials.AddCtxTo("check layer", &err)
+	c := layerCreator(anynet.AllParameters(layer))
+	if c == nil {
+		return errors.New("layer has no parameters")
+	}
+	in := anydiff.NewConst(c.MakeVector(obs.VecLen()))
+	return checkOutputSize(layer.Apply(in, 1).Output(), paramSize)
+}
+
+// CheckBlock validates that a block maps vectors from the
+// observation space to paramSize outputs.
+func CheckBlock(block anyrnn.Block, obs Space, paramSize int) (err error) {
+	defer essentials.AddCtxTo("check block", &err)
+	c := layerCreator(anynet.AllParameters(block))
+	if c == nil {
+		return errors.New("block has no parameters")
+	}
+	res := block.Step(block.Start(1), c.MakeVector(obs.VecLen()))
+	return checkOutputSize(res.Output(), paramSize)
+}
+
+func checkOutputSize(out anyvec.Vector, paramSize int) error {
+	if out.Len() != paramSize {
+		return fmt.Errorf("expected %d outputs but got %d", paramSize, out.Len())
+	}
+	return nil
+}
+
+func layerCreator(params []*anydiff.Var) anyvec.Creator {
+	if len(params) == 0 {
+		return nil
+	}
+	return params[0].Output().Creator()
+}
+
+// spaceFromGym converts a gym space to a Space.
+//
+// Tuples are converted to Dicts with names "0", "1", etc.
+func spaceFromGym(s *gym.Space) (Space, error) {
+	switch s.Type {
+	case "Box":
+		return &Box{Shape: s.Shape, Low: s.Low, High: s.High}, nil
+	case "Discrete":
+		return &Discrete{N: s.N}, nil
+	case "MultiBinary":
+		return &MultiBinary{N: s.N}, nil
+	case "Tuple":
+		res := &Dict{}
+		for i, subSpace := range s.Subspaces {
+			sub, err := spaceFromGym(subSpace)
+			if err != nil {
+				return nil, err
+			}
+			res.Names = append(res.Names, strconv.Itoa(i))
+			res.Spaces = append(res.Spaces, sub)
+		}
+		return res, nil
+	default:
+		return nil, errors.New("unsupported space: " + s.Type)
+	}
+}
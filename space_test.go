@@ -0,0 +1,159 @@
+package anyrl
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestActionSpaceFor(t *testing.T) {
+	space := &Dict{
+		Names: []string{"move", "jump", "torque"},
+		Spaces: []Space{
+			&Discrete{N: 3},
+			&MultiBinary{N: 2},
+			&Box{Shape: []int{2}, Low: []float64{-1, -1}, High: []float64{1, 1}},
+		},
+	}
+	if space.VecLen() != 7 {
+		t.Errorf("expected VecLen 7 but got %d", space.VecLen())
+	}
+	actionSpace, paramSize, err := ActionSpaceFor(space)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paramSize != 9 {
+		t.Errorf("expected 9 params but got %d", paramSize)
+	}
+	tuple := actionSpace.(*Tuple)
+	if _, ok := tuple.Spaces[2].(Gaussian); !ok {
+		t.Errorf("expected Gaussian but got %T", tuple.Spaces[2])
+	}
+	if ActionFilterFor(space.Spaces[2]) == nil {
+		t.Error("expected filter for bounded box")
+	}
+
+	c := anyvec64.DefaultCreator{}
+	obs := &Box{Shape: []int{4}}
+	if err := CheckLayer(anynet.NewFC(c, 4, paramSize), obs, paramSize); err != nil {
+		t.Error(err)
+	}
+	if err := CheckLayer(anynet.NewFC(c, 4, 3), obs, paramSize); err == nil {
+		t.Error("expected error for mismatched output size")
+	}
+}
+
+func TestBuildNetworks(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	spaces := []Space{&Box{Shape: []int{4}}, &Box{Shape: []int{32, 32, 3}}}
+	for _, obs := range spaces {
+		nets, err := BuildNetworks(c, obs, &Discrete{N: 3}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := nets.ActionSpace.(Softmax); !ok {
+			t.Errorf("expected Softmax but got %T", nets.ActionSpace)
+		}
+	}
+}
+
+func TestInitOutputLayer(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	space := &Tuple{
+		Spaces:      []interface{}{Softmax{}, Gaussian{}},
+		ParamSizes:  []int{2, 4},
+		SampleSizes: []int{2, 2},
+	}
+	fc := anynet.NewFC(c, 3, 6)
+	InitOutputLayer(fc, space, 0.5)
+
+	biases := c.Float64Slice(fc.Biases.Vector.Data())
+	logVar := 2 * math.Log(0.5)
+	expected := []float64{0, 0, 0, logVar, 0, logVar}
+	for i, x := range expected {
+		if math.Abs(biases[i]-x) > 1e-8 {
+			t.Errorf("expected biases %v but got %v", expected, biases)
+			break
+		}
+	}
+	for _, w := range c.Float64Slice(fc.Weights.Vector.Data()) {
+		if math.Abs(w) > 0.1 {
+			t.Errorf("weight too large: %f", w)
+		}
+	}
+}
+
+func TestInitOutputLayerSpaces(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	logVar := 2 * math.Log(0.5)
+	for _, testCase := range []struct {
+		Name     string
+		Space    interface{}
+		Biases   []float64
+		Logits   []bool
+		InitStd  float64
+		OutCount int
+	}{
+		{
+			Name:     "Softmax",
+			Space:    Softmax{},
+			Biases:   []float64{0, 0, 0},
+			Logits:   []bool{true, true, true},
+			InitStd:  0.5,
+			OutCount: 3,
+		},
+		{
+			Name:     "Gaussian",
+			Space:    &Gaussian{},
+			Biases:   []float64{0, logVar, 0, logVar},
+			Logits:   []bool{true, false, true, false},
+			InitStd:  0.5,
+			OutCount: 4,
+		},
+		{
+			Name:     "DefaultStd",
+			Space:    Gaussian{},
+			Biases:   []float64{0, 0},
+			Logits:   []bool{true, false},
+			OutCount: 2,
+		},
+		{
+			Name: "Tuple",
+			Space: &Tuple{
+				Spaces:     []interface{}{Softmax{}, &Gaussian{}},
+				ParamSizes: []int{2, 4},
+			},
+			Biases:   []float64{0, 0, 0, logVar, 0, logVar},
+			Logits:   []bool{true, true, true, false, true, false},
+			InitStd:  0.5,
+			OutCount: 6,
+		},
+	} {
+		fc := anynet.NewFC(c, 4, testCase.OutCount)
+		fc.Biases.Vector.AddScalar(c.MakeNumeric(3))
+		InitOutputLayer(fc, testCase.Space, testCase.InitStd)
+
+		biases := c.Float64Slice(fc.Biases.Vector.Data())
+		for i, x := range testCase.Biases {
+			if math.Abs(biases[i]-x) > 1e-8 {
+				t.Errorf("%s: expected biases %v but got %v", testCase.Name,
+					testCase.Biases, biases)
+				break
+			}
+		}
+
+		// The outputs without biases should be small for
+		// reasonably-sized inputs.
+		in := anydiff.NewConst(anyvec.Make(c, []float64{1, -1, 1, -1}))
+		out := c.Float64Slice(fc.Apply(in, 1).Output().Data())
+		for i, x := range out {
+			if testCase.Logits[i] && math.Abs(x-biases[i]) > 0.05 {
+				t.Errorf("%s: output %d is too large: %f", testCase.Name, i, x)
+			}
+		}
+	}
+}
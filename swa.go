@@ -0,0 +1,93 @@
+package anyrl
+
+import (
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+)
+
+// SWA implements stochastic weight averaging, which keeps
+// a running average of parameters across training
+// iterations.
+//
+// The averaged parameters can be swapped in for evaluation
+// or deployment, which tends to be more stable than using
+// the parameters from the final iteration.
+type SWA struct {
+	Params []*anydiff.Var
+
+	// Window, if non-zero, limits the average to the most
+	// recent Window snapshots.
+	//
+	// If 0, every snapshot is averaged equally.
+	Window int
+
+	sums      []anyvec.Vector
+	snapshots [][]anyvec.Vector
+	count     int
+}
+
+// Update adds a snapshot of the current parameters to the
+// average.
+func (s *SWA) Update() {
+	if s.sums == nil {
+		for _, p := range s.Params {
+			s.sums = append(s.sums, p.Vector.Creator().MakeVector(p.Vector.Len()))
+		}
+	}
+	var snapshot []anyvec.Vector
+	for i, p := range s.Params {
+		s.sums[i].Add(p.Vector)
+		if s.Window != 0 {
+			snapshot = append(snapshot, p.Vector.Copy())
+		}
+	}
+	s.count++
+	if s.Window != 0 {
+		s.snapshots = append(s.snapshots, snapshot)
+		if len(s.snapshots) > s.Window {
+			for i, old := range s.snapshots[0] {
+				s.sums[i].Sub(old)
+			}
+			s.snapshots = s.snapshots[1:]
+			s.count--
+		}
+	}
+}
+
+// Count returns the number of snapshots in the average.
+func (s *SWA) Count() int {
+	return s.count
+}
+
+// Average computes the averaged value of each parameter.
+//
+// If no snapshots have been taken, the current parameter
+// values are returned.
+func (s *SWA) Average() []anyvec.Vector {
+	var res []anyvec.Vector
+	for i, p := range s.Params {
+		if s.count == 0 {
+			res = append(res, p.Vector.Copy())
+			continue
+		}
+		avg := s.sums[i].Copy()
+		avg.Scale(avg.Creator().MakeNumeric(1 / float64(s.count)))
+		res = append(res, avg)
+	}
+	return res
+}
+
+// Apply sets the parameters to their averaged values and
+// returns a function which restores the previous values.
+func (s *SWA) Apply() (restore func()) {
+	var backup []anyvec.Vector
+	for i, avg := range s.Average() {
+		backup = append(backup, s.Params[i].Vector.Copy())
+		s.Params[i].Vector.Set(avg)
+	}
+	return func() {
+		for i, old := range backup {
+			s.Params[i].Vector.Set(old)
+		}
+	}
+}
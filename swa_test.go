@@ -0,0 +1,35 @@
+package anyrl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestSWA(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	param := anydiff.NewVar(anyvec.Make(c, []float64{1, 2}))
+	swa := &SWA{Params: []*anydiff.Var{param}, Window: 2}
+
+	for _, values := range [][]float64{{100, 100}, {2, 4}, {4, 8}} {
+		param.Vector.Set(anyvec.Make(c, values))
+		swa.Update()
+	}
+	if swa.Count() != 2 {
+		t.Errorf("expected count 2 but got %d", swa.Count())
+	}
+
+	restore := swa.Apply()
+	if actual := c.Float64Slice(param.Vector.Data()); !reflect.DeepEqual(actual,
+		[]float64{3, 6}) {
+		t.Errorf("unexpected average: %v", actual)
+	}
+	restore()
+	if actual := c.Float64Slice(param.Vector.Data()); !reflect.DeepEqual(actual,
+		[]float64{4, 8}) {
+		t.Errorf("unexpected restored value: %v", actual)
+	}
+}
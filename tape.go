@@ -0,0 +1,110 @@
+package anyrl
+
+import (
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/lazyseq"
+)
+
+// MapTape creates a tape by applying f to every batch of
+// another tape.
+//
+// The tape is produced lazily in the background, so f
+// should be safe to call from another goroutine.
+func MapTape(t lazyseq.Tape, f func(b *anyseq.Batch) *anyseq.Batch) lazyseq.Tape {
+	res, writer := lazyseq.ReferenceTape(t.Creator())
+	go func() {
+		defer close(writer)
+		for batch := range t.ReadTape(0, -1) {
+			writer <- f(batch)
+		}
+	}()
+	return res
+}
+
+// ZipTapes creates a tape by applying f to the
+// corresponding batches of multiple tapes.
+//
+// The tapes must have the same length.
+// As with MapTape, f is called in the background.
+func ZipTapes(f func(bs ...*anyseq.Batch) *anyseq.Batch, ts ...lazyseq.Tape) lazyseq.Tape {
+	if len(ts) == 0 {
+		panic("no tapes to zip")
+	}
+	res, writer := lazyseq.ReferenceTape(ts[0].Creator())
+	go func() {
+		defer close(writer)
+		var chans []<-chan *anyseq.Batch
+		for _, t := range ts {
+			chans = append(chans, t.ReadTape(0, -1))
+		}
+		for {
+			batches := make([]*anyseq.Batch, len(chans))
+			for i, ch := range chans {
+				var ok bool
+				if batches[i], ok = <-ch; !ok {
+					drainTapes(chans)
+					return
+				}
+			}
+			writer <- f(batches...)
+		}
+	}()
+	return res
+}
+
+// ConcatTapes creates a tape whose vectors are the
+// concatenations of the corresponding vectors in the
+// tapes, e.g. to join observations with extra features.
+//
+// The tapes must have the same length and the same
+// present sequences at every timestep.
+func ConcatTapes(ts ...lazyseq.Tape) lazyseq.Tape {
+	return ZipTapes(func(bs ...*anyseq.Batch) *anyseq.Batch {
+		c := bs[0].Packed.Creator()
+		var splits [][]anyvec.Vector
+		for _, b := range bs {
+			splits = append(splits, splitBatch(b))
+		}
+		var parts []anyvec.Vector
+		for i := range bs[0].Present {
+			for _, split := range splits {
+				if split[i] != nil {
+					parts = append(parts, split[i])
+				}
+			}
+		}
+		return &anyseq.Batch{Packed: concatOrEmpty(c, parts), Present: bs[0].Present}
+	}, ts...)
+}
+
+// SliceTape creates a tape containing the components in
+// the range [start, end) of each vector in another tape.
+func SliceTape(t lazyseq.Tape, start, end int) lazyseq.Tape {
+	return MapTape(t, func(b *anyseq.Batch) *anyseq.Batch {
+		var parts []anyvec.Vector
+		for _, chunk := range splitBatch(b) {
+			if chunk != nil {
+				parts = append(parts, chunk.Slice(start, end))
+			}
+		}
+		return &anyseq.Batch{
+			Packed:  concatOrEmpty(b.Packed.Creator(), parts),
+			Present: b.Present,
+		}
+	})
+}
+
+func concatOrEmpty(c anyvec.Creator, vecs []anyvec.Vector) anyvec.Vector {
+	if len(vecs) == 0 {
+		return c.MakeVector(0)
+	}
+	return c.Concat(vecs...)
+}
+
+func drainTapes(chans []<-chan *anyseq.Batch) {
+	for _, ch := range chans {
+		for _ = range ch {
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package anyrl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/lazyseq"
+)
+
+func TestTapeCombinators(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	t1 := Rewards{{1, 2}, {3}}.Tape(c)
+	t2 := Rewards{{4, 5}, {6}}.Tape(c)
+
+	doubled := MapTape(t1, func(b *anyseq.Batch) *anyseq.Batch {
+		packed := b.Packed.Copy()
+		packed.Scale(2.0)
+		return &anyseq.Batch{Packed: packed, Present: b.Present}
+	})
+	expected := [][]float64{{2, 6}, {4}}
+	if actual := tapeData(doubled); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("MapTape: expected %v but got %v", expected, actual)
+	}
+
+	concat := ConcatTapes(t1, t2)
+	expected = [][]float64{{1, 4, 3, 6}, {2, 5}}
+	if actual := tapeData(concat); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("ConcatTapes: expected %v but got %v", expected, actual)
+	}
+
+	sliced := SliceTape(concat, 1, 2)
+	expected = [][]float64{{4, 6}, {5}}
+	if actual := tapeData(sliced); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("SliceTape: expected %v but got %v", expected, actual)
+	}
+}
+
+func tapeData(t lazyseq.Tape) [][]float64 {
+	var res [][]float64
+	for batch := range t.ReadTape(0, -1) {
+		res = append(res, batch.Packed.Data().([]float64))
+	}
+	return res
+}
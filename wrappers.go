@@ -2,6 +2,7 @@ package anyrl
 
 import (
 	"errors"
+	"math/rand"
 )
 
 // MetaEnv is a meta-learning environment in which
@@ -80,16 +81,21 @@ func (m *MetaEnv) Step(act []float64) (obs []float64, rew float64,
 
 // MaxStepsEnv wraps an Env and ends episodes early if
 // they run longer than MaxSteps timesteps.
+//
+// Episodes which are ended early are reported as
+// truncated, since their final states are not terminal.
 type MaxStepsEnv struct {
 	Env
 	MaxSteps int
 
-	steps int
+	steps     int
+	truncated bool
 }
 
 // Reset resets the environment.
 func (m *MaxStepsEnv) Reset() ([]float64, error) {
 	m.steps = 0
+	m.truncated = false
 	return m.Env.Reset()
 }
 
@@ -97,8 +103,239 @@ func (m *MaxStepsEnv) Reset() ([]float64, error) {
 func (m *MaxStepsEnv) Step(action []float64) ([]float64, float64, bool, error) {
 	obs, rew, done, err := m.Env.Step(action)
 	m.steps++
-	if m.steps == m.MaxSteps {
+	if done {
+		m.truncated = EnvTruncated(m.Env)
+	} else if m.steps == m.MaxSteps {
 		done = true
+		m.truncated = true
 	}
 	return obs, rew, done, err
 }
+
+// Truncated reports whether the most recent episode was
+// ended by the step limit (or truncated by the wrapped
+// environment).
+func (m *MaxStepsEnv) Truncated() bool {
+	return m.truncated
+}
+
+// Metadata returns the wrapped environment's metadata.
+func (m *MaxStepsEnv) Metadata() map[string]float64 {
+	return EnvMetadata(m.Env)
+}
+
+// DelayEnv wraps an Env and withholds rewards, delivering
+// them later in the episode.
+// This can be used to test how well an algorithm assigns
+// credit to actions with delayed consequences.
+//
+// Any rewards still pending when the episode ends are
+// delivered on the final timestep.
+type DelayEnv struct {
+	Env
+
+	// Delay is the number of timesteps by which rewards
+	// are delayed.
+	Delay int
+
+	// If AtEnd is set, all rewards are delivered on the
+	// final timestep and Delay is ignored.
+	AtEnd bool
+
+	pending []float64
+}
+
+// Reset resets the environment.
+func (d *DelayEnv) Reset() ([]float64, error) {
+	d.pending = nil
+	return d.Env.Reset()
+}
+
+// Step takes a step in the environment.
+func (d *DelayEnv) Step(action []float64) ([]float64, float64, bool, error) {
+	obs, rew, done, err := d.Env.Step(action)
+	if err != nil {
+		return obs, rew, done, err
+	}
+	d.pending = append(d.pending, rew)
+	if done {
+		var total float64
+		for _, r := range d.pending {
+			total += r
+		}
+		d.pending = nil
+		return obs, total, done, err
+	}
+	if d.AtEnd || len(d.pending) <= d.Delay {
+		return obs, 0, done, err
+	}
+	rew = d.pending[0]
+	d.pending = d.pending[1:]
+	return obs, rew, done, err
+}
+
+// FrameDiffEnv wraps an Env and replaces observations with
+// the difference between the current and previous
+// observations.
+// This is an alternative to frame stacking for tasks
+// where motion is what matters.
+//
+// The first observation of an episode is differenced
+// against a zero observation.
+type FrameDiffEnv struct {
+	Env
+
+	// If Concat is set, the current observation is
+	// appended to the difference, doubling the size of
+	// the observations.
+	Concat bool
+
+	last []float64
+}
+
+// Reset resets the environment.
+func (f *FrameDiffEnv) Reset() ([]float64, error) {
+	obs, err := f.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	f.last = make([]float64, len(obs))
+	return f.diff(obs), nil
+}
+
+// Step takes a step in the environment.
+func (f *FrameDiffEnv) Step(action []float64) ([]float64, float64, bool, error) {
+	obs, rew, done, err := f.Env.Step(action)
+	if err != nil {
+		return nil, rew, done, err
+	}
+	return f.diff(obs), rew, done, nil
+}
+
+func (f *FrameDiffEnv) diff(obs []float64) []float64 {
+	res := make([]float64, len(obs))
+	for i, x := range obs {
+		res[i] = x - f.last[i]
+	}
+	f.last = append([]float64{}, obs...)
+	if f.Concat {
+		res = append(res, obs...)
+	}
+	return res
+}
+
+// RewardDropEnv wraps an Env and randomly replaces
+// rewards with zero.
+// This can be used to test how robust an algorithm is to
+// sparse or noisy reward signals.
+type RewardDropEnv struct {
+	Env
+
+	// DropProb is the probability that each reward is
+	// dropped.
+	DropProb float64
+
+	// Rand, if non-nil, is used to decide which rewards
+	// to drop.
+	// If nil, the math/rand package is used.
+	Rand *rand.Rand
+}
+
+// Step takes a step in the environment.
+func (r *RewardDropEnv) Step(action []float64) ([]float64, float64, bool, error) {
+	obs, rew, done, err := r.Env.Step(action)
+	if err != nil {
+		return obs, rew, done, err
+	}
+	var sample float64
+	if r.Rand != nil {
+		sample = r.Rand.Float64()
+	} else {
+		sample = rand.Float64()
+	}
+	if sample < r.DropProb {
+		rew = 0
+	}
+	return obs, rew, done, nil
+}
+
+// BinaryRewardEnv wraps an Env and replaces each reward
+// with 1 if it exceeds a threshold, or 0 otherwise.
+// This removes information about reward magnitudes, as
+// in many sparse tasks.
+type BinaryRewardEnv struct {
+	Env
+
+	// Threshold is the value which rewards must exceed
+	// to become 1.
+	Threshold float64
+}
+
+// Step takes a step in the environment.
+func (b *BinaryRewardEnv) Step(action []float64) ([]float64, float64, bool, error) {
+	obs, rew, done, err := b.Env.Step(action)
+	if err != nil {
+		return obs, rew, done, err
+	}
+	if rew > b.Threshold {
+		rew = 1
+	} else {
+		rew = 0
+	}
+	return obs, rew, done, nil
+}
+
+// Truncated reports whether the wrapped environment's
+// most recent episode was truncated.
+func (m *MetaEnv) Truncated() bool {
+	return EnvTruncated(m.Env)
+}
+
+// Metadata returns the wrapped environment's metadata.
+func (m *MetaEnv) Metadata() map[string]float64 {
+	return EnvMetadata(m.Env)
+}
+
+// Truncated reports whether the wrapped environment's
+// most recent episode was truncated.
+func (d *DelayEnv) Truncated() bool {
+	return EnvTruncated(d.Env)
+}
+
+// Metadata returns the wrapped environment's metadata.
+func (d *DelayEnv) Metadata() map[string]float64 {
+	return EnvMetadata(d.Env)
+}
+
+// Truncated reports whether the wrapped environment's
+// most recent episode was truncated.
+func (f *FrameDiffEnv) Truncated() bool {
+	return EnvTruncated(f.Env)
+}
+
+// Metadata returns the wrapped environment's metadata.
+func (f *FrameDiffEnv) Metadata() map[string]float64 {
+	return EnvMetadata(f.Env)
+}
+
+// Truncated reports whether the wrapped environment's
+// most recent episode was truncated.
+func (r *RewardDropEnv) Truncated() bool {
+	return EnvTruncated(r.Env)
+}
+
+// Metadata returns the wrapped environment's metadata.
+func (r *RewardDropEnv) Metadata() map[string]float64 {
+	return EnvMetadata(r.Env)
+}
+
+// Truncated reports whether the wrapped environment's
+// most recent episode was truncated.
+func (b *BinaryRewardEnv) Truncated() bool {
+	return EnvTruncated(b.Env)
+}
+
+// Metadata returns the wrapped environment's metadata.
+func (b *BinaryRewardEnv) Metadata() map[string]float64 {
+	return EnvMetadata(b.Env)
+}
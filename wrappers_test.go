@@ -0,0 +1,209 @@
+package anyrl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/essentials"
+)
+
+type countingEnv struct {
+	steps    int
+	maxSteps int
+}
+
+func (c *countingEnv) Reset() ([]float64, error) {
+	c.steps = 0
+	return []float64{0}, nil
+}
+
+func (c *countingEnv) Step(action []float64) ([]float64, float64, bool, error) {
+	c.steps++
+	return []float64{float64(c.steps)}, float64(c.steps), c.steps == c.maxSteps, nil
+}
+
+func TestDelayEnv(t *testing.T) {
+	for _, atEnd := range []bool{false, true} {
+		env := &DelayEnv{Env: &countingEnv{maxSteps: 5}, Delay: 2, AtEnd: atEnd}
+		var rewards []float64
+		for i := 0; i < 2; i++ {
+			rewards = nil
+			if _, err := env.Reset(); err != nil {
+				t.Fatal(err)
+			}
+			for {
+				_, rew, done, err := env.Step(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rewards = append(rewards, rew)
+				if done {
+					break
+				}
+			}
+		}
+		expected := []float64{0, 0, 1, 2, 12}
+		if atEnd {
+			expected = []float64{0, 0, 0, 0, 15}
+		}
+		if !reflect.DeepEqual(rewards, expected) {
+			t.Errorf("atEnd=%v: expected %v but got %v", atEnd, expected, rewards)
+		}
+	}
+}
+
+func TestRepeatEnv(t *testing.T) {
+	env := &RepeatEnv{Env: &countingEnv{maxSteps: 5}, MaxRepeat: 3}
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	var rewards []float64
+	for _, action := range [][]float64{{1, 0, 0, 1}, {1, 1, 0, 0}, {1, 0, 0, 1}} {
+		obs, rew, done, err := env.Step(action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rewards = append(rewards, rew)
+		if done != (obs[0] == 5) {
+			t.Errorf("unexpected done value %v at step %v", done, obs[0])
+		}
+	}
+	expected := []float64{1 + 2 + 3, 4, 5}
+	if !reflect.DeepEqual(rewards, expected) {
+		t.Errorf("expected %v but got %v", expected, rewards)
+	}
+}
+
+func TestFrameDiffEnv(t *testing.T) {
+	env := &FrameDiffEnv{Env: &countingEnv{maxSteps: 5}, Concat: true}
+	obs, err := env.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(obs, []float64{0, 0}) {
+		t.Errorf("unexpected first observation: %v", obs)
+	}
+	env.Step(nil)
+	obs, _, _, err = env.Step(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(obs, []float64{1, 2}) {
+		t.Errorf("unexpected observation: %v", obs)
+	}
+}
+
+type paramEnv struct {
+	countingEnv
+	params map[string]float64
+}
+
+func (p *paramEnv) SetParams(params map[string]float64) error {
+	p.params = params
+	return nil
+}
+
+func TestRandomizedEnv(t *testing.T) {
+	inner := &paramEnv{countingEnv: countingEnv{maxSteps: 1}}
+	env := &RandomizedEnv{
+		Env: inner,
+		Params: []RandomParam{
+			{Name: "friction", Min: 0.5, Max: 1},
+			{Name: "size", Min: 2, Max: 2},
+		},
+	}
+	var meta MetadataEnv = env
+	for i := 0; i < 10; i++ {
+		if _, err := env.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		params := meta.Metadata()
+		if !reflect.DeepEqual(params, inner.params) {
+			t.Fatal("metadata does not match parameters")
+		}
+		if params["friction"] < 0.5 || params["friction"] > 1 || params["size"] != 2 {
+			t.Fatalf("unexpected parameters: %v", params)
+		}
+	}
+}
+
+func TestWrapperMetadata(t *testing.T) {
+	inner := &RandomizedEnv{
+		Env:    &paramEnv{countingEnv: countingEnv{maxSteps: 1}},
+		Params: []RandomParam{{Name: "size", Min: 2, Max: 2}},
+	}
+	env := &DelayEnv{Env: &MaxStepsEnv{Env: inner, MaxSteps: 5}}
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]float64{"size": 2}
+	if actual := EnvMetadata(env); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+	if actual := EnvMetadata(&MaxStepsEnv{Env: &countingEnv{}}); actual != nil {
+		t.Errorf("expected nil metadata but got %v", actual)
+	}
+}
+
+func TestMaxStepsEnvTruncation(t *testing.T) {
+	for _, maxSteps := range []int{3, 5, 7} {
+		env := &DelayEnv{Env: &MaxStepsEnv{Env: &countingEnv{maxSteps: 5}, MaxSteps: maxSteps}}
+		if _, err := env.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		var steps int
+		for {
+			_, _, done, err := env.Step(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			steps++
+			if done {
+				break
+			}
+		}
+		expectedSteps := essentials.MinInt(maxSteps, 5)
+		if steps != expectedSteps {
+			t.Errorf("max %d: expected %d steps but got %d", maxSteps, expectedSteps, steps)
+		}
+		if EnvTruncated(env) != (maxSteps < 5) {
+			t.Errorf("max %d: unexpected truncation %v", maxSteps, EnvTruncated(env))
+		}
+	}
+}
+
+func TestRewardWrappers(t *testing.T) {
+	episodeRewards := func(env Env) []float64 {
+		if _, err := env.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		var rewards []float64
+		for {
+			_, rew, done, err := env.Step(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rewards = append(rewards, rew)
+			if done {
+				return rewards
+			}
+		}
+	}
+
+	binary := &BinaryRewardEnv{Env: &countingEnv{maxSteps: 5}, Threshold: 2.5}
+	if actual := episodeRewards(binary); !reflect.DeepEqual(actual,
+		[]float64{0, 0, 1, 1, 1}) {
+		t.Errorf("unexpected binary rewards: %v", actual)
+	}
+
+	for _, prob := range []float64{0, 1} {
+		drop := &RewardDropEnv{Env: &countingEnv{maxSteps: 3}, DropProb: prob}
+		expected := []float64{1, 2, 3}
+		if prob == 1 {
+			expected = []float64{0, 0, 0}
+		}
+		if actual := episodeRewards(drop); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("prob %f: expected %v but got %v", prob, expected, actual)
+		}
+	}
+}